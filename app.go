@@ -172,8 +172,21 @@ func (a *App) startup(ctx context.Context) {
 	// when user enabled it AND filled in a webhook URL, so the rules
 	// engine isn't burning ticks on a no-op fan-out.
 	a.startNotifySubsystem()
+	if a.gatewayHost != nil {
+		a.gatewayHost.NotifyRelayEvents(a.currentNotifyBus)
+	}
 	diagnostics.Default.Mark("notify-subsystem")
 
+	// Relay health monitor: background probes of every relay endpoint,
+	// recorded into relay-health/ and reported through the notify hook
+	// wired above.
+	if a.gatewayHost != nil {
+		if err := a.gatewayHost.StartMonitor(); err != nil {
+			log.Printf("relay monitor: %v", err)
+		}
+	}
+	diagnostics.Default.Mark("relay-monitor")
+
 	// Tray: surface quota + gateway status in the system tray.
 	a.trayMgr = tray.New(a.trayQuotaSnapshot, a.trayGatewayStatus)
 	a.trayMgr.SetRelayProvider(&appRelayProvider{app: a})
@@ -211,6 +224,9 @@ func (a *App) shutdown(ctx context.Context) {
 	if a.heartbeat != nil {
 		a.heartbeat.Stop()
 	}
+	if a.gatewayHost != nil && a.gatewayHost.Monitor != nil {
+		a.gatewayHost.Monitor.Stop()
+	}
 
 	// Stop local API gateway (flushes metering buffer).
 	if a.gatewaySrv != nil {
@@ -58,6 +58,16 @@ func (a *App) SetAppConnected(id string, connected bool) error {
 	return a.appRegistry.SetConnected(id, connected)
 }
 
+// SetAppRateLimits sets an app's own gateway rate limits. nil clears
+// them, falling back to the gateway's per-tier defaults. The gateway
+// reads limits on every request, so changes apply immediately.
+func (a *App) SetAppRateLimits(id string, limits *appreg.RateLimits) (*appreg.App, error) {
+	if a.appRegistry == nil {
+		return nil, fmt.Errorf("app registry not initialized")
+	}
+	return a.appRegistry.SetLimits(id, limits)
+}
+
 // GetConnectedAppCount returns the number of apps currently connected to the gateway.
 func (a *App) GetConnectedAppCount() int {
 	if a.appRegistry == nil {
@@ -90,6 +90,15 @@ func (a *App) GetChargebackReport(fromMs, toMs int64) (*ChargebackReport, error)
 		}
 	}
 
+	// Team-sharing members outside the org chart are named after their
+	// member token (see appreg.IssueMember).
+	memberByEmp := map[string]string{}
+	if a.appRegistry != nil {
+		for _, m := range a.appRegistry.Members() {
+			memberByEmp[m.OwnerEmployeeID] = m.Name
+		}
+	}
+
 	report := &ChargebackReport{FromMs: fromMs, ToMs: toMs}
 
 	for _, cs := range a.meterStore.CostCenterSummaries(from, to) {
@@ -124,6 +133,8 @@ func (a *App) GetChargebackReport(fromMs, toMs int64) (*ChargebackReport, error)
 		if e := empByID[es.EmployeeID]; e != nil {
 			row.Email = e.Email
 			row.DisplayName = e.DisplayName
+		} else if name, ok := memberByEmp[es.EmployeeID]; ok {
+			row.DisplayName = name
 		} else if es.EmployeeID == "" {
 			row.DisplayName = "(unattributed)"
 		}
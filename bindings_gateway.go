@@ -220,6 +220,12 @@ type RequestLogEntry struct {
 	Error      string `json:"error,omitempty"`
 	ServedBy   string `json:"servedBy,omitempty"`
 	MatchedBy  string `json:"matchedBy,omitempty"`
+
+	// ServedModel is the upstream model when a gateway alias rewrote Model.
+	ServedModel string `json:"servedModel,omitempty"`
+
+	// KeyID fingerprints the pooled relay key that served the call.
+	KeyID string `json:"keyId,omitempty"`
 }
 
 // GetRequestLog returns detailed recent API calls, optionally filtered by app/model.
@@ -245,18 +251,20 @@ func (a *App) GetRequestLog(limit int, filterApp string, filterModel string) []R
 			continue
 		}
 		out = append(out, RequestLogEntry{
-			ID:         r.ID,
-			Timestamp:  r.Timestamp.Format("2006-01-02T15:04:05"),
-			AppID:      r.AppID,
-			Model:      r.Model,
-			TokensIn:   r.TokensIn,
-			TokensOut:  r.TokensOut,
-			LatencyMs:  r.LatencyMs,
-			StatusCode: r.StatusCode,
-			Cached:     r.CachedHit,
-			Error:      r.ErrorMessage,
-			ServedBy:   r.ServedBy,
-			MatchedBy:  r.MatchedBy,
+			ID:          r.ID,
+			Timestamp:   r.Timestamp.Format("2006-01-02T15:04:05"),
+			AppID:       r.AppID,
+			Model:       r.Model,
+			TokensIn:    r.TokensIn,
+			TokensOut:   r.TokensOut,
+			LatencyMs:   r.LatencyMs,
+			StatusCode:  r.StatusCode,
+			Cached:      r.CachedHit,
+			Error:       r.ErrorMessage,
+			ServedBy:    r.ServedBy,
+			MatchedBy:   r.MatchedBy,
+			ServedModel: r.ServedModel,
+			KeyID:       r.KeyID,
 		})
 	}
 	return out
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"lurus-switch/internal/gateway"
+)
+
+// ============================
+// Gateway Capture Methods
+// ============================
+
+// ListGatewayCaptures returns the captured upstream exchanges, newest
+// first, without bodies. Empty unless the gateway config enables capture.
+func (a *App) ListGatewayCaptures() []gateway.Capture {
+	if a.gatewaySrv == nil {
+		return []gateway.Capture{}
+	}
+	return a.gatewaySrv.Captures()
+}
+
+// GetGatewayCapture returns the full capture, bodies included, of a request.
+func (a *App) GetGatewayCapture(requestID string) (*gateway.Capture, error) {
+	if a.gatewaySrv == nil {
+		return nil, fmt.Errorf("gateway not initialized")
+	}
+	c, ok := a.gatewaySrv.Capture(requestID)
+	if !ok {
+		return nil, fmt.Errorf("no capture for request %q", requestID)
+	}
+	return &c, nil
+}
+
+// ExportGatewayCaptures writes every capture to a file as "har" or
+// "jsonl" and returns its path.
+func (a *App) ExportGatewayCaptures(format string) (string, error) {
+	if a.gatewaySrv == nil {
+		return "", fmt.Errorf("gateway not initialized")
+	}
+	if format != gateway.CaptureFormatHAR && format != gateway.CaptureFormatJSONL {
+		return "", fmt.Errorf("unknown capture export format %q", format)
+	}
+	outDir := filepath.Join(appDataBaseDir(), "gateway-captures")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, "captures-"+time.Now().Format("20060102-150405")+"."+format)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err := a.gatewaySrv.ExportCaptures(f, format); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// ReplayGatewayCapture re-sends a captured request to one relay endpoint
+// and returns its response for comparison with the captured one.
+func (a *App) ReplayGatewayCapture(requestID, endpointID string) (*gateway.ReplayResult, error) {
+	if a.gatewaySrv == nil {
+		return nil, fmt.Errorf("gateway not initialized")
+	}
+	if a.relayStore == nil {
+		return nil, fmt.Errorf("relay store not initialized")
+	}
+	eps, err := a.relayStore.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range eps {
+		if ep.ID == endpointID {
+			res, err := a.gatewaySrv.ReplayCapture(a.ctx, requestID, ep)
+			if err != nil {
+				return nil, err
+			}
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("relay endpoint %q not found", endpointID)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/capability"
+)
+
+// ============================
+// Gateway Team-Sharing Methods
+// ============================
+//
+// Team members are appreg apps of KindMember whose tokens work on the
+// gateway's LAN listener (gateway.Config.Share). The gateway looks the
+// token up on every request, so issuing, revoking and re-scoping a
+// member applies to the running listener without a restart.
+
+// Audit op names for team-member mutations. Journaled without the
+// token; no undo handler, since a revoked token can't be restored.
+const (
+	auditOpTeamMemberIssue  = "gateway.member.issue"
+	auditOpTeamMemberIPs    = "gateway.member.allowed_ips"
+	auditOpTeamMemberRevoke = "gateway.member.revoke"
+)
+
+// ListTeamMembers returns the team members, oldest first, with tokens.
+func (a *App) ListTeamMembers() []*appreg.App {
+	if a.appRegistry == nil {
+		return []*appreg.App{}
+	}
+	return a.appRegistry.Members()
+}
+
+// IssueTeamMember creates a member token for a teammate. employeeID and
+// costCenter attribute its usage in chargeback (empty employeeID = a row
+// of its own); allowedIPs restricts where it may be used from.
+func (a *App) IssueTeamMember(name, employeeID, costCenter string, allowedIPs []string) (m *appreg.App, err error) {
+	if a.appRegistry == nil {
+		return nil, fmt.Errorf("app registry not initialized")
+	}
+	input := map[string]any{"name": name, "employeeId": employeeID, "costCenter": costCenter, "allowedIps": allowedIPs}
+	if err = a.requireAndAudit(capability.CapUserCreate, auditOpTeamMemberIssue, name, input); err != nil {
+		return nil, err
+	}
+	m, err = a.appRegistry.IssueMember(name, employeeID, costCenter, allowedIPs)
+	target := name
+	if m != nil {
+		target = m.ID
+	}
+	a.recordOutcome(auditOpTeamMemberIssue, target, input, err)
+	return m, err
+}
+
+// SetTeamMemberAllowedIPs replaces a member's IP allow-list; empty
+// allows any address.
+func (a *App) SetTeamMemberAllowedIPs(id string, allowedIPs []string) (m *appreg.App, err error) {
+	if a.appRegistry == nil {
+		return nil, fmt.Errorf("app registry not initialized")
+	}
+	if err = a.requireAndAudit(capability.CapUserModify, auditOpTeamMemberIPs, id, allowedIPs); err != nil {
+		return nil, err
+	}
+	var before []string
+	if prev := a.appRegistry.Get(id); prev != nil {
+		before = prev.AllowedIPs
+	}
+	m, err = a.appRegistry.SetAllowedIPs(id, allowedIPs)
+	var after []string
+	if m != nil {
+		after = m.AllowedIPs
+	}
+	a.recordOutcomeFull(auditOpTeamMemberIPs, id, before, after, err)
+	return m, err
+}
+
+// RevokeTeamMember deletes a member; its token stops working on the
+// next request.
+func (a *App) RevokeTeamMember(id string) (err error) {
+	if a.appRegistry == nil {
+		return fmt.Errorf("app registry not initialized")
+	}
+	if err = a.requireAndAudit(capability.CapUserDelete, auditOpTeamMemberRevoke, id, nil); err != nil {
+		return err
+	}
+	defer func() { a.recordOutcome(auditOpTeamMemberRevoke, id, nil, err) }()
+	if m := a.appRegistry.Get(id); m == nil || m.Kind != appreg.KindMember {
+		return fmt.Errorf("team member %q not found", id)
+	}
+	return a.appRegistry.Delete(id)
+}
+
+// GetGatewayShareCA returns the PEM certificate teammates trust to reach
+// the team-sharing listener when it serves the generated certificate.
+func (a *App) GetGatewayShareCA() (string, error) {
+	if a.gatewaySrv == nil {
+		return "", fmt.Errorf("gateway not initialized")
+	}
+	pem, err := a.gatewaySrv.ShareCACert()
+	return string(pem), err
+}
@@ -8,11 +8,8 @@ import (
 	"time"
 
 	"lurus-switch/internal/notify"
-	"lurus-switch/internal/notify/feishu"
 	"lurus-switch/internal/notify/rules"
-	"lurus-switch/internal/notify/slack"
 	"lurus-switch/internal/notify/store"
-	"lurus-switch/internal/notify/telegram"
 )
 
 // ============================
@@ -66,16 +63,7 @@ func (a *App) rebuildNotifyLocked(cfg store.AppConfig) {
 		return
 	}
 
-	bus := notify.NewBus()
-	if cfg.Feishu.WebhookURL != "" {
-		bus.Register(feishu.New(cfg.Feishu))
-	}
-	if cfg.Telegram.BotToken != "" && cfg.Telegram.ChatID != "" {
-		bus.Register(telegram.New(cfg.Telegram))
-	}
-	if cfg.Slack.WebhookURL != "" {
-		bus.Register(slack.New(cfg.Slack))
-	}
+	bus := cfg.NewBus()
 	a.notifyBus = bus
 
 	// Only spin the rules engine when the watcher exists — otherwise the
@@ -191,6 +179,15 @@ func (a *App) TestNotify() error {
 	return nil
 }
 
+// currentNotifyBus returns the live bus, nil while notify is disabled.
+// Background publishers (relay breaker transitions) look it up per
+// event so a SaveNotifyConfig rewire takes effect at once.
+func (a *App) currentNotifyBus() *notify.Bus {
+	notifyRebuildMu.Lock()
+	defer notifyRebuildMu.Unlock()
+	return a.notifyBus
+}
+
 // GetRecentNotifications returns the bus's recent-events ring buffer
 // (newest last). Empty when notify is disabled or nothing's fired yet.
 func (a *App) GetRecentNotifications() []notify.Event {
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"lurus-switch/internal/relay"
+	"lurus-switch/internal/secrets"
 )
 
 // ============================
@@ -79,6 +82,104 @@ func (a *App) CheckRelayHealth() ([]relay.RelayEndpoint, error) {
 	return relay.CheckHealth(ctx, endpoints), nil
 }
 
+// ProbeRelayHealth runs active health probes on all relay endpoints in
+// mode ("reachability", "models" or "completion"; see relay.ProbeMode).
+// Unlike CheckRelayHealth the results feed the router: each lands in the
+// endpoint's rolling probe stats, and passing probes refresh its stored
+// latency.
+func (a *App) ProbeRelayHealth(mode string) ([]relay.RelayEndpoint, error) {
+	if a.relayStore == nil {
+		return nil, fmt.Errorf("relay store not initialized")
+	}
+	m, err := relay.ParseProbeMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := a.relayStore.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	var breaker *relay.CircuitBreaker
+	if a.relayRouter != nil {
+		breaker = a.relayRouter.Breaker()
+	}
+	ctx, cancel := context.WithTimeout(a.ctx, relay.ProbeTimeout)
+	defer cancel()
+	results := relay.CheckHealthMode(ctx, endpoints, m, breaker)
+	for _, ep := range results {
+		if ep.Healthy {
+			_ = a.relayStore.UpdateEndpointLatency(ep.ID, ep.LatencyMs)
+		}
+	}
+	return results, nil
+}
+
+// GetRelayMonitorConfig returns the background health monitor's
+// settings.
+func (a *App) GetRelayMonitorConfig() (relay.MonitorConfig, error) {
+	if a.gatewayHost == nil || a.gatewayHost.Monitor == nil {
+		return relay.MonitorConfig{}, fmt.Errorf("relay monitor not initialized")
+	}
+	return a.gatewayHost.Monitor.Config(), nil
+}
+
+// SaveRelayMonitorConfig persists the monitor settings and restarts the
+// monitor with them.
+func (a *App) SaveRelayMonitorConfig(cfg relay.MonitorConfig) error {
+	if a.gatewayHost == nil {
+		return fmt.Errorf("relay monitor not initialized")
+	}
+	return a.gatewayHost.SetMonitorConfig(cfg)
+}
+
+// GetRelayHealthHistory returns every endpoint's uptime and latency
+// percentiles over the last 24h and 7 days, from the monitor's history.
+func (a *App) GetRelayHealthHistory() ([]relay.HealthSummary, error) {
+	hist, err := a.relayHistory()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := a.relayStore.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]relay.HealthSummary, 0, len(endpoints))
+	for _, ep := range endpoints {
+		sum, err := hist.Summary(ep.ID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sum)
+	}
+	return out, nil
+}
+
+// GetRelayHealthSamples returns one endpoint's raw health samples from
+// the last hours (capped at the 7-day retention), oldest first, for
+// charting.
+func (a *App) GetRelayHealthSamples(endpointID string, hours int) ([]relay.HealthSample, error) {
+	hist, err := a.relayHistory()
+	if err != nil {
+		return nil, err
+	}
+	window := min(time.Duration(hours)*time.Hour, relay.HistoryRetention)
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	samples, err := hist.Samples(endpointID, time.Now().Add(-window))
+	if samples == nil {
+		samples = []relay.HealthSample{}
+	}
+	return samples, err
+}
+
+func (a *App) relayHistory() (*relay.History, error) {
+	if a.relayStore == nil || a.gatewayHost == nil || a.gatewayHost.Monitor == nil || a.gatewayHost.Monitor.History() == nil {
+		return nil, fmt.Errorf("relay health history not initialized")
+	}
+	return a.gatewayHost.Monitor.History(), nil
+}
+
 // ApplyAllToolRelays applies each tool's configured relay endpoint to its config file.
 // Returns a per-tool error map (empty map = all succeeded).
 func (a *App) ApplyAllToolRelays() map[string]string {
@@ -115,6 +216,10 @@ func (a *App) ApplyAllToolRelays() map[string]string {
 			result[tool] = fmt.Sprintf("relay endpoint %q not found", relayID)
 			continue
 		}
+		if secrets.IsRef(ep.APIKey) {
+			result[tool] = fmt.Sprintf("relay endpoint %q: its API key is in the locked secrets vault", relayID)
+			continue
+		}
 		apiKey := ep.APIKey
 		if apiKey == "" {
 			// Fall back to user token for Lurus relay
@@ -205,15 +310,27 @@ func (a *App) DryRunRouter(tool, model string, estTokens int64, hasTools bool) (
 	if a.relayRouter == nil {
 		return nil, fmt.Errorf("relay router not initialised")
 	}
-	res, err := a.relayRouter.Pick(tool, relay.PickHint{
+	ex := a.relayRouter.Explain(tool, relay.PickHint{
 		Model:                model,
 		EstimatedInputTokens: estTokens,
 		HasTools:             hasTools,
 	})
-	if err != nil {
-		return nil, err
+	if ex.Error != "" {
+		return nil, errors.New(ex.Error)
 	}
-	return &res, nil
+	return ex.Result, nil
+}
+
+// ExplainRelayRoute is the rule dry-run: for a hypothetical request it
+// reports every rule's verdict (fired, matched, or which predicates
+// failed) alongside the pick. hint.Time lets the UI test time-window
+// rules at another hour; zero means now.
+func (a *App) ExplainRelayRoute(tool string, hint relay.PickHint) (*relay.Explanation, error) {
+	if a.relayRouter == nil {
+		return nil, fmt.Errorf("relay router not initialised")
+	}
+	ex := a.relayRouter.Explain(tool, hint)
+	return &ex, nil
 }
 
 // migrateProxyToRelay is called on first startup to seed the relay store from
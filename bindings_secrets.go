@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"lurus-switch/internal/capability"
+	"lurus-switch/internal/secrets"
+)
+
+// ============================
+// Secrets Vault Methods
+// ============================
+//
+// Relay endpoint keys, custom provider keys, gateway tokens, notify
+// credentials and MCP env values live in the secrets vault
+// (internal/secrets); their stores only persist references. These
+// bindings drive the vault from Settings. None of them returns a secret
+// value, and passphrases never reach the audit journal.
+
+// Audit op names for vault operations.
+const (
+	auditOpSecretsUnlock     = "secrets.unlock"
+	auditOpSecretsLock       = "secrets.lock"
+	auditOpSecretsPassphrase = "secrets.passphrase"
+	auditOpSecretsRotate     = "secrets.rotate"
+)
+
+func (a *App) secretsVault() (*secrets.Vault, error) {
+	if a.gatewayHost == nil || a.gatewayHost.Vault == nil {
+		return nil, fmt.Errorf("secrets vault not initialized")
+	}
+	return a.gatewayHost.Vault, nil
+}
+
+// GetSecretsStatus reports whether the vault is locked, how it is
+// protected and when its data key was last rotated.
+func (a *App) GetSecretsStatus() (secrets.Status, error) {
+	v, err := a.secretsVault()
+	if err != nil {
+		return secrets.Status{}, err
+	}
+	return v.Status(), nil
+}
+
+// UnlockSecrets opens a passphrase-protected vault, then reloads the
+// stores that loaded references while it was locked.
+func (a *App) UnlockSecrets(passphrase string) (err error) {
+	if _, err = a.secretsVault(); err != nil {
+		return err
+	}
+	if err = a.requireAndAudit(capability.CapOptionWrite, auditOpSecretsUnlock, "vault", nil); err != nil {
+		return err
+	}
+	defer func() { a.recordOutcome(auditOpSecretsUnlock, "vault", nil, err) }()
+	if err = a.gatewayHost.Unlock(passphrase); err != nil {
+		return err
+	}
+	if a.customProviderStore != nil {
+		if rErr := a.customProviderStore.Reload(); rErr != nil {
+			log.Printf("secrets: reload custom providers: %v", rErr)
+		}
+	}
+	for _, w := range a.migrateSecrets() {
+		log.Printf("secrets: %s", w)
+	}
+	a.startNotifySubsystem()
+	return nil
+}
+
+// LockSecrets forgets the vault's keys. Values already loaded keep
+// working until the app restarts; saving a credential fails until the
+// vault is unlocked again. Only a passphrase-protected vault can lock.
+func (a *App) LockSecrets() (err error) {
+	v, err := a.secretsVault()
+	if err != nil {
+		return err
+	}
+	if err = a.requireAndAudit(capability.CapOptionWrite, auditOpSecretsLock, "vault", nil); err != nil {
+		return err
+	}
+	err = v.Lock()
+	a.recordOutcome(auditOpSecretsLock, "vault", nil, err)
+	return err
+}
+
+// SetSecretsPassphrase protects the vault with next instead of this
+// machine's identity; current is the existing passphrase, if any. An
+// empty next goes back to machine protection.
+func (a *App) SetSecretsPassphrase(current, next string) (err error) {
+	v, err := a.secretsVault()
+	if err != nil {
+		return err
+	}
+	input := map[string]any{"passphrase": next != ""}
+	if err = a.requireAndAudit(capability.CapOptionWrite, auditOpSecretsPassphrase, "vault", input); err != nil {
+		return err
+	}
+	err = v.SetPassphrase(current, next)
+	a.recordOutcome(auditOpSecretsPassphrase, "vault", input, err)
+	return err
+}
+
+// RotateSecretsKey re-encrypts every secret under a fresh data key.
+func (a *App) RotateSecretsKey() (st secrets.Status, err error) {
+	v, err := a.secretsVault()
+	if err != nil {
+		return secrets.Status{}, err
+	}
+	if err = a.requireAndAudit(capability.CapOptionWrite, auditOpSecretsRotate, "vault", nil); err != nil {
+		return secrets.Status{}, err
+	}
+	err = v.Rotate()
+	st = v.Status()
+	a.recordOutcome(auditOpSecretsRotate, "vault", map[string]any{"keyGeneration": st.KeyGeneration}, err)
+	return st, err
+}
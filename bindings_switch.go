@@ -357,7 +357,20 @@ func (a *App) ExportDiagnostics() string {
 	} else {
 		sb.WriteString("Gateway: STOPPED\n")
 	}
-	sb.WriteString(fmt.Sprintf("Tools: %d installed, %d connected\n\n", check.InstalledCount, check.BoundCount))
+	sb.WriteString(fmt.Sprintf("Tools: %d installed, %d connected\n", check.InstalledCount, check.BoundCount))
+	// Credentials live in the vault, so the report only states its shape.
+	if st, err := a.GetSecretsStatus(); err == nil {
+		protection := "machine"
+		if st.Passphrase {
+			protection = "passphrase"
+		}
+		if st.Locked {
+			sb.WriteString(fmt.Sprintf("Secrets vault: LOCKED (%s)\n", protection))
+		} else {
+			sb.WriteString(fmt.Sprintf("Secrets vault: %d secrets (%s, key generation %d)\n", st.Count, protection, st.KeyGeneration))
+		}
+	}
+	sb.WriteString("\n")
 
 	// Tools
 	for _, t := range check.Tools {
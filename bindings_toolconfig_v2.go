@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"lurus-switch/internal/secrets"
 	"lurus-switch/internal/toolconfig"
 )
 
@@ -125,7 +126,7 @@ func (a *App) InjectAiderCredentials() *ToolConfigResult {
 	gwURL := a.gatewayBaseURL()
 
 	for _, ep := range endpoints {
-		if ep.APIKey == "" {
+		if ep.APIKey == "" || secrets.IsRef(ep.APIKey) {
 			continue
 		}
 		if creds.OpenAIKey == "" {
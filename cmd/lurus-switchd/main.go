@@ -0,0 +1,266 @@
+// Command lurus-switchd runs the lurus-switch local API gateway without
+// the GUI, for Linux dev servers and containers that laptops' tools point
+// at. It is a separate binary because the desktop app links Wails, the
+// tray and global hotkeys, which need a display to even initialise.
+//
+// It opens the same app-data directory as the desktop app and runs the
+// gateway with the relay router, budget guard, DLP scanner and metering,
+// plus the live-session watcher, relay health monitor and notify bus.
+// With --data-dir, proxy.json and app-settings.json are read from that
+// directory too, and gateway.json's upstream stands unless proxy.json
+// sets one.
+// SIGTERM / SIGINT stop it gracefully; SIGHUP reloads gateway.json,
+// relay-rules.yaml, budget.json, relay-monitor.json, proxy.json's
+// upstream and notify.json. Logs are structured (JSON by
+// default) on stderr. A passphrase-protected secrets vault is unlocked
+// from --vault-passphrase-file; without it the daemon refuses to start,
+// since nothing could unlock the vault later and no stored credential
+// would resolve.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"lurus-switch/internal/appconfig"
+	"lurus-switch/internal/gatewayhost"
+	"lurus-switch/internal/livesession"
+	"lurus-switch/internal/netproxy"
+	"lurus-switch/internal/notify"
+	"lurus-switch/internal/notify/rules"
+	"lurus-switch/internal/notify/store"
+	"lurus-switch/internal/proxy"
+)
+
+// version is stamped at build time via -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// daemon is the running process state that SIGHUP rewires.
+type daemon struct {
+	ctx      context.Context
+	dataDir  string
+	proxyDir string // holds proxy.json; "" = the desktop app's config directory
+	logger   *slog.Logger
+	host     *gatewayhost.Host
+	watcher  *livesession.Watcher
+	engine   *rules.Engine              // nil while notify is disabled
+	bus      atomic.Pointer[notify.Bus] // the engine's; read by the breaker's transition hook
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("lurus-switchd", flag.ContinueOnError)
+	logFormat := fs.String("log-format", "json", `log format: "json" or "text"`)
+	dataDir := fs.String("data-dir", "", "app-data directory (default: the desktop app's)")
+	passFile := fs.String("vault-passphrase-file", "", "file holding the secrets vault passphrase (required once one is set)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	logger, err := newLogger(os.Stderr, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	slog.SetDefault(logger) // also routes the packages' log.Printf output
+	proxyDir := *dataDir
+	if *dataDir == "" {
+		if *dataDir, err = appconfig.DataDir(); err != nil {
+			logger.Error("resolve app-data directory", "err", err)
+			return 1
+		}
+	}
+
+	host, warnings := gatewayhost.Open(*dataDir, version)
+	for _, w := range warnings {
+		logger.Warn("service init", "warning", w)
+	}
+	if host.Gateway == nil {
+		logger.Error("gateway unavailable: app registry or metering store failed to open", "dataDir", *dataDir)
+		return 1
+	}
+	if err := unlockVault(host, *passFile); err != nil {
+		logger.Error("unlock secrets vault", "err", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &daemon{ctx: ctx, dataDir: *dataDir, proxyDir: proxyDir, logger: logger, host: host}
+
+	d.watcher = livesession.New(func() {})
+	d.watcher.Start()
+	d.rebuildNotify()
+	host.NotifyRelayEvents(d.bus.Load)
+	if err := host.StartMonitor(); err != nil {
+		logger.Warn("relay monitor config (using defaults)", "err", err)
+	}
+
+	host.Gateway.SetCrashCallback(func(attempt int, err error) {
+		logger.Error("gateway crashed, restarting", "attempt", attempt, "err", err)
+	})
+
+	// Listen before serving, so a signal sent once the gateway answers
+	// is never the default kill.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+	d.syncUpstream()
+	if err := host.Gateway.Start(ctx); err != nil {
+		logger.Error("gateway start failed", "err", err)
+		d.shutdown()
+		return 1
+	}
+	st := host.Gateway.Status()
+	logger.Info("gateway started", "url", st.URL, "port", st.Port, "dataDir", *dataDir, "version", version)
+
+	for sig := range sigs {
+		if sig == syscall.SIGHUP {
+			d.reload()
+			continue
+		}
+		logger.Info("shutting down", "signal", sig.String())
+		break
+	}
+	cancel()
+	d.shutdown()
+	logger.Info("stopped")
+	return 0
+}
+
+// reload re-reads every reloadable config from disk. Each failure is
+// logged and leaves that service as it was.
+func (d *daemon) reload() {
+	gw := d.host.Gateway
+	portChanged, err := d.host.Reload()
+	if err != nil {
+		d.logger.Error("reload config", "err", err)
+	}
+	// gateway.json doesn't own the upstream: re-apply it from the proxy
+	// settings as startup does.
+	d.syncUpstream()
+	if portChanged && gw.Status().Running {
+		if err := errors.Join(gw.Stop(), gw.Start(d.ctx)); err != nil {
+			d.logger.Error("restart gateway on new port", "err", err)
+		}
+	}
+	d.rebuildNotify()
+	st := gw.Status()
+	d.logger.Info("config reloaded", "running", st.Running, "port", st.Port)
+}
+
+// syncUpstream pushes the proxy settings' upstream URL/token to the
+// gateway and installs the user's outbound HTTP/SOCKS5 proxy, if any.
+// Proxy settings without an upstream (or no proxy.json at all) leave
+// the one in gateway.json alone.
+func (d *daemon) syncUpstream() {
+	pm, err := d.proxyManager()
+	if err != nil {
+		d.logger.Warn("load proxy settings", "err", err)
+		return
+	}
+	settings := pm.GetSettings()
+	if up := settings.UpstreamProxy; up != nil {
+		if err := netproxy.Apply(*up); err != nil {
+			d.logger.Warn("upstream proxy", "err", err)
+		}
+	}
+	url, token := settings.APIEndpoint, settings.BuildToolAPIKey()
+	if url == "" && token == "" {
+		return
+	}
+	d.host.Gateway.UpdateUpstream(url, token)
+}
+
+// proxyManager opens proxy.json: the one in --data-dir when given,
+// else the desktop app's.
+func (d *daemon) proxyManager() (*proxy.ProxyManager, error) {
+	if d.proxyDir != "" {
+		return proxy.NewProxyManagerIn(d.proxyDir), nil
+	}
+	return proxy.NewProxyManager()
+}
+
+// rebuildNotify tears down any previous notify engine and, when the user
+// enabled the subsystem, rebuilds the bus + rules engine from notify.json.
+func (d *daemon) rebuildNotify() {
+	if d.engine != nil {
+		d.engine.Stop()
+		d.engine = nil
+	}
+	d.bus.Store(nil)
+	cfg, err := store.Load(d.dataDir)
+	if err != nil {
+		d.logger.Warn("load notify config (using defaults)", "err", err)
+		cfg = store.DefaultAppConfig()
+	}
+	if !cfg.Enabled {
+		return
+	}
+	bus := cfg.NewBus()
+	d.bus.Store(bus)
+	d.engine = rules.NewEngine(d.watcher, bus, cfg.Rules.ToRulesConfig())
+	d.engine.Start()
+}
+
+// shutdown stops everything in the same order as the desktop app.
+func (d *daemon) shutdown() {
+	if d.engine != nil {
+		d.engine.Stop()
+	}
+	d.watcher.Stop()
+	if d.host.Monitor != nil {
+		d.host.Monitor.Stop()
+	}
+	if err := d.host.Gateway.Stop(); err != nil {
+		d.logger.Error("gateway stop failed", "err", err)
+	}
+	if d.host.ObsShutdown != nil {
+		if err := d.host.ObsShutdown(context.Background()); err != nil {
+			d.logger.Error("observability flush failed", "err", err)
+		}
+	}
+}
+
+// errVaultLocked stops a daemon whose vault is passphrase-protected but
+// that was given no passphrase file.
+var errVaultLocked = errors.New("secrets vault is locked: pass its passphrase with --vault-passphrase-file")
+
+// unlockVault unlocks a passphrase-protected secrets vault with the
+// passphrase in path (one trailing newline ignored, as `echo` writes
+// one). An unlocked or absent vault needs nothing.
+func unlockVault(host *gatewayhost.Host, path string) error {
+	if host.Vault == nil || !host.Vault.Status().Locked {
+		return nil
+	}
+	if path == "" {
+		return errVaultLocked
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	passphrase := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	return host.Unlock(passphrase)
+}
+
+func newLogger(w io.Writer, format string) (*slog.Logger, error) {
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	}
+	return nil, fmt.Errorf("unknown --log-format %q (want json or text)", format)
+}
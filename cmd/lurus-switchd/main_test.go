@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/secrets"
+)
+
+func TestRun_RefusesLockedVault(t *testing.T) {
+	dir := t.TempDir()
+	v, err := secrets.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.SetPassphrase("", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+
+	if code := run([]string{"--data-dir", dir, "--log-format", "text"}); code != 1 {
+		t.Errorf("locked vault without a passphrase file: exit %d, want 1", code)
+	}
+	wrong := filepath.Join(dir, "pass")
+	os.WriteFile(wrong, []byte("nope\n"), 0o600)
+	if code := run([]string{"--data-dir", dir, "--vault-passphrase-file", wrong}); code != 1 {
+		t.Errorf("wrong passphrase: exit %d, want 1", code)
+	}
+}
+
+// TestRun_CustomDataDirKeepsGatewayUpstream runs the daemon on a data
+// directory with no proxy.json: the upstream gateway.json was seeded
+// with must survive startup and a SIGHUP reload, and the gateway must
+// start on it.
+func TestRun_CustomDataDirKeepsGatewayUpstream(t *testing.T) {
+	dir := t.TempDir()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	seed := fmt.Sprintf(`{"port":%d,"upstreamUrl":"https://upstream.example","userToken":"tok"}`, port)
+	if err := os.WriteFile(filepath.Join(dir, "gateway.json"), []byte(seed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+
+	exit := make(chan int, 1)
+	go func() { exit <- run([]string{"--data-dir", dir, "--log-format", "text"}) }()
+	healthURL := fmt.Sprintf("http://127.0.0.1:%d/health", port)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		select {
+		case code := <-exit:
+			t.Fatalf("daemon exited with %d before serving", code)
+		default:
+		}
+		if resp, err := http.Get(healthURL); err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("gateway never came up")
+		}
+	}
+
+	upstream := func() string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, "gateway.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg struct {
+			UpstreamURL string `json:"upstreamUrl"`
+			UserToken   string `json:"userToken"`
+		}
+		json.Unmarshal(data, &cfg)
+		if cfg.UserToken == "" {
+			return ""
+		}
+		return cfg.UpstreamURL
+	}
+	if got := upstream(); got != "https://upstream.example" {
+		t.Errorf("upstream after startup = %q, want the seeded one", got)
+	}
+
+	// The daemon handles signals in order, so once SIGTERM has stopped
+	// it the reload is done. The pause keeps SIGTERM from arriving
+	// while SIGHUP still fills the signal channel.
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	time.Sleep(200 * time.Millisecond)
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	select {
+	case code := <-exit:
+		if code != 0 {
+			t.Errorf("exit %d, want 0", code)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon did not stop on SIGTERM")
+	}
+	if got := upstream(); got != "https://upstream.example" {
+		t.Errorf("upstream after SIGHUP = %q, want the seeded one", got)
+	}
+}
@@ -35,6 +35,21 @@ const SESSION_PRESETS = [
   { label: '500K', value: 500_000 },
 ]
 
+// USD caps are checked before forwarding against the request's worst
+// case (input + max_tokens at the model's rate), so they hold even on
+// expensive models where a token cap is only a rough proxy.
+const DAILY_USD_PRESETS = [
+  { label: '$5', value: 5 },
+  { label: '$20', value: 20 },
+  { label: '$100', value: 100 },
+]
+
+const SESSION_USD_PRESETS = [
+  { label: '$1', value: 1 },
+  { label: '$5', value: 5 },
+  { label: '$20', value: 20 },
+]
+
 export function BudgetModal({ open, onClose }: BudgetModalProps) {
   const { i18n } = useTranslation()
   const isZh = i18n.language?.startsWith('zh') ?? true
@@ -191,6 +206,30 @@ export function BudgetModal({ open, onClose }: BudgetModalProps) {
                     hit={status.hitSession}
                     warn={status.warnSession}
                   />
+                  {status.dailyUsd > 0 && (
+                    <Gauge
+                      isZh={isZh}
+                      titleZh="今日花费" titleEn="Today (USD)"
+                      used={status.dailyUsedUsd}
+                      limit={status.dailyUsd}
+                      pct={status.dailyUsdPct}
+                      hit={status.hitDailyUsd}
+                      warn={status.warnDailyUsd}
+                      format={fmtUSD}
+                    />
+                  )}
+                  {status.sessionUsd > 0 && (
+                    <Gauge
+                      isZh={isZh}
+                      titleZh="本次 session 花费" titleEn="This session (USD)"
+                      used={status.sessionUsedUsd}
+                      limit={status.sessionUsd}
+                      pct={status.sessionUsdPct}
+                      hit={status.hitSessionUsd}
+                      warn={status.warnSessionUsd}
+                      format={fmtUSD}
+                    />
+                  )}
                 </div>
 
                 {/* Limits */}
@@ -215,6 +254,24 @@ export function BudgetModal({ open, onClose }: BudgetModalProps) {
                     presets={SESSION_PRESETS}
                     onChange={(v) => updateCfg({ sessionTokens: v })}
                   />
+                  <LimitField
+                    isZh={isZh}
+                    labelZh="每日花费上限（USD）" labelEn="Daily spend cap (USD)"
+                    helperZh="0 = 不限。转发前按最坏情况预估，会超限的请求直接拦截。" helperEn="0 = unlimited. Checked before forwarding against the request's worst-case cost."
+                    value={cfg.dailyUsd ?? 0}
+                    presets={DAILY_USD_PRESETS}
+                    step={0.5}
+                    onChange={(v) => updateCfg({ dailyUsd: v })}
+                  />
+                  <LimitField
+                    isZh={isZh}
+                    labelZh="单 session 花费上限（USD）" labelEn="Session spend cap (USD)"
+                    helperZh="0 = 不限。随 session 一起重置。" helperEn="0 = unlimited. Resets with the session."
+                    value={cfg.sessionUsd ?? 0}
+                    presets={SESSION_USD_PRESETS}
+                    step={0.5}
+                    onChange={(v) => updateCfg({ sessionUsd: v })}
+                  />
 
                   <div className="space-y-1">
                     <label className="block text-xs text-muted-foreground">
@@ -280,10 +337,11 @@ export function BudgetModal({ open, onClose }: BudgetModalProps) {
 }
 
 function Gauge({
-  isZh, titleZh, titleEn, used, limit, pct, hit, warn,
+  isZh, titleZh, titleEn, used, limit, pct, hit, warn, format = fmtTokens,
 }: {
   isZh: boolean; titleZh: string; titleEn: string
   used: number; limit: number; pct: number; hit: boolean; warn: boolean
+  format?: (n: number) => string
 }) {
   const noLimit = !limit || limit <= 0
   const barColor = hit
@@ -297,7 +355,7 @@ function Gauge({
       <div className="flex items-center justify-between text-xs mb-1.5">
         <span className="font-medium">{isZh ? titleZh : titleEn}</span>
         <span className="tabular-nums text-muted-foreground">
-          {fmtTokens(used)}{!noLimit && <> <span className="opacity-50">/</span> {fmtTokens(limit)}</>}
+          {format(used)}{!noLimit && <> <span className="opacity-50">/</span> {format(limit)}</>}
           {!noLimit && <span className="ml-2 text-foreground tabular-nums">{pct}%</span>}
         </span>
       </div>
@@ -327,12 +385,13 @@ function Gauge({
 }
 
 function LimitField({
-  isZh, labelZh, labelEn, helperZh, helperEn, value, presets, onChange,
+  isZh, labelZh, labelEn, helperZh, helperEn, value, presets, step = 1000, onChange,
 }: {
   isZh: boolean; labelZh: string; labelEn: string
   helperZh: string; helperEn: string
   value: number
   presets: { label: string; value: number }[]
+  step?: number
   onChange: (v: number) => void
 }) {
   return (
@@ -345,9 +404,9 @@ function LimitField({
         <input
           type="number"
           min={0}
-          step={1000}
+          step={step}
           value={value}
-          onChange={(e) => onChange(parseInt(e.target.value) || 0)}
+          onChange={(e) => onChange((step < 1 ? parseFloat(e.target.value) : parseInt(e.target.value)) || 0)}
           className="flex-1 px-2 py-1.5 text-xs bg-muted/30 border border-border rounded-md focus:outline-none focus:ring-1 focus:ring-primary tabular-nums font-mono"
         />
         {presets.map((p) => (
@@ -382,6 +441,10 @@ function LimitField({
   )
 }
 
+function fmtUSD(n: number): string {
+  return '$' + n.toFixed(n < 10 ? 2 : 0)
+}
+
 function fmtTokens(n: number): string {
   if (n < 1000) return n.toLocaleString()
   if (n < 1_000_000) return (n / 1000).toFixed(n < 10_000 ? 1 : 0) + 'K'
@@ -14,6 +14,17 @@ const PLACEHOLDER = `# Routing rules (optional). First match wins.
 #     match_model_prefix: claude-opus
 #     min_tokens: 50000
 #     prefer_endpoint_id: <relay-endpoint-id>
+#   - name: codex-with-tools
+#     match_tool: codex
+#     has_tools: true
+#     prefer_endpoint_id: <relay-endpoint-id>
+#   - name: engineering-in-house
+#     match_cost_center: ENG-*
+#     prefer_endpoint_id: <relay-endpoint-id>
+#   - name: weekday-nights
+#     match_model_regex: '^gpt-5'
+#     time_window: { days: [weekdays], from: "20:00", to: "08:00" }
+#     prefer_endpoint_id: <relay-endpoint-id>
 `
 
 export function RelayRulesEditor() {
@@ -70,6 +70,8 @@ export type NotifyKind =
   | 'session_done'
   | 'budget_alert'
   | 'bashguard_approval'
+  | 'circuit'
+  | 'relay_health'
   | 'test'
 
 export type NotifySeverity = 'info' | 'success' | 'warning' | 'error'
@@ -56,6 +56,7 @@ import {promoter} from '../models';
 import {deploy} from '../models';
 import {toolconfig} from '../models';
 import {validator} from '../models';
+import {secrets} from '../models';
 
 export function ActivateRedemption(arg1:string):Promise<main.ActivationStatus>;
 
@@ -249,6 +250,8 @@ export function EnableAutostart(arg1:string):Promise<void>;
 
 export function EnsureServerBinary():Promise<void>;
 
+export function ExplainRelayRoute(arg1:string,arg2:relay.PickHint):Promise<relay.Explanation>;
+
 export function ExportClaudeConfig(arg1:config.ClaudeConfig):Promise<string>;
 
 export function ExportCodexConfig(arg1:config.CodexConfig):Promise<string>;
@@ -259,6 +262,8 @@ export function ExportConversation(arg1:string,arg2:string,arg3:string,arg4:bool
 
 export function ExportDiagnostics():Promise<string>;
 
+export function ExportGatewayCaptures(arg1:string):Promise<string>;
+
 export function ExportGeminiConfig(arg1:config.GeminiConfig):Promise<Array<string>>;
 
 export function ExportNullClawConfig(arg1:config.NullClawConfig):Promise<string>;
@@ -377,8 +382,11 @@ export function GetEndUserStatus():Promise<main.ActivationStatus>;
 
 export function GetGYProducts():Promise<Array<gy.GYProduct>>;
 
+export function GetGatewayCapture(arg1:string):Promise<gateway.Capture>;
 export function GetGatewayConfig():Promise<gateway.Config>;
 
+export function GetGatewayShareCA():Promise<string>;
+
 export function GetGatewayStatus():Promise<gateway.Status>;
 
 export function GetGatewayURL():Promise<string>;
@@ -419,12 +427,20 @@ export function GetRelayCircuitState():Promise<Array<relay.CircuitState>>;
 
 export function GetRelayEndpoints():Promise<Array<relay.RelayEndpoint>>;
 
+export function GetRelayHealthHistory():Promise<Array<relay.HealthSummary>>;
+
+export function GetRelayHealthSamples(arg1:string,arg2:number):Promise<Array<relay.HealthSample>>;
+
+export function GetRelayMonitorConfig():Promise<relay.MonitorConfig>;
+
 export function GetRelayRules():Promise<string>;
 
 export function GetRequestLog(arg1:number,arg2:string,arg3:string):Promise<Array<main.RequestLogEntry>>;
 
 export function GetRollbackHistory():Promise<Array<sysenv.RollbackEntry>>;
 
+export function GetSecretsStatus():Promise<secrets.Status>;
+
 export function GetServerAdminToken():Promise<string>;
 
 export function GetServerConfig():Promise<serverctl.ServerConfig>;
@@ -551,6 +567,8 @@ export function InstallTool(arg1:string):Promise<installer.InstallResult>;
 
 export function IsModeLocked():Promise<boolean>;
 
+export function IssueTeamMember(arg1:string,arg2:string,arg3:string,arg4:Array<string>):Promise<appreg.App>;
+
 export function KillCLIProcess(arg1:number):Promise<void>;
 
 export function LaunchAgent(arg1:string):Promise<void>;
@@ -593,6 +611,7 @@ export function ListDepartments():Promise<Array<orgsync.Department>>;
 
 export function ListEmployees(arg1:string,arg2:boolean):Promise<Array<orgsync.Employee>>;
 
+export function ListGatewayCaptures():Promise<Array<gateway.Capture>>;
 export function ListGeminiConfigs():Promise<Array<string>>;
 
 export function ListMCPPresets():Promise<Array<mcp.MCPPreset>>;
@@ -605,6 +624,17 @@ export function ListPicoClawConfigs():Promise<Array<string>>;
 
 export function ListPrompts(arg1:string):Promise<Array<promptlib.Prompt>>;
 
+export function ListTeamMembers():Promise<Array<appreg.App>>;
+
+export function LockSecrets():Promise<void>;
+
+export function ProbeRelayHealth(arg1:string):Promise<Array<relay.RelayEndpoint>>;
+
+export function ReplayGatewayCapture(arg1:string,arg2:string):Promise<gateway.ReplayResult>;
+export function RevokeTeamMember(arg1:string):Promise<void>;
+
+export function RotateSecretsKey():Promise<secrets.Status>;
+
 export function RulesMarketList():Promise<Array<rulesmarket.RuleTemplate>>;
 
 export function RulesMarketRefresh(arg1:string):Promise<{success:boolean;message:string}>;
@@ -749,6 +779,8 @@ export function SaveProxySettings(arg1:proxy.ProxySettings):Promise<void>;
 
 export function SaveRelayEndpoint(arg1:relay.RelayEndpoint):Promise<void>;
 
+export function SaveRelayMonitorConfig(arg1:relay.MonitorConfig):Promise<void>;
+
 export function SaveRelayRules(arg1:string):Promise<void>;
 
 export function SaveServerConfig(arg1:serverctl.ServerConfig):Promise<void>;
@@ -769,10 +801,16 @@ export function SetAppMode(arg1:string):Promise<void>;
 
 export function SetAppOwnership(arg1:string,arg2:string,arg3:string):Promise<appreg.App>;
 
+export function SetAppRateLimits(arg1:string,arg2:appreg.RateLimits):Promise<appreg.App>;
+
 export function SetDLPPolicy(arg1:string,arg2:string):Promise<boolean>;
 
 export function SetEnvironmentVariable(arg1:string,arg2:string):Promise<void>;
 
+export function SetSecretsPassphrase(arg1:string,arg2:string):Promise<void>;
+
+export function SetTeamMemberAllowedIPs(arg1:string,arg2:Array<string>):Promise<appreg.App>;
+
 export function StartGateway():Promise<void>;
 
 export function StartServer():Promise<void>;
@@ -805,6 +843,8 @@ export function UninstallAllTools():Promise<Array<installer.InstallResult>>;
 
 export function UninstallTool(arg1:string):Promise<installer.InstallResult>;
 
+export function UnlockSecrets(arg1:string):Promise<void>;
+
 export function UpdateAPIKey(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function UpdateAgent(arg1:string,arg2:agent.UpdateParams):Promise<agent.Profile>;
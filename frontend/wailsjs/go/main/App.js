@@ -386,6 +386,10 @@ export function EnsureServerBinary() {
   return window['go']['main']['App']['EnsureServerBinary']();
 }
 
+export function ExplainRelayRoute(arg1, arg2) {
+  return window['go']['main']['App']['ExplainRelayRoute'](arg1, arg2);
+}
+
 export function ExportClaudeConfig(arg1) {
   return window['go']['main']['App']['ExportClaudeConfig'](arg1);
 }
@@ -406,6 +410,10 @@ export function ExportDiagnostics() {
   return window['go']['main']['App']['ExportDiagnostics']();
 }
 
+export function ExportGatewayCaptures(arg1) {
+  return window['go']['main']['App']['ExportGatewayCaptures'](arg1);
+}
+
 export function ExportGeminiConfig(arg1) {
   return window['go']['main']['App']['ExportGeminiConfig'](arg1);
 }
@@ -642,10 +650,18 @@ export function GetGYProducts() {
   return window['go']['main']['App']['GetGYProducts']();
 }
 
+export function GetGatewayCapture(arg1) {
+  return window['go']['main']['App']['GetGatewayCapture'](arg1);
+}
+
 export function GetGatewayConfig() {
   return window['go']['main']['App']['GetGatewayConfig']();
 }
 
+export function GetGatewayShareCA() {
+  return window['go']['main']['App']['GetGatewayShareCA']();
+}
+
 export function GetGatewayStatus() {
   return window['go']['main']['App']['GetGatewayStatus']();
 }
@@ -726,6 +742,18 @@ export function GetRelayEndpoints() {
   return window['go']['main']['App']['GetRelayEndpoints']();
 }
 
+export function GetRelayHealthHistory() {
+  return window['go']['main']['App']['GetRelayHealthHistory']();
+}
+
+export function GetRelayHealthSamples(arg1, arg2) {
+  return window['go']['main']['App']['GetRelayHealthSamples'](arg1, arg2);
+}
+
+export function GetRelayMonitorConfig() {
+  return window['go']['main']['App']['GetRelayMonitorConfig']();
+}
+
 export function GetRelayRules() {
   return window['go']['main']['App']['GetRelayRules']();
 }
@@ -738,6 +766,10 @@ export function GetRollbackHistory() {
   return window['go']['main']['App']['GetRollbackHistory']();
 }
 
+export function GetSecretsStatus() {
+  return window['go']['main']['App']['GetSecretsStatus']();
+}
+
 export function GetServerAdminToken() {
   return window['go']['main']['App']['GetServerAdminToken']();
 }
@@ -990,6 +1022,10 @@ export function IsModeLocked() {
   return window['go']['main']['App']['IsModeLocked']();
 }
 
+export function IssueTeamMember(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['IssueTeamMember'](arg1, arg2, arg3, arg4);
+}
+
 export function KillCLIProcess(arg1) {
   return window['go']['main']['App']['KillCLIProcess'](arg1);
 }
@@ -1074,6 +1110,10 @@ export function ListEmployees(arg1, arg2) {
   return window['go']['main']['App']['ListEmployees'](arg1, arg2);
 }
 
+export function ListGatewayCaptures() {
+  return window['go']['main']['App']['ListGatewayCaptures']();
+}
+
 export function ListGeminiConfigs() {
   return window['go']['main']['App']['ListGeminiConfigs']();
 }
@@ -1098,6 +1138,30 @@ export function ListPrompts(arg1) {
   return window['go']['main']['App']['ListPrompts'](arg1);
 }
 
+export function ListTeamMembers() {
+  return window['go']['main']['App']['ListTeamMembers']();
+}
+
+export function LockSecrets() {
+  return window['go']['main']['App']['LockSecrets']();
+}
+
+export function ProbeRelayHealth(arg1) {
+  return window['go']['main']['App']['ProbeRelayHealth'](arg1);
+}
+
+export function ReplayGatewayCapture(arg1, arg2) {
+  return window['go']['main']['App']['ReplayGatewayCapture'](arg1, arg2);
+}
+
+export function RevokeTeamMember(arg1) {
+  return window['go']['main']['App']['RevokeTeamMember'](arg1);
+}
+
+export function RotateSecretsKey() {
+  return window['go']['main']['App']['RotateSecretsKey']();
+}
+
 export function RulesMarketList() {
   return window['go']['main']['App']['RulesMarketList']();
 }
@@ -1386,6 +1450,10 @@ export function SaveRelayEndpoint(arg1) {
   return window['go']['main']['App']['SaveRelayEndpoint'](arg1);
 }
 
+export function SaveRelayMonitorConfig(arg1) {
+  return window['go']['main']['App']['SaveRelayMonitorConfig'](arg1);
+}
+
 export function SaveRelayRules(arg1) {
   return window['go']['main']['App']['SaveRelayRules'](arg1);
 }
@@ -1426,6 +1494,10 @@ export function SetAppOwnership(arg1, arg2, arg3) {
   return window['go']['main']['App']['SetAppOwnership'](arg1, arg2, arg3);
 }
 
+export function SetAppRateLimits(arg1, arg2) {
+  return window['go']['main']['App']['SetAppRateLimits'](arg1, arg2);
+}
+
 export function SetDLPPolicy(arg1, arg2) {
   return window['go']['main']['App']['SetDLPPolicy'](arg1, arg2);
 }
@@ -1434,6 +1506,14 @@ export function SetEnvironmentVariable(arg1, arg2) {
   return window['go']['main']['App']['SetEnvironmentVariable'](arg1, arg2);
 }
 
+export function SetSecretsPassphrase(arg1, arg2) {
+  return window['go']['main']['App']['SetSecretsPassphrase'](arg1, arg2);
+}
+
+export function SetTeamMemberAllowedIPs(arg1, arg2) {
+  return window['go']['main']['App']['SetTeamMemberAllowedIPs'](arg1, arg2);
+}
+
 export function StartGateway() {
   return window['go']['main']['App']['StartGateway']();
 }
@@ -1498,6 +1578,10 @@ export function UninstallTool(arg1) {
   return window['go']['main']['App']['UninstallTool'](arg1);
 }
 
+export function UnlockSecrets(arg1) {
+  return window['go']['main']['App']['UnlockSecrets'](arg1);
+}
+
 export function UpdateAPIKey(arg1, arg2, arg3) {
   return window['go']['main']['App']['UpdateAPIKey'](arg1, arg2, arg3);
 }
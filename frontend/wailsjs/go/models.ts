@@ -889,6 +889,7 @@ export namespace appconfig {
 	    enabled: boolean;
 	    endpoint?: string;
 	    protocol?: string;
+	    reasoning?: string;
 	    headers?: {[key: string]: string};
 
 	    static createFrom(source: any = {}) {
@@ -900,6 +901,7 @@ export namespace appconfig {
 	        this.enabled = source["enabled"];
 	        this.endpoint = source["endpoint"];
 	        this.protocol = source["protocol"];
+	        this.reasoning = source["reasoning"];
 	        this.headers = source["headers"];
 	    }
 	}
@@ -1007,6 +1009,8 @@ export namespace appreg {
 	    connected: boolean;
 	    ownerEmployeeId?: string;
 	    costCenter?: string;
+	    limits?: RateLimits;
+	    allowedIps?: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new App(source);
@@ -1026,6 +1030,8 @@ export namespace appreg {
 	        this.connected = source["connected"];
 	        this.ownerEmployeeId = source["ownerEmployeeId"];
 	        this.costCenter = source["costCenter"];
+	        this.limits = this.convertValues(source["limits"], RateLimits);
+	        this.allowedIps = source["allowedIps"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -1047,6 +1053,23 @@ export namespace appreg {
 		}
 	}
 
+	export class RateLimits {
+	    requestsPerMinute?: number;
+	    tokensPerMinute?: number;
+	    maxInFlight?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requestsPerMinute = source["requestsPerMinute"];
+	        this.tokensPerMinute = source["tokensPerMinute"];
+	        this.maxInFlight = source["maxInFlight"];
+	    }
+	}
+
 }
 
 export namespace audit {
@@ -1604,11 +1627,34 @@ export namespace billing {
 
 export namespace budget {
 	
+	export class Limit {
+	    scope: string;
+	    key?: string;
+	    window: string;
+	    tokens?: number;
+	    usd?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Limit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.scope = source["scope"];
+	        this.key = source["key"];
+	        this.window = source["window"];
+	        this.tokens = source["tokens"];
+	        this.usd = source["usd"];
+	    }
+	}
 	export class Config {
 	    enabled: boolean;
 	    dailyTokens: number;
 	    sessionTokens: number;
 	    softWarnPct: number;
+	    dailyUsd?: number;
+	    sessionUsd?: number;
+	    limits?: Limit[];
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -1620,7 +1666,66 @@ export namespace budget {
 	        this.dailyTokens = source["dailyTokens"];
 	        this.sessionTokens = source["sessionTokens"];
 	        this.softWarnPct = source["softWarnPct"];
+	        this.dailyUsd = source["dailyUsd"];
+	        this.sessionUsd = source["sessionUsd"];
+	        this.limits = this.convertValues(source["limits"], Limit);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LimitStatus {
+	    limit: Limit;
+	    used: number;
+	    usedUsd: number;
+	    pct: number;
+	    hit: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new LimitStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.limit = this.convertValues(source["limit"], Limit);
+	        this.used = source["used"];
+	        this.usedUsd = source["usedUsd"];
+	        this.pct = source["pct"];
+	        this.hit = source["hit"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class Status {
 	    enabled: boolean;
@@ -1637,6 +1742,17 @@ export namespace budget {
 	    hitSession: boolean;
 	    warnDaily: boolean;
 	    warnSession: boolean;
+	    dailyUsd: number;
+	    sessionUsd: number;
+	    dailyUsedUsd: number;
+	    sessionUsedUsd: number;
+	    dailyUsdPct: number;
+	    sessionUsdPct: number;
+	    hitDailyUsd: boolean;
+	    hitSessionUsd: boolean;
+	    warnDailyUsd: boolean;
+	    warnSessionUsd: boolean;
+	    limits?: LimitStatus[];
 	
 	    static createFrom(source: any = {}) {
 	        return new Status(source);
@@ -1657,6 +1773,17 @@ export namespace budget {
 	        this.hitSession = source["hitSession"];
 	        this.warnDaily = source["warnDaily"];
 	        this.warnSession = source["warnSession"];
+	        this.dailyUsd = source["dailyUsd"];
+	        this.sessionUsd = source["sessionUsd"];
+	        this.dailyUsedUsd = source["dailyUsedUsd"];
+	        this.sessionUsedUsd = source["sessionUsedUsd"];
+	        this.dailyUsdPct = source["dailyUsdPct"];
+	        this.sessionUsdPct = source["sessionUsdPct"];
+	        this.hitDailyUsd = source["hitDailyUsd"];
+	        this.hitSessionUsd = source["hitSessionUsd"];
+	        this.warnDailyUsd = source["warnDailyUsd"];
+	        this.warnSessionUsd = source["warnSessionUsd"];
+	        this.limits = this.convertValues(source["limits"], LimitStatus);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -3519,12 +3646,185 @@ export namespace gateway {
 	        this.priority = source["priority"];
 	    }
 	}
+	export class CacheConfig {
+	    enabled: boolean;
+	    ttlSeconds?: number;
+	    maxEntryBytes?: number;
+	    maxTotalBytes?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CacheConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.ttlSeconds = source["ttlSeconds"];
+	        this.maxEntryBytes = source["maxEntryBytes"];
+	        this.maxTotalBytes = source["maxTotalBytes"];
+	    }
+	}
+	export class CaptureAttempt {
+	    endpoint: string;
+	    endpointId?: string;
+	    url: string;
+	    status?: number;
+	    error?: string;
+	    latencyMs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CaptureAttempt(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.endpoint = source["endpoint"];
+	        this.endpointId = source["endpointId"];
+	        this.url = source["url"];
+	        this.status = source["status"];
+	        this.error = source["error"];
+	        this.latencyMs = source["latencyMs"];
+	    }
+	}
+	export class Capture {
+	    seq: number;
+	    requestId: string;
+	    appId?: string;
+	    model?: string;
+	    time: any;
+	    method: string;
+	    path: string;
+	    query?: string;
+	    chain: string[];
+	    attempts: CaptureAttempt[];
+	    servedBy?: string;
+	    servedById?: string;
+	    url?: string;
+	    protocol?: string;
+	    requestHeaders?: Record<string, string>;
+	    requestBody?: string;
+	    requestTruncated?: boolean;
+	    status?: number;
+	    responseHeaders?: Record<string, string>;
+	    responseBody?: string;
+	    responseTruncated?: boolean;
+	    streaming?: boolean;
+	    error?: string;
+	    durationMs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Capture(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.seq = source["seq"];
+	        this.requestId = source["requestId"];
+	        this.appId = source["appId"];
+	        this.model = source["model"];
+	        this.time = this.convertValues(source["time"], null);
+	        this.method = source["method"];
+	        this.path = source["path"];
+	        this.query = source["query"];
+	        this.chain = source["chain"];
+	        this.attempts = this.convertValues(source["attempts"], CaptureAttempt);
+	        this.servedBy = source["servedBy"];
+	        this.servedById = source["servedById"];
+	        this.url = source["url"];
+	        this.protocol = source["protocol"];
+	        this.requestHeaders = source["requestHeaders"];
+	        this.requestBody = source["requestBody"];
+	        this.requestTruncated = source["requestTruncated"];
+	        this.status = source["status"];
+	        this.responseHeaders = source["responseHeaders"];
+	        this.responseBody = source["responseBody"];
+	        this.responseTruncated = source["responseTruncated"];
+	        this.streaming = source["streaming"];
+	        this.error = source["error"];
+	        this.durationMs = source["durationMs"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CaptureConfig {
+	    enabled: boolean;
+	    maxEntries?: number;
+	    maxBodyBytes?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CaptureConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.maxEntries = source["maxEntries"];
+	        this.maxBodyBytes = source["maxBodyBytes"];
+	    }
+	}
+	export class AdminToken {
+	    name: string;
+	    token: string;
+	    caps: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AdminToken(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.token = source["token"];
+	        this.caps = source["caps"];
+	    }
+	}
+	export class ShareConfig {
+	    enabled: boolean;
+	    listenAddr?: string;
+	    certFile?: string;
+	    keyFile?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ShareConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.listenAddr = source["listenAddr"];
+	        this.certFile = source["certFile"];
+	        this.keyFile = source["keyFile"];
+	    }
+	}
 	export class Config {
 	    port: number;
 	    upstreamUrl: string;
 	    userToken: string;
 	    autoStart: boolean;
 	    fallbacks?: FallbackEntry[];
+	    cache: CacheConfig;
+	    modelAliases?: Record<string, string>;
+	    tierLimits?: Record<number, appreg.RateLimits>;
+	    streamIdleTimeoutSec?: number;
+	    capture: CaptureConfig;
+	    adminTokens?: AdminToken[];
+	    share: ShareConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -3537,6 +3837,13 @@ export namespace gateway {
 	        this.userToken = source["userToken"];
 	        this.autoStart = source["autoStart"];
 	        this.fallbacks = this.convertValues(source["fallbacks"], FallbackEntry);
+	        this.cache = this.convertValues(source["cache"], CacheConfig);
+	        this.modelAliases = source["modelAliases"];
+	        this.tierLimits = this.convertValues(source["tierLimits"], appreg.RateLimits, true);
+	        this.streamIdleTimeoutSec = source["streamIdleTimeoutSec"];
+	        this.capture = this.convertValues(source["capture"], CaptureConfig);
+	        this.adminTokens = this.convertValues(source["adminTokens"], AdminToken);
+	        this.share = this.convertValues(source["share"], ShareConfig);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -3558,6 +3865,34 @@ export namespace gateway {
 		}
 	}
 	
+	export class ReplayResult {
+	    requestId: string;
+	    endpoint: string;
+	    url: string;
+	    status?: number;
+	    latencyMs: number;
+	    headers?: Record<string, string>;
+	    body?: string;
+	    truncated?: boolean;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReplayResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requestId = source["requestId"];
+	        this.endpoint = source["endpoint"];
+	        this.url = source["url"];
+	        this.status = source["status"];
+	        this.latencyMs = source["latencyMs"];
+	        this.headers = source["headers"];
+	        this.body = source["body"];
+	        this.truncated = source["truncated"];
+	        this.error = source["error"];
+	    }
+	}
 	export class Status {
 	    running: boolean;
 	    port: number;
@@ -3565,6 +3900,7 @@ export namespace gateway {
 	    uptime: number;
 	    totalRequests: number;
 	    activeConns: number;
+	    shareUrl?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Status(source);
@@ -3578,6 +3914,7 @@ export namespace gateway {
 	        this.uptime = source["uptime"];
 	        this.totalRequests = source["totalRequests"];
 	        this.activeConns = source["activeConns"];
+	        this.shareUrl = source["shareUrl"];
 	    }
 	}
 
@@ -4593,6 +4930,8 @@ export namespace main {
 	    error?: string;
 	    servedBy?: string;
 	    matchedBy?: string;
+	    servedModel?: string;
+	    keyId?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new RequestLogEntry(source);
@@ -4612,6 +4951,8 @@ export namespace main {
 	        this.error = source["error"];
 	        this.servedBy = source["servedBy"];
 	        this.matchedBy = source["matchedBy"];
+	        this.servedModel = source["servedModel"];
+	        this.keyId = source["keyId"];
 	    }
 	}
 	
@@ -5122,6 +5463,7 @@ export namespace modelcatalog {
 	    reportedModel: string;
 	    verdict: string;
 	    latencyMs: number;
+	    httpStatus?: number;
 	    note?: string;
 	    // Go type: time
 	    testedAt: any;
@@ -5138,6 +5480,7 @@ export namespace modelcatalog {
 	        this.reportedModel = source["reportedModel"];
 	        this.verdict = source["verdict"];
 	        this.latencyMs = source["latencyMs"];
+	        this.httpStatus = source["httpStatus"];
 	        this.note = source["note"];
 	        this.testedAt = this.convertValues(source["testedAt"], null);
 	    }
@@ -5165,6 +5508,7 @@ export namespace modelcatalog {
 	    providerName: string;
 	    status: string;
 	    latencyMs: number;
+	    httpStatus?: number;
 	    models: string[];
 	    error?: string;
 	    // Go type: time
@@ -5180,6 +5524,7 @@ export namespace modelcatalog {
 	        this.providerName = source["providerName"];
 	        this.status = source["status"];
 	        this.latencyMs = source["latencyMs"];
+	        this.httpStatus = source["httpStatus"];
 	        this.models = source["models"];
 	        this.error = source["error"];
 	        this.testedAt = this.convertValues(source["testedAt"], null);
@@ -5905,6 +6250,52 @@ export namespace proxydetect {
 
 export namespace relay {
 	
+	export class ProbeStats {
+	    samples: number;
+	    errorRate: number;
+	    p50Ms?: number;
+	    p95Ms?: number;
+	    lastVerdict: string;
+	    lastProbeMs: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.samples = source["samples"];
+	        this.errorRate = source["errorRate"];
+	        this.p50Ms = source["p50Ms"];
+	        this.p95Ms = source["p95Ms"];
+	        this.lastVerdict = source["lastVerdict"];
+	        this.lastProbeMs = source["lastProbeMs"];
+	    }
+	}
+	export class KeyState {
+	    keyId: string;
+	    status: string;
+	    uses: number;
+	    failures: number;
+	    lastStatus?: number;
+	    lastError?: string;
+	    nextProbeMs?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new KeyState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.keyId = source["keyId"];
+	        this.status = source["status"];
+	        this.uses = source["uses"];
+	        this.failures = source["failures"];
+	        this.lastStatus = source["lastStatus"];
+	        this.lastError = source["lastError"];
+	        this.nextProbeMs = source["nextProbeMs"];
+	    }
+	}
 	export class CircuitState {
 	    endpointID: string;
 	    status: string;
@@ -5912,6 +6303,12 @@ export namespace relay {
 	    lastFailureMs?: number;
 	    nextProbeMs?: number;
 	    lastError?: string;
+	    hedgeWins?: number;
+	    hedgeLosses?: number;
+	    probe?: ProbeStats;
+	    keys?: KeyState[];
+	    trips?: number;
+	    tripReason?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new CircuitState(source);
@@ -5925,7 +6322,208 @@ export namespace relay {
 	        this.lastFailureMs = source["lastFailureMs"];
 	        this.nextProbeMs = source["nextProbeMs"];
 	        this.lastError = source["lastError"];
+	        this.hedgeWins = source["hedgeWins"];
+	        this.hedgeLosses = source["hedgeLosses"];
+	        this.probe = this.convertValues(source["probe"], ProbeStats);
+	        this.keys = this.convertValues(source["keys"], KeyState);
+	        this.trips = source["trips"];
+	        this.tripReason = source["tripReason"];
 	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BreakerPolicy {
+	    failureThreshold?: number;
+	    cooldownSec?: number;
+	    maxCooldownSec?: number;
+	    errorRate?: number;
+	    errorWindow?: number;
+	    slowP95Ms?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BreakerPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.failureThreshold = source["failureThreshold"];
+	        this.cooldownSec = source["cooldownSec"];
+	        this.maxCooldownSec = source["maxCooldownSec"];
+	        this.errorRate = source["errorRate"];
+	        this.errorWindow = source["errorWindow"];
+	        this.slowP95Ms = source["slowP95Ms"];
+	    }
+	}
+	export class EndpointPrice {
+	    modelPrefix: string;
+	    inputPerMTok: number;
+	    outputPerMTok: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EndpointPrice(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.modelPrefix = source["modelPrefix"];
+	        this.inputPerMTok = source["inputPerMTok"];
+	        this.outputPerMTok = source["outputPerMTok"];
+	    }
+	}
+	export class HealthSample {
+	    t: number;
+	    ok: boolean;
+	    ms?: number;
+	    code?: number;
+	    verdict?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthSample(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.t = source["t"];
+	        this.ok = source["ok"];
+	        this.ms = source["ms"];
+	        this.code = source["code"];
+	        this.verdict = source["verdict"];
+	    }
+	}
+	export class HealthStats {
+	    samples: number;
+	    uptimePct: number;
+	    p50Ms?: number;
+	    p95Ms?: number;
+	    statusCodes?: Record<number, number>;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.samples = source["samples"];
+	        this.uptimePct = source["uptimePct"];
+	        this.p50Ms = source["p50Ms"];
+	        this.p95Ms = source["p95Ms"];
+	        this.statusCodes = source["statusCodes"];
+	    }
+	}
+	export class HealthSummary {
+	    endpointID: string;
+	    day: HealthStats;
+	    week: HealthStats;
+	    last?: HealthSample;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.endpointID = source["endpointID"];
+	        this.day = this.convertValues(source["day"], HealthStats);
+	        this.week = this.convertValues(source["week"], HealthStats);
+	        this.last = this.convertValues(source["last"], HealthSample);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MonitorConfig {
+	    enabled: boolean;
+	    intervalSec?: number;
+	    mode?: string;
+	    downAfter?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MonitorConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.intervalSec = source["intervalSec"];
+	        this.mode = source["mode"];
+	        this.downAfter = source["downAfter"];
+	    }
+	}
+	export class ProbeResult {
+	    mode: string;
+	    model?: string;
+	    verdict: string;
+	    healthy: boolean;
+	    latencyMs: number;
+	    statusCode?: number;
+	    note?: string;
+	    // Go type: time
+	    testedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mode = source["mode"];
+	        this.model = source["model"];
+	        this.verdict = source["verdict"];
+	        this.healthy = source["healthy"];
+	        this.latencyMs = source["latencyMs"];
+	        this.statusCode = source["statusCode"];
+	        this.note = source["note"];
+	        this.testedAt = this.convertValues(source["testedAt"], null);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class RelayEndpoint {
 	    id: string;
@@ -5933,10 +6531,20 @@ export namespace relay {
 	    kind: string;
 	    url: string;
 	    apiKey: string;
+	    apiKeys?: string[];
+	    keySelection?: string;
+	    breaker?: BreakerPolicy;
 	    description?: string;
+	    vision?: boolean;
+	    protocol?: string;
+	    reasoning?: string;
+	    prices?: EndpointPrice[];
+	    modelAliases?: Record<string, string>;
+	    probeModel?: string;
 	    latencyMs: number;
 	    healthy: boolean;
 	    lastChecked?: string;
+	    probe?: ProbeResult;
 	
 	    static createFrom(source: any = {}) {
 	        return new RelayEndpoint(source);
@@ -5949,15 +6557,44 @@ export namespace relay {
 	        this.kind = source["kind"];
 	        this.url = source["url"];
 	        this.apiKey = source["apiKey"];
+	        this.apiKeys = source["apiKeys"];
+	        this.keySelection = source["keySelection"];
+	        this.breaker = this.convertValues(source["breaker"], BreakerPolicy);
 	        this.description = source["description"];
+	        this.vision = source["vision"];
+	        this.protocol = source["protocol"];
+	        this.reasoning = source["reasoning"];
+	        this.prices = this.convertValues(source["prices"], EndpointPrice);
+	        this.modelAliases = source["modelAliases"];
+	        this.probeModel = source["probeModel"];
 	        this.latencyMs = source["latencyMs"];
 	        this.healthy = source["healthy"];
 	        this.lastChecked = source["lastChecked"];
+	        this.probe = this.convertValues(source["probe"], ProbeResult);
 	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class PickResult {
 	    Endpoint: RelayEndpoint;
 	    MatchedBy: string;
+	    Strategy: string;
 	    Healthy: RelayEndpoint[];
 	    Ordered: RelayEndpoint[];
 	
@@ -5969,6 +6606,7 @@ export namespace relay {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.Endpoint = this.convertValues(source["Endpoint"], RelayEndpoint);
 	        this.MatchedBy = source["MatchedBy"];
+	        this.Strategy = source["Strategy"];
 	        this.Healthy = this.convertValues(source["Healthy"], RelayEndpoint);
 	        this.Ordered = this.convertValues(source["Ordered"], RelayEndpoint);
 	    }
@@ -5991,6 +6629,101 @@ export namespace relay {
 		    return a;
 		}
 	}
+	export class RuleTrace {
+	    name: string;
+	    matched: boolean;
+	    fired: boolean;
+	    mismatches?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RuleTrace(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.matched = source["matched"];
+	        this.fired = source["fired"];
+	        this.mismatches = source["mismatches"];
+	    }
+	}
+	export class Explanation {
+	    rules: RuleTrace[];
+	    decision: string;
+	    result?: PickResult;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Explanation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.rules = this.convertValues(source["rules"], RuleTrace);
+	        this.decision = source["decision"];
+	        this.result = this.convertValues(source["result"], PickResult);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PickHint {
+	    model?: string;
+	    estimatedInputTokens?: number;
+	    hasTools?: boolean;
+	    appId?: string;
+	    costCenter?: string;
+	    // Go type: time
+	    time?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PickHint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.model = source["model"];
+	        this.estimatedInputTokens = source["estimatedInputTokens"];
+	        this.hasTools = source["hasTools"];
+	        this.appId = source["appId"];
+	        this.costCenter = source["costCenter"];
+	        this.time = this.convertValues(source["time"], null);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
@@ -6066,6 +6799,50 @@ export namespace repoaudit {
 
 }
 
+export namespace secrets {
+	
+	export class Status {
+	    locked: boolean;
+	    passphrase: boolean;
+	    count: number;
+	    keyGeneration: number;
+	    // Go type: time
+	    rotatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Status(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.locked = source["locked"];
+	        this.passphrase = source["passphrase"];
+	        this.count = source["count"];
+	        this.keyGeneration = source["keyGeneration"];
+	        this.rotatedAt = this.convertValues(source["rotatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
 export namespace serverctl {
 	
 	export class ServerConfig {
@@ -74,28 +74,38 @@ type ResellerConfig struct {
 	DisplayName string `json:"displayName,omitempty"` // shown in UI ("Acme Corp")
 }
 
-// settingsPath returns the path to app-settings.json
-func settingsPath() (string, error) {
+// DataDir returns the per-user app-data directory every lurus-switch
+// store lives under.
+func DataDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	var dir string
 	switch runtime.GOOS {
 	case "windows":
 		appData := os.Getenv("APPDATA")
 		if appData == "" {
 			appData = filepath.Join(home, "AppData", "Roaming")
 		}
-		dir = filepath.Join(appData, "lurus-switch")
+		return filepath.Join(appData, "lurus-switch"), nil
 	case "darwin":
-		dir = filepath.Join(home, "Library", "Application Support", "lurus-switch")
+		return filepath.Join(home, "Library", "Application Support", "lurus-switch"), nil
 	default:
-		dir = filepath.Join(home, ".lurus-switch")
+		return filepath.Join(home, ".lurus-switch"), nil
 	}
+}
 
-	return filepath.Join(dir, "app-settings.json"), nil
+// settingsFileName is app-settings.json's name under the data directory.
+const settingsFileName = "app-settings.json"
+
+// settingsPath returns the path to app-settings.json
+func settingsPath() (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, settingsFileName), nil
 }
 
 // DefaultAppSettings returns factory defaults. AppMode defaults to ModeUnset
@@ -115,12 +125,18 @@ func DefaultAppSettings() *AppSettings {
 
 // LoadAppSettings reads app settings from disk; returns defaults if file is missing
 func LoadAppSettings() (*AppSettings, error) {
-	p, err := settingsPath()
+	dir, err := DataDir()
 	if err != nil {
 		return DefaultAppSettings(), nil
 	}
+	return LoadAppSettingsFrom(dir)
+}
 
-	data, err := os.ReadFile(p)
+// LoadAppSettingsFrom is LoadAppSettings for the app-settings.json in
+// dataDir, for processes run against another data directory (e.g.
+// lurus-switchd --data-dir).
+func LoadAppSettingsFrom(dataDir string) (*AppSettings, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, settingsFileName))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return DefaultAppSettings(), nil
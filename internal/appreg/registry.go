@@ -5,8 +5,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -194,6 +197,170 @@ func (r *Registry) LookupOwnership(appID string) (employeeID, costCenter string)
 	return "", ""
 }
 
+// SetLimits sets an app's own rate limits; nil clears them so the
+// gateway's tier defaults apply. Returns the updated app on success.
+func (r *Registry) SetLimits(id string, limits *RateLimits) (*App, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	app, ok := r.apps[id]
+	if !ok {
+		return nil, fmt.Errorf("app %q not found", id)
+	}
+	if limits != nil {
+		cp := *limits
+		limits = &cp
+	}
+	app.Limits = limits
+	if err := r.saveLocked(); err != nil {
+		return nil, fmt.Errorf("save registry: %w", err)
+	}
+	cp := *app
+	return &cp, nil
+}
+
+// LookupLimits returns the app's tier and its own limits (nil when it
+// has none). Runs on the gateway hot path like LookupOwnership.
+func (r *Registry) LookupLimits(appID string) (Tier, *RateLimits) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if a, ok := r.apps[appID]; ok {
+		return a.Tier, a.Limits
+	}
+	return 0, nil
+}
+
+// IssueMember creates a token for a teammate who reaches the gateway
+// over the LAN. The member is attributed to employeeID in chargeback —
+// or to its own app ID when employeeID is empty, so its usage still
+// shows up as a row of its own. allowedIPs restricts where the token
+// may be used from; see App.AllowedIPs. Revoke the member with Delete.
+func (r *Registry) IssueMember(name, employeeID, costCenter string, allowedIPs []string) (*App, error) {
+	if name == "" {
+		return nil, fmt.Errorf("member name is required")
+	}
+	allowed, err := normalizeAllowedIPs(allowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	token, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	id := generateAppID()
+	if employeeID == "" {
+		employeeID = id
+	}
+	app := &App{
+		ID:              id,
+		Name:            name,
+		Kind:            KindMember,
+		Tier:            TierManual,
+		Token:           token,
+		CreatedAt:       time.Now(),
+		Connected:       true,
+		OwnerEmployeeID: employeeID,
+		CostCenter:      costCenter,
+		AllowedIPs:      allowed,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apps[id] = app
+	r.byToken[token] = id
+	if err := r.saveLocked(); err != nil {
+		delete(r.apps, id)
+		delete(r.byToken, token)
+		return nil, fmt.Errorf("save registry: %w", err)
+	}
+	cp := *app
+	return &cp, nil
+}
+
+// SetAllowedIPs replaces a member's IP allow-list; empty allows any
+// address. The gateway checks it on every request, so the change
+// applies immediately.
+func (r *Registry) SetAllowedIPs(id string, allowedIPs []string) (*App, error) {
+	allowed, err := normalizeAllowedIPs(allowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	app, ok := r.apps[id]
+	if !ok {
+		return nil, fmt.Errorf("app %q not found", id)
+	}
+	if app.Kind != KindMember {
+		return nil, fmt.Errorf("app %q is not a team member", id)
+	}
+	app.AllowedIPs = allowed
+	if err := r.saveLocked(); err != nil {
+		return nil, fmt.Errorf("save registry: %w", err)
+	}
+	cp := *app
+	return &cp, nil
+}
+
+// Members returns the team members, oldest first.
+func (r *Registry) Members() []*App {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*App
+	for _, a := range r.apps {
+		if a.Kind == KindMember {
+			cp := *a
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// LookupAccess reports whether the app is a team member and, if so,
+// whether addr is inside its allow-list. Runs on the gateway hot path
+// like LookupOwnership.
+func (r *Registry) LookupAccess(appID string, addr netip.Addr) (member, allowed bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.apps[appID]
+	if !ok || a.Kind != KindMember {
+		return false, false
+	}
+	if len(a.AllowedIPs) == 0 {
+		return true, true
+	}
+	addr = addr.Unmap()
+	for _, s := range a.AllowedIPs {
+		if p, err := netip.ParsePrefix(s); err == nil && p.Contains(addr) {
+			return true, true
+		}
+	}
+	return true, false
+}
+
+// normalizeAllowedIPs validates an allow-list, turning bare addresses
+// into single-address prefixes.
+func normalizeAllowedIPs(in []string) ([]string, error) {
+	var out []string
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(s); err == nil {
+			addr = addr.Unmap()
+			s = netip.PrefixFrom(addr, addr.BitLen()).String()
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed IP %q: want an address or CIDR prefix", s)
+		}
+		out = append(out, p.Masked().String())
+	}
+	return out, nil
+}
+
 // SetConnected marks an app as connected or disconnected.
 func (r *Registry) SetConnected(id string, connected bool) error {
 	r.mu.Lock()
@@ -1,7 +1,9 @@
 package appreg
 
 import (
+	"net/netip"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -188,3 +190,82 @@ func TestGenerateToken(t *testing.T) {
 	// Clean up any test artifacts.
 	os.RemoveAll(t.TempDir())
 }
+
+func TestRegistry_SetLimits(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	app, _ := reg.Register("Batch Job", "", "")
+
+	if tier, lim := reg.LookupLimits(app.ID); tier != TierManual || lim != nil {
+		t.Fatalf("fresh app: tier=%v limits=%+v", tier, lim)
+	}
+	if _, err := reg.SetLimits(app.ID, &RateLimits{RequestsPerMinute: 60, MaxInFlight: 2}); err != nil {
+		t.Fatalf("SetLimits: %v", err)
+	}
+	if _, err := reg.SetLimits("nope", &RateLimits{}); err == nil {
+		t.Fatal("expected error for unknown app")
+	}
+
+	reg2, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewRegistry (reload): %v", err)
+	}
+	if _, lim := reg2.LookupLimits(app.ID); lim == nil || lim.RequestsPerMinute != 60 || lim.MaxInFlight != 2 {
+		t.Fatalf("limits after reload = %+v", lim)
+	}
+	if _, err := reg2.SetLimits(app.ID, nil); err != nil {
+		t.Fatalf("SetLimits(nil): %v", err)
+	}
+	if _, lim := reg2.LookupLimits(app.ID); lim != nil {
+		t.Fatalf("cleared limits = %+v", lim)
+	}
+}
+
+func TestRegistry_Members(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if _, err := reg.IssueMember("alice", "", "", []string{"not-an-ip"}); err == nil {
+		t.Fatal("expected error for an invalid allowed IP")
+	}
+	m, err := reg.IssueMember("alice", "", "eng", []string{" 192.168.1.20 ", "10.1.2.3/16"})
+	if err != nil {
+		t.Fatalf("IssueMember: %v", err)
+	}
+	if m.Kind != KindMember || m.OwnerEmployeeID != m.ID || m.CostCenter != "eng" {
+		t.Fatalf("member = %+v", m)
+	}
+	if got := strings.Join(m.AllowedIPs, ","); got != "192.168.1.20/32,10.1.0.0/16" {
+		t.Fatalf("allowed IPs = %s", got)
+	}
+
+	check := func(ip string, wantMember, wantAllowed bool) {
+		t.Helper()
+		member, allowed := reg.LookupAccess(m.ID, netip.MustParseAddr(ip))
+		if member != wantMember || allowed != wantAllowed {
+			t.Errorf("LookupAccess(%s) = %v, %v; want %v, %v", ip, member, allowed, wantMember, wantAllowed)
+		}
+	}
+	check("192.168.1.20", true, true)
+	check("::ffff:10.1.200.7", true, true)
+	check("192.168.1.21", true, false)
+
+	if _, err := reg.SetAllowedIPs(m.ID, nil); err != nil {
+		t.Fatalf("SetAllowedIPs: %v", err)
+	}
+	check("172.16.0.1", true, true)
+	if _, err := reg.SetAllowedIPs("claude", []string{"10.0.0.1"}); err == nil {
+		t.Fatal("expected error scoping a non-member app")
+	}
+	if member, _ := reg.LookupAccess("claude", netip.MustParseAddr("127.0.0.1")); member {
+		t.Fatal("builtin app reported as a member")
+	}
+	if ms := reg.Members(); len(ms) != 1 || ms[0].ID != m.ID {
+		t.Fatalf("Members = %+v", ms)
+	}
+}
@@ -22,6 +22,7 @@ type AppKind string
 const (
 	KindBuiltin AppKind = "builtin" // pre-defined tool (Claude, Codex, etc.)
 	KindUser    AppKind = "user"    // manually registered by user
+	KindMember  AppKind = "member"  // teammate reaching the gateway over the LAN
 )
 
 // App represents a registered application that uses the Switch gateway.
@@ -43,6 +44,28 @@ type App struct {
 	// employee in the org chart.
 	OwnerEmployeeID string `json:"ownerEmployeeId,omitempty"`
 	CostCenter      string `json:"costCenter,omitempty"` // mirrors orgsync Department.CostCenter
+
+	// Limits caps how hard this app may drive the gateway. nil falls
+	// back to the gateway's per-tier defaults.
+	Limits *RateLimits `json:"limits,omitempty"`
+
+	// AllowedIPs restricts where a KindMember token may be used from:
+	// addresses ("192.168.1.20") or CIDR prefixes ("10.0.0.0/24").
+	// Empty allows any address.
+	AllowedIPs []string `json:"allowedIps,omitempty"`
+}
+
+// RateLimits bounds one app's gateway traffic. Zero fields are
+// unlimited.
+type RateLimits struct {
+	RequestsPerMinute int64 `json:"requestsPerMinute,omitempty"`
+	TokensPerMinute   int64 `json:"tokensPerMinute,omitempty"` // input + output, booked after each response
+	MaxInFlight       int   `json:"maxInFlight,omitempty"`     // concurrent requests
+}
+
+// IsZero reports whether no limit is set.
+func (l RateLimits) IsZero() bool {
+	return l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0 && l.MaxInFlight <= 0
 }
 
 // BuiltinTool defines a pre-known tool that Switch can auto-detect and configure.
@@ -120,8 +120,14 @@ func (j *Journal) IsReversible(op string) bool {
 // `before` and `after` are JSON-serialized into the on-disk record;
 // pass small structures only.
 func (j *Journal) Record(op, target string, before, after any, err error) Entry {
+	return j.RecordAs(capability.Current(), op, target, before, after, err)
+}
+
+// RecordAs is Record for a caller holding its own token rather than
+// the process-wide one — e.g. the gateway admin API, whose callers
+// authenticate per request.
+func (j *Journal) RecordAs(tok capability.Token, op, target string, before, after any, err error) Entry {
 	now := time.Now()
-	tok := capability.Current()
 
 	entry := Entry{
 		ID:         j.nextID(now),
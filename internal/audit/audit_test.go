@@ -39,6 +39,15 @@ func TestRecord_DeniedOutcome(t *testing.T) {
 	}
 }
 
+func TestRecordAs_UsesGivenToken(t *testing.T) {
+	j := newTestJournal(t)
+	tok := capability.NewToken("admin:ci", capability.CapChannelRead)
+	e := j.RecordAs(tok, "relay.endpoint.delete", "ep1", nil, nil, &capability.Error{Required: capability.CapChannelWrite, Principal: tok.Principal})
+	if e.Principal != "admin:ci" || len(e.CapsHeld) != 1 || e.CapsHeld[0] != "channel.read" || e.Outcome != "denied" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
 func TestRecord_ErrorOutcome(t *testing.T) {
 	j := newTestJournal(t)
 	e := j.Record("channel.create", "ch-1", nil, nil, errors.New("upstream 500"))
@@ -0,0 +1,66 @@
+package budget
+
+import (
+	"fmt"
+
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/pricing"
+)
+
+// USD ceilings. Tokens are a poor proxy for spend — the same million
+// tokens cost ~50× more on claude-opus than on deepseek-chat — so the
+// daily and session walls (and scoped Limits) can also be set in USD.
+// Token caps only notice a request after it has been paid for; USD caps
+// are checked pre-flight against the request's worst case (Estimate) so
+// a request that would blow through the wall is refused up front.
+
+// DefaultMaxOutputTokens is the output allowance an Estimate assumes
+// when the request sets no max_tokens of its own.
+const DefaultMaxOutputTokens = 4096
+
+// Estimate is a request's worst-case size, supplied by the gateway
+// before forwarding. The zero Estimate checks only spend already made.
+type Estimate struct {
+	Model           string `json:"model"`
+	InputTokens     int64  `json:"inputTokens"`
+	MaxOutputTokens int64  `json:"maxOutputTokens"` // 0 = DefaultMaxOutputTokens
+}
+
+// USD is the estimate priced at the model's input and output rates.
+func (e Estimate) USD() float64 {
+	if e.Model == "" && e.InputTokens == 0 && e.MaxOutputTokens == 0 {
+		return 0
+	}
+	out := e.MaxOutputTokens
+	if out <= 0 {
+		out = DefaultMaxOutputTokens
+	}
+	return pricing.Cost(e.Model, e.InputTokens, out, 0, 0)
+}
+
+// recordUSD prices a booked response.
+func recordUSD(rec metering.Record) float64 {
+	return pricing.Cost(rec.BilledModel(), rec.TokensIn, rec.TokensOut, rec.CacheCreateTokens, rec.CacheReadTokens)
+}
+
+// checkUSD refuses a request whose worst case would take spend past
+// limit, or that arrives after spend already reached it. kind names the
+// wall ("daily" / "session").
+func checkUSD(kind string, spent, limit, estimate float64) (Verdict, bool) {
+	if limit <= 0 || (spent < limit && spent+estimate <= limit) {
+		return Verdict{}, false
+	}
+	v := Verdict{LimitKind: kind, Unit: "usd", UsedUSD: spent, LimitUSD: limit, EstimatedUSD: estimate}
+	if spent >= limit {
+		v.Reason = fmt.Sprintf("%s spend cap reached: $%.2f / $%.2f", kind, spent, limit)
+	} else {
+		v.Reason = fmt.Sprintf("%s spend cap would be exceeded: $%.2f spent + up to $%.2f for this request > $%.2f",
+			kind, spent, estimate, limit)
+	}
+	return v, true
+}
+
+// pctUSD is pctClamped for dollar amounts.
+func pctUSD(used, limit float64) int {
+	return pctClamped(int64(used*1e6), int64(limit*1e6))
+}
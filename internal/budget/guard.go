@@ -1,12 +1,14 @@
 // Package budget enforces a hard token-spend ceiling at the Switch
 // gateway. Defends against the 2025-2026 horror story where a runaway
 // Claude Code session burned $1,600 in tokens overnight (#token-burn
-// thread on r/ClaudeCode). Two limits are tracked:
+// thread on r/ClaudeCode). Two instance-wide limits are tracked, each
+// in tokens and / or USD (see cost.go):
 //
-//   - Daily   — total tokens routed through this Switch instance today.
-//   - Session — tokens since the user last clicked "reset session".
+//   - Daily   — total spend routed through this Switch instance today.
+//   - Session — spend since the user last clicked "reset session".
 //
-// When either limit is hit, Check() returns Allowed=false and the
+// plus optional per-scope walls (global / cost center / employee / app,
+// see limits.go). When any limit is hit, Check() returns Allowed=false and the
 // gateway responds 429 with a friendly "you've hit your spend wall"
 // payload. The user lifts the wall by raising the limit or clicking
 // reset — never silently, so they're aware the cap was reached.
@@ -32,6 +34,14 @@ type Config struct {
 	// SoftWarnPct triggers a non-blocking warning event when usage
 	// crosses this percentage of either limit. 0 disables the warning.
 	SoftWarnPct int `json:"softWarnPct"`
+
+	// USD ceilings, checked pre-flight against the request's worst-case
+	// cost (see cost.go). 0 means no limit.
+	DailyUSD   float64 `json:"dailyUsd,omitempty"`
+	SessionUSD float64 `json:"sessionUsd,omitempty"`
+
+	// Limits are the hierarchical per-scope walls (see limits.go).
+	Limits []Limit `json:"limits,omitempty"`
 }
 
 func DefaultConfig() Config {
@@ -47,9 +57,20 @@ func DefaultConfig() Config {
 type Verdict struct {
 	Allowed   bool   `json:"allowed"`
 	Reason    string `json:"reason,omitempty"`
-	LimitKind string `json:"limitKind,omitempty"` // "daily" | "session"
+	LimitKind string `json:"limitKind,omitempty"` // "daily" | "session" | "weekly" | "monthly"
 	Used      int64  `json:"used,omitempty"`
 	Limit     int64  `json:"limit,omitempty"`
+
+	// Set when a scoped limit tripped: which member of which scope, and
+	// whether the cap was in tokens (Used / Limit) or USD.
+	Scope    Scope   `json:"scope,omitempty"`
+	Key      string  `json:"key,omitempty"`
+	Unit     string  `json:"unit,omitempty"` // "tokens" | "usd"
+	UsedUSD  float64 `json:"usedUsd,omitempty"`
+	LimitUSD float64 `json:"limitUsd,omitempty"`
+	// EstimatedUSD is the refused request's worst-case cost when a USD
+	// wall tripped pre-flight.
+	EstimatedUSD float64 `json:"estimatedUsd,omitempty"`
 }
 
 // Status is what the UI consumes to render gauges.
@@ -67,6 +88,20 @@ type Status struct {
 	HitSession    bool      `json:"hitSession"`
 	WarnDaily     bool      `json:"warnDaily"`
 	WarnSession   bool      `json:"warnSession"`
+
+	// The USD walls, same shape as the token ones above.
+	DailyUSD       float64 `json:"dailyUsd"`
+	SessionUSD     float64 `json:"sessionUsd"`
+	DailyUsedUSD   float64 `json:"dailyUsedUsd"`
+	SessionUsedUSD float64 `json:"sessionUsedUsd"`
+	DailyUSDPct    int     `json:"dailyUsdPct"`
+	SessionUSDPct  int     `json:"sessionUsdPct"`
+	HitDailyUSD    bool    `json:"hitDailyUsd"`
+	HitSessionUSD  bool    `json:"hitSessionUsd"`
+	WarnDailyUSD   bool    `json:"warnDailyUsd"`
+	WarnSessionUSD bool    `json:"warnSessionUsd"`
+
+	Limits []LimitStatus `json:"limits,omitempty"`
 }
 
 // Guard is the live in-process budget enforcer. The session counter is
@@ -79,7 +114,13 @@ type Guard struct {
 	cfgPath      string
 	sessionUsed  atomic.Int64
 	sessionStart time.Time
+	sessionUSD   float64 // guarded by mu
 	today        func() metering.DailySummary
+
+	// Per-scope window usage for Config.Limits.
+	usageMu sync.Mutex
+	usage   map[counterKey]*windowUsage
+	now     func() time.Time // swapped in tests
 }
 
 // New loads the persisted config (if any) and initialises a Guard.
@@ -90,6 +131,8 @@ func New(cfgPath string, today func() metering.DailySummary) (*Guard, error) {
 		cfgPath:      cfgPath,
 		sessionStart: time.Now(),
 		today:        today,
+		usage:        map[counterKey]*windowUsage{},
+		now:          time.Now,
 	}
 	g.cfg = DefaultConfig()
 	if cfgPath != "" {
@@ -103,6 +146,30 @@ func New(cfgPath string, today func() metering.DailySummary) (*Guard, error) {
 	return g, nil
 }
 
+// Reload re-reads the persisted config, e.g. after it was edited by
+// hand. A file that fails to parse is an error and changes nothing;
+// usage counters are kept either way.
+func (g *Guard) Reload() error {
+	if g.cfgPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(g.cfgPath)
+	if os.IsNotExist(err) {
+		data, err = json.Marshal(DefaultConfig())
+	}
+	if err != nil {
+		return err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("budget: parse %s: %w", g.cfgPath, err)
+	}
+	g.mu.Lock()
+	g.cfg = c
+	g.mu.Unlock()
+	return nil
+}
+
 func (g *Guard) GetConfig() Config {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -110,14 +177,28 @@ func (g *Guard) GetConfig() Config {
 }
 
 // SetConfig validates and persists. Negative limits are clamped to 0
-// (= unlimited) so the UI can't accidentally lock users out.
+// (= unlimited) so the UI can't accidentally lock users out; a scoped
+// limit with an unknown scope or window is rejected.
 func (g *Guard) SetConfig(c Config) error {
+	for i := range c.Limits {
+		l := &c.Limits[i]
+		if err := l.validate(); err != nil {
+			return err
+		}
+		if l.Scope == ScopeGlobal {
+			l.Key = ""
+		}
+		l.Tokens = max(l.Tokens, 0)
+		l.USD = max(l.USD, 0)
+	}
 	if c.DailyTokens < 0 {
 		c.DailyTokens = 0
 	}
 	if c.SessionTokens < 0 {
 		c.SessionTokens = 0
 	}
+	c.DailyUSD = max(c.DailyUSD, 0)
+	c.SessionUSD = max(c.SessionUSD, 0)
 	if c.SoftWarnPct < 0 || c.SoftWarnPct > 100 {
 		c.SoftWarnPct = 80
 	}
@@ -144,6 +225,7 @@ func (g *Guard) SetConfig(c Config) error {
 func (g *Guard) ResetSession() {
 	g.mu.Lock()
 	g.sessionUsed.Store(0)
+	g.sessionUSD = 0
 	g.sessionStart = time.Now()
 	g.mu.Unlock()
 }
@@ -166,8 +248,10 @@ func (g *Guard) RecordUsage(in, out int64) {
 	g.mu.Unlock()
 }
 
-// Check is called BEFORE forwarding. Returns Allowed=false when either
-// limit is already exceeded.
+// Check is called BEFORE forwarding with the request's attribution and
+// worst-case size. Returns Allowed=false when the daily or session cap,
+// or any scoped limit sub falls under, is already exceeded — or, for USD
+// caps, would be by est; the verdict names the limit that tripped.
 //
 // Concurrency contract (the session axis is now atomic, the daily axis is
 // not): the session counter read + threshold comparison happen under a
@@ -179,11 +263,26 @@ func (g *Guard) RecordUsage(in, out int64) {
 // one of them is mid-incrementing. The daily axis delegates to the metering
 // store (an external source we cannot atomically reserve against), so it
 // stays advisory; that is documented at the call site below.
-func (g *Guard) Check() Verdict {
+func (g *Guard) Check(sub Subject, est Estimate) Verdict {
 	cfg := g.GetConfig()
 	if !cfg.Enabled {
 		return Verdict{Allowed: true}
 	}
+	estUSD := est.USD()
+	if cfg.DailyUSD > 0 && g.today != nil {
+		// Advisory like the daily token axis below.
+		if v, hit := checkUSD("daily", g.today().CostUSD, cfg.DailyUSD, estUSD); hit {
+			return v
+		}
+	}
+	if cfg.SessionUSD > 0 {
+		g.mu.Lock()
+		spent := g.sessionUSD
+		g.mu.Unlock()
+		if v, hit := checkUSD("session", spent, cfg.SessionUSD, estUSD); hit {
+			return v
+		}
+	}
 	if cfg.DailyTokens > 0 && g.today != nil {
 		// Daily axis is advisory: g.today() reads the metering store, an
 		// independent owner of "tokens today". We cannot reserve against it
@@ -213,13 +312,14 @@ func (g *Guard) Check() Verdict {
 			}
 		}
 	}
-	return Verdict{Allowed: true}
+	return g.checkLimits(cfg.Limits, sub, estUSD)
 }
 
 func (g *Guard) Status() Status {
 	cfg := g.GetConfig()
 	g.mu.RLock()
 	start := g.sessionStart
+	sessionUSD := g.sessionUSD
 	g.mu.RUnlock()
 
 	st := Status{
@@ -229,10 +329,16 @@ func (g *Guard) Status() Status {
 		SessionStart:  start,
 		SoftWarnPct:   cfg.SoftWarnPct,
 		SessionUsed:   g.sessionUsed.Load(),
+		Limits:        g.limitStatuses(cfg.Limits),
+
+		DailyUSD:       cfg.DailyUSD,
+		SessionUSD:     cfg.SessionUSD,
+		SessionUsedUSD: sessionUSD,
 	}
 	if g.today != nil {
 		s := g.today()
 		st.DailyUsed = s.TokensIn + s.TokensOut
+		st.DailyUsedUSD = s.CostUSD
 	}
 	if cfg.DailyTokens > 0 {
 		st.DailyPct = pctClamped(st.DailyUsed, cfg.DailyTokens)
@@ -244,6 +350,16 @@ func (g *Guard) Status() Status {
 		st.HitSession = st.SessionUsed >= cfg.SessionTokens
 		st.WarnSession = !st.HitSession && cfg.SoftWarnPct > 0 && st.SessionPct >= cfg.SoftWarnPct
 	}
+	if cfg.DailyUSD > 0 {
+		st.DailyUSDPct = pctUSD(st.DailyUsedUSD, cfg.DailyUSD)
+		st.HitDailyUSD = st.DailyUsedUSD >= cfg.DailyUSD
+		st.WarnDailyUSD = !st.HitDailyUSD && cfg.SoftWarnPct > 0 && st.DailyUSDPct >= cfg.SoftWarnPct
+	}
+	if cfg.SessionUSD > 0 {
+		st.SessionUSDPct = pctUSD(st.SessionUsedUSD, cfg.SessionUSD)
+		st.HitSessionUSD = st.SessionUsedUSD >= cfg.SessionUSD
+		st.WarnSessionUSD = !st.HitSessionUSD && cfg.SoftWarnPct > 0 && st.SessionUSDPct >= cfg.SoftWarnPct
+	}
 	return st
 }
 
@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"lurus-switch/internal/metering"
 )
@@ -11,7 +12,7 @@ import (
 func TestGuard_DisabledByDefaultAllowsEverything(t *testing.T) {
 	g, _ := New("", nil)
 	g.RecordUsage(1_000_000, 1_000_000) // a lot
-	if v := g.Check(); !v.Allowed {
+	if v := g.Check(Subject{}, Estimate{}); !v.Allowed {
 		t.Errorf("disabled guard should allow; got %+v", v)
 	}
 }
@@ -20,7 +21,7 @@ func TestGuard_SessionLimitBlocks(t *testing.T) {
 	g, _ := New("", nil)
 	_ = g.SetConfig(Config{Enabled: true, SessionTokens: 1000})
 	g.RecordUsage(600, 500) // 1100 > 1000
-	v := g.Check()
+	v := g.Check(Subject{}, Estimate{})
 	if v.Allowed {
 		t.Error("should block at session cap")
 	}
@@ -35,7 +36,7 @@ func TestGuard_DailyLimitBlocks(t *testing.T) {
 	}
 	g, _ := New("", today)
 	_ = g.SetConfig(Config{Enabled: true, DailyTokens: 1000})
-	v := g.Check()
+	v := g.Check(Subject{}, Estimate{})
 	if v.Allowed {
 		t.Error("should block at daily cap")
 	}
@@ -48,11 +49,11 @@ func TestGuard_ResetSessionClearsCounter(t *testing.T) {
 	g, _ := New("", nil)
 	_ = g.SetConfig(Config{Enabled: true, SessionTokens: 1000})
 	g.RecordUsage(1500, 0)
-	if g.Check().Allowed {
+	if g.Check(Subject{}, Estimate{}).Allowed {
 		t.Fatal("expected block before reset")
 	}
 	g.ResetSession()
-	if !g.Check().Allowed {
+	if !g.Check(Subject{}, Estimate{}).Allowed {
 		t.Error("expected allow after reset")
 	}
 }
@@ -114,7 +115,7 @@ func TestGuard_ConcurrentCheckRecordNoRace(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			for j := 0; j < perWorker; j++ {
-				_ = g.Check()
+				_ = g.Check(Subject{}, Estimate{})
 				g.RecordUsage(1, 1)
 			}
 		}()
@@ -123,7 +124,7 @@ func TestGuard_ConcurrentCheckRecordNoRace(t *testing.T) {
 
 	// After 32*50*2 = 3200 tokens recorded against a 1000 cap, Check must
 	// block — and the verdict must report the session axis.
-	v := g.Check()
+	v := g.Check(Subject{}, Estimate{})
 	if v.Allowed {
 		t.Errorf("session cap should be hit after concurrent load; got %+v", v)
 	}
@@ -146,3 +147,118 @@ func TestGuard_NegativeConfigClampedToZero(t *testing.T) {
 		t.Errorf("softWarnPct=%d, want 80 (default after clamp)", c.SoftWarnPct)
 	}
 }
+
+func TestGuard_ScopedLimitsReportWhichTripped(t *testing.T) {
+	g, _ := New("", nil)
+	now := time.Date(2026, 3, 11, 15, 0, 0, 0, time.UTC) // a Wednesday
+	g.now = func() time.Time { return now }
+	err := g.SetConfig(Config{Enabled: true, Limits: []Limit{
+		{Scope: ScopeCostCenter, Key: "ENG", Window: WindowMonthly, Tokens: 10_000},
+		{Scope: ScopeEmployee, Key: EachKey, Window: WindowWeekly, Tokens: 5_000},
+		{Scope: ScopeApp, Key: "codex", Window: WindowDaily, Tokens: 1_000},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := Subject{AppID: "codex", EmployeeID: "alice", CostCenter: "ENG"}
+	bob := Subject{AppID: "claude", EmployeeID: "bob", CostCenter: "ENG"}
+	book := func(sub Subject, tokens int64, at time.Time) {
+		g.Book(metering.Record{AppID: sub.AppID, EmployeeID: sub.EmployeeID, CostCenter: sub.CostCenter,
+			Model: "gpt-4o", TokensIn: tokens, Timestamp: at})
+	}
+
+	book(alice, 1_200, now.Add(-time.Hour))
+	if v := g.Check(alice, Estimate{}); v.Allowed || v.Scope != ScopeApp || v.Key != "codex" || v.LimitKind != "daily" {
+		t.Errorf("app daily cap: %+v", v)
+	}
+	if v := g.Check(bob, Estimate{}); !v.Allowed {
+		t.Errorf("bob is under every limit: %+v", v)
+	}
+
+	book(alice, 4_000, now.AddDate(0, 0, -2)) // Monday: same week, earlier day
+	if v := g.Check(Subject{AppID: "other", EmployeeID: "alice", CostCenter: "ENG"}, Estimate{}); v.Allowed || v.Scope != ScopeEmployee || v.Key != "alice" {
+		t.Errorf("per-employee weekly cap: %+v", v)
+	}
+
+	book(bob, 5_000, now.AddDate(0, 0, -9)) // last week, same month
+	if v := g.Check(bob, Estimate{}); v.Allowed || v.Scope != ScopeCostCenter || v.LimitKind != "monthly" || v.Used != 10_200 {
+		t.Errorf("cost-center monthly cap should trip first: %+v", v)
+	}
+
+	now = now.AddDate(0, 1, 0) // next month: every window has rolled over
+	if v := g.Check(alice, Estimate{}); !v.Allowed {
+		t.Errorf("windows should reset: %+v", v)
+	}
+}
+
+func TestGuard_ScopedUSDLimitAndSeed(t *testing.T) {
+	g, _ := New("", nil)
+	now := time.Date(2026, 3, 11, 15, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	_ = g.SetConfig(Config{Enabled: true, Limits: []Limit{{Scope: ScopeGlobal, Window: WindowDaily, USD: 1}}})
+
+	g.Seed([]metering.Record{
+		{Model: "gpt-4o", TokensIn: 1_000_000, Timestamp: now.AddDate(0, 0, -1)}, // yesterday
+		{Model: "gpt-4o", TokensIn: 10, ErrorMessage: "boom", Timestamp: now},
+	})
+	if v := g.Check(Subject{}, Estimate{}); !v.Allowed {
+		t.Fatalf("yesterday's spend counted today: %+v", v)
+	}
+	g.Seed([]metering.Record{{Model: "gpt-4o", TokensIn: 1_000_000, Timestamp: now.Add(-time.Minute)}})
+	v := g.Check(Subject{}, Estimate{})
+	if v.Allowed || v.Unit != "usd" || v.Scope != ScopeGlobal || v.UsedUSD < 1 {
+		t.Errorf("global USD cap: %+v", v)
+	}
+	if st := g.Status(); len(st.Limits) != 1 || !st.Limits[0].Hit || st.Limits[0].Pct != 100 {
+		t.Errorf("status limits = %+v", st.Limits)
+	}
+}
+
+func TestGuard_RejectsMalformedLimits(t *testing.T) {
+	g, _ := New("", nil)
+	for _, l := range []Limit{
+		{Scope: "team", Window: WindowDaily},
+		{Scope: ScopeApp, Key: "codex", Window: "hourly"},
+		{Scope: ScopeEmployee, Window: WindowDaily, Tokens: 1},
+	} {
+		if err := g.SetConfig(Config{Enabled: true, Limits: []Limit{l}}); err == nil {
+			t.Errorf("accepted %+v", l)
+		}
+	}
+}
+
+func TestGuard_USDCapRefusesWorstCasePreFlight(t *testing.T) {
+	g, _ := New("", nil)
+	_ = g.SetConfig(Config{Enabled: true, SessionUSD: 1})
+	g.Book(metering.Record{Model: "claude-opus-4", TokensIn: 10_000, TokensOut: 2_000, Timestamp: time.Now()}) // $0.30
+
+	// 20K in + 8K out on opus is $0.90 worst case: over the remaining $0.70.
+	opus := Estimate{Model: "claude-opus-4", InputTokens: 20_000, MaxOutputTokens: 8_000}
+	v := g.Check(Subject{}, opus)
+	if v.Allowed || v.LimitKind != "session" || v.Unit != "usd" || v.EstimatedUSD < 0.89 {
+		t.Fatalf("opus request should be refused pre-flight: %+v", v)
+	}
+	// The same request on deepseek costs cents and goes through.
+	if v := g.Check(Subject{}, Estimate{Model: "deepseek-chat", InputTokens: 20_000, MaxOutputTokens: 8_000}); !v.Allowed {
+		t.Errorf("cheap request refused: %+v", v)
+	}
+	if st := g.Status(); st.SessionUsedUSD < 0.29 || st.SessionUSDPct != 30 {
+		t.Errorf("status = %+v", st)
+	}
+	g.ResetSession()
+	if v := g.Check(Subject{}, opus); !v.Allowed {
+		t.Errorf("reset should clear session spend: %+v", v)
+	}
+}
+
+func TestGuard_DailyUSDCapUsesMeteredCost(t *testing.T) {
+	today := func() metering.DailySummary { return metering.DailySummary{CostUSD: 4.99} }
+	g, _ := New("", today)
+	_ = g.SetConfig(Config{Enabled: true, DailyUSD: 5})
+	if v := g.Check(Subject{}, Estimate{}); !v.Allowed {
+		t.Errorf("spend under the cap with no estimate: %+v", v)
+	}
+	if v := g.Check(Subject{}, Estimate{Model: "gpt-4o", InputTokens: 1_000}); v.Allowed || v.LimitKind != "daily" {
+		t.Errorf("default max output should push it over: %+v", v)
+	}
+}
@@ -0,0 +1,288 @@
+package budget
+
+import (
+	"fmt"
+	"time"
+
+	"lurus-switch/internal/metering"
+)
+
+// Hierarchical budget walls. Beyond the instance-wide daily / session
+// caps, Config.Limits caps spend per scope — global, cost center,
+// employee, app — over a calendar day, week (Monday-based) or month, in
+// tokens or USD (priced by pricing.Cost). Check walks the scopes from
+// the widest down and reports the first limit that has tripped.
+//
+// Usage per scope is kept in memory, booked by Book after each metered
+// response and seeded from the metering history at startup (Seed), so a
+// restart mid-month doesn't hand everyone a fresh allowance.
+
+// Scope is the level of the attribution hierarchy a limit applies to.
+type Scope string
+
+const (
+	ScopeGlobal     Scope = "global"
+	ScopeCostCenter Scope = "costCenter"
+	ScopeEmployee   Scope = "employee"
+	ScopeApp        Scope = "app"
+)
+
+// scopeOrder is the order Check evaluates scopes in.
+var scopeOrder = []Scope{ScopeGlobal, ScopeCostCenter, ScopeEmployee, ScopeApp}
+
+// Window is the calendar period a limit resets over.
+type Window string
+
+const (
+	WindowDaily   Window = "daily"
+	WindowWeekly  Window = "weekly"
+	WindowMonthly Window = "monthly"
+)
+
+var windows = []Window{WindowDaily, WindowWeekly, WindowMonthly}
+
+// EachKey as a Limit.Key applies the limit to every member of the scope
+// separately (e.g. each employee may spend $20 a day).
+const EachKey = "*"
+
+// Limit caps one scope member's spend over a window. Set Tokens, USD or
+// both; 0 means no limit on that unit.
+type Limit struct {
+	Scope  Scope   `json:"scope"`
+	Key    string  `json:"key,omitempty"` // cost center / employee / app ID, or "*"; empty for global
+	Window Window  `json:"window"`
+	Tokens int64   `json:"tokens,omitempty"`
+	USD    float64 `json:"usd,omitempty"`
+}
+
+// Subject is who a request is attributed to: the gateway's
+// RequestMeta dimensions. Empty fields are unattributed and match no
+// scoped limit.
+type Subject struct {
+	AppID      string `json:"appId,omitempty"`
+	EmployeeID string `json:"employeeId,omitempty"`
+	CostCenter string `json:"costCenter,omitempty"`
+}
+
+// key returns the subject's member ID at scope.
+func (s Subject) key(scope Scope) string {
+	switch scope {
+	case ScopeCostCenter:
+		return s.CostCenter
+	case ScopeEmployee:
+		return s.EmployeeID
+	case ScopeApp:
+		return s.AppID
+	}
+	return ""
+}
+
+// LimitStatus is one configured limit's current usage, for the UI.
+// Per-member ("*") limits aren't listed — they have no single usage.
+type LimitStatus struct {
+	Limit   Limit   `json:"limit"`
+	Used    int64   `json:"used"`
+	UsedUSD float64 `json:"usedUsd"`
+	Pct     int     `json:"pct"` // of the tighter unit, 0..100
+	Hit     bool    `json:"hit"`
+}
+
+func (l Limit) validate() error {
+	switch l.Scope {
+	case ScopeGlobal, ScopeCostCenter, ScopeEmployee, ScopeApp:
+	default:
+		return fmt.Errorf("budget limit: unknown scope %q", l.Scope)
+	}
+	switch l.Window {
+	case WindowDaily, WindowWeekly, WindowMonthly:
+	default:
+		return fmt.Errorf("budget limit: unknown window %q", l.Window)
+	}
+	if l.Scope != ScopeGlobal && l.Key == "" {
+		return fmt.Errorf("budget limit: %s limit needs a key (or %q for each)", l.Scope, EachKey)
+	}
+	return nil
+}
+
+// windowStart is the start of the window containing t, in t's location.
+func windowStart(w Window, t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch w {
+	case WindowWeekly:
+		back := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-back, 0, 0, 0, 0, t.Location())
+	case WindowMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// HistoryStart is how far back Seed needs records to cover every window
+// current at now.
+func HistoryStart(now time.Time) time.Time {
+	week, month := windowStart(WindowWeekly, now), windowStart(WindowMonthly, now)
+	if week.Before(month) {
+		return week
+	}
+	return month
+}
+
+type counterKey struct {
+	scope  Scope
+	key    string
+	window Window
+}
+
+// windowUsage is one scope member's usage in one window.
+type windowUsage struct {
+	start  time.Time
+	tokens int64
+	usd    float64
+}
+
+// add books a record stamped at into s, rolling s over when the record
+// opens a newer window and ignoring records from an older one.
+func (s *windowUsage) add(start time.Time, tokens int64, usd float64) {
+	switch {
+	case start.After(s.start):
+		*s = windowUsage{start: start}
+	case start.Before(s.start):
+		return
+	}
+	s.tokens += tokens
+	s.usd += usd
+}
+
+// Book adds a metered response to every scope it is attributed to and
+// to the session's USD spend. Called by the gateway after each booked
+// upstream response.
+func (g *Guard) Book(rec metering.Record) {
+	usd := g.bookScopes(rec)
+	g.mu.Lock()
+	g.sessionUSD += usd
+	g.mu.Unlock()
+}
+
+// bookScopes adds rec to its scopes' window usage and returns its cost.
+func (g *Guard) bookScopes(rec metering.Record) float64 {
+	tokens := rec.TokensIn + rec.CacheReadTokens + rec.CacheCreateTokens + rec.TokensOut
+	if tokens <= 0 {
+		return 0
+	}
+	usd := recordUSD(rec)
+	at := rec.Timestamp
+	if at.IsZero() {
+		at = g.now()
+	}
+	sub := Subject{AppID: rec.AppID, EmployeeID: rec.EmployeeID, CostCenter: rec.CostCenter}
+
+	g.usageMu.Lock()
+	defer g.usageMu.Unlock()
+	for _, scope := range scopeOrder {
+		key := sub.key(scope)
+		if scope != ScopeGlobal && key == "" {
+			continue
+		}
+		for _, w := range windows {
+			ck := counterKey{scope, key, w}
+			s := g.usage[ck]
+			if s == nil {
+				s = &windowUsage{}
+				g.usage[ck] = s
+			}
+			s.add(windowStart(w, at.In(g.now().Location())), tokens, usd)
+		}
+	}
+	return usd
+}
+
+// Seed books historical records — the metering store's records since
+// HistoryStart — so scoped windows survive a restart. Call once, before
+// the gateway starts serving. Failed requests carry no tokens, except a
+// stream that broke part-way, whose partial output was paid for.
+func (g *Guard) Seed(recs []metering.Record) {
+	for _, r := range recs {
+		g.bookScopes(r)
+	}
+}
+
+// used returns the usage booked against ck in the window current at now.
+func (g *Guard) used(ck counterKey, now time.Time) (int64, float64) {
+	g.usageMu.Lock()
+	defer g.usageMu.Unlock()
+	s := g.usage[ck]
+	if s == nil || !s.start.Equal(windowStart(ck.window, now)) {
+		return 0, 0
+	}
+	return s.tokens, s.usd
+}
+
+// checkLimits evaluates cfg.Limits for sub, widest scope first. USD
+// limits are checked against spend plus estUSD, the request's worst case.
+func (g *Guard) checkLimits(limits []Limit, sub Subject, estUSD float64) Verdict {
+	now := g.now()
+	for _, scope := range scopeOrder {
+		key := sub.key(scope)
+		if scope != ScopeGlobal && key == "" {
+			continue
+		}
+		for _, l := range limits {
+			if l.Scope != scope || (scope != ScopeGlobal && l.Key != key && l.Key != EachKey) {
+				continue
+			}
+			tokens, usd := g.used(counterKey{scope, key, l.Window}, now)
+			if v, hit := l.verdict(key, tokens, usd, estUSD); hit {
+				return v
+			}
+		}
+	}
+	return Verdict{Allowed: true}
+}
+
+// verdict reports whether l blocks a request of worst-case cost estUSD,
+// and the blocking verdict if so. key is the concrete member the usage
+// belongs to.
+func (l Limit) verdict(key string, tokens int64, usd, estUSD float64) (Verdict, bool) {
+	who := string(l.Scope)
+	if l.Scope != ScopeGlobal {
+		who = fmt.Sprintf("%s %q", l.Scope, key)
+	}
+	if l.Tokens > 0 && tokens >= l.Tokens {
+		return Verdict{
+			LimitKind: string(l.Window), Scope: l.Scope, Key: key, Unit: "tokens", Used: tokens, Limit: l.Tokens,
+			Reason: fmt.Sprintf("%s %s token cap reached: %d / %d", who, l.Window, tokens, l.Tokens),
+		}, true
+	}
+	v, hit := checkUSD(string(l.Window), usd, l.USD, estUSD)
+	if hit {
+		v.Scope, v.Key = l.Scope, key
+		v.Reason = who + " " + v.Reason
+	}
+	return v, hit
+}
+
+// limitStatuses reports usage against every concrete configured limit.
+func (g *Guard) limitStatuses(limits []Limit) []LimitStatus {
+	now := g.now()
+	var out []LimitStatus
+	for _, l := range limits {
+		if l.Key == EachKey {
+			continue
+		}
+		key := l.Key
+		if l.Scope == ScopeGlobal {
+			key = ""
+		}
+		tokens, usd := g.used(counterKey{l.Scope, key, l.Window}, now)
+		st := LimitStatus{Limit: l, Used: tokens, UsedUSD: usd}
+		if l.Tokens > 0 {
+			st.Pct = pctClamped(tokens, l.Tokens)
+		}
+		if l.USD > 0 {
+			st.Pct = max(st.Pct, pctUSD(usd, l.USD))
+		}
+		_, st.Hit = l.verdict(key, tokens, usd, 0)
+		out = append(out, st)
+	}
+	return out
+}
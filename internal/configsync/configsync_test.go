@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"lurus-switch/internal/secrets"
 )
 
 // readAllEntries decompresses a bundle and returns a name->content map, so
@@ -141,6 +143,25 @@ func TestExport_IncludesSecretsWhenRequested(t *testing.T) {
 	}
 }
 
+func TestExport_VaultReferencesOnlyResolvedWithKeys(t *testing.T) {
+	d := fixture(t)
+	v, _ := secrets.Open(t.TempDir())
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	ref, _ := secrets.Seal("mcp/m2/env/GITHUB_TOKEN", "ghp-vaulted")
+	mustWrite(t, filepath.Join(d.AppData, "mcp-presets", "m2.json"), `{"id":"m2","server":{"env":{"GITHUB_TOKEN":"`+ref+`"}}}`)
+
+	var redacted, withKeys bytes.Buffer
+	Export(d, false, "1", &redacted)
+	Export(d, true, "1", &withKeys)
+	if joined := allEntriesJoined(t, redacted.Bytes()); strings.Contains(joined, "ghp-vaulted") || !strings.Contains(joined, ref) {
+		t.Error("an export without keys should carry the reference, not the value")
+	}
+	if joined := allEntriesJoined(t, withKeys.Bytes()); !strings.Contains(joined, "ghp-vaulted") {
+		t.Error("an export with keys should resolve vault references")
+	}
+}
+
 func TestRoundTrip_ExportThenImport(t *testing.T) {
 	src := fixture(t)
 	var buf bytes.Buffer
@@ -261,13 +282,16 @@ func TestImport_RejectsNonZip(t *testing.T) {
 }
 
 func TestRedactJSON_NestedAndArrays(t *testing.T) {
-	in := []byte(`{"a":{"apiKey":"x"},"list":[{"openaiKey":"y"}],"keep":"ok"}`)
+	in := []byte(`{"a":{"apiKey":"x","apiKeys":["p1","p2"]},"list":[{"openaiKey":"y"}],"keep":"ok"}`)
 	out := redactJSON(in)
 	var v map[string]any
 	json.Unmarshal(out, &v)
 	if v["a"].(map[string]any)["apiKey"] != redactedPlaceholder {
 		t.Error("nested apiKey not redacted")
 	}
+	if pool := v["a"].(map[string]any)["apiKeys"].([]any); pool[0] != redactedPlaceholder || pool[1] != redactedPlaceholder {
+		t.Errorf("key pool not redacted: %v", pool)
+	}
 	if v["list"].([]any)[0].(map[string]any)["openaiKey"] != redactedPlaceholder {
 		t.Error("array openaiKey not redacted")
 	}
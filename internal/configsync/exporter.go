@@ -100,7 +100,8 @@ func dirItems(zipPrefix, srcDir string, redact, isTOML bool) []item {
 
 // Export writes a configuration bundle to w. Only components that actually
 // have files on disk are included; the manifest lists exactly those. When
-// includeKeys is false, JSON/TOML secrets are redacted in-flight.
+// includeKeys is false, JSON/TOML secrets are redacted in-flight; when it
+// is true, secrets vault references are resolved so the keys travel.
 func Export(d Dirs, includeKeys bool, appVersion string, w io.Writer) (Manifest, error) {
 	zw := zip.NewWriter(w)
 	present := make([]string, 0, len(AllComponents))
@@ -113,6 +114,9 @@ func Export(d Dirs, includeKeys bool, appVersion string, w io.Writer) (Manifest,
 			if err != nil {
 				continue // missing file — skip silently
 			}
+			if includeKeys && !it.isTOML {
+				data = revealRefs(data)
+			}
 			if !includeKeys && it.redact {
 				if it.isTOML {
 					data = redactTOMLLines(data)
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"strings"
+
+	"lurus-switch/internal/secrets"
 )
 
 // redactedKeyNames is the case-insensitive denylist of JSON key names whose
@@ -12,6 +14,7 @@ import (
 // this list, which is why the PR checklist forces a secrets review.
 var redactedKeyNames = map[string]struct{}{
 	"apikey":          {},
+	"apikeys":         {}, // relay endpoint key pools
 	"api_key":         {},
 	"anthropicapikey": {},
 	"openaikey":       {},
@@ -69,6 +72,13 @@ func redactValue(v any) {
 					t[k] = redactedPlaceholder
 					continue
 				}
+				if list, ok := child.([]any); ok {
+					for i, e := range list {
+						if s, ok := e.(string); ok && s != "" {
+							list[i] = redactedPlaceholder
+						}
+					}
+				}
 				if child == nil {
 					continue
 				}
@@ -105,3 +115,40 @@ func redactTOMLLines(raw []byte) []byte {
 	}
 	return bytes.Join(lines, []byte("\n"))
 }
+
+// revealRefs replaces secrets vault references anywhere in a JSON
+// document with their values, for bundles exported with includeKeys: the
+// files on disk only hold references, which mean nothing on the machine
+// the bundle is imported on. Documents without references are returned
+// unchanged; references that can't be resolved (locked vault) stay.
+func revealRefs(raw []byte) []byte {
+	if !bytes.Contains(raw, []byte(secrets.RefPrefix)) {
+		return raw
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw
+	}
+	v = revealValue(v)
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func revealValue(v any) any {
+	switch t := v.(type) {
+	case string:
+		return secrets.Reveal(t)
+	case map[string]any:
+		for k, child := range t {
+			t[k] = revealValue(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = revealValue(child)
+		}
+	}
+	return v
+}
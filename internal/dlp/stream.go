@@ -0,0 +1,102 @@
+package dlp
+
+import "unicode/utf8"
+
+// Outbound scanning. Completions differ from prompts in two ways:
+//
+//   - They stream. A secret the model echoes arrives a few bytes per
+//     event, so scanning each piece alone never matches. StreamScanner
+//     joins the pieces and holds back a trailing window, releasing text
+//     only once no later byte can extend a match into it.
+//   - They can't be refused. By the time a completion is scanned the
+//     tokens are paid for and part of the answer may already be on the
+//     user's screen, so PolicyBlock hits are masked like PolicyRedact
+//     instead of rejecting anything.
+
+// DefaultStreamWindow is the overlap a StreamScanner holds back. It
+// covers every default pattern except unusually long JWTs; a hit that
+// straddles the window edge is held back whole regardless.
+const DefaultStreamWindow = 256
+
+// StreamScanner scans text that arrives in pieces. Not safe for
+// concurrent use; one per stream.
+type StreamScanner struct {
+	scanner *Scanner
+	window  int
+	pending string // scanned but not yet released
+	offset  int    // stream offset of pending[0]
+}
+
+// NewStream returns a StreamScanner holding back window bytes
+// (DefaultStreamWindow when window <= 0).
+func (s *Scanner) NewStream(window int) *StreamScanner {
+	if window <= 0 {
+		window = DefaultStreamWindow
+	}
+	return &StreamScanner{scanner: s, window: window}
+}
+
+// Push appends text and returns the prefix that is now safe to release,
+// with redact and block hits masked, plus the hits inside that prefix.
+// Hit offsets are relative to the start of the stream.
+func (ss *StreamScanner) Push(text string) (string, []Hit) {
+	ss.pending += text
+	if len(ss.pending) <= ss.window {
+		return "", nil
+	}
+	return ss.release(len(ss.pending) - ss.window)
+}
+
+// Flush releases everything still held back. Call once at end of stream.
+func (ss *StreamScanner) Flush() (string, []Hit) {
+	return ss.release(len(ss.pending))
+}
+
+// ScanCompletion masks a complete piece of model output in one go: the
+// outbound counterpart of Scan.
+func (s *Scanner) ScanCompletion(text string) (string, []Hit) {
+	ss := s.NewStream(0)
+	ss.pending = text
+	return ss.Flush()
+}
+
+func (ss *StreamScanner) release(cut int) (string, []Hit) {
+	res := ss.scanner.Scan(ss.pending)
+
+	// Never split a hit: pull the cut back to the start of any match
+	// that straddles it, repeating since an earlier match may now do so.
+	for moved := true; moved; {
+		moved = false
+		for _, h := range res.Hits {
+			if h.Start < cut && h.End > cut {
+				cut = h.Start
+				moved = true
+			}
+		}
+	}
+	for cut > 0 && cut < len(ss.pending) && !utf8.RuneStart(ss.pending[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return "", nil
+	}
+
+	var done, mask []Hit
+	for _, h := range res.Hits {
+		if h.End > cut {
+			continue
+		}
+		m := h
+		if m.Policy == PolicyBlock {
+			m.Policy = PolicyRedact
+		}
+		mask = append(mask, m)
+		h.Start += ss.offset
+		h.End += ss.offset
+		done = append(done, h)
+	}
+	out := applyRedaction(ss.pending[:cut], mask)
+	ss.pending = ss.pending[cut:]
+	ss.offset += cut
+	return out, done
+}
@@ -0,0 +1,74 @@
+package dlp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamScanner_SecretSplitAcrossPieces(t *testing.T) {
+	s := NewScanner()
+	ss := s.NewStream(32)
+
+	pieces := []string{"Your key is AK", "IAABCDEFGH", "IJKLMNOP and ", strings.Repeat("more text ", 10)}
+	var out strings.Builder
+	var hits []Hit
+	for _, p := range pieces {
+		rel, h := ss.Push(p)
+		out.WriteString(rel)
+		hits = append(hits, h...)
+	}
+	rel, h := ss.Flush()
+	out.WriteString(rel)
+	hits = append(hits, h...)
+
+	got := out.String()
+	if strings.Contains(got, "AKIA") {
+		t.Errorf("access key leaked: %q", got)
+	}
+	if !strings.Contains(got, "Your key is [REDACTED:api_key.aws_access] and more text") {
+		t.Errorf("unexpected output %q", got)
+	}
+	if len(hits) != 1 || hits[0].PatternName != "api_key.aws_access" || hits[0].Start != len("Your key is ") {
+		t.Errorf("hits = %+v", hits)
+	}
+	if want := strings.Join(pieces, ""); len(got) != len(want)-20+len("[REDACTED:api_key.aws_access]") {
+		t.Errorf("text lost or duplicated: %q", got)
+	}
+}
+
+func TestStreamScanner_HoldsBackWindow(t *testing.T) {
+	ss := NewScanner().NewStream(16)
+	if rel, _ := ss.Push("short"); rel != "" {
+		t.Errorf("text within the window must be held back, got %q", rel)
+	}
+	rel, _ := ss.Push(strings.Repeat("x", 20))
+	if len(rel) != 9 {
+		t.Errorf("released %d bytes, want 9", len(rel))
+	}
+	if rest, _ := ss.Flush(); len(rest) != 16 {
+		t.Errorf("flush released %d bytes, want 16", len(rest))
+	}
+}
+
+func TestStreamScanner_DoesNotSplitHitAtCut(t *testing.T) {
+	ss := NewScanner().NewStream(4)
+	// The SSN ends inside the window: it must stay held back whole.
+	rel, hits := ss.Push("id 123-45-6789 ok")
+	if rel != "id " || len(hits) != 0 {
+		t.Errorf("rel=%q hits=%v", rel, hits)
+	}
+	rest, hits := ss.Flush()
+	if rest != "[REDACTED:pii.ssn_us] ok" || len(hits) != 1 {
+		t.Errorf("rest=%q hits=%v", rest, hits)
+	}
+}
+
+func TestScanCompletion_MasksBlockPolicy(t *testing.T) {
+	out, hits := NewScanner().ScanCompletion("token ghp_" + strings.Repeat("a", 36) + " end")
+	if out != "token [REDACTED:secret.github_pat] end" {
+		t.Errorf("out = %q", out)
+	}
+	if len(hits) != 1 || hits[0].Policy != PolicyBlock {
+		t.Errorf("hits should keep their configured policy: %+v", hits)
+	}
+}
@@ -0,0 +1,529 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lurus-switch/internal/budget"
+	"lurus-switch/internal/capability"
+	"lurus-switch/internal/dlp"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/relay"
+)
+
+// Admin API. Scripts and headless hosts manage the gateway over
+// /switch/v1/admin/ on the gateway's own listener — the same stores
+// the desktop UI drives through Wails bindings: relay endpoints and
+// circuit states, the budget guard, DLP patterns and metering.
+//
+// Callers authenticate with an AdminToken from Config.AdminTokens
+// (Authorization: Bearer …; app tokens don't work here). Each token
+// carries its own capability set and every route checks one cap, so a
+// CI job can be handed channel.read without being able to rewrite
+// budgets. Mutations and denied calls are reported to the audit fn
+// (wired to the audit journal by services.go) under the token's
+// principal, "admin:<name>".
+
+const adminPrefix = "/switch/v1/admin"
+
+// AdminToken grants access to the admin API.
+type AdminToken struct {
+	Name  string           `json:"name"` // principal in the audit journal, as "admin:<name>"
+	Token string           `json:"token"`
+	Caps  []capability.Cap `json:"caps"`
+}
+
+// SetAdminAuditFn injects (or clears, with nil) the admin API's audit
+// callback, invoked once per mutation and per denied call. err is nil
+// on success and a *capability.Error on a denial.
+func (s *Server) SetAdminAuditFn(fn func(tok capability.Token, op, target string, before, after any, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminAuditFn = fn
+}
+
+// adminRoute is one admin endpoint. handle returns the affected entity
+// (the audit target), its state before a mutation, and the response.
+type adminRoute struct {
+	pattern string // http.ServeMux pattern, method included
+	cap     capability.Cap
+	op      string // audit operation
+	mutates bool
+	handle  func(s *Server, r *http.Request) (target string, before, result any, err error)
+}
+
+// adminError carries the HTTP status for a failed admin call; other
+// errors are 500s.
+type adminError struct {
+	status int
+	msg    string
+}
+
+func (e *adminError) Error() string { return e.msg }
+
+func adminBadRequest(format string, args ...any) error {
+	return &adminError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+func adminNotFound(format string, args ...any) error {
+	return &adminError{status: http.StatusNotFound, msg: fmt.Sprintf(format, args...)}
+}
+
+func adminUnavailable(what string) error {
+	return &adminError{status: http.StatusServiceUnavailable, msg: what + " is not configured"}
+}
+
+var adminRoutes = []adminRoute{
+	{"GET " + adminPrefix + "/relay/endpoints", capability.CapChannelRead, "relay.endpoint.list", false, (*Server).adminListEndpoints},
+	{"PUT " + adminPrefix + "/relay/endpoints/{id}", capability.CapChannelWrite, "relay.endpoint.save", true, (*Server).adminSaveEndpoint},
+	{"DELETE " + adminPrefix + "/relay/endpoints/{id}", capability.CapChannelWrite, "relay.endpoint.delete", true, (*Server).adminDeleteEndpoint},
+	{"GET " + adminPrefix + "/relay/circuits", capability.CapChannelRead, "relay.circuit.list", false, (*Server).adminListCircuits},
+	{"POST " + adminPrefix + "/relay/circuits/{id}/reset", capability.CapChannelWrite, "relay.circuit.reset", true, (*Server).adminResetCircuit},
+
+	{"GET " + adminPrefix + "/budget", capability.CapOptionRead, "budget.get", false, (*Server).adminGetBudget},
+	{"PUT " + adminPrefix + "/budget", capability.CapOptionWrite, "budget.set", true, (*Server).adminSetBudget},
+	{"POST " + adminPrefix + "/budget/reset-session", capability.CapOptionWrite, "budget.reset_session", true, (*Server).adminResetBudgetSession},
+
+	{"GET " + adminPrefix + "/dlp/patterns", capability.CapOptionRead, "dlp.pattern.list", false, (*Server).adminListPatterns},
+	{"POST " + adminPrefix + "/dlp/patterns", capability.CapOptionWrite, "dlp.pattern.add", true, (*Server).adminAddPattern},
+	{"PUT " + adminPrefix + "/dlp/patterns/{name}/policy", capability.CapOptionWrite, "dlp.pattern.policy", true, (*Server).adminSetPatternPolicy},
+	{"DELETE " + adminPrefix + "/dlp/patterns/{name}", capability.CapOptionWrite, "dlp.pattern.delete", true, (*Server).adminRemovePattern},
+	{"GET " + adminPrefix + "/dlp/hits", capability.CapLogReadAll, "dlp.hit.list", false, (*Server).adminListHits},
+
+	{"GET " + adminPrefix + "/metering/summary", capability.CapLogReadAll, "metering.summary", false, (*Server).adminMeteringSummary},
+	{"GET " + adminPrefix + "/metering/records", capability.CapLogReadAll, "metering.records", false, (*Server).adminMeteringRecords},
+}
+
+// registerAdminRoutes mounts the admin API on mux.
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+	for _, rt := range adminRoutes {
+		mux.HandleFunc(rt.pattern, s.withAdminAuth(rt))
+	}
+}
+
+// adminToken resolves the request's bearer token against the
+// configured admin tokens. A token the locked vault couldn't resolve is
+// skipped: its reference string is not a secret.
+func (s *Server) adminToken(r *http.Request) (capability.Token, bool) {
+	presented := extractBearerToken(r)
+	if presented == "" {
+		return capability.Token{}, false
+	}
+	s.mu.Lock()
+	tokens := s.cfg.AdminTokens
+	s.mu.Unlock()
+	for _, t := range tokens {
+		if t.Token != "" && !unresolvedToken(t.Token) && subtle.ConstantTimeCompare([]byte(t.Token), []byte(presented)) == 1 {
+			return capability.NewToken("admin:"+t.Name, t.Caps...), true
+		}
+	}
+	return capability.Token{}, false
+}
+
+// withAdminAuth authenticates, checks rt's cap, runs it and audits.
+func (s *Server) withAdminAuth(rt adminRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := s.adminToken(r)
+		if !ok {
+			writeOpenAIError(w, http.StatusUnauthorized, "invalid_admin_token",
+				"A valid admin token is required (gateway config adminTokens).")
+			return
+		}
+		ctx := capability.WithToken(r.Context(), tok)
+		if err := capability.Require(ctx, rt.cap); err != nil {
+			s.auditAdmin(tok, rt.op, r.URL.Path, nil, nil, err)
+			writeOpenAIError(w, http.StatusForbidden, "permission_denied", err.Error())
+			return
+		}
+		target, before, result, err := rt.handle(s, r.WithContext(ctx))
+		if rt.mutates {
+			s.auditAdmin(tok, rt.op, target, before, result, err)
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			var ae *adminError
+			if errors.As(err, &ae) {
+				status = ae.status
+			}
+			writeOpenAIError(w, status, "admin_error", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+func (s *Server) auditAdmin(tok capability.Token, op, target string, before, after any, err error) {
+	s.mu.Lock()
+	fn := s.adminAuditFn
+	s.mu.Unlock()
+	if fn != nil {
+		fn(tok, op, target, before, after, err)
+	}
+}
+
+func decodeAdminBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(v); err != nil {
+		return adminBadRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// --- relay ---
+
+func (s *Server) adminRelay() (*relay.Router, *relay.Store, error) {
+	s.mu.Lock()
+	router := s.router
+	s.mu.Unlock()
+	if router == nil || router.Store() == nil {
+		return nil, nil, adminUnavailable("relay router")
+	}
+	return router, router.Store(), nil
+}
+
+// maskAPIKey hides all but the ends of an API key. A save that sends
+// the masked form back keeps the stored key.
+func maskAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
+
+func maskEndpoint(ep relay.RelayEndpoint) relay.RelayEndpoint {
+	ep.APIKey = maskAPIKey(ep.APIKey)
+	if len(ep.APIKeys) > 0 {
+		pool := make([]string, len(ep.APIKeys))
+		for i, k := range ep.APIKeys {
+			pool[i] = maskAPIKey(k)
+		}
+		ep.APIKeys = pool
+	}
+	return ep
+}
+
+// unmaskPool puts back the pooled keys a client echoed in masked form.
+func unmaskPool(pool, prev []string) {
+	for i, k := range pool {
+		for _, p := range prev {
+			if k == maskAPIKey(p) {
+				pool[i] = p
+				break
+			}
+		}
+	}
+}
+
+func findEndpoint(store *relay.Store, id string) (relay.RelayEndpoint, bool, error) {
+	eps, err := store.ListEndpoints()
+	if err != nil {
+		return relay.RelayEndpoint{}, false, err
+	}
+	for _, ep := range eps {
+		if ep.ID == id {
+			return ep, true, nil
+		}
+	}
+	return relay.RelayEndpoint{}, false, nil
+}
+
+func (s *Server) adminListEndpoints(r *http.Request) (string, any, any, error) {
+	_, store, err := s.adminRelay()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	eps, err := store.ListEndpoints()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	out := make([]relay.RelayEndpoint, 0, len(eps))
+	for _, ep := range eps {
+		out = append(out, maskEndpoint(ep))
+	}
+	return "", nil, out, nil
+}
+
+func (s *Server) adminSaveEndpoint(r *http.Request) (string, any, any, error) {
+	id := r.PathValue("id")
+	_, store, err := s.adminRelay()
+	if err != nil {
+		return id, nil, nil, err
+	}
+	var ep relay.RelayEndpoint
+	if err := decodeAdminBody(r, &ep); err != nil {
+		return id, nil, nil, err
+	}
+	if ep.URL == "" {
+		return id, nil, nil, adminBadRequest("endpoint url is required")
+	}
+	ep.ID = id
+	prev, found, err := findEndpoint(store, id)
+	if err != nil {
+		return id, nil, nil, err
+	}
+	var before any
+	if found {
+		before = maskEndpoint(prev)
+		if ep.APIKey == maskAPIKey(prev.APIKey) {
+			ep.APIKey = prev.APIKey
+		}
+		unmaskPool(ep.APIKeys, prev.APIKeys)
+	}
+	if err := store.SaveEndpoint(ep); err != nil {
+		return id, before, nil, err
+	}
+	return id, before, maskEndpoint(ep), nil
+}
+
+func (s *Server) adminDeleteEndpoint(r *http.Request) (string, any, any, error) {
+	id := r.PathValue("id")
+	_, store, err := s.adminRelay()
+	if err != nil {
+		return id, nil, nil, err
+	}
+	prev, found, err := findEndpoint(store, id)
+	if err != nil {
+		return id, nil, nil, err
+	}
+	if !found {
+		return id, nil, nil, adminNotFound("relay endpoint %q not found", id)
+	}
+	if err := store.DeleteEndpoint(id); err != nil {
+		return id, maskEndpoint(prev), nil, err
+	}
+	return id, maskEndpoint(prev), map[string]any{"deleted": id}, nil
+}
+
+func (s *Server) adminListCircuits(r *http.Request) (string, any, any, error) {
+	router, _, err := s.adminRelay()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return "", nil, router.Breaker().Snapshot(), nil
+}
+
+func (s *Server) adminResetCircuit(r *http.Request) (string, any, any, error) {
+	id := r.PathValue("id")
+	router, _, err := s.adminRelay()
+	if err != nil {
+		return id, nil, nil, err
+	}
+	before, had := router.Breaker().Snapshot()[id]
+	router.Breaker().Reset(id)
+	if !had {
+		return id, nil, map[string]any{"reset": id}, nil
+	}
+	return id, before, map[string]any{"reset": id}, nil
+}
+
+// --- budget ---
+
+// adminBudget is the budget guard's config and live status.
+type adminBudget struct {
+	Config budget.Config `json:"config"`
+	Status budget.Status `json:"status"`
+}
+
+func (s *Server) adminGuard() (*budget.Guard, error) {
+	s.mu.Lock()
+	g := s.guard
+	s.mu.Unlock()
+	if g == nil {
+		return nil, adminUnavailable("budget guard")
+	}
+	return g, nil
+}
+
+func (s *Server) adminGetBudget(r *http.Request) (string, any, any, error) {
+	g, err := s.adminGuard()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return "", nil, adminBudget{Config: g.GetConfig(), Status: g.Status()}, nil
+}
+
+func (s *Server) adminSetBudget(r *http.Request) (string, any, any, error) {
+	g, err := s.adminGuard()
+	if err != nil {
+		return "budget", nil, nil, err
+	}
+	var cfg budget.Config
+	if err := decodeAdminBody(r, &cfg); err != nil {
+		return "budget", nil, nil, err
+	}
+	before := g.GetConfig()
+	if err := g.SetConfig(cfg); err != nil {
+		return "budget", before, nil, adminBadRequest("%v", err)
+	}
+	return "budget", before, adminBudget{Config: g.GetConfig(), Status: g.Status()}, nil
+}
+
+func (s *Server) adminResetBudgetSession(r *http.Request) (string, any, any, error) {
+	g, err := s.adminGuard()
+	if err != nil {
+		return "budget", nil, nil, err
+	}
+	g.ResetSession()
+	return "budget", nil, g.Status(), nil
+}
+
+// --- DLP ---
+
+func (s *Server) adminScanner() (*dlp.Scanner, error) {
+	s.mu.Lock()
+	sc := s.dlpScanner
+	s.mu.Unlock()
+	if sc == nil {
+		return nil, adminUnavailable("DLP scanner")
+	}
+	return sc, nil
+}
+
+func findPattern(sc *dlp.Scanner, name string) (dlp.Pattern, bool) {
+	for _, p := range sc.Patterns() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return dlp.Pattern{}, false
+}
+
+func (s *Server) adminListPatterns(r *http.Request) (string, any, any, error) {
+	sc, err := s.adminScanner()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return "", nil, sc.Patterns(), nil
+}
+
+func (s *Server) adminAddPattern(r *http.Request) (string, any, any, error) {
+	sc, err := s.adminScanner()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var p dlp.Pattern
+	if err := decodeAdminBody(r, &p); err != nil {
+		return "", nil, nil, err
+	}
+	if err := sc.Add(p); err != nil {
+		return p.Name, nil, nil, adminBadRequest("%v", err)
+	}
+	added, _ := findPattern(sc, p.Name)
+	return p.Name, nil, added, nil
+}
+
+func (s *Server) adminSetPatternPolicy(r *http.Request) (string, any, any, error) {
+	name := r.PathValue("name")
+	sc, err := s.adminScanner()
+	if err != nil {
+		return name, nil, nil, err
+	}
+	var body struct {
+		Policy dlp.Policy `json:"policy"`
+	}
+	if err := decodeAdminBody(r, &body); err != nil {
+		return name, nil, nil, err
+	}
+	switch body.Policy {
+	case dlp.PolicyAllow, dlp.PolicyRedact, dlp.PolicyBlock, dlp.PolicyWarn:
+	default:
+		return name, nil, nil, adminBadRequest("unknown DLP policy %q", body.Policy)
+	}
+	before, found := findPattern(sc, name)
+	if !found || !sc.SetPolicy(name, body.Policy) {
+		return name, nil, nil, adminNotFound("DLP pattern %q not found", name)
+	}
+	after, _ := findPattern(sc, name)
+	return name, before, after, nil
+}
+
+func (s *Server) adminRemovePattern(r *http.Request) (string, any, any, error) {
+	name := r.PathValue("name")
+	sc, err := s.adminScanner()
+	if err != nil {
+		return name, nil, nil, err
+	}
+	before, found := findPattern(sc, name)
+	if !found || !sc.Remove(name) {
+		return name, nil, nil, adminNotFound("DLP pattern %q not found", name)
+	}
+	return name, before, map[string]any{"deleted": name}, nil
+}
+
+func (s *Server) adminListHits(r *http.Request) (string, any, any, error) {
+	sc, err := s.adminScanner()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return "", nil, sc.RecentHits(queryInt(r, "limit", 100)), nil
+}
+
+// --- metering ---
+
+// adminMeteringSummary is usage over a period, rolled up by app and by
+// model.
+type adminMeteringSummary struct {
+	Period string                  `json:"period"`
+	From   time.Time               `json:"from"`
+	To     time.Time               `json:"to"`
+	Apps   []metering.AppSummary   `json:"apps"`
+	Models []metering.ModelSummary `json:"models"`
+}
+
+// periodRange maps "today" (the default), "week" or "month" to a time
+// range ending now.
+func periodRange(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case "", "today":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	case "week":
+		return now.AddDate(0, 0, -7), nil
+	case "month":
+		return now.AddDate(0, -1, 0), nil
+	}
+	return time.Time{}, adminBadRequest("unknown period %q (want today, week or month)", period)
+}
+
+func (s *Server) adminMeteringSummary(r *http.Request) (string, any, any, error) {
+	if s.meter == nil {
+		return "", nil, nil, adminUnavailable("metering")
+	}
+	period := r.URL.Query().Get("period")
+	to := time.Now()
+	from, err := periodRange(period, to)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if period == "" {
+		period = "today"
+	}
+	return "", nil, adminMeteringSummary{
+		Period: period, From: from, To: to,
+		Apps:   s.meter.AppSummaries(from, to),
+		Models: s.meter.ModelSummaries(from, to),
+	}, nil
+}
+
+func (s *Server) adminMeteringRecords(r *http.Request) (string, any, any, error) {
+	if s.meter == nil {
+		return "", nil, nil, adminUnavailable("metering")
+	}
+	return "", nil, s.meter.RecentRecords(queryInt(r, "limit", 100)), nil
+}
+
+// queryInt reads a positive integer query parameter, capped at 1000.
+func queryInt(r *http.Request, key string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return min(n, 1000)
+}
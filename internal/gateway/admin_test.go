@@ -0,0 +1,272 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/budget"
+	"lurus-switch/internal/capability"
+	"lurus-switch/internal/dlp"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/relay"
+	"lurus-switch/internal/secrets"
+)
+
+type adminAudit struct {
+	principal, op, target string
+	err                   error
+}
+
+func setupAdminServer(t *testing.T) (*Server, *relay.Store, *[]adminAudit) {
+	t.Helper()
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, err := relay.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveEndpoint(relay.RelayEndpoint{ID: "ep1", Name: "one", URL: "https://one.example", APIKey: "sk-1234567890abcd"}); err != nil {
+		t.Fatal(err)
+	}
+	router, err := relay.NewRouter(dir, store, relay.NewCircuitBreaker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := budget.New(filepath.Join(dir, "budget.json"), meter.TodaySummary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(dir, reg, meter)
+	srv.SetRelayRouter(router)
+	srv.SetBudgetGuard(guard)
+	srv.SetDLPScanner(dlp.NewScanner())
+	srv.cfg.AdminTokens = []AdminToken{
+		{Name: "reader", Token: "read-tok", Caps: []capability.Cap{capability.CapChannelRead}},
+		{Name: "ops", Token: "ops-tok", Caps: []capability.Cap{capability.CapAll}},
+	}
+	var audits []adminAudit
+	srv.SetAdminAuditFn(func(tok capability.Token, op, target string, before, after any, err error) {
+		audits = append(audits, adminAudit{tok.Principal, op, target, err})
+	})
+	return srv, store, &audits
+}
+
+func serveAdmin(srv *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_AuthAndCapabilities(t *testing.T) {
+	srv, _, audits := setupAdminServer(t)
+
+	if w := serveAdmin(srv, "GET", "/switch/v1/admin/relay/endpoints", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", w.Code)
+	}
+	if w := serveAdmin(srv, "GET", "/switch/v1/admin/relay/endpoints", "bogus", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status %d, want 401", w.Code)
+	}
+
+	w := serveAdmin(srv, "GET", "/switch/v1/admin/relay/endpoints", "read-tok", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("reader list: status %d: %s", w.Code, w.Body.String())
+	}
+	var eps []relay.RelayEndpoint
+	json.Unmarshal(w.Body.Bytes(), &eps)
+	var one relay.RelayEndpoint
+	for _, ep := range eps {
+		if ep.ID == "ep1" {
+			one = ep
+		}
+	}
+	if one.APIKey != "sk-1****abcd" {
+		t.Errorf("listed api key = %q, want it masked", one.APIKey)
+	}
+	if len(*audits) != 0 {
+		t.Errorf("reads are not journaled: %+v", *audits)
+	}
+
+	w = serveAdmin(srv, "DELETE", "/switch/v1/admin/relay/endpoints/ep1", "read-tok", "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("reader delete: status %d, want 403", w.Code)
+	}
+	var denied *capability.Error
+	if len(*audits) != 1 || (*audits)[0].principal != "admin:reader" || !errors.As((*audits)[0].err, &denied) {
+		t.Errorf("audits = %+v, want one denial for admin:reader", *audits)
+	}
+}
+
+func TestAdmin_RelayEndpointsAndCircuits(t *testing.T) {
+	srv, store, audits := setupAdminServer(t)
+
+	// Sending the masked key back keeps the stored one.
+	w := serveAdmin(srv, "PUT", "/switch/v1/admin/relay/endpoints/ep1", "ops-tok",
+		`{"name":"renamed","url":"https://one.example","apiKey":"sk-1****abcd"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", w.Code, w.Body.String())
+	}
+	eps, _ := store.ListEndpoints()
+	for _, ep := range eps {
+		if ep.ID == "ep1" && (ep.Name != "renamed" || ep.APIKey != "sk-1234567890abcd") {
+			t.Errorf("saved endpoint = %+v", ep)
+		}
+	}
+
+	srv.router.Breaker().RecordFailure("ep1", "boom")
+	w = serveAdmin(srv, "GET", "/switch/v1/admin/relay/circuits", "ops-tok", "")
+	if !strings.Contains(w.Body.String(), `"ep1"`) {
+		t.Errorf("circuits = %s", w.Body.String())
+	}
+	if w = serveAdmin(srv, "POST", "/switch/v1/admin/relay/circuits/ep1/reset", "ops-tok", ""); w.Code != http.StatusOK {
+		t.Errorf("reset: status %d", w.Code)
+	}
+	if _, ok := srv.router.Breaker().Snapshot()["ep1"]; ok {
+		t.Error("circuit should be reset")
+	}
+
+	if w = serveAdmin(srv, "DELETE", "/switch/v1/admin/relay/endpoints/nope", "ops-tok", ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete unknown: status %d, want 404", w.Code)
+	}
+	if w = serveAdmin(srv, "DELETE", "/switch/v1/admin/relay/endpoints/ep1", "ops-tok", ""); w.Code != http.StatusOK {
+		t.Errorf("delete: status %d", w.Code)
+	}
+
+	var ops []string
+	for _, a := range *audits {
+		ops = append(ops, a.op+":"+a.target)
+	}
+	want := "relay.endpoint.save:ep1,relay.circuit.reset:ep1,relay.endpoint.delete:nope,relay.endpoint.delete:ep1"
+	if strings.Join(ops, ",") != want {
+		t.Errorf("audited %v, want %s", ops, want)
+	}
+}
+
+func TestAdmin_BudgetDLPAndMetering(t *testing.T) {
+	srv, _, _ := setupAdminServer(t)
+
+	w := serveAdmin(srv, "PUT", "/switch/v1/admin/budget", "ops-tok", `{"enabled":true,"dailyTokens":5000}`)
+	if w.Code != http.StatusOK || srv.guard.GetConfig().DailyTokens != 5000 {
+		t.Errorf("set budget: status %d: %s", w.Code, w.Body.String())
+	}
+	w = serveAdmin(srv, "GET", "/switch/v1/admin/budget", "ops-tok", "")
+	if !strings.Contains(w.Body.String(), `"dailyTokens":5000`) {
+		t.Errorf("budget = %s", w.Body.String())
+	}
+
+	w = serveAdmin(srv, "POST", "/switch/v1/admin/dlp/patterns", "ops-tok", `{"name":"cust-id","regex":"CUST-\\d{6}","policy":"redact"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("add pattern: status %d: %s", w.Code, w.Body.String())
+	}
+	if w = serveAdmin(srv, "PUT", "/switch/v1/admin/dlp/patterns/cust-id/policy", "ops-tok", `{"policy":"nope"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad policy: status %d, want 400", w.Code)
+	}
+	if w = serveAdmin(srv, "PUT", "/switch/v1/admin/dlp/patterns/cust-id/policy", "ops-tok", `{"policy":"block"}`); w.Code != http.StatusOK {
+		t.Errorf("set policy: status %d", w.Code)
+	}
+	if res := srv.dlpScanner.Scan("id CUST-123456"); len(res.Hits) != 1 || res.Hits[0].Policy != dlp.PolicyBlock {
+		t.Errorf("scan = %+v, want the new pattern blocking", res)
+	}
+	if w = serveAdmin(srv, "DELETE", "/switch/v1/admin/dlp/patterns/cust-id", "ops-tok", ""); w.Code != http.StatusOK {
+		t.Errorf("remove pattern: status %d", w.Code)
+	}
+
+	if w = serveAdmin(srv, "GET", "/switch/v1/admin/metering/summary?period=week", "ops-tok", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"period":"week"`) {
+		t.Errorf("summary: status %d: %s", w.Code, w.Body.String())
+	}
+	if w = serveAdmin(srv, "GET", "/switch/v1/admin/metering/summary?period=decade", "ops-tok", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("bad period: status %d, want 400", w.Code)
+	}
+	if w = serveAdmin(srv, "GET", "/switch/v1/admin/metering/records?limit=5", "ops-tok", ""); w.Code != http.StatusOK {
+		t.Errorf("records: status %d", w.Code)
+	}
+}
+
+func TestConfig_TokensLiveInSecretsVault(t *testing.T) {
+	dir := t.TempDir()
+	v, _ := secrets.Open(dir)
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	srv := NewServer(dir, reg, meter)
+
+	cfg := srv.GetConfig()
+	cfg.UserToken = "lurus-user-token"
+	cfg.AdminTokens = []AdminToken{{Name: "ci", Token: "adm-ci-token", Caps: []capability.Cap{capability.CapChannelRead}}}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, configFileName))
+	for _, leak := range []string{"lurus-user-token", "adm-ci-token"} {
+		if strings.Contains(string(raw), leak) {
+			t.Errorf("gateway.json holds %q:\n%s", leak, raw)
+		}
+	}
+	if got := srv.GetConfig(); got.UserToken != "lurus-user-token" || got.AdminTokens[0].Token != "adm-ci-token" {
+		t.Errorf("in-memory config should keep the values, got %+v", got)
+	}
+
+	reopened := NewServer(dir, reg, meter)
+	if got := reopened.GetConfig(); got.UserToken != "lurus-user-token" || got.AdminTokens[0].Token != "adm-ci-token" {
+		t.Errorf("reloaded config = %+v, want resolved tokens", got)
+	}
+}
+
+func TestConfig_LockedVaultReferencesNeverAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	v, _ := secrets.Open(dir)
+	if err := v.SetPassphrase("", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, _ := relay.NewStore(dir)
+	if err := store.SaveEndpoint(relay.RelayEndpoint{ID: "ep1", Name: "one", URL: "https://one.example", APIKey: "sk-locked-away"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(dir, reg, meter)
+	cfg := srv.GetConfig()
+	cfg.UserToken = "lurus-user-token"
+	cfg.AdminTokens = []AdminToken{{Name: "ci", Token: "adm-ci-token", Caps: []capability.Cap{capability.CapAll}}}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := NewServer(dir, reg, meter)
+	router, _ := relay.NewRouter(dir, store, relay.NewCircuitBreaker())
+	locked.SetRelayRouter(router)
+	for _, tok := range []string{secrets.Ref(adminTokenSecretPrefix + "ci"), "adm-ci-token"} {
+		if w := serveAdmin(locked, "GET", "/switch/v1/admin/relay/endpoints", tok, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q with the vault locked: status %d, want 401", tok, w.Code)
+		}
+	}
+	if tok := usableUserToken(locked.GetConfig()); tok != "" {
+		t.Errorf("user token = %q, want none while locked", tok)
+	}
+	if chain, _, ok := locked.buildChainFromRouter("claude", "", 0, false, nil, ""); ok {
+		for _, e := range chain {
+			if e.ID == "ep1" {
+				t.Errorf("chain carries ep1 with token %q while its key is locked", e.Token)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+)
+
+// Model aliases let tools keep their hard-coded model names while each
+// upstream sees the ID it actually serves: a relay endpoint's own
+// ModelAliases map wins, then the gateway-wide Config.ModelAliases.
+// The outgoing body's "model" is rewritten per attempt and every
+// "model" in the response is rewritten back, so the client never sees
+// the upstream ID — metering records it as ServedModel instead.
+
+// SetModelAliases replaces the gateway-wide alias map (requested model →
+// upstream model) applied to every attempt whose entry has no alias of
+// its own for the model.
+func (fc *FallbackChain) SetModelAliases(aliases map[string]string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.aliases = aliases
+}
+
+// upstreamModel resolves the model entry should be asked for.
+func upstreamModel(entry FallbackEntry, global map[string]string, model string) string {
+	if model == "" {
+		return ""
+	}
+	if to := entry.ModelAliases[model]; to != "" {
+		return to
+	}
+	if to := global[model]; to != "" {
+		return to
+	}
+	return model
+}
+
+// ServedModel reports the upstream model behind a response: the alias
+// the serving entry resolved model to, or model itself.
+func (fc *FallbackChain) ServedModel(served FallbackEntry, model string) string {
+	fc.mu.RLock()
+	global := fc.aliases
+	fc.mu.RUnlock()
+	return upstreamModel(served, global, model)
+}
+
+// withModel returns body with its top-level "model" replaced, other
+// fields kept verbatim. Bodies that aren't JSON objects are returned
+// unchanged.
+func withModel(body []byte, model string) []byte {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil || obj["model"] == nil {
+		return body
+	}
+	raw, err := json.Marshal(model)
+	if err != nil {
+		return body
+	}
+	obj["model"] = raw
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// modelRewriteBody streams an upstream response back to the gateway with
+// every `"model": "<served>"` turned into the requested name. It works
+// line by line, which covers SSE events and JSON bodies alike: the
+// field never spans a newline.
+type modelRewriteBody struct {
+	io.ReadCloser
+	r        *bufio.Reader
+	pattern  *regexp.Regexp
+	from, to []byte // JSON-quoted served and requested names
+	pending  []byte
+	err      error
+}
+
+func newModelRewriteBody(body io.ReadCloser, served, requested string) io.ReadCloser {
+	from, _ := json.Marshal(served)
+	to, _ := json.Marshal(requested)
+	return &modelRewriteBody{
+		ReadCloser: body,
+		r:          bufio.NewReader(body),
+		pattern:    regexp.MustCompile(`"model"\s*:\s*` + regexp.QuoteMeta(string(from))),
+		from:       from,
+		to:         to,
+	}
+}
+
+func (b *modelRewriteBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		var line []byte
+		line, b.err = b.r.ReadBytes('\n')
+		b.pending = b.pattern.ReplaceAllFunc(line, func(m []byte) []byte {
+			out := append([]byte{}, m[:len(m)-len(b.from)]...)
+			return append(out, b.to...)
+		})
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// servedModel is RequestMeta.ServedModel for a response: the upstream
+// model when an alias rewrote the request, else "".
+func (s *Server) servedModel(served FallbackEntry, model string) string {
+	if upstream := s.fallback.ServedModel(served, model); upstream != model {
+		return upstream
+	}
+	return ""
+}
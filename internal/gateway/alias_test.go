@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/relay"
+)
+
+// echoModelUpstream answers with the model it was asked for, as a
+// buffered completion or a two-chunk stream.
+func echoModelUpstream(t *testing.T, seen *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("upstream got non-JSON body: %s", body)
+		}
+		*seen = append(*seen, req.Model)
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"model":"`+req.Model+`","choices":[{"delta":{"content":"hi"}}]}`+"\n\n")
+			io.WriteString(w, `data: {"model": "`+req.Model+`","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1}}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","model":"`+req.Model+`","choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`)
+	}
+}
+
+func TestAlias_GlobalRewritesRequestAndResponse(t *testing.T) {
+	var seen []string
+	srv, reg, meter, upstream := setupTestServer(t, echoModelUpstream(t, &seen))
+	defer upstream.Close()
+	cfg := srv.GetConfig()
+	cfg.ModelAliases = map[string]string{"gpt-4o": "openai/gpt-4o-2024-11-20"}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	app, _ := reg.Register("Codex", "", "")
+
+	buffered := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	streamed := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","stream":true,"messages":[]}`)
+
+	if len(seen) != 2 || seen[0] != "openai/gpt-4o-2024-11-20" || seen[1] != seen[0] {
+		t.Fatalf("upstream saw %v", seen)
+	}
+	for name, w := range map[string]*httptest.ResponseRecorder{"buffered": buffered, "streamed": streamed} {
+		if strings.Contains(w.Body.String(), "openai/") || !strings.Contains(w.Body.String(), `"gpt-4o"`) {
+			t.Errorf("%s response leaks the upstream model: %s", name, w.Body.String())
+		}
+	}
+	recs := meter.RecentRecords(2)
+	for _, rec := range recs {
+		if rec.Model != "gpt-4o" || rec.ServedModel != "openai/gpt-4o-2024-11-20" {
+			t.Errorf("record model=%q served=%q", rec.Model, rec.ServedModel)
+		}
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestAlias_EndpointAliasOverridesGlobal(t *testing.T) {
+	var seen []string
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("cfg upstream should not be hit")
+	})
+	defer upstream.Close()
+	endpoint := httptest.NewServer(echoModelUpstream(t, &seen))
+	defer endpoint.Close()
+
+	dir := t.TempDir()
+	store, _ := relay.NewStore(dir)
+	store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "or", Name: "openrouter", URL: endpoint.URL, APIKey: "k", Healthy: true, LatencyMs: 1,
+		ModelAliases: map[string]string{"claude-sonnet-4-5": "anthropic/claude-sonnet-4.5"},
+	})
+	router, _ := relay.NewRouter(dir, store, relay.NewCircuitBreaker())
+	router.LoadRulesYAML("rules:\n  - name: all\n    prefer_endpoint_id: or\n")
+	srv.SetRelayRouter(router)
+	srv.fallback.SetModelAliases(map[string]string{"claude-sonnet-4-5": "global-name", "gpt-4o": "gpt-4o-mini"})
+	app, _ := reg.Register("Claude Code", "", "")
+
+	serve(srv, "/v1/chat/completions", app.Token, `{"model":"claude-sonnet-4-5","messages":[]}`)
+	serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+
+	if len(seen) != 2 || seen[0] != "anthropic/claude-sonnet-4.5" || seen[1] != "gpt-4o-mini" {
+		t.Fatalf("upstream saw %v", seen)
+	}
+	if recs := meter.RecentRecords(2); recs[0].ServedModel != "anthropic/claude-sonnet-4.5" || recs[1].ServedModel != "gpt-4o-mini" {
+		t.Errorf("served models = %q, %q", recs[0].ServedModel, recs[1].ServedModel)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestModelRewriteBody_SplitReads(t *testing.T) {
+	in := "data: {\"model\":\"up/x\",\"a\":1}\n\ndata: {\"model\" : \"up/x\"}\n\ndata: {\"other\":\"up/x\"}\n"
+	body := newModelRewriteBody(io.NopCloser(strings.NewReader(in)), "up/x", "x")
+	var out []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := body.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	want := "data: {\"model\":\"x\",\"a\":1}\n\ndata: {\"model\" : \"x\"}\n\ndata: {\"other\":\"up/x\"}\n"
+	if string(out) != want {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestAlias_AnthropicMessagesBillServedModel(t *testing.T) {
+	// Translated: /v1/messages sent to an OpenAI-shaped upstream.
+	var seen []string
+	srv, reg, meter, upstream := setupTestServer(t, echoModelUpstream(t, &seen))
+	defer upstream.Close()
+	cfg := srv.GetConfig()
+	cfg.ModelAliases = map[string]string{"claude-fast": "vendor/claude-haiku"}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	app, _ := reg.Register("Claude Code", "", "")
+	serve(srv, "/v1/messages", app.Token, `{"model":"claude-fast","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`)
+	meter.Flush()
+	if recs := meter.RecentRecords(1); len(recs) != 1 || recs[0].Model != "claude-fast" || recs[0].ServedModel != "vendor/claude-haiku" {
+		t.Errorf("translated records = %+v, want claude-fast served as vendor/claude-haiku", recs)
+	}
+
+	// Native: /v1/messages passed through to an Anthropic upstream.
+	var native []string
+	srv2, token, meter2 := newAnthropicUpstreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		native = append(native, req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"msg_1","type":"message","model":"`+req.Model+`","content":[],"usage":{"input_tokens":4,"output_tokens":2}}`)
+	})
+	cfg2 := srv2.GetConfig()
+	cfg2.ModelAliases = map[string]string{"claude-fast": "claude-haiku-4-5"}
+	if err := srv2.SaveConfig(cfg2); err != nil {
+		t.Fatal(err)
+	}
+	serve(srv2, "/v1/messages", token, `{"model":"claude-fast","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`)
+	meter2.Flush()
+	if len(native) != 1 || native[0] != "claude-haiku-4-5" {
+		t.Fatalf("Anthropic upstream saw %v", native)
+	}
+	if recs := meter2.RecentRecords(1); len(recs) != 1 || recs[0].ServedModel != "claude-haiku-4-5" || recs[0].BilledModel() != "claude-haiku-4-5" {
+		t.Errorf("native records = %+v, want billed as claude-haiku-4-5", recs)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
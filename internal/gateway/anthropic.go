@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,7 +17,9 @@ import (
 
 // handleAnthropicMessages bridges Claude Code (Anthropic Messages API
 // /v1/messages) to whatever OpenAI-compatible upstream the gateway is
-// configured to talk to. Translation is performed in two phases:
+// configured to talk to (Anthropic-protocol relay endpoints are served
+// verbatim instead — see anthropic_upstream.go). Translation is
+// performed in two phases:
 //
 //	  request:  Anthropic JSON → OpenAI JSON  → upstream
 //	  response: upstream OpenAI → Anthropic   → client
@@ -30,6 +33,10 @@ func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request)
 	s.totalReqs.Add(1)
 
 	meta := getMeta(r)
+	mw := s.pipeline()
+	ex := &Exchange{Request: r, Meta: meta, Dialect: DialectAnthropic, Path: r.URL.Path}
+	w, finish := mw.wrap(ex, w)
+	defer finish()
 
 	rawBody, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
 	if err != nil {
@@ -38,18 +45,47 @@ func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	r.Body.Close()
+	ex.Body = rawBody
+	ex.Model = extractModelFromBody(rawBody)
+	ex.Header = make(http.Header)
+	copyRequestHeaders2(ex.Header, r)
+	ex.Header.Set("Content-Type", "application/json")
 
-	// DLP middleware — scan the raw Anthropic body before translation.
-	rawBody, dlpBlocked, dlpReason := s.applyDLPRequest(rawBody, r.URL.Path)
-	if dlpBlocked {
-		writeAnthropicError(w, http.StatusUnavailableForLegalReasons, "permission_error", dlpReason)
+	// Request phase — DLP scans the raw Anthropic body before
+	// translation, then the Budget Wall bails out before paying any
+	// tokens upstream.
+	if se := mw.request(ex); se != nil {
+		writeAnthropicError(w, se.Status, anthropicErrTypeForStatus(se.Status), se.Message)
+		s.recordError(meta, ex.Model, se.Message)
+		return
+	}
+	if s.serveCached(w, mw, ex) {
 		return
 	}
 
+	// Parse and translate Anthropic → OpenAI. Re-run on a pipeline retry
+	// so a rectified body is translated afresh.
 	var req translator.AnthropicRequest
-	if err := json.Unmarshal(rawBody, &req); err != nil {
-		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error",
-			"malformed Anthropic request body: "+err.Error())
+	var openAIBody []byte
+	translate := func() error {
+		req = translator.AnthropicRequest{}
+		if err := json.Unmarshal(ex.Body, &req); err != nil {
+			return &StageError{Status: http.StatusBadRequest, Code: "invalid_request_error",
+				Message: "malformed Anthropic request body: " + err.Error()}
+		}
+		openAIReq, err := translator.RequestToOpenAI(&req)
+		if err != nil {
+			return &StageError{Status: http.StatusBadRequest, Code: "invalid_request_error", Message: err.Error()}
+		}
+		if openAIBody, err = json.Marshal(openAIReq); err != nil {
+			return &StageError{Status: http.StatusInternalServerError, Code: "api_error",
+				Message: "translator marshal failed: " + err.Error()}
+		}
+		return nil
+	}
+	if err := translate(); err != nil {
+		se := err.(*StageError)
+		writeAnthropicError(w, se.Status, se.Code, se.Message)
 		return
 	}
 	model := req.Model
@@ -57,20 +93,11 @@ func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request)
 		meta.Model = model
 	}
 
-	// Budget Wall — bail out before paying any tokens upstream.
 	s.mu.Lock()
 	guard := s.guard
 	upstreamURL := s.cfg.UpstreamURL
-	userToken := s.cfg.UserToken
+	userToken := usableUserToken(s.cfg)
 	s.mu.Unlock()
-	if guard != nil {
-		if v := guard.Check(); !v.Allowed {
-			writeAnthropicError(w, http.StatusTooManyRequests, "rate_limit_error",
-				fmt.Sprintf("Lurus Switch budget wall: %s. Raise the limit or click 'reset session' in the Budget panel.", v.Reason))
-			s.recordError(meta, model, v.Reason)
-			return
-		}
-	}
 	if upstreamURL == "" {
 		writeAnthropicError(w, http.StatusServiceUnavailable, "api_error",
 			"Gateway upstream not configured. Open Lurus Switch settings to set your API endpoint.")
@@ -82,63 +109,83 @@ func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Translate Anthropic → OpenAI.
-	openAIReq, err := translator.RequestToOpenAI(&req)
-	if err != nil {
-		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
-		return
-	}
-	openAIBody, err := json.Marshal(openAIReq)
-	if err != nil {
-		writeAnthropicError(w, http.StatusInternalServerError, "api_error",
-			"translator marshal failed: "+err.Error())
-		return
-	}
-
 	// Forward via existing fallback chain — same retry / fallback chain
 	// the OpenAI-protocol path uses. Prefer the relay router's ordered
 	// chain when wired so /v1/messages traffic obeys the same routing
 	// rules as the OpenAI-protocol path.
 	normalizedURL := NormalizeChannelBaseURL(upstreamURL)
-	outHeaders := make(http.Header)
-	copyRequestHeaders2(outHeaders, r)
-	outHeaders.Set("Content-Type", "application/json")
-
-	chain, matchedBy, routerOK := s.buildChainFromRouter(
-		toolFromRequest(r),
-		model,
-		estimateTokens(openAIBody),
-		bodyHasTools(openAIBody),
-		userToken,
-	)
 
-	var resp *http.Response
-	var servedBy string
-	if routerOK {
-		if meta != nil {
-			meta.MatchedBy = matchedBy
+	var chain []FallbackEntry
+	var routerOK bool
+	var served FallbackEntry
+	attempts := 0
+	dispatch := func() (*http.Response, error) {
+		if attempts++; attempts > 1 {
+			if err := translate(); err != nil {
+				return nil, err
+			}
 		}
-		resp, servedBy, err = s.fallback.TryUpstreamChain(
-			r.Context(), "POST", "/v1/chat/completions", "",
-			openAIBody, outHeaders,
-			chain,
-		)
-	} else {
-		resp, servedBy, err = s.fallback.TryUpstream(
-			r.Context(), "POST", "/v1/chat/completions", "",
-			openAIBody, outHeaders,
-			normalizedURL, userToken,
+		var matchedBy string
+		chain, matchedBy, routerOK = s.buildChainFromRouter(
+			toolFromRequest(r),
+			model,
+			estimateTokens(openAIBody),
+			bodyHasTools(openAIBody),
+			meta,
+			userToken,
 		)
+		if routerOK {
+			attachEntryBodies(chain, translator.HasMediaBlocks(&req), func(opts translator.RequestOptions) (*translator.OpenAIRequest, error) {
+				return translator.RequestToOpenAIWithOptions(&req, opts)
+			})
+			// Anthropic-protocol endpoints take the client's body verbatim.
+			chainForAnthropicRequest(chain, ex.Body)
+		}
+
+		var resp *http.Response
+		var err error
+		if routerOK {
+			if meta != nil {
+				meta.MatchedBy = matchedBy
+			}
+			resp, served, err = s.fallback.TryUpstreamChain(
+				r.Context(), "POST", "/v1/chat/completions", "",
+				openAIBody, ex.Header,
+				chain,
+			)
+		} else {
+			resp, served, err = s.fallback.TryUpstream(
+				r.Context(), "POST", "/v1/chat/completions", "",
+				openAIBody, ex.Header,
+				normalizedURL, userToken,
+			)
+		}
+		return resp, err
 	}
+
+	resp, err := mw.roundTrip(ex, dispatch)
 	if err != nil {
-		writeAnthropicError(w, http.StatusBadGateway, "api_error",
-			fmt.Sprintf("upstream error: %v", err))
+		var se *StageError
+		if errors.As(err, &se) {
+			writeAnthropicError(w, se.Status, anthropicErrTypeForStatus(se.Status), se.Message)
+		} else {
+			writeAnthropicError(w, http.StatusBadGateway, "api_error",
+				fmt.Sprintf("upstream error: %v", err))
+		}
 		s.recordError(meta, model, err.Error())
 		return
 	}
 	defer resp.Body.Close()
 	if meta != nil {
-		meta.ServedBy = servedBy
+		meta.ServedBy = served.Name
+		meta.ServedModel = s.servedModel(served, model)
+		meta.ServedKeyID = served.KeyID
+	}
+
+	// A native Anthropic upstream needs no translation either way.
+	if routerOK && servedByAnthropic(served) {
+		s.passthroughAnthropic(w, resp, meta, model, guard)
+		return
 	}
 
 	// Forward 4xx/5xx from upstream back as Anthropic errors so Claude
@@ -151,15 +198,47 @@ func (s *Server) handleAnthropicMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	isStreaming := req.Stream &&
-		strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
-	if isStreaming {
+	if req.Stream && ex.Streaming {
 		s.streamAnthropic(w, resp, meta, model, &req, guard)
 	} else {
 		s.bufferedAnthropic(w, resp, meta, model, &req, guard)
 	}
 }
 
+// attachEntryBodies gives every entry whose capabilities call for a
+// different translation its own request body: vision-capable entries
+// get image / document blocks forwarded as OpenAI content parts (when
+// hasMedia), and entries with a configured reasoning style get the
+// thinking budget in their own field. Everything else keeps the
+// chain-wide body — stub notes for media, model-inferred reasoning —
+// which is also all the legacy cfg path ever sends. translate re-runs
+// the front door's request translation with the given options; bodies
+// are shared between entries with identical options.
+func attachEntryBodies(chain []FallbackEntry, hasMedia bool, translate func(translator.RequestOptions) (*translator.OpenAIRequest, error)) {
+	bodies := map[translator.RequestOptions][]byte{}
+	for i := range chain {
+		opts := translator.RequestOptions{
+			ForwardMedia: hasMedia && chain[i].Vision,
+			Reasoning:    translator.ReasoningStyle(chain[i].Reasoning),
+		}
+		if opts == (translator.RequestOptions{}) {
+			continue
+		}
+		body, ok := bodies[opts]
+		if !ok {
+			oreq, err := translate(opts)
+			if err != nil {
+				continue
+			}
+			if body, err = json.Marshal(oreq); err != nil {
+				continue
+			}
+			bodies[opts] = body
+		}
+		chain[i].Body = body
+	}
+}
+
 func (s *Server) bufferedAnthropic(
 	w http.ResponseWriter, resp *http.Response,
 	meta *RequestMeta, model string, req *translator.AnthropicRequest,
@@ -178,6 +257,7 @@ func (s *Server) bufferedAnthropic(
 		return
 	}
 	anthResp := translator.ResponseToAnthropic(&openAIResp, model)
+	openAIResp.Usage.CompletionTokensDetails.ReasoningTokens = translator.ReasoningTokens(&openAIResp)
 	out, _ := json.Marshal(anthResp)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -216,6 +296,13 @@ func (s *Server) streamAnthropic(
 		model, 0,
 	)
 	if err := tr.Run(resp.Body, w, flusher.Flush); err != nil {
+		var si *streamInterrupted
+		if errors.As(err, &si) {
+			inTok, outTok := tr.Usage()
+			writeStreamError(w, DialectAnthropic, si.Error())
+			s.recordInterrupted(meta, model, UsageFromResponse{PromptTokens: int64(inTok), CompletionTokens: int64(outTok)}, si)
+			return
+		}
 		// Stream connection broken — best we can do is log via metering.
 		s.recordError(meta, model, "anthropic stream: "+err.Error())
 		return
@@ -270,12 +357,18 @@ func (s *Server) recordAnthropicUsage(meta *RequestMeta, model string, u transla
 		LatencyMs:       time.Since(meta.StartTime).Milliseconds(),
 		StatusCode:      statusCode,
 		Timestamp:       time.Now(),
+		EmployeeID:      meta.OwnerEmployeeID,
+		CostCenter:      meta.CostCenter,
 		// Routing attribution — same dimensions the OpenAI-protocol path
 		// records, so dashboards bucket Claude Code traffic by upstream too.
-		ServedBy:  meta.ServedBy,
-		MatchedBy: meta.MatchedBy,
+		ServedBy:    meta.ServedBy,
+		MatchedBy:   meta.MatchedBy,
+		KeyID:       meta.ServedKeyID,
+		ServedModel: meta.ServedModel,
+		SessionID:   meta.SessionID,
 	}
 	s.meter.Record(rec)
+	s.spendTokens(rec)
 
 	// Mirror into the optional OTel recorder. Operation "messages" marks the
 	// Anthropic-input path so dashboards can split it from the OpenAI path.
@@ -317,6 +410,8 @@ func anthropicErrTypeForStatus(status int) string {
 	switch {
 	case status == 401, status == 403:
 		return "authentication_error"
+	case status == 451:
+		return "permission_error"
 	case status == 404:
 		return "not_found_error"
 	case status == 429:
@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"lurus-switch/internal/translator"
 )
 
 // TestAnthropic_BridgesToOpenAIUpstream is the smoke test for the
@@ -186,3 +188,25 @@ func TestAnthropic_RejectsMalformedBody(t *testing.T) {
 		t.Errorf("missing error.type: %s", body)
 	}
 }
+
+// TestAttachEntryBodies_ReasoningStylePerEntry checks that an endpoint
+// configured with a reasoning style gets its own translated body while
+// peers without one keep the chain-wide body.
+func TestAttachEntryBodies_ReasoningStylePerEntry(t *testing.T) {
+	req := &translator.AnthropicRequest{
+		Model:     "qwen3-235b-a22b",
+		MaxTokens: 16000,
+		Messages:  []translator.AnthropicMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		Thinking:  &translator.AnthropicThinking{Type: "enabled", BudgetTokens: 8000},
+	}
+	chain := []FallbackEntry{{URL: "http://plain"}, {URL: "http://qwen", Reasoning: "qwen"}}
+	attachEntryBodies(chain, false, func(opts translator.RequestOptions) (*translator.OpenAIRequest, error) {
+		return translator.RequestToOpenAIWithOptions(req, opts)
+	})
+	if chain[0].Body != nil {
+		t.Errorf("entry without a reasoning style should keep the chain body, got %s", chain[0].Body)
+	}
+	if !strings.Contains(string(chain[1].Body), `"enable_thinking":true,"thinking_budget":8000`) {
+		t.Errorf("qwen entry body = %s", chain[1].Body)
+	}
+}
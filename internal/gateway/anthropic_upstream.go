@@ -0,0 +1,319 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"lurus-switch/internal/budget"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/obs"
+	"lurus-switch/internal/relay"
+	"lurus-switch/internal/translator"
+)
+
+// Anthropic-protocol upstreams. A relay endpoint marked
+// relay.ProtocolAnthropic only serves POST /v1/messages, so the router
+// chain is adjusted per entry before FallbackChain walks it:
+//
+//	OpenAI /v1/chat/completions → translate body, Path=/v1/messages,
+//	                              translate the response back
+//	Anthropic /v1/messages      → forward the raw body verbatim
+//	anything else               → entry dropped (no Anthropic analog)
+
+// chainForOpenAIRequest adapts a router chain for an OpenAI-protocol
+// request on path. Returns ok=false when no entry can serve it.
+func chainForOpenAIRequest(chain []FallbackEntry, path string, body []byte) ([]FallbackEntry, bool) {
+	if !chainHasProtocol(chain, relay.ProtocolAnthropic) {
+		return chain, len(chain) > 0
+	}
+	var anthBody []byte
+	if path == "/v1/chat/completions" {
+		var oreq translator.OpenAIRequest
+		if json.Unmarshal(body, &oreq) == nil {
+			if areq, err := translator.RequestToAnthropic(&oreq); err == nil {
+				anthBody, _ = json.Marshal(areq)
+			}
+		}
+	}
+	out := make([]FallbackEntry, 0, len(chain))
+	for _, e := range chain {
+		if e.Protocol == relay.ProtocolAnthropic {
+			if anthBody == nil {
+				continue
+			}
+			e.Body = anthBody
+			e.Path = "/v1/messages"
+		}
+		out = append(out, e)
+	}
+	return out, len(out) > 0
+}
+
+// chainForAnthropicRequest points Anthropic-protocol entries at the
+// client's original /v1/messages body, bypassing translation.
+func chainForAnthropicRequest(chain []FallbackEntry, rawBody []byte) {
+	for i := range chain {
+		if chain[i].Protocol == relay.ProtocolAnthropic {
+			chain[i].Body = rawBody
+			chain[i].Path = "/v1/messages"
+		}
+	}
+}
+
+func chainHasProtocol(chain []FallbackEntry, p relay.Protocol) bool {
+	for _, e := range chain {
+		if e.Protocol == p {
+			return true
+		}
+	}
+	return false
+}
+
+// servedByAnthropic reports whether the served entry is an
+// Anthropic-protocol upstream.
+func servedByAnthropic(served FallbackEntry) bool {
+	return served.Protocol == relay.ProtocolAnthropic
+}
+
+// proxyFromAnthropic answers an OpenAI-protocol client from an
+// Anthropic-protocol upstream response: errors become OpenAI error
+// envelopes, SSE is re-framed as chat.completion.chunk, and buffered
+// bodies become a ChatCompletion.
+func (s *Server) proxyFromAnthropic(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string, reqBody []byte) {
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		msg := string(body)
+		var envelope translator.AnthropicError
+		if json.Unmarshal(body, &envelope) == nil && envelope.Error.Message != "" {
+			msg = envelope.Error.Message
+		}
+		writeOpenAIError(w, resp.StatusCode, "upstream_error", fmt.Sprintf("upstream %d: %s", resp.StatusCode, msg))
+		s.recordError(meta, model, msg)
+		return
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		if flusher, ok := w.(http.Flusher); ok {
+			var oreq translator.OpenAIRequest
+			_ = json.Unmarshal(reqBody, &oreq)
+			includeUsage := oreq.StreamOptions != nil && oreq.StreamOptions.IncludeUsage
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			flusher.Flush()
+
+			tr := translator.NewOpenAIStreamTranslator("chatcmpl-"+meta.RequestID, model, includeUsage)
+			if err := tr.Run(resp.Body, w, flusher.Flush); err != nil {
+				var si *streamInterrupted
+				if errors.As(err, &si) {
+					writeStreamError(w, DialectOpenAI, si.Error())
+					s.recordInterrupted(meta, model, usageFromAnthropic(tr.Usage()), si)
+					return
+				}
+				s.recordError(meta, model, "anthropic upstream stream: "+err.Error())
+				return
+			}
+			s.recordUsage(meta, model, usageFromAnthropic(tr.Usage()), http.StatusOK, true)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodySize))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "upstream_read_error", "failed to read upstream response")
+		return
+	}
+	var anthResp translator.AnthropicResponse
+	if err := json.Unmarshal(body, &anthResp); err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "upstream_error",
+			"decode upstream Anthropic response: "+err.Error())
+		return
+	}
+	out, _ := json.Marshal(translator.ResponseToOpenAI(&anthResp, model))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+
+	usage := usageFromAnthropic(anthResp.Usage)
+	usage.Model = anthResp.Model
+	s.recordUsage(meta, model, usage, http.StatusOK, false)
+}
+
+// passthroughAnthropic relays an Anthropic-protocol upstream response
+// to a /v1/messages client unchanged, reading usage on the way through.
+func (s *Server) passthroughAnthropic(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string, guard *budget.Guard) {
+	for _, key := range []string{"Content-Type", "Request-Id", "Anthropic-Ratelimit-Requests-Remaining", "Anthropic-Ratelimit-Tokens-Remaining"} {
+		if v := resp.Header.Get(key); v != "" {
+			w.Header().Set(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		w.Write(body)
+		s.recordError(meta, model, string(body))
+		return
+	}
+
+	var usage translator.AnthropicUsage
+	streaming := strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+	flusher, canFlush := w.(http.Flusher)
+	if streaming && canFlush {
+		var scanner anthropicSSEUsageScanner
+		buf := make([]byte, 4096)
+		for {
+			n, readErr := resp.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				flusher.Flush()
+				scanner.feed(buf[:n])
+			}
+			if readErr != nil {
+				var si *streamInterrupted
+				if errors.As(readErr, &si) {
+					writeStreamError(w, DialectAnthropic, si.Error())
+					s.recordInterrupted(meta, model, usageFromAnthropic(scanner.finish()), si)
+					return
+				}
+				break
+			}
+		}
+		usage = scanner.finish()
+	} else {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodySize))
+		if err != nil {
+			s.recordError(meta, model, "read upstream body: "+err.Error())
+			return
+		}
+		w.Write(body)
+		var anthResp translator.AnthropicResponse
+		if json.Unmarshal(body, &anthResp) == nil {
+			usage = anthResp.Usage
+		}
+	}
+
+	s.recordNativeAnthropicUsage(meta, model, usage, resp.StatusCode, streaming)
+	if guard != nil {
+		guard.RecordUsage(int64(usage.InputTokens+usage.CacheReadInputTokens+usage.CacheCreationInputTokens), int64(usage.OutputTokens))
+	}
+}
+
+// recordNativeAnthropicUsage books usage reported in Anthropic's own
+// shape. Unlike the OpenAI shape, cache tokens are NOT inside
+// input_tokens — they ADD to billed input (see translator.AnthropicUsage)
+// — so nothing is subtracted here.
+func (s *Server) recordNativeAnthropicUsage(meta *RequestMeta, model string, u translator.AnthropicUsage, statusCode int, streaming bool) {
+	if s.meter == nil || meta == nil {
+		return
+	}
+	rec := metering.Record{
+		ID:                meta.RequestID,
+		AppID:             meta.AppID,
+		Model:             model,
+		TokensIn:          int64(u.InputTokens),
+		TokensOut:         int64(u.OutputTokens),
+		CacheCreateTokens: int64(u.CacheCreationInputTokens),
+		CacheReadTokens:   int64(u.CacheReadInputTokens),
+		LatencyMs:         time.Since(meta.StartTime).Milliseconds(),
+		StatusCode:        statusCode,
+		Timestamp:         time.Now(),
+		EmployeeID:        meta.OwnerEmployeeID,
+		CostCenter:        meta.CostCenter,
+		ServedBy:          meta.ServedBy,
+		MatchedBy:         meta.MatchedBy,
+		KeyID:             meta.ServedKeyID,
+		ServedModel:       meta.ServedModel,
+		SessionID:         meta.SessionID,
+	}
+	s.meter.Record(rec)
+	s.spendTokens(rec)
+
+	s.observe(obs.RequestObservation{
+		Operation:  "messages",
+		Model:      model,
+		ServedBy:   meta.ServedBy,
+		MatchedBy:  meta.MatchedBy,
+		TokensIn:   rec.TokensIn,
+		TokensOut:  rec.TokensOut,
+		StartTime:  meta.StartTime,
+		LatencyMs:  rec.LatencyMs,
+		StatusCode: statusCode,
+		Streaming:  streaming,
+	})
+}
+
+// usageFromAnthropic converts Anthropic usage into the OpenAI-shape
+// UsageFromResponse recordUsage expects: both cache streams are folded
+// into PromptTokens and broken out as subsets, so recordUsage's
+// subtraction lands each on its own billing stream.
+func usageFromAnthropic(u translator.AnthropicUsage) UsageFromResponse {
+	prompt := int64(u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens)
+	return UsageFromResponse{
+		PromptTokens:      prompt,
+		CompletionTokens:  int64(u.OutputTokens),
+		TotalTokens:       prompt + int64(u.OutputTokens),
+		CachedTokens:      int64(u.CacheReadInputTokens),
+		CacheCreateTokens: int64(u.CacheCreationInputTokens),
+	}
+}
+
+// anthropicSSEUsageScanner is sseUsageScanner's counterpart for
+// Anthropic SSE: input / cache counts arrive on message_start, the
+// output count on message_delta. Line-buffered across Read boundaries
+// for the same reason.
+type anthropicSSEUsageScanner struct {
+	buf   []byte
+	usage translator.AnthropicUsage
+}
+
+func (s *anthropicSSEUsageScanner) feed(chunk []byte) {
+	s.buf = append(s.buf, chunk...)
+	if idx := bytes.LastIndexByte(s.buf, '\n'); idx >= 0 {
+		s.scan(s.buf[:idx+1])
+		s.buf = append(s.buf[:0], s.buf[idx+1:]...)
+	}
+	if len(s.buf) > maxSSELineBuf {
+		s.buf = s.buf[:0]
+	}
+}
+
+func (s *anthropicSSEUsageScanner) finish() translator.AnthropicUsage {
+	if len(s.buf) > 0 {
+		s.scan(s.buf)
+	}
+	return s.usage
+}
+
+func (s *anthropicSSEUsageScanner) scan(lines []byte) {
+	for _, line := range bytes.Split(lines, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		var ev translator.AnthropicStreamEvent
+		if json.Unmarshal(bytes.TrimSpace(data), &ev) != nil {
+			continue
+		}
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				s.usage.InputTokens = ev.Message.Usage.InputTokens
+				s.usage.CacheReadInputTokens = ev.Message.Usage.CacheReadInputTokens
+				s.usage.CacheCreationInputTokens = ev.Message.Usage.CacheCreationInputTokens
+			}
+		case "message_delta":
+			if ev.Usage != nil {
+				s.usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		}
+	}
+}
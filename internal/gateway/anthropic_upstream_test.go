@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/relay"
+)
+
+// newAnthropicUpstreamServer wires a gateway whose only relay endpoint
+// speaks the Anthropic Messages API.
+func newAnthropicUpstreamServer(t *testing.T, upstream http.HandlerFunc) (*Server, string, *metering.Store) {
+	t.Helper()
+	up := httptest.NewServer(upstream)
+	t.Cleanup(up.Close)
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, err := relay.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "anth", Name: "anth", URL: up.URL, APIKey: "sk-ant", Protocol: relay.ProtocolAnthropic,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router, err := relay.NewRouter(dir, store, relay.NewCircuitBreaker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://127.0.0.1:1"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	app, err := reg.Register("Codex", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, app.Token, meter
+}
+
+func TestProxy_ChatCompletionsServedByAnthropicUpstream(t *testing.T) {
+	var gotPath, gotKey, gotVersion, gotBody string
+	srv, token, meter := newAnthropicUpstreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-api-key")
+		gotVersion = r.Header.Get("anthropic-version")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+			"content":     []map[string]any{{"type": "text", "text": "pong"}},
+			"stop_reason": "end_turn",
+			"usage":       map[string]int{"input_tokens": 7, "output_tokens": 3, "cache_read_input_tokens": 20},
+		})
+	})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(
+		`{"model":"claude-sonnet-4-5","messages":[{"role":"system","content":"sys"},{"role":"user","content":"ping"}]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if gotPath != "/v1/messages" || gotKey != "sk-ant" || gotVersion == "" {
+		t.Errorf("upstream saw path=%q x-api-key=%q version=%q", gotPath, gotKey, gotVersion)
+	}
+	if !strings.Contains(gotBody, `"system":"sys"`) || !strings.Contains(gotBody, `"max_tokens"`) {
+		t.Errorf("upstream body not Anthropic-shaped: %s", gotBody)
+	}
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	if out["object"] != "chat.completion" || !strings.Contains(w.Body.String(), `"content":"pong"`) {
+		t.Errorf("client should get a ChatCompletion: %s", w.Body.String())
+	}
+
+	meter.Flush()
+	recs := meter.RecentRecords(1)
+	if len(recs) != 1 {
+		t.Fatalf("want 1 metering record, got %d", len(recs))
+	}
+	if recs[0].TokensIn != 7 || recs[0].CacheReadTokens != 20 || recs[0].TokensOut != 3 {
+		t.Errorf("record = in:%d cacheRead:%d out:%d, want 7/20/3", recs[0].TokensIn, recs[0].CacheReadTokens, recs[0].TokensOut)
+	}
+}
+
+func TestProxy_StreamingChatFromAnthropicUpstream(t *testing.T) {
+	srv, token, meter := newAnthropicUpstreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":11,\"output_tokens\":1}}}\n\n")
+		io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+		io.WriteString(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":6}}\n\n")
+		io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(
+		`{"model":"claude-sonnet-4-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	got := w.Body.String()
+	for _, want := range []string{`"object":"chat.completion.chunk"`, `"content":"Hi"`, `"finish_reason":"stop"`, `"prompt_tokens":11`, "data: [DONE]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stream missing %q:\n%s", want, got)
+		}
+	}
+	if s := meter.TodaySummary(); s.TokensIn != 11 || s.TokensOut != 6 {
+		t.Errorf("metered in:%d out:%d, want 11/6", s.TokensIn, s.TokensOut)
+	}
+}
+
+func TestAnthropic_MessagesPassthroughToAnthropicUpstream(t *testing.T) {
+	const upstreamResp = `{"id":"msg_9","type":"message","role":"assistant","model":"claude-x","content":[{"type":"text","text":"native"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":2,"cache_creation_input_tokens":30}}`
+	var gotBody string
+	srv, token, meter := newAnthropicUpstreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, upstreamResp)
+	})
+
+	reqBody := `{"model":"claude-x","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(reqBody))
+	req.Header.Set("Authorization", "Bearer "+token)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if gotBody != reqBody {
+		t.Errorf("Anthropic upstream should get the client body verbatim, got %s", gotBody)
+	}
+	if w.Body.String() != upstreamResp {
+		t.Errorf("client should get the upstream body verbatim, got %s", w.Body.String())
+	}
+	meter.Flush()
+	recs := meter.RecentRecords(1)
+	if len(recs) != 1 || recs[0].TokensIn != 4 || recs[0].CacheCreateTokens != 30 {
+		t.Errorf("records = %+v, want TokensIn 4 + CacheCreate 30 (additive)", recs)
+	}
+}
+
+func TestChainForOpenAIRequest_DropsAnthropicForNonChatPaths(t *testing.T) {
+	chain, ok := chainForOpenAIRequest([]FallbackEntry{
+		{Name: "anth", URL: "http://a", Protocol: relay.ProtocolAnthropic},
+		{Name: "oai", URL: "http://b"},
+	}, "/v1/embeddings", []byte(`{"model":"e","input":"x"}`))
+	if !ok || len(chain) != 1 || chain[0].Name != "oai" {
+		t.Fatalf("chain = %+v, want only the OpenAI entry", chain)
+	}
+
+	chain, ok = chainForOpenAIRequest([]FallbackEntry{
+		{Name: "anth", URL: "http://a", Protocol: relay.ProtocolAnthropic},
+	}, "/v1/models", nil)
+	if ok || len(chain) != 0 {
+		t.Fatalf("an all-Anthropic chain can't serve /v1/models, got %+v", chain)
+	}
+}
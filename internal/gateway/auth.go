@@ -17,6 +17,9 @@ const metaKey contextKey = "request_meta"
 func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := extractBearerToken(r)
+		if token == "" {
+			token = extractGoogAPIKey(r)
+		}
 		if token == "" {
 			writeOpenAIError(w, http.StatusUnauthorized, "missing_api_key",
 				"Authorization header with Bearer token is required. Get your token from Lurus Switch.")
@@ -31,6 +34,23 @@ func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Team sharing: the LAN listener only takes member tokens, and
+		// member tokens only work from their allowed addresses. Looked
+		// up per request so revocations apply immediately.
+		if reason := s.checkShareAccess(r, appID); reason != "" {
+			writeOpenAIError(w, http.StatusForbidden, "access_denied", reason)
+			return
+		}
+
+		// Enforce the app's rate limits before any upstream work. The
+		// slot is held until the handler returns, streams included.
+		release, retryAfter, reason := s.limiter.admit(appID, s.limitsFor(appID))
+		if release == nil {
+			writeRateLimited(w, r, retryAfter, reason)
+			return
+		}
+		defer release()
+
 		// Update last-seen (non-blocking).
 		go s.registry.TouchLastSeen(appID)
 
@@ -111,6 +131,20 @@ func extractBearerToken(r *http.Request) string {
 	return strings.TrimSpace(auth[len(prefix):])
 }
 
+// extractGoogAPIKey gets the token the way google-genai clients (Gemini
+// CLI) send it: the x-goog-api-key header, or the legacy ?key= query
+// parameter. The query form is only honored on Gemini routes — the
+// OpenAI catch-all forwards RawQuery upstream, which would leak it.
+func extractGoogAPIKey(r *http.Request) string {
+	if k := strings.TrimSpace(r.Header.Get("X-Goog-Api-Key")); k != "" {
+		return k
+	}
+	if strings.HasPrefix(r.URL.Path, geminiModelsPrefix) {
+		return strings.TrimSpace(r.URL.Query().Get("key"))
+	}
+	return ""
+}
+
 // writeOpenAIError writes an error response in OpenAI API format.
 func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
 	w.Header().Set("Content-Type", "application/json")
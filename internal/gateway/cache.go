@@ -0,0 +1,430 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/obs"
+)
+
+// Response cache. CI jobs and agent retries re-send byte-identical
+// temperature-0 requests; with Config.Cache enabled the gateway answers
+// the repeats from disk instead of paying the upstream again.
+//
+// Only requests that ask for deterministic output (temperature 0) are
+// cached. The key hashes the app, the model, the front-door path and the
+// canonicalized request body, so two apps never share an entry and
+// reordered JSON keys still hit. What is stored is the client-bound
+// bytes as the dlp stage left them — secrets are never written to disk —
+// and only complete, successful responses are kept: a stream cut short
+// or an error envelope is dropped.
+//
+// A hit is replayed through the response phase like any upstream answer
+// (SSE streams write by write, as recorded) and booked with
+// CachedHit=true and zero tokens, so it costs nothing and does not count
+// against the Budget Wall. Clients opt out per request with
+// "X-Switch-Cache: bypass"; replays carry "X-Switch-Cache: hit".
+
+const (
+	cacheDirName    = "gateway-cache"
+	cacheHeader     = "X-Switch-Cache"
+	cacheBypass     = "bypass"
+	cacheServedBy   = "cache"
+	defaultCacheTTL = 24 * time.Hour
+
+	defaultCacheMaxEntryBytes = 4 << 20
+	defaultCacheMaxTotalBytes = 256 << 20
+)
+
+// CacheConfig configures the opt-in response cache. Zero values select
+// the defaults (24h TTL, 4 MB per entry, 256 MB in total).
+type CacheConfig struct {
+	Enabled       bool  `json:"enabled"`
+	TTLSeconds    int   `json:"ttlSeconds,omitempty"`
+	MaxEntryBytes int64 `json:"maxEntryBytes,omitempty"`
+	MaxTotalBytes int64 `json:"maxTotalBytes,omitempty"`
+}
+
+func (c CacheConfig) ttl() time.Duration {
+	if c.TTLSeconds <= 0 {
+		return defaultCacheTTL
+	}
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+func (c CacheConfig) maxEntryBytes() int64 {
+	if c.MaxEntryBytes <= 0 {
+		return defaultCacheMaxEntryBytes
+	}
+	return c.MaxEntryBytes
+}
+
+func (c CacheConfig) maxTotalBytes() int64 {
+	if c.MaxTotalBytes <= 0 {
+		return defaultCacheMaxTotalBytes
+	}
+	return c.MaxTotalBytes
+}
+
+// cacheEntry is one cached response as stored on disk.
+type cacheEntry struct {
+	AppID     string    `json:"appId"`
+	Model     string    `json:"model"`
+	Path      string    `json:"path"`
+	Streaming bool      `json:"streaming"`
+	Created   time.Time `json:"created"`
+	Chunks    [][]byte  `json:"chunks"` // client-bound writes, in order
+}
+
+func (e *cacheEntry) contentType() string {
+	if e.Streaming {
+		return "text/event-stream"
+	}
+	return "application/json"
+}
+
+// responseCache is the on-disk store: one JSON file per key.
+type responseCache struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newResponseCache(dir string) *responseCache {
+	return &responseCache{dir: dir}
+}
+
+func (c *responseCache) file(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get returns the entry for key, or nil when absent or older than ttl.
+func (c *responseCache) get(key string, ttl time.Duration) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := os.ReadFile(c.file(key))
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || time.Since(e.Created) > ttl {
+		os.Remove(c.file(key))
+		return nil
+	}
+	return &e
+}
+
+// put stores e under key, then drops expired entries and evicts the
+// oldest until the directory fits maxTotal.
+func (c *responseCache) put(key string, e *cacheEntry, cfg CacheConfig) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	tmp := c.file(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.file(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	c.pruneLocked(cfg.ttl(), cfg.maxTotalBytes())
+	return nil
+}
+
+func (c *responseCache) pruneLocked(ttl time.Duration, maxTotal int64) {
+	dirents, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		path string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	var total int64
+	for _, de := range dirents {
+		info, err := de.Info()
+		if err != nil || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(c.dir, de.Name())
+		if time.Since(info.ModTime()) > ttl {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, file{path, info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	for _, f := range files {
+		if total <= maxTotal {
+			break
+		}
+		os.Remove(f.path)
+		total -= f.size
+	}
+}
+
+// cacheState is the cache stage's per-request state.
+type cacheState struct {
+	cfg   CacheConfig
+	key   string
+	entry *cacheEntry // set on a hit
+
+	recording bool
+	size      int64
+	chunks    [][]byte
+}
+
+// cacheStage looks requests up in the response cache and records
+// cacheable responses. It runs right after dlp, so it keys on the masked
+// request and records the masked response.
+type cacheStage struct {
+	MiddlewareBase
+	s *Server
+}
+
+func (cacheStage) Name() string { return "cache" }
+
+func (c cacheStage) OnRequest(ex *Exchange) error {
+	c.s.mu.Lock()
+	cfg := c.s.cfg.Cache
+	store := c.s.cache
+	c.s.mu.Unlock()
+	if !cfg.Enabled || store == nil || ex.Request == nil ||
+		strings.EqualFold(ex.Request.Header.Get(cacheHeader), cacheBypass) ||
+		!deterministicRequest(ex.Dialect, ex.Body) {
+		return nil
+	}
+	key := cacheKey(ex)
+	if key == "" {
+		return nil
+	}
+	ex.cache = &cacheState{cfg: cfg, key: key, entry: store.get(key, cfg.ttl())}
+	return nil
+}
+
+func (cacheStage) OnResponse(ex *Exchange, resp *http.Response) error {
+	if ex.cache != nil && ex.cache.entry == nil {
+		ex.cache.recording = resp.StatusCode == http.StatusOK
+		ex.cache.size, ex.cache.chunks = 0, nil
+	}
+	return nil
+}
+
+func (cacheStage) OnChunk(ex *Exchange, chunk []byte) ([]byte, error) {
+	st := ex.cache
+	if st == nil || !st.recording || len(chunk) == 0 {
+		return chunk, nil
+	}
+	st.size += int64(len(chunk))
+	if st.size > st.cfg.maxEntryBytes() {
+		st.recording, st.chunks = false, nil
+		return chunk, nil
+	}
+	st.chunks = append(st.chunks, append([]byte(nil), chunk...))
+	return chunk, nil
+}
+
+func (c cacheStage) FinishChunks(ex *Exchange) ([]byte, error) {
+	st := ex.cache
+	if st == nil || !st.recording || len(st.chunks) == 0 {
+		return nil, nil
+	}
+	st.recording = false
+	body := bytes.Join(st.chunks, nil)
+	streaming := looksLikeSSE(body)
+	if !completeResponse(ex.Dialect, streaming, body) {
+		return nil, nil
+	}
+	c.s.mu.Lock()
+	store := c.s.cache
+	c.s.mu.Unlock()
+	if store == nil {
+		return nil, nil
+	}
+	appID := ""
+	if ex.Meta != nil {
+		appID = ex.Meta.AppID
+	}
+	_ = store.put(st.key, &cacheEntry{
+		AppID:     appID,
+		Model:     ex.Model,
+		Path:      ex.Path,
+		Streaming: streaming,
+		Created:   time.Now(),
+		Chunks:    st.chunks,
+	}, st.cfg)
+	return nil, nil
+}
+
+// cacheHit reports whether the cache stage found ex in the cache.
+func (ex *Exchange) cacheHit() bool {
+	return ex.cache != nil && ex.cache.entry != nil
+}
+
+// serveCached answers ex from the response cache when the cache stage
+// found a hit. The cached bytes go through the response phase and the
+// chunk hooks like an upstream answer would. Returns false on a miss.
+func (s *Server) serveCached(w http.ResponseWriter, mw pipeline, ex *Exchange) bool {
+	if !ex.cacheHit() {
+		return false
+	}
+	e := ex.cache.entry
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {e.contentType()}},
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+	}
+	if err := mw.response(ex, resp); err != nil {
+		ex.cache.entry = nil
+		return false
+	}
+
+	w.Header().Set("Content-Type", e.contentType())
+	w.Header().Set(cacheHeader, "hit")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	for _, chunk := range e.Chunks {
+		if _, err := w.Write(chunk); err != nil {
+			s.recordError(ex.Meta, ex.Model, "cache replay: "+err.Error())
+			return true
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	s.recordCacheHit(ex.Meta, ex.Model, e.Streaming)
+	return true
+}
+
+// recordCacheHit books a cache hit: no tokens, so no cost and nothing
+// fed to the Budget Wall.
+func (s *Server) recordCacheHit(meta *RequestMeta, model string, streaming bool) {
+	if s.meter == nil || meta == nil {
+		return
+	}
+	meta.ServedBy = cacheServedBy
+	rec := metering.Record{
+		ID:         meta.RequestID,
+		AppID:      meta.AppID,
+		Model:      model,
+		LatencyMs:  time.Since(meta.StartTime).Milliseconds(),
+		CachedHit:  true,
+		StatusCode: http.StatusOK,
+		Timestamp:  time.Now(),
+		EmployeeID: meta.OwnerEmployeeID,
+		CostCenter: meta.CostCenter,
+		ServedBy:   cacheServedBy,
+	}
+	s.meter.Record(rec)
+	s.observe(obs.RequestObservation{
+		Operation:  "chat",
+		Model:      model,
+		ServedBy:   cacheServedBy,
+		StartTime:  meta.StartTime,
+		LatencyMs:  rec.LatencyMs,
+		StatusCode: http.StatusOK,
+		Streaming:  streaming,
+	})
+}
+
+// deterministicRequest reports whether body explicitly asks for
+// temperature 0 — the only requests worth replaying.
+func deterministicRequest(d Dialect, body []byte) bool {
+	var probe struct {
+		Temperature      *float64 `json:"temperature"`
+		GenerationConfig *struct {
+			Temperature *float64 `json:"temperature"`
+		} `json:"generationConfig"`
+	}
+	if json.Unmarshal(body, &probe) != nil {
+		return false
+	}
+	t := probe.Temperature
+	if d == DialectGemini {
+		t = nil
+		if probe.GenerationConfig != nil {
+			t = probe.GenerationConfig.Temperature
+		}
+	}
+	return t != nil && *t == 0
+}
+
+// cacheKey hashes app, model, path (plus Gemini's alt) and the request
+// body re-marshalled with sorted keys. Empty when the body isn't JSON.
+func cacheKey(ex *Exchange) string {
+	dec := json.NewDecoder(bytes.NewReader(ex.Body))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return ""
+	}
+	canon, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	appID := ""
+	if ex.Meta != nil {
+		appID = ex.Meta.AppID
+	}
+	h := sha256.New()
+	for _, part := range []string{appID, ex.Model, ex.Path, ex.Request.URL.Query().Get("alt")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(canon)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func looksLikeSSE(body []byte) bool {
+	b := bytes.TrimSpace(body)
+	return bytes.HasPrefix(b, []byte("data:")) || bytes.HasPrefix(b, []byte("event:"))
+}
+
+// completeResponse reports whether body is a whole, successful response:
+// a stream that reached its terminal event, or JSON that parses and is
+// not an error envelope.
+func completeResponse(d Dialect, streaming bool, body []byte) bool {
+	if streaming {
+		switch d {
+		case DialectAnthropic:
+			return bytes.Contains(body, []byte("event: message_stop"))
+		case DialectGemini:
+			return bytes.Contains(body, []byte(`"finishReason"`))
+		case DialectResponses:
+			return bytes.Contains(body, []byte("response.completed"))
+		default:
+			return bytes.Contains(body, []byte("data: [DONE]"))
+		}
+	}
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		return false
+	}
+	var env struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if body[0] == '{' && json.Unmarshal(body, &env) == nil && len(env.Error) > 0 && string(env.Error) != "null" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serveWithHeader(srv *Server, path, token, body, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestCache_BufferedHitIsFree(t *testing.T) {
+	var calls int32
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","choices":[{"message":{"content":"4"}}],"usage":{"prompt_tokens":10,"completion_tokens":1,"total_tokens":11}}`)
+	})
+	defer upstream.Close()
+	srv.cfg.Cache.Enabled = true
+	app, _ := reg.Register("CI", "", "")
+
+	first := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"2+2"}]}`)
+	// Same request with keys reordered: still a hit.
+	second := serve(srv, "/v1/chat/completions", app.Token, `{"messages":[{"content":"2+2","role":"user"}],"temperature":0,"model":"gpt-4o"}`)
+
+	if calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1", calls)
+	}
+	if second.Code != http.StatusOK || second.Header().Get(cacheHeader) != "hit" {
+		t.Fatalf("second: status=%d cache=%q", second.Code, second.Header().Get(cacheHeader))
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("replayed body differs:\n%s\n%s", first.Body.String(), second.Body.String())
+	}
+	s := meter.TodaySummary()
+	if s.TotalCalls != 2 || s.CacheHits != 1 || s.TokensIn != 10 || s.TokensOut != 1 {
+		t.Errorf("summary = %+v, want the hit booked with zero tokens", s)
+	}
+}
+
+func TestCache_StreamReplayedOnAnthropicFrontDoor(t *testing.T) {
+	var calls int32
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+	defer upstream.Close()
+	srv.cfg.Cache.Enabled = true
+	app, _ := reg.Register("Claude Code", "", "")
+
+	body := `{"model":"deepseek-chat","max_tokens":64,"temperature":0,"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	first := serve(srv, "/v1/messages", app.Token, body)
+	second := serve(srv, "/v1/messages", app.Token, body)
+
+	if calls != 1 {
+		t.Fatalf("upstream calls = %d, want 1", calls)
+	}
+	if ct := second.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("replay content type = %q", ct)
+	}
+	if second.Body.String() != first.Body.String() || !strings.Contains(second.Body.String(), "event: message_stop") {
+		t.Errorf("replayed stream differs:\n%s\n%s", first.Body.String(), second.Body.String())
+	}
+}
+
+func TestCache_SkipsBypassNonDeterministicAndOtherApps(t *testing.T) {
+	var calls int32
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","choices":[{"message":{"content":"ok"}}]}`)
+	})
+	defer upstream.Close()
+	srv.cfg.Cache.Enabled = true
+	a, _ := reg.Register("A", "", "")
+	b, _ := reg.Register("B", "", "")
+
+	det := `{"model":"gpt-4o","temperature":0,"messages":[]}`
+	serve(srv, "/v1/chat/completions", a.Token, det)
+	if w := serveWithHeader(srv, "/v1/chat/completions", a.Token, det, cacheHeader, "bypass"); w.Header().Get(cacheHeader) != "" {
+		t.Error("bypass header must skip the cache")
+	}
+	serve(srv, "/v1/chat/completions", b.Token, det)
+	serve(srv, "/v1/chat/completions", a.Token, `{"model":"gpt-4o","temperature":0.7,"messages":[]}`)
+	serve(srv, "/v1/chat/completions", a.Token, `{"model":"gpt-4o","temperature":0.7,"messages":[]}`)
+
+	if calls != 5 {
+		t.Errorf("upstream calls = %d, want 5", calls)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestCache_DoesNotStoreErrorsOrTruncatedStreams(t *testing.T) {
+	if completeResponse(DialectOpenAI, true, []byte("data: {\"choices\":[]}\n\n")) {
+		t.Error("a stream without [DONE] is incomplete")
+	}
+	if completeResponse(DialectAnthropic, false, []byte(`{"type":"error","error":{"type":"api_error"}}`)) {
+		t.Error("an error envelope must not be cached")
+	}
+	if !completeResponse(DialectGemini, false, []byte(`[{"candidates":[]}]`)) {
+		t.Error("a Gemini JSON-array stream is a complete document")
+	}
+}
+
+func TestResponseCache_TTLAndSizeCap(t *testing.T) {
+	c := newResponseCache(t.TempDir())
+	cfg := CacheConfig{Enabled: true, MaxTotalBytes: 1 << 20}
+	entry := &cacheEntry{Created: time.Now(), Chunks: [][]byte{[]byte(strings.Repeat("x", 100))}}
+
+	for i, key := range []string{"a", "b"} {
+		if err := c.put(key, entry, cfg); err != nil {
+			t.Fatal(err)
+		}
+		mod := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.file(key), mod, mod)
+	}
+	info, _ := os.Stat(c.file("a"))
+	cfg.MaxTotalBytes = 2*info.Size() + 1
+	if err := c.put("c", entry, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.file("a")); !os.IsNotExist(err) {
+		t.Error("oldest entry should have been evicted to fit the size cap")
+	}
+	if c.get("b", time.Hour) == nil || c.get("c", time.Hour) == nil {
+		t.Error("newer entries missing")
+	}
+	if c.get("c", time.Nanosecond) != nil {
+		t.Error("expired entry returned")
+	}
+	if matches, _ := filepath.Glob(c.file("c")); len(matches) != 0 {
+		t.Error("expired entry should be removed on lookup")
+	}
+}
@@ -0,0 +1,639 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/dlp"
+	"lurus-switch/internal/relay"
+)
+
+// Traffic capture. With Config.Capture enabled, every upstream exchange
+// TryUpstreamChain runs is recorded into a size-bounded in-memory ring:
+// the request exactly as sent upstream (after DLP redaction, the
+// rectifier and model aliasing), the chain the router built, each
+// attempt's outcome, and the upstream response — for streams, the
+// events as spliced together by failoverStream. Credentials never enter
+// a capture: only the proxied header allow-list is kept, minus auth.
+// With a DLP scanner set, the response body is masked as the dlp stage
+// masks the client's copy, so a capture holds no secret the client
+// didn't see either.
+//
+// Captures are keyed by RequestMeta.RequestID; a rectifier retry makes
+// a second capture under the same ID. ExportCaptures writes them as HAR
+// or JSONL and ReplayCapture re-sends one to a chosen relay endpoint.
+
+const (
+	defaultCaptureEntries   = 200
+	defaultCaptureBodyBytes = 64 << 10
+)
+
+// CaptureConfig turns the capture ring on and bounds it. Off by default:
+// captured bodies hold prompts and completions.
+type CaptureConfig struct {
+	Enabled      bool `json:"enabled"`
+	MaxEntries   int  `json:"maxEntries,omitempty"`   // 0 = 200
+	MaxBodyBytes int  `json:"maxBodyBytes,omitempty"` // per body; 0 = 64 KiB
+}
+
+func (c CaptureConfig) maxEntries() int {
+	if c.MaxEntries <= 0 {
+		return defaultCaptureEntries
+	}
+	return c.MaxEntries
+}
+
+func (c CaptureConfig) maxBodyBytes() int {
+	if c.MaxBodyBytes <= 0 {
+		return defaultCaptureBodyBytes
+	}
+	return c.MaxBodyBytes
+}
+
+// CaptureAttempt is one upstream attempt of a captured request.
+type CaptureAttempt struct {
+	Endpoint   string `json:"endpoint"`
+	EndpointID string `json:"endpointId,omitempty"` // relay endpoint ID; empty for the default upstream
+	URL        string `json:"url"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+}
+
+// Capture is one recorded upstream exchange. The request fields describe
+// what went to the serving entry (or, when every attempt failed, the
+// last one tried).
+type Capture struct {
+	Seq       int64     `json:"seq"`
+	RequestID string    `json:"requestId"`
+	AppID     string    `json:"appId,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Time      time.Time `json:"time"`
+
+	Method     string           `json:"method"`
+	Path       string           `json:"path"`
+	Query      string           `json:"query,omitempty"`
+	Chain      []string         `json:"chain"`
+	Attempts   []CaptureAttempt `json:"attempts"`
+	ServedBy   string           `json:"servedBy,omitempty"`
+	ServedByID string           `json:"servedById,omitempty"` // relay endpoint ID; names need not be unique
+	URL        string           `json:"url,omitempty"`
+	Protocol   relay.Protocol   `json:"protocol,omitempty"`
+
+	RequestHeaders   map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody      string            `json:"requestBody,omitempty"`
+	RequestTruncated bool              `json:"requestTruncated,omitempty"`
+
+	Status            int               `json:"status,omitempty"`
+	ResponseHeaders   map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody      string            `json:"responseBody,omitempty"`
+	ResponseTruncated bool              `json:"responseTruncated,omitempty"`
+	Streaming         bool              `json:"streaming,omitempty"`
+
+	// Error is why the exchange failed: every attempt failing, or the
+	// response stream breaking.
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// capture is a Capture being recorded. Hedge legs record attempts
+// concurrently, so everything goes through mu.
+type capture struct {
+	mu      sync.Mutex
+	c       Capture
+	maxBody int
+	ring    *captureRing
+	dlp     *dlp.Scanner // masks the response body; nil = DLP off
+	once    sync.Once
+	sent    map[string]capturedRequest // by captureKey
+	last    string                     // captureKey of the latest attempt
+}
+
+// captureKey identifies entry among a chain's attempts: its relay
+// endpoint ID, or its name for the ID-less default upstream.
+func captureKey(entry FallbackEntry) string {
+	if entry.ID != "" {
+		return entry.ID
+	}
+	return entry.Name
+}
+
+// capturedRequest is what one attempt sent upstream.
+type capturedRequest struct {
+	path, url string
+	protocol  relay.Protocol
+	headers   map[string]string
+	body      string
+	truncated bool
+}
+
+// captureRing holds the most recent captures.
+type captureRing struct {
+	mu      sync.Mutex
+	cfg     CaptureConfig
+	dlp     *dlp.Scanner
+	entries []*capture // oldest first
+	seq     int64
+}
+
+func newCaptureRing() *captureRing { return &captureRing{} }
+
+func (r *captureRing) configure(cfg CaptureConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	if !cfg.Enabled {
+		r.entries = nil
+		return
+	}
+	if n := cfg.maxEntries(); len(r.entries) > n {
+		r.entries = append([]*capture(nil), r.entries[len(r.entries)-n:]...)
+	}
+}
+
+func (r *captureRing) setDLP(scanner *dlp.Scanner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dlp = scanner
+}
+
+// begin starts a capture for a chain run, or returns nil when capture
+// is off. The nil *capture's methods are no-ops.
+func (r *captureRing) begin(ctx context.Context, method, path, query string, chain []FallbackEntry) *capture {
+	r.mu.Lock()
+	cfg, scanner := r.cfg, r.dlp
+	r.mu.Unlock()
+	if !cfg.Enabled {
+		return nil
+	}
+	c := &capture{c: Capture{Time: time.Now(), Method: method, Path: path, Query: query}, maxBody: cfg.maxBodyBytes(), ring: r, dlp: scanner}
+	if meta, _ := ctx.Value(metaKey).(*RequestMeta); meta != nil {
+		c.c.RequestID, c.c.AppID, c.c.Model = meta.RequestID, meta.AppID, meta.Model
+	}
+	for _, e := range chain {
+		if e.URL != "" {
+			c.c.Chain = append(c.c.Chain, e.Name)
+		}
+	}
+	return c
+}
+
+func (r *captureRing) add(c *capture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.cfg.Enabled {
+		return
+	}
+	r.seq++
+	c.mu.Lock()
+	c.c.Seq = r.seq
+	c.mu.Unlock()
+	r.entries = append(r.entries, c)
+	if n := r.cfg.maxEntries(); len(r.entries) > n {
+		r.entries = r.entries[len(r.entries)-n:]
+	}
+}
+
+// list returns copies of the captures, newest first.
+func (r *captureRing) list() []Capture {
+	r.mu.Lock()
+	entries := append([]*capture(nil), r.entries...)
+	r.mu.Unlock()
+	out := make([]Capture, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		out = append(out, entries[i].snapshot())
+	}
+	return out
+}
+
+// attempt records one attempt and the request it sent.
+func (c *capture) attempt(entry FallbackEntry, path string, body []byte, headers http.Header, resp *http.Response, err error, latencyMs int64) {
+	if c == nil {
+		return
+	}
+	a := CaptureAttempt{Endpoint: entry.Name, EndpointID: entry.ID, URL: strings.TrimRight(entry.URL, "/") + path, LatencyMs: latencyMs}
+	if err != nil {
+		a.Error = err.Error()
+	} else if resp != nil {
+		a.Status = resp.StatusCode
+	}
+	req := capturedRequest{path: path, url: a.URL, protocol: entry.Protocol, headers: captureHeaders(headers)}
+	req.body, req.truncated = clip(body, c.maxBody)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.Attempts = append(c.c.Attempts, a)
+	if c.sent == nil {
+		c.sent = map[string]capturedRequest{}
+	}
+	c.sent[captureKey(entry)] = req
+	c.last = captureKey(entry)
+}
+
+// served marks entry's response as the one handed to the client —
+// the hedge winner, or the entry a broken stream resumed on.
+func (c *capture) served(entry FallbackEntry, resp *http.Response) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.c.ServedBy, c.c.ServedByID = entry.Name, entry.ID
+	c.useRequest(captureKey(entry))
+	c.c.Status = resp.StatusCode
+	c.c.ResponseHeaders = captureHeaders(resp.Header)
+	c.c.Streaming = isEventStream(resp)
+}
+
+// useRequest makes the request sent to the entry with key the
+// capture's. Callers hold c.mu.
+func (c *capture) useRequest(key string) {
+	req, ok := c.sent[key]
+	if !ok {
+		return
+	}
+	c.c.Path, c.c.URL, c.c.Protocol = req.path, req.url, req.protocol
+	c.c.RequestHeaders = req.headers
+	c.c.RequestBody, c.c.RequestTruncated = req.body, req.truncated
+}
+
+// fail files a capture whose chain produced no response.
+func (c *capture) fail(err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.useRequest(c.last)
+	c.mu.Unlock()
+	c.finish(nil, err)
+}
+
+// wrap tees a served response body into the capture, filing it when
+// the body is closed.
+func (c *capture) wrap(body io.ReadCloser) io.ReadCloser {
+	if c == nil {
+		return body
+	}
+	return &captureBody{ReadCloser: body, c: c}
+}
+
+func (c *capture) finish(body []byte, err error) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		if body != nil {
+			c.c.ResponseBody, c.c.ResponseTruncated = clip(c.redact(body), c.maxBody)
+		}
+		if err != nil {
+			c.c.Error = err.Error()
+		}
+		c.c.DurationMs = time.Since(c.c.Time).Milliseconds()
+		c.mu.Unlock()
+		c.ring.add(c)
+	})
+}
+
+// redact masks DLP hits in a response body. The dlp stage has already
+// recorded them off the client's copy, so nothing is recorded here.
+// Callers hold c.mu.
+func (c *capture) redact(body []byte) []byte {
+	if c.dlp == nil || len(body) == 0 {
+		return body
+	}
+	d := &dlpResponseScanner{scanner: c.dlp, dialect: captureDialect(c.c.Protocol, c.c.Path), path: c.c.Path, sse: c.c.Streaming, quiet: true}
+	return append(d.feed(body), d.finish()...)
+}
+
+// captureDialect is the shape of the upstream's response to a request
+// sent to path in protocol.
+func captureDialect(p relay.Protocol, path string) Dialect {
+	switch {
+	case p == relay.ProtocolAnthropic:
+		return DialectAnthropic
+	case strings.HasPrefix(path, "/v1/responses"):
+		return DialectResponses
+	case strings.HasPrefix(path, "/v1beta/"):
+		return DialectGemini
+	}
+	return DialectOpenAI
+}
+
+// snapshot copies the capture.
+func (c *capture) snapshot() Capture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.c
+	out.Chain = append([]string(nil), c.c.Chain...)
+	out.Attempts = append([]CaptureAttempt(nil), c.c.Attempts...)
+	return out
+}
+
+// captureBody keeps the first maxBody+1 bytes read through it (the
+// extra byte tells a full body from a truncated one).
+type captureBody struct {
+	io.ReadCloser
+	c   *capture
+	buf []byte
+	err error
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.c.maxBody + 1 - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(n, room)]...)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	if b.buf == nil {
+		b.buf = []byte{}
+	}
+	b.c.finish(b.buf, b.err)
+	return b.ReadCloser.Close()
+}
+
+// clip returns b as a string of at most limit bytes.
+func clip(b []byte, limit int) (string, bool) {
+	if len(b) > limit {
+		return string(b[:limit]), true
+	}
+	return string(b), false
+}
+
+// captureHeaders flattens h, dropping credentials.
+func captureHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, vv := range h {
+		switch strings.ToLower(k) {
+		case "authorization", "x-api-key", "x-goog-api-key", "cookie", "set-cookie":
+			continue
+		}
+		out[k] = strings.Join(vv, ", ")
+	}
+	return out
+}
+
+// SetCapture applies a capture config; disabling it drops what was
+// captured.
+func (fc *FallbackChain) SetCapture(cfg CaptureConfig) {
+	fc.capture.configure(cfg)
+}
+
+// SetCaptureDLP sets (or clears, with nil) the scanner captured response
+// bodies are masked with.
+func (fc *FallbackChain) SetCaptureDLP(scanner *dlp.Scanner) {
+	fc.capture.setDLP(scanner)
+}
+
+// Captures returns the captured exchanges, newest first, without their
+// bodies — fetch one with Capture for those.
+func (s *Server) Captures() []Capture {
+	out := s.fallback.capture.list()
+	for i := range out {
+		out[i].RequestBody, out[i].ResponseBody = "", ""
+	}
+	return out
+}
+
+// Capture returns the most recent capture for requestID.
+func (s *Server) Capture(requestID string) (Capture, bool) {
+	for _, c := range s.fallback.capture.list() {
+		if c.RequestID == requestID {
+			return c, true
+		}
+	}
+	return Capture{}, false
+}
+
+// Capture export formats.
+const (
+	CaptureFormatHAR   = "har"
+	CaptureFormatJSONL = "jsonl"
+)
+
+// ExportCaptures writes every capture, oldest first, to w as a HAR 1.2
+// log or as JSON lines.
+func (s *Server) ExportCaptures(w io.Writer, format string) error {
+	caps := s.fallback.capture.list()
+	for i, j := 0, len(caps)-1; i < j; i, j = i+1, j-1 {
+		caps[i], caps[j] = caps[j], caps[i]
+	}
+	switch format {
+	case CaptureFormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, c := range caps {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CaptureFormatHAR:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(capturesToHAR(caps))
+	}
+	return fmt.Errorf("unknown capture export format %q (want %s or %s)", format, CaptureFormatHAR, CaptureFormatJSONL)
+}
+
+// HAR 1.2 (http://www.softwareishard.com/blog/har-12-spec/), the subset
+// browsers' devtools and HAR viewers need.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	Time            int64  `json:"time"`
+	Request         struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		Cookies     []harNameValue `json:"cookies"`
+		PostData    *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData,omitempty"`
+		HeadersSize int `json:"headersSize"`
+		BodySize    int `json:"bodySize"`
+	} `json:"request"`
+	Response struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		Cookies     []harNameValue `json:"cookies"`
+		Content     struct {
+			Size     int    `json:"size"`
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"content"`
+		RedirectURL string `json:"redirectURL"`
+		HeadersSize int    `json:"headersSize"`
+		BodySize    int    `json:"bodySize"`
+	} `json:"response"`
+	Cache   struct{} `json:"cache"`
+	Timings struct {
+		Send    int64 `json:"send"`
+		Wait    int64 `json:"wait"`
+		Receive int64 `json:"receive"`
+	} `json:"timings"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type harLog struct {
+	Log struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+func harHeaders(h map[string]string) []harNameValue {
+	out := []harNameValue{}
+	for k, v := range h {
+		out = append(out, harNameValue{Name: k, Value: v})
+	}
+	return out
+}
+
+func capturesToHAR(caps []Capture) harLog {
+	var log harLog
+	log.Log.Version = "1.2"
+	log.Log.Creator.Name = "lurus-switch"
+	log.Log.Creator.Version = "1"
+	log.Log.Entries = []harEntry{}
+	for _, c := range caps {
+		var e harEntry
+		e.StartedDateTime = c.Time.Format(time.RFC3339Nano)
+		e.Time = c.DurationMs
+		e.Request.Method = c.Method
+		e.Request.URL = c.URL
+		if c.Query != "" {
+			e.Request.URL += "?" + c.Query
+		}
+		e.Request.HTTPVersion = "HTTP/1.1"
+		e.Request.Headers = harHeaders(c.RequestHeaders)
+		e.Request.QueryString = []harNameValue{}
+		e.Request.Cookies = []harNameValue{}
+		if c.RequestBody != "" {
+			e.Request.PostData = &struct {
+				MimeType string `json:"mimeType"`
+				Text     string `json:"text"`
+			}{MimeType: "application/json", Text: c.RequestBody}
+		}
+		e.Request.HeadersSize, e.Request.BodySize = -1, len(c.RequestBody)
+		e.Response.Status = c.Status
+		e.Response.StatusText = http.StatusText(c.Status)
+		e.Response.HTTPVersion = "HTTP/1.1"
+		e.Response.Headers = harHeaders(c.ResponseHeaders)
+		e.Response.Cookies = []harNameValue{}
+		e.Response.Content.Size = len(c.ResponseBody)
+		e.Response.Content.MimeType = c.ResponseHeaders["Content-Type"]
+		e.Response.Content.Text = c.ResponseBody
+		e.Response.HeadersSize, e.Response.BodySize = -1, len(c.ResponseBody)
+		e.Timings.Send, e.Timings.Wait, e.Timings.Receive = 0, c.DurationMs, 0
+		if len(c.Attempts) > 0 {
+			e.Timings.Wait = c.Attempts[len(c.Attempts)-1].LatencyMs
+			e.Timings.Receive = max(c.DurationMs-e.Timings.Wait, 0)
+		}
+		e.Comment = fmt.Sprintf("request %s via %s; chain %s", c.RequestID, c.ServedBy, strings.Join(c.Chain, " → "))
+		if c.Error != "" {
+			e.Comment += "; error: " + c.Error
+		}
+		log.Log.Entries = append(log.Log.Entries, e)
+	}
+	return log
+}
+
+// ReplayResult is a captured request's outcome against another endpoint.
+type ReplayResult struct {
+	RequestID string            `json:"requestId"`
+	Endpoint  string            `json:"endpoint"`
+	URL       string            `json:"url"`
+	Status    int               `json:"status,omitempty"`
+	LatencyMs int64             `json:"latencyMs"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// ReplayCapture re-sends the captured request for requestID, body and
+// path as originally sent, to ep alone — no fallback, metering or
+// capture — so its behaviour can be compared with the original
+// response. The endpoint must speak the protocol the request was
+// shaped for.
+func (s *Server) ReplayCapture(ctx context.Context, requestID string, ep relay.RelayEndpoint) (ReplayResult, error) {
+	c, ok := s.Capture(requestID)
+	if !ok {
+		return ReplayResult{}, fmt.Errorf("no capture for request %q", requestID)
+	}
+	if c.RequestTruncated {
+		return ReplayResult{}, fmt.Errorf("capture %q has a truncated request body; raise capture.maxBodyBytes to replay it", requestID)
+	}
+	if ep.Protocol != c.Protocol {
+		return ReplayResult{}, fmt.Errorf("endpoint %q speaks %q but the captured request was shaped for %q",
+			endpointDisplayName(ep), protocolName(ep.Protocol), protocolName(c.Protocol))
+	}
+	if relay.KeysLocked(ep) {
+		return ReplayResult{}, fmt.Errorf("endpoint %q: its API key is in the locked secrets vault", endpointDisplayName(ep))
+	}
+	s.mu.Lock()
+	userToken := usableUserToken(s.cfg)
+	s.mu.Unlock()
+	entry := entryFromEndpoint(ep, userToken)
+
+	headers := http.Header{}
+	for k, v := range c.RequestHeaders {
+		headers.Set(k, v)
+	}
+	if entry.Protocol == relay.ProtocolAnthropic {
+		headers = anthropicHeaders(headers, entry.Token)
+	}
+	res := ReplayResult{RequestID: requestID, Endpoint: entry.Name, URL: strings.TrimRight(entry.URL, "/") + c.Path}
+	resp, latencyMs, err := s.fallback.doRequest(ctx, &http.Client{Timeout: upstreamTimeout},
+		c.Method, entry.URL, c.Path, c.Query, []byte(c.RequestBody), headers, entry.Token)
+	res.LatencyMs = latencyMs
+	if err != nil {
+		res.Error = err.Error()
+		return res, nil
+	}
+	defer resp.Body.Close()
+	limit := c.responseLimit()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.Status = resp.StatusCode
+	res.Headers = captureHeaders(resp.Header)
+	res.Body, res.Truncated = clip(body, limit)
+	return res, nil
+}
+
+// responseLimit sizes a replay's body to the capture's, so the two
+// compare like for like.
+func (c Capture) responseLimit() int {
+	return max(len(c.ResponseBody), len(c.RequestBody), defaultCaptureBodyBytes)
+}
+
+func protocolName(p relay.Protocol) string {
+	if p == "" {
+		return "openai"
+	}
+	return string(p)
+}
@@ -0,0 +1,235 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/dlp"
+	"lurus-switch/internal/relay"
+)
+
+func captureChain(t *testing.T, cfg CaptureConfig, chain []FallbackEntry, body string) *FallbackChain {
+	t.Helper()
+	fc := NewFallbackChain(nil)
+	fc.SetCapture(cfg)
+	ctx := context.WithValue(context.Background(), metaKey, &RequestMeta{RequestID: "req-1", AppID: "app"})
+	headers := http.Header{"Content-Type": {"application/json"}}
+	resp, _, err := fc.TryUpstreamChain(ctx, "POST", "/v1/chat/completions", "", []byte(body), headers, chain)
+	if err == nil {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	return fc
+}
+
+func TestCapture_RecordsAttemptsAndServedResponse(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer up.Close()
+
+	fc := captureChain(t, CaptureConfig{Enabled: true}, []FallbackEntry{
+		{Name: "down", URL: down.URL, Token: "secret"},
+		{Name: "up", URL: up.URL, Token: "secret"},
+	}, `{"model":"m"}`)
+
+	caps := fc.capture.list()
+	if len(caps) != 1 {
+		t.Fatalf("captures = %d, want 1", len(caps))
+	}
+	c := caps[0]
+	if c.RequestID != "req-1" || c.ServedBy != "up" || c.Status != 200 {
+		t.Errorf("capture = %+v", c)
+	}
+	if strings.Join(c.Chain, ",") != "down,up" || len(c.Attempts) != 2 || c.Attempts[0].Status != 503 {
+		t.Errorf("chain %v attempts %+v", c.Chain, c.Attempts)
+	}
+	if c.RequestBody != `{"model":"m"}` || c.ResponseBody != `{"ok":true}` {
+		t.Errorf("bodies %q / %q", c.RequestBody, c.ResponseBody)
+	}
+	for k := range c.RequestHeaders {
+		if strings.EqualFold(k, "Authorization") {
+			t.Error("credentials must not be captured")
+		}
+	}
+}
+
+func TestCapture_SameNamedEntriesKeptApartByID(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer up.Close()
+
+	fc := captureChain(t, CaptureConfig{Enabled: true}, []FallbackEntry{
+		{ID: "first", Name: "dup", URL: down.URL, Body: []byte(`{"model":"a"}`)},
+		{ID: "second", Name: "dup", URL: up.URL, Body: []byte(`{"model":"b"}`)},
+	}, `{"model":"m"}`)
+
+	c := fc.capture.list()[0]
+	if c.ServedBy != "dup" || c.ServedByID != "second" {
+		t.Errorf("served by %q/%q, want dup/second", c.ServedBy, c.ServedByID)
+	}
+	if c.RequestBody != `{"model":"b"}` || !strings.HasPrefix(c.URL, up.URL) {
+		t.Errorf("request %q to %s, want what was sent to the serving entry", c.RequestBody, c.URL)
+	}
+	if len(c.Attempts) != 2 || c.Attempts[0].EndpointID != "first" || c.Attempts[1].EndpointID != "second" {
+		t.Errorf("attempts = %+v", c.Attempts)
+	}
+}
+
+func TestCapture_StreamBoundedAndRingEvicts(t *testing.T) {
+	up := sseUpstream(roleChunk, contentChunk, finishChunk)
+	defer up.Close()
+
+	fc := NewFallbackChain(nil)
+	fc.SetCapture(CaptureConfig{Enabled: true, MaxEntries: 2, MaxBodyBytes: 16})
+	for i := 0; i < 3; i++ {
+		resp, _, err := fc.TryUpstreamChain(context.Background(), "POST", "/v1/chat/completions", "",
+			[]byte(`{"model":"m","stream":true}`), http.Header{}, []FallbackEntry{{Name: "up", URL: up.URL}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	caps := fc.capture.list()
+	if len(caps) != 2 || caps[0].Seq != 3 {
+		t.Fatalf("captures = %d (newest seq %d), want the newest 2", len(caps), caps[0].Seq)
+	}
+	c := caps[0]
+	if !c.Streaming || !c.ResponseTruncated || len(c.ResponseBody) != 16 || !c.RequestTruncated {
+		t.Errorf("capture = %+v, want a streamed exchange clipped to 16 bytes", c)
+	}
+}
+
+func TestCapture_AllFailedAndDisabled(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	chain := []FallbackEntry{{Name: "down", URL: down.URL}}
+
+	fc := captureChain(t, CaptureConfig{Enabled: true}, chain, `{}`)
+	caps := fc.capture.list()
+	if len(caps) != 1 || caps[0].ServedBy != "" || !strings.Contains(caps[0].Error, "last status: 502") || caps[0].RequestBody != `{}` {
+		t.Errorf("captures = %+v, want one failed exchange", caps)
+	}
+
+	if fc := captureChain(t, CaptureConfig{}, chain, `{}`); len(fc.capture.list()) != 0 {
+		t.Error("nothing is captured while disabled")
+	}
+}
+
+func TestServer_ExportAndReplayCapture(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"x","model":"gpt-4o","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	})
+	defer upstream.Close()
+	cfg := srv.GetConfig()
+	cfg.Capture = CaptureConfig{Enabled: true}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	app, _ := reg.Register("Codex", "", "")
+	serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+
+	caps := srv.Captures()
+	if len(caps) != 1 || caps[0].RequestBody != "" || caps[0].RequestID == "" {
+		t.Fatalf("captures = %+v, want one, listed without bodies", caps)
+	}
+	id := caps[0].RequestID
+
+	var har bytes.Buffer
+	if err := srv.ExportCaptures(&har, CaptureFormatHAR); err != nil {
+		t.Fatal(err)
+	}
+	var log harLog
+	if err := json.Unmarshal(har.Bytes(), &log); err != nil || len(log.Log.Entries) != 1 {
+		t.Fatalf("HAR = %s (%v)", har.String(), err)
+	}
+	if e := log.Log.Entries[0]; e.Request.PostData == nil || !strings.Contains(e.Response.Content.Text, `"gpt-4o"`) {
+		t.Errorf("HAR entry = %+v", e)
+	}
+	var jsonl bytes.Buffer
+	srv.ExportCaptures(&jsonl, CaptureFormatJSONL)
+	if strings.Count(jsonl.String(), "\n") != 1 {
+		t.Errorf("JSONL = %q, want one line", jsonl.String())
+	}
+	if err := srv.ExportCaptures(io.Discard, "xml"); err == nil {
+		t.Error("unknown export format should fail")
+	}
+
+	var gotPath, gotBody string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.Path, string(b)
+		io.WriteString(w, `{"replayed":true}`)
+	}))
+	defer other.Close()
+	res, err := srv.ReplayCapture(context.Background(), id, relay.RelayEndpoint{ID: "o", Name: "other", URL: other.URL, APIKey: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != 200 || res.Body != `{"replayed":true}` || gotPath != "/v1/chat/completions" || !strings.Contains(gotBody, `"hi"`) {
+		t.Errorf("replay = %+v, upstream saw %s %s", res, gotPath, gotBody)
+	}
+	if _, err := srv.ReplayCapture(context.Background(), id, relay.RelayEndpoint{ID: "a", URL: other.URL, Protocol: relay.ProtocolAnthropic}); err == nil {
+		t.Error("replaying onto an endpoint of another protocol should fail")
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestServer_CaptureMasksResponseSecrets(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range []string{"Your key is AK", "IAABCDEFGH", "IJKLMNOP, keep it safe."} {
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"`+piece+`"},"finish_reason":null}]}`+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+	defer upstream.Close()
+	cfg := srv.GetConfig()
+	cfg.Capture = CaptureConfig{Enabled: true}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	scanner := dlp.NewScanner()
+	srv.SetDLPScanner(scanner)
+	var ops []string
+	srv.SetDLPAuditFn(func(op, target string, payload any, metadata map[string]string) {
+		ops = append(ops, op)
+	})
+	app, _ := reg.Register("Codex", "", "")
+	serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	for _, format := range []string{CaptureFormatHAR, CaptureFormatJSONL} {
+		var out bytes.Buffer
+		if err := srv.ExportCaptures(&out, format); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(out.String(), "ABCDEFGH") || !strings.Contains(out.String(), "[REDACTED:api_key.aws_access]") {
+			t.Errorf("%s export = %s, want the key masked", format, out.String())
+		}
+	}
+	if len(ops) != 1 {
+		t.Errorf("audit ops = %v, want the client copy's redaction only", ops)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
@@ -9,7 +9,8 @@ import (
 
 // DLP middleware integration. The gateway holds an optional *dlp.Scanner
 // (set by services.go at boot via SetDLPScanner). Each inbound proxy
-// request body is scanned BEFORE forwarding upstream:
+// request body is scanned BEFORE forwarding upstream, by the built-in
+// "dlp" pipeline stage (see middleware.go):
 //
 //   - PolicyBlock hit         → return 451 + dlp_blocked error
 //   - PolicyRedact hit        → swap body for the redacted version
@@ -20,10 +21,9 @@ import (
 // so the admin DLP page can display "what got caught lately" without a
 // separate audit pipeline.
 //
-// Why not response scanning too? The streaming case (SSE chunks) makes
-// post-hoc redaction tricky — you'd have to re-frame the chunks, and
-// any redaction lands in the user's terminal mid-stream. We start with
-// request-only and revisit response scanning when there's a concrete ask.
+// Model output is scanned too, by the same stage on the way out (see
+// dlp_response.go): buffered bodies and SSE deltas are masked before the
+// client sees them, with hits recorded as "gateway.response".
 
 // SetDLPScanner injects (or clears, with nil) the DLP scanner. Safe to
 // call after Start; the next request picks up the change.
@@ -31,6 +31,7 @@ func (s *Server) SetDLPScanner(scanner *dlp.Scanner) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.dlpScanner = scanner
+	s.fallback.SetCaptureDLP(scanner)
 }
 
 // SetDLPAuditFn injects (or clears, with nil) the audit callback. The
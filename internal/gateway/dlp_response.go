@@ -0,0 +1,376 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"lurus-switch/internal/dlp"
+)
+
+// Outbound DLP. The dlp stage's chunk hook scans model output on its way
+// to the client, after translation, so the same code covers every front
+// door and every upstream protocol:
+//
+//   - buffered bodies (and each element of a Gemini JSON-array stream)
+//     are decoded and every string in them is scanned and masked;
+//   - SSE streams are processed one event at a time. The streamed text
+//     of consecutive delta events is joined into one dlp.StreamScanner,
+//     which holds back a small overlap window so a secret split across
+//     events is still caught. Each delta event carries the text released
+//     so far; the held-back remainder goes out in a synthesized delta
+//     just before the next non-delta event (finish chunk, block stop,
+//     [DONE]) or at end of response.
+//
+// Outbound hits are masked whatever their policy — a completion can't be
+// refused once paid for — and recorded into the scanner ring as source
+// "gateway.response". Redactions are also audited as
+// "dlp.response.redact", once per streamed text segment or buffered body.
+
+// dlpResponseScanner is the per-response state of outbound scanning.
+type dlpResponseScanner struct {
+	scanner *dlp.Scanner
+	auditFn func(op, target string, payload any, metadata map[string]string)
+	dialect Dialect
+	path    string
+	reqBody []byte // for the audit entry's session metadata
+	sse     bool
+	quiet   bool // mask only: hits are neither recorded nor audited
+
+	buf   []byte // bytes after the last complete line
+	event []byte // complete lines of the event being assembled
+
+	stream   *dlp.StreamScanner
+	channel  string
+	template *sseEvent // last delta event, reused for the flush
+	hits     []dlp.Hit // stream hits not yet recorded
+}
+
+func newDLPResponseScanner(s *Server, ex *Exchange) *dlpResponseScanner {
+	s.mu.Lock()
+	scanner := s.dlpScanner
+	auditFn := s.dlpAuditFn
+	s.mu.Unlock()
+	if scanner == nil {
+		return nil
+	}
+	sse := ex.Streaming
+	if ex.Dialect == DialectGemini && ex.Request != nil {
+		sse = ex.Streaming && ex.Request.URL.Query().Get("alt") == "sse"
+	}
+	return &dlpResponseScanner{
+		scanner: scanner,
+		auditFn: auditFn,
+		dialect: ex.Dialect,
+		path:    ex.Path,
+		reqBody: ex.Body,
+		sse:     sse,
+	}
+}
+
+// feed consumes client-bound bytes and returns what may be written now.
+func (d *dlpResponseScanner) feed(chunk []byte) []byte {
+	if !d.sse {
+		return d.maskDocument(chunk)
+	}
+	d.buf = append(d.buf, chunk...)
+	var out []byte
+	for {
+		idx := bytes.IndexByte(d.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := d.buf[:idx+1]
+		d.event = append(d.event, line...)
+		if len(bytes.TrimSpace(line)) == 0 {
+			out = append(out, d.processEvent(d.event)...)
+			d.event = d.event[:0]
+		}
+		d.buf = d.buf[idx+1:]
+	}
+	d.buf = append([]byte(nil), d.buf...)
+	return out
+}
+
+// finish releases everything still held: a trailing unterminated event
+// and the stream scanner's window.
+func (d *dlpResponseScanner) finish() []byte {
+	var out []byte
+	if rest := append(d.event, d.buf...); len(rest) > 0 {
+		out = d.processEvent(rest)
+		d.event, d.buf = nil, nil
+	}
+	return append(out, d.flush()...)
+}
+
+// sseEvent is one SSE event split into lines (terminators kept).
+type sseEvent struct {
+	lines   [][]byte
+	dataIdx int
+	obj     map[string]any
+	slot    textSlot
+}
+
+func (d *dlpResponseScanner) processEvent(raw []byte) []byte {
+	ev := parseSSEEvent(raw)
+	if ev.obj == nil {
+		// No JSON payload ([DONE], comments, keep-alives). Only a data
+		// line ends the text segment.
+		if ev.dataIdx >= 0 {
+			return append(d.flush(), raw...)
+		}
+		return raw
+	}
+
+	if slot, ok := deltaSlot(d.dialect, ev.obj); ok {
+		var out []byte
+		if d.stream != nil && slot.channel != d.channel {
+			out = d.flush()
+		}
+		if d.stream == nil {
+			d.stream = d.scanner.NewStream(dlp.DefaultStreamWindow)
+			d.channel = slot.channel
+		}
+		ev.slot = slot
+		release, hits := d.stream.Push(slot.get())
+		d.hits = append(d.hits, hits...)
+		slot.set(release)
+		d.template = ev
+		return append(out, ev.render()...)
+	}
+
+	out := d.flush()
+	if hits := maskStrings(d.scanner, ev.obj); len(hits) > 0 {
+		d.record(hits)
+		return append(out, ev.render()...)
+	}
+	return append(out, raw...)
+}
+
+// flush ends the current text segment: the held-back text goes out in a
+// copy of the last delta event and the segment's hits are recorded.
+func (d *dlpResponseScanner) flush() []byte {
+	if d.stream == nil {
+		return nil
+	}
+	rest, hits := d.stream.Flush()
+	d.record(append(d.hits, hits...))
+	d.stream, d.channel, d.hits = nil, "", nil
+	if rest == "" || d.template == nil {
+		return nil
+	}
+	d.template.slot.set(rest)
+	return d.template.render()
+}
+
+// maskDocument scans one complete JSON document — a buffered body, a
+// JSON-array element or a single-chunk SSE frame — keeping whatever
+// framing surrounds the object.
+func (d *dlpResponseScanner) maskDocument(chunk []byte) []byte {
+	start := bytes.IndexAny(chunk, "{[")
+	if start < 0 {
+		return chunk
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(chunk[start:]))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		out, hits := d.scanner.ScanCompletion(string(chunk))
+		d.record(hits)
+		return []byte(out)
+	}
+	end := start + int(dec.InputOffset())
+	hits := maskStrings(d.scanner, doc)
+	if len(hits) == 0 {
+		return chunk
+	}
+	d.record(hits)
+	body, err := marshalNoEscape(doc)
+	if err != nil {
+		return chunk
+	}
+	out := append([]byte(nil), chunk[:start]...)
+	out = append(out, body...)
+	return append(out, chunk[end:]...)
+}
+
+// record files outbound hits in the scanner ring and audits redactions.
+func (d *dlpResponseScanner) record(hits []dlp.Hit) {
+	if len(hits) == 0 || d.quiet {
+		return
+	}
+	d.scanner.RecordHits("gateway.response", d.path, hits)
+	if d.auditFn == nil {
+		return
+	}
+	for _, h := range hits {
+		if h.Policy == dlp.PolicyRedact || h.Policy == dlp.PolicyBlock {
+			payload := dlpAuditPayload(d.path, hits)
+			payload["direction"] = "response"
+			d.auditFn("dlp.response.redact", d.path, payload, extractSessionMetadata(d.reqBody))
+			return
+		}
+	}
+}
+
+func parseSSEEvent(raw []byte) *sseEvent {
+	ev := &sseEvent{dataIdx: -1}
+	raw = append([]byte(nil), raw...) // the caller reuses its buffer
+	for len(raw) > 0 {
+		idx := bytes.IndexByte(raw, '\n')
+		if idx < 0 {
+			idx = len(raw) - 1
+		}
+		ev.lines = append(ev.lines, raw[:idx+1])
+		raw = raw[idx+1:]
+	}
+	for i, line := range ev.lines {
+		payload, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte("data:"))
+		if !ok {
+			continue
+		}
+		if ev.dataIdx >= 0 {
+			// Multi-line data: leave the event alone.
+			ev.obj = nil
+			return ev
+		}
+		ev.dataIdx = i
+		dec := json.NewDecoder(bytes.NewReader(bytes.TrimSpace(payload)))
+		dec.UseNumber()
+		var obj map[string]any
+		if dec.Decode(&obj) == nil {
+			ev.obj = obj
+		}
+	}
+	return ev
+}
+
+// render re-serializes the event with its (possibly rewritten) data line.
+func (ev *sseEvent) render() []byte {
+	var out []byte
+	for i, line := range ev.lines {
+		if i != ev.dataIdx {
+			out = append(out, line...)
+			continue
+		}
+		data, err := marshalNoEscape(ev.obj)
+		if err != nil {
+			out = append(out, line...)
+			continue
+		}
+		eol := line[len(bytes.TrimRight(line, "\r\n")):]
+		out = append(out, "data: "...)
+		out = append(out, data...)
+		out = append(out, eol...)
+	}
+	return out
+}
+
+// textSlot points at the streamed text inside one decoded delta event.
+type textSlot struct {
+	channel string // events on the same channel continue one text
+	m       map[string]any
+	key     string
+}
+
+func (t textSlot) get() string  { s, _ := t.m[t.key].(string); return s }
+func (t textSlot) set(s string) { t.m[t.key] = s }
+
+// deltaSlot finds the streamed text of a delta event in d's shape.
+// Events that also end something (a finish reason) are not deltas.
+func deltaSlot(d Dialect, ev map[string]any) (textSlot, bool) {
+	switch d {
+	case DialectOpenAI:
+		choices, _ := ev["choices"].([]any)
+		if len(choices) != 1 {
+			return textSlot{}, false
+		}
+		c, _ := choices[0].(map[string]any)
+		if fr, ok := c["finish_reason"]; ok && fr != nil {
+			return textSlot{}, false
+		}
+		delta, _ := c["delta"].(map[string]any)
+		for _, key := range []string{"content", "reasoning_content", "reasoning"} {
+			if s, ok := delta[key].(string); ok && s != "" {
+				return textSlot{channel: fmt.Sprint(c["index"], "/", key), m: delta, key: key}, true
+			}
+		}
+	case DialectResponses:
+		switch ev["type"] {
+		case "response.output_text.delta", "response.reasoning_text.delta", "response.reasoning_summary_text.delta":
+			if _, ok := ev["delta"].(string); ok {
+				return textSlot{channel: fmt.Sprint(ev["item_id"], "/", ev["content_index"], ev["summary_index"]), m: ev, key: "delta"}, true
+			}
+		}
+	case DialectAnthropic:
+		if ev["type"] != "content_block_delta" {
+			return textSlot{}, false
+		}
+		delta, _ := ev["delta"].(map[string]any)
+		key := map[any]string{"text_delta": "text", "thinking_delta": "thinking"}[delta["type"]]
+		if _, ok := delta[key].(string); ok {
+			return textSlot{channel: fmt.Sprint(ev["index"], "/", key), m: delta, key: key}, true
+		}
+	case DialectGemini:
+		cands, _ := ev["candidates"].([]any)
+		if len(cands) != 1 {
+			return textSlot{}, false
+		}
+		c, _ := cands[0].(map[string]any)
+		if fr, _ := c["finishReason"].(string); fr != "" {
+			return textSlot{}, false
+		}
+		content, _ := c["content"].(map[string]any)
+		parts, _ := content["parts"].([]any)
+		if len(parts) != 1 {
+			return textSlot{}, false
+		}
+		part, _ := parts[0].(map[string]any)
+		if _, ok := part["text"].(string); ok {
+			return textSlot{channel: fmt.Sprint("thought=", part["thought"]), m: part, key: "text"}, true
+		}
+	}
+	return textSlot{}, false
+}
+
+// maskStrings scans every string value in a decoded JSON tree, masking
+// hits in place. Returns the hits.
+func maskStrings(scanner *dlp.Scanner, v any) []dlp.Hit {
+	var hits []dlp.Hit
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				t[k] = walk(child)
+			}
+		case []any:
+			for i, child := range t {
+				t[i] = walk(child)
+			}
+		case string:
+			if strings.TrimSpace(t) == "" {
+				return t
+			}
+			out, h := scanner.ScanCompletion(t)
+			hits = append(hits, h...)
+			return out
+		}
+		return v
+	}
+	walk(v)
+	return hits
+}
+
+// marshalNoEscape is json.Marshal without HTML escaping, so re-rendered
+// model text keeps its <, > and & as the upstream sent them.
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
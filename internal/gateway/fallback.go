@@ -9,6 +9,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"lurus-switch/internal/relay"
 )
 
 // FallbackChain holds an ordered list of upstream endpoints to try.
@@ -24,11 +26,36 @@ type FallbackChain struct {
 	entries  []FallbackEntry
 	maxRetry int // max entries to try (0 = try all)
 
-	// observer is invoked once per upstream attempt with the endpoint
-	// name (or "primary"), a success/failure verdict, and the measured
-	// round-trip latency in milliseconds. Used by the relay router's
-	// circuit breaker + latency feedback loop; nil-safe.
-	observer func(endpointName string, ok bool, errMsg string, latencyMs int64)
+	// observer is invoked once per upstream attempt with the entry tried
+	// (router-built entries carry their relay endpoint ID), a
+	// success/failure verdict, and the measured round-trip latency in
+	// milliseconds. Used by the relay router's circuit breaker + latency
+	// feedback loop; nil-safe.
+	observer func(entry FallbackEntry, ok bool, errMsg string, latencyMs int64)
+
+	// tracker counts in-flight requests per router-built entry: called
+	// before each attempt, its release func runs when the attempt fails
+	// or the served response body is closed. Feeds the relay router's
+	// least_in_flight strategy; nil-safe.
+	tracker func(entry FallbackEntry) (release func())
+
+	// aliases is the gateway-wide model alias map (see alias.go).
+	aliases map[string]string
+
+	// streamIdle is how long a served event stream may go silent before
+	// it counts as failed (see streamfail.go); 0 disables the check.
+	streamIdle time.Duration
+
+	// hedgeObserver hears the outcome of every hedged race (hedge.go).
+	hedgeObserver func(winner, loser FallbackEntry)
+
+	// keyObserver hears the upstream status (0 for no response) of every
+	// attempt sent on a pooled key, for the relay router's per-key
+	// breakers; nil-safe.
+	keyObserver func(endpointID, keyID string, status int, errMsg string)
+
+	// capture records upstream exchanges when enabled (capture.go).
+	capture *captureRing
 }
 
 // SetObserver wires a per-attempt callback into the chain. The observer
@@ -36,24 +63,87 @@ type FallbackChain struct {
 // ok=true, anything that trips shouldFallback returns ok=false. The
 // latencyMs argument is the wall-clock duration of the upstream HTTP
 // round trip; 0 when the attempt was short-circuited before dialing.
-func (fc *FallbackChain) SetObserver(fn func(endpointName string, ok bool, errMsg string, latencyMs int64)) {
+func (fc *FallbackChain) SetObserver(fn func(entry FallbackEntry, ok bool, errMsg string, latencyMs int64)) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 	fc.observer = fn
 }
 
+// SetKeyObserver wires the per-key callback. An attempt on a pooled key
+// that fails with a key-scoped status (relay.KeyScopedStatus) is
+// reported only here, not to the observer: one throttled or revoked key
+// shouldn't count against its endpoint.
+func (fc *FallbackChain) SetKeyObserver(fn func(endpointID, keyID string, status int, errMsg string)) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.keyObserver = fn
+}
+
+// SetTracker wires the in-flight tracker used by TryUpstreamChain.
+func (fc *FallbackChain) SetTracker(fn func(entry FallbackEntry) (release func())) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.tracker = fn
+}
+
+// SetStreamIdleTimeout sets how long a served event stream may go
+// silent before it counts as failed; 0 disables the check.
+func (fc *FallbackChain) SetStreamIdleTimeout(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.streamIdle = d
+}
+
 // FallbackEntry is one upstream endpoint in the chain.
 type FallbackEntry struct {
 	Name     string `json:"name"`     // display name (e.g. "Groq-Free", "DeepSeek")
 	URL      string `json:"url"`      // base URL (without /v1)
 	Token    string `json:"token"`    // API key / bearer token
 	Priority int    `json:"priority"` // lower = tried first
+
+	// ID is the relay endpoint ID for router-built chains.
+	ID string `json:"-"`
+	// KeyID fingerprints the pooled key Token holds (relay.KeyID) when
+	// the endpoint has a key pool; empty otherwise.
+	KeyID string `json:"-"`
+	// Vision mirrors relay.RelayEndpoint.Vision for router-built chains.
+	Vision bool `json:"-"`
+	// Protocol mirrors relay.RelayEndpoint.Protocol. Anthropic entries
+	// authenticate with x-api-key + anthropic-version instead of only a
+	// bearer token.
+	Protocol relay.Protocol `json:"-"`
+	// Reasoning mirrors relay.RelayEndpoint.Reasoning.
+	Reasoning string `json:"-"`
+	// ModelAliases mirrors relay.RelayEndpoint.ModelAliases.
+	ModelAliases map[string]string `json:"-"`
+	// Body, when non-nil, replaces the chain-wide request body for this
+	// entry only. Lets one chain carry per-upstream translations (e.g.
+	// media forwarded to vision endpoints, stub notes for the rest).
+	Body []byte `json:"-"`
+	// Path, when set, replaces the chain-wide request path for this
+	// entry (e.g. /v1/messages for an Anthropic-protocol upstream).
+	Path string `json:"-"`
+	// HedgeAfter, when set on a chain's first entry, hedges the request:
+	// if that entry hasn't returned headers by then, the next entry gets
+	// the same request and the first to answer wins (see hedge.go).
+	HedgeAfter time.Duration `json:"-"`
+
+	// pickKey, set for an endpoint with a key pool, chooses the key
+	// Token and KeyID are filled from. It is called only when a request
+	// actually goes out on the entry: picking a key moves the pool's
+	// rotation and usage counts and can half-open a cooled-down key.
+	pickKey func() (key, keyID string, ok bool)
 }
 
+// anthropicAPIVersion is sent to Anthropic-protocol upstreams that the
+// client didn't already pin to a version.
+const anthropicAPIVersion = "2023-06-01"
+
 // NewFallbackChain creates a chain. Entries are tried in order of priority (ascending).
 func NewFallbackChain(entries []FallbackEntry) *FallbackChain {
 	return &FallbackChain{
 		entries: entries,
+		capture: newCaptureRing(),
 	}
 }
 
@@ -107,7 +197,7 @@ func shouldFallback(resp *http.Response, err error) bool {
 
 // TryUpstream attempts the request against the primary upstream first.
 // If it fails and a fallback chain is configured, tries each fallback in order.
-// Returns the response, the name of the endpoint that succeeded, and any final error.
+// Returns the response, the entry that succeeded, and any final error.
 //
 // TryUpstream is the cfg-driven legacy entry point: caller provides one
 // primary URL+token and the chain pulls fallbacks from its persisted
@@ -119,7 +209,7 @@ func (fc *FallbackChain) TryUpstream(
 	body []byte,
 	headers http.Header,
 	primaryURL, primaryToken string,
-) (resp *http.Response, servedBy string, err error) {
+) (resp *http.Response, served FallbackEntry, err error) {
 	fc.mu.RLock()
 	entries := make([]FallbackEntry, 0, 1+len(fc.entries))
 	entries = append(entries, FallbackEntry{Name: "primary", URL: primaryURL, Token: primaryToken})
@@ -136,8 +226,9 @@ func (fc *FallbackChain) TryUpstream(
 // TryUpstreamChain attempts the request against the provided ordered
 // chain in sequence. The first entry is treated as the primary; the
 // rest are tried in order on 5xx / 429 / connection failure. Observer
-// fires once per attempt. Returns the response, name of the entry that
-// succeeded, and any final error.
+// fires once per attempt. Returns the response, the entry that
+// succeeded, and any final error. Callers identify the served entry by
+// its ID, not its Name: display names need not be unique.
 //
 // The chain is provided by the caller (router-driven), so this method
 // does NOT consult fc.entries — that path is exclusively TryUpstream's.
@@ -147,9 +238,9 @@ func (fc *FallbackChain) TryUpstreamChain(
 	body []byte,
 	headers http.Header,
 	chain []FallbackEntry,
-) (resp *http.Response, servedBy string, err error) {
+) (resp *http.Response, served FallbackEntry, err error) {
 	if len(chain) == 0 {
-		return nil, "", fmt.Errorf("upstream chain is empty")
+		return nil, FallbackEntry{}, fmt.Errorf("upstream chain is empty")
 	}
 	if ctx == nil {
 		ctx = context.Background()
@@ -158,42 +249,169 @@ func (fc *FallbackChain) TryUpstreamChain(
 	client := &http.Client{Timeout: upstreamTimeout}
 	fc.mu.RLock()
 	observer := fc.observer
+	tracker := fc.tracker
+	aliases := fc.aliases
+	idle := fc.streamIdle
+	hedgeObserver := fc.hedgeObserver
+	keyObserver := fc.keyObserver
 	fc.mu.RUnlock()
+	rec := fc.capture.begin(ctx, method, path, query, chain)
 
-	for _, entry := range chain {
-		if entry.URL == "" {
-			continue
+	// attempt sends the request to one entry, first picking its pooled
+	// key into *e. A served response (ok) comes back with its body
+	// wrapped for model-name rewriting and in-flight release, plus the
+	// model an alias rewrote the request to (empty when none did); a
+	// failed one is drained, closed and reported.
+	attempt := func(ctx context.Context, e *FallbackEntry) (resp *http.Response, servedModel string, ok bool, err error) {
+		if e.pickKey != nil {
+			key, keyID, ok := e.pickKey()
+			if !ok {
+				return nil, "", false, fmt.Errorf("%s: no API key available", e.Name)
+			}
+			e.Token, e.KeyID, e.pickKey = key, keyID, nil
+		}
+		entry := *e
+		release := func() {}
+		if tracker != nil && entry.ID != "" {
+			release = tracker(entry)
+		}
+		reqBody, reqPath, reqHeaders := body, path, headers
+		if entry.Body != nil {
+			reqBody = entry.Body
+		}
+		if entry.Path != "" {
+			reqPath = entry.Path
+		}
+		if entry.Protocol == relay.ProtocolAnthropic {
+			reqHeaders = anthropicHeaders(headers, entry.Token)
+		}
+		requested := extractModelFromBody(reqBody)
+		served := upstreamModel(entry, aliases, requested)
+		if served != requested {
+			reqBody = withModel(reqBody, served)
+		}
+		resp, latencyMs, err := fc.doRequest(ctx, client, method, entry.URL, reqPath, query, reqBody, reqHeaders, entry.Token)
+		rec.attempt(entry, reqPath, reqBody, reqHeaders, resp, err, latencyMs)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
 		}
-		var latencyMs int64
-		resp, latencyMs, err = fc.doRequest(ctx, client, method, entry.URL, path, query, body, headers, entry.Token)
 		if !shouldFallback(resp, err) {
 			if observer != nil {
-				observer(entry.Name, true, "", latencyMs)
+				observer(entry, true, "", latencyMs)
+			}
+			if keyObserver != nil && entry.KeyID != "" {
+				keyObserver(entry.ID, entry.KeyID, status, "")
+			}
+			if served != requested {
+				resp.Body = newModelRewriteBody(resp.Body, served, requested)
+				servedModel = served
 			}
-			return resp, entry.Name, nil
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+			return resp, servedModel, true, nil
 		}
-		if observer != nil {
+		release()
+		// A hedge leg cancelled because the other leg won didn't fail.
+		if !lostHedge(ctx) {
 			msg := ""
 			if err != nil {
 				msg = err.Error()
 			} else if resp != nil {
 				msg = fmt.Sprintf("status %d", resp.StatusCode)
 			}
-			observer(entry.Name, false, msg, latencyMs)
+			keyFault := entry.KeyID != "" && relay.KeyScopedStatus(status)
+			if observer != nil && !keyFault {
+				observer(entry, false, msg, latencyMs)
+			}
+			if keyObserver != nil && entry.KeyID != "" {
+				keyObserver(entry.ID, entry.KeyID, status, msg)
+			}
 		}
 		if resp != nil {
 			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
 		}
+		return resp, "", false, err
+	}
+
+	// resume is attempt for a stream picking up on a later entry.
+	resume := func(ctx context.Context, entry *FallbackEntry) (*http.Response, string, bool, error) {
+		resp, servedModel, ok, err := attempt(ctx, entry)
+		if ok {
+			rec.served(*entry, resp)
+		}
+		return resp, servedModel, ok, err
+	}
+
+	// serve hands back the response of entry, chain[i] as attempted. A
+	// served event stream can still fail part-way; failoverStream
+	// watches it and carries on from the rest of the chain.
+	serve := func(i int, entry FallbackEntry, resp *http.Response) (*http.Response, FallbackEntry, error) {
+		rec.served(entry, resp)
+		if isEventStream(resp) {
+			fs := &failoverStream{
+				ctx:         ctx,
+				idle:        idle,
+				format:      streamFormatFor(entry, path),
+				entry:       entry,
+				rest:        chain[i+1:],
+				attempt:     resume,
+				observer:    observer,
+				inputTokens: estimateTokens(body),
+			}
+			fs.start(resp.Body)
+			resp.Body = fs
+		}
+		resp.Body = rec.wrap(resp.Body)
+		return resp, entry, nil
+	}
+
+	if chain[0].HedgeAfter > 0 {
+		var won int
+		var entry FallbackEntry
+		if resp, won, entry, err = race(ctx, chain, attempt, hedgeObserver); won >= 0 {
+			return serve(won, entry, resp)
+		}
+	} else {
+		for i, entry := range chain {
+			if entry.URL == "" {
+				continue
+			}
+			var ok bool
+			resp, _, ok, err = attempt(ctx, &entry)
+			if ok {
+				return serve(i, entry, resp)
+			}
+		}
 	}
 
 	if err != nil {
-		return nil, "", fmt.Errorf("all upstream endpoints failed, last error: %w", err)
+		err = fmt.Errorf("all upstream endpoints failed, last error: %w", err)
+	} else if resp != nil {
+		err = fmt.Errorf("all upstream endpoints failed (last status: %d)", resp.StatusCode)
+	} else {
+		err = fmt.Errorf("all upstream endpoints had empty URLs")
+	}
+	rec.fail(err)
+	return nil, FallbackEntry{}, err
+}
+
+// anthropicHeaders returns a copy of headers carrying the auth and
+// version headers the Anthropic Messages API requires. The bearer token
+// doRequest sets is kept as well — most Anthropic-compatible relays
+// accept either.
+func anthropicHeaders(headers http.Header, token string) http.Header {
+	out := headers.Clone()
+	if out == nil {
+		out = make(http.Header)
 	}
-	if resp != nil {
-		return nil, "", fmt.Errorf("all upstream endpoints failed (last status: %d)", resp.StatusCode)
+	if token != "" {
+		out.Set("x-api-key", token)
+	}
+	if out.Get("anthropic-version") == "" {
+		out.Set("anthropic-version", anthropicAPIVersion)
 	}
-	return nil, "", fmt.Errorf("all upstream endpoints had empty URLs")
+	return out
 }
 
 // doRequest performs one upstream HTTP call and returns the response
@@ -237,3 +455,15 @@ func (fc *FallbackChain) doRequest(
 	latency := time.Since(start).Milliseconds()
 	return resp, latency, err
 }
+
+// releasingBody runs release when the response body is closed, ending
+// the attempt's in-flight count.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	b.release()
+	return b.ReadCloser.Close()
+}
@@ -66,15 +66,15 @@ func TestFallback_Upstream401TripsBreaker(t *testing.T) {
 	breaker := relay.NewCircuitBreakerForTest(3, time.Minute, frozen)
 
 	fc := NewFallbackChain(nil)
-	fc.SetObserver(func(name string, ok bool, errMsg string, latencyMs int64) {
+	fc.SetObserver(func(e FallbackEntry, ok bool, errMsg string, latencyMs int64) {
 		if ok {
-			breaker.RecordSuccess(name)
+			breaker.RecordSuccess(e.ID)
 		} else {
-			breaker.RecordFailure(name, errMsg)
+			breaker.RecordFailure(e.ID, errMsg)
 		}
 	})
 
-	chain := []FallbackEntry{{Name: "dead", URL: dead.URL, Token: "k"}}
+	chain := []FallbackEntry{{ID: "dead", Name: "dead", URL: dead.URL, Token: "k"}}
 	for i := 0; i < 3; i++ {
 		_, _, err := fc.TryUpstreamChain(
 			context.Background(), "POST", "/v1/chat/completions", "",
@@ -89,3 +89,41 @@ func TestFallback_Upstream401TripsBreaker(t *testing.T) {
 		t.Fatalf("breaker should be open after 3 consecutive upstream 401s")
 	}
 }
+
+// TestFallback_TrackerCountsUntilBodyClosed confirms a served attempt
+// stays in flight until the handler closes the body, while a failed
+// attempt is released straight away.
+func TestFallback_TrackerCountsUntilBodyClosed(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer dead.Close()
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer live.Close()
+
+	inFlight := map[string]int{}
+	fc := NewFallbackChain(nil)
+	fc.SetTracker(func(e FallbackEntry) func() {
+		inFlight[e.ID]++
+		return func() { inFlight[e.ID]-- }
+	})
+
+	chain := []FallbackEntry{
+		{ID: "dead", Name: "dead", URL: dead.URL},
+		{ID: "live", Name: "live", URL: live.URL},
+	}
+	resp, served, err := fc.TryUpstreamChain(context.Background(), "POST", "/v1/chat/completions", "",
+		[]byte(`{}`), http.Header{}, chain)
+	if err != nil || served.ID != "live" {
+		t.Fatalf("served=%q err=%v", served.ID, err)
+	}
+	if inFlight["dead"] != 0 || inFlight["live"] != 1 {
+		t.Fatalf("in flight before close = %v", inFlight)
+	}
+	resp.Body.Close()
+	if inFlight["live"] != 0 {
+		t.Errorf("in flight after close = %v", inFlight)
+	}
+}
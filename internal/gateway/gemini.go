@@ -0,0 +1,354 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"lurus-switch/internal/relay"
+	"lurus-switch/internal/translator"
+)
+
+// geminiModelsPrefix is the route Gemini CLI and other google-genai
+// clients call: {prefix}{model}:generateContent or
+// {prefix}{model}:streamGenerateContent.
+const geminiModelsPrefix = "/v1beta/models/"
+
+// handleGemini bridges Google's generateContent protocol to the same
+// OpenAI-compatible upstreams the other front doors use, so Gemini CLI
+// traffic passes the DLP scan, the Budget Wall and metering:
+//
+//	request:  Gemini JSON → OpenAI JSON → upstream /v1/chat/completions
+//	response: upstream OpenAI → Gemini  → client
+//
+// Anthropic-protocol relay endpoints are skipped — there is no direct
+// Gemini ↔ Anthropic translation.
+func (s *Server) handleGemini(w http.ResponseWriter, r *http.Request) {
+	s.activeReqs.Add(1)
+	defer s.activeReqs.Add(-1)
+	s.totalReqs.Add(1)
+
+	meta := getMeta(r)
+
+	model, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, geminiModelsPrefix), ":")
+	if !ok || model == "" || (method != "generateContent" && method != "streamGenerateContent") {
+		writeGeminiError(w, http.StatusNotFound,
+			fmt.Sprintf("unsupported Gemini method %q; Lurus Switch serves generateContent and streamGenerateContent", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeGeminiError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	stream := method == "streamGenerateContent"
+	sse := r.URL.Query().Get("alt") == "sse"
+
+	mw := s.pipeline()
+	ex := &Exchange{Request: r, Meta: meta, Dialect: DialectGemini, Path: r.URL.Path, Model: model}
+	w, finish := mw.wrap(ex, w)
+	defer finish()
+
+	rawBody, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	r.Body.Close()
+	ex.Body = rawBody
+	ex.Header = make(http.Header)
+	copyRequestHeaders2(ex.Header, r)
+	ex.Header.Set("Content-Type", "application/json")
+	if meta != nil {
+		meta.Model = model
+	}
+
+	// Request phase — DLP scans the raw Gemini body before translation,
+	// then the Budget Wall bails out before paying any tokens upstream.
+	if se := mw.request(ex); se != nil {
+		writeGeminiError(w, se.Status, se.Message)
+		s.recordError(meta, model, se.Message)
+		return
+	}
+	if s.serveCached(w, mw, ex) {
+		return
+	}
+
+	var req translator.GeminiRequest
+	parse := func() error {
+		req = translator.GeminiRequest{}
+		if err := json.Unmarshal(ex.Body, &req); err != nil {
+			return &StageError{Status: http.StatusBadRequest, Message: "malformed Gemini request body: " + err.Error()}
+		}
+		return nil
+	}
+	if err := parse(); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	upstreamURL := s.cfg.UpstreamURL
+	userToken := usableUserToken(s.cfg)
+	s.mu.Unlock()
+	if upstreamURL == "" {
+		writeGeminiError(w, http.StatusServiceUnavailable,
+			"Gateway upstream not configured. Open Lurus Switch settings to set your API endpoint.")
+		return
+	}
+	if userToken == "" {
+		writeGeminiError(w, http.StatusPaymentRequired,
+			"No upstream API key configured. Open Account → Connection in Lurus Switch.")
+		return
+	}
+
+	// Translate Gemini → OpenAI. Streaming asks for the trailing usage
+	// chunk so the stream is metered like every other path.
+	translate := func(opts translator.RequestOptions) (*translator.OpenAIRequest, error) {
+		oreq, err := translator.GeminiRequestToOpenAI(&req, model, opts)
+		if err != nil {
+			return nil, err
+		}
+		if stream {
+			oreq.Stream = true
+			oreq.StreamOptions = &translator.StreamOptions{IncludeUsage: true}
+		}
+		return oreq, nil
+	}
+	if _, err := translate(translator.RequestOptions{}); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	normalizedURL := NormalizeChannelBaseURL(upstreamURL)
+
+	var chain []FallbackEntry
+	var routerOK bool
+	var served FallbackEntry
+	attempts := 0
+	dispatch := func() (*http.Response, error) {
+		if attempts++; attempts > 1 {
+			if err := parse(); err != nil {
+				return nil, err
+			}
+		}
+		openAIReq, err := translate(translator.RequestOptions{})
+		if err != nil {
+			return nil, &StageError{Status: http.StatusBadRequest, Message: err.Error()}
+		}
+		openAIBody, err := json.Marshal(openAIReq)
+		if err != nil {
+			return nil, &StageError{Status: http.StatusInternalServerError, Message: "translator marshal failed: " + err.Error()}
+		}
+
+		var matchedBy string
+		chain, matchedBy, routerOK = s.buildChainFromRouter(
+			toolFromRequest(r),
+			model,
+			estimateTokens(openAIBody),
+			len(openAIReq.Tools) > 0,
+			meta,
+			userToken,
+		)
+		if routerOK {
+			chain = chainWithoutProtocol(chain, relay.ProtocolAnthropic)
+			routerOK = len(chain) > 0
+		}
+		if routerOK {
+			attachEntryBodies(chain, translator.GeminiHasMedia(&req), translate)
+		}
+
+		var resp *http.Response
+		if routerOK {
+			if meta != nil {
+				meta.MatchedBy = matchedBy
+			}
+			resp, served, err = s.fallback.TryUpstreamChain(
+				r.Context(), "POST", "/v1/chat/completions", "",
+				openAIBody, ex.Header,
+				chain,
+			)
+		} else {
+			resp, served, err = s.fallback.TryUpstream(
+				r.Context(), "POST", "/v1/chat/completions", "",
+				openAIBody, ex.Header,
+				normalizedURL, userToken,
+			)
+		}
+		return resp, err
+	}
+
+	resp, err := mw.roundTrip(ex, dispatch)
+	if err != nil {
+		var se *StageError
+		if errors.As(err, &se) {
+			writeGeminiError(w, se.Status, se.Message)
+		} else {
+			writeGeminiError(w, http.StatusBadGateway, fmt.Sprintf("upstream error: %v", err))
+		}
+		s.recordError(meta, model, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if meta != nil {
+		meta.ServedBy = served.Name
+		meta.ServedModel = s.servedModel(served, model)
+		meta.ServedKeyID = served.KeyID
+	}
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+		writeGeminiError(w, resp.StatusCode, fmt.Sprintf("upstream %d: %s", resp.StatusCode, string(body)))
+		s.recordError(meta, model, string(body))
+		return
+	}
+
+	if stream && ex.Streaming {
+		s.streamGemini(w, resp, meta, model, sse, translator.GeminiIncludeThoughts(&req))
+	} else {
+		s.bufferedGemini(w, resp, meta, model, stream, sse, translator.GeminiIncludeThoughts(&req))
+	}
+}
+
+// bufferedGemini answers from a non-streaming upstream body. A client
+// that asked for a stream still gets one — a single-chunk stream.
+func (s *Server) bufferedGemini(
+	w http.ResponseWriter, resp *http.Response,
+	meta *RequestMeta, model string, stream, sse, includeThoughts bool,
+) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodySize))
+	if err != nil {
+		writeGeminiError(w, http.StatusBadGateway, "read upstream body: "+err.Error())
+		return
+	}
+	var openAIResp translator.OpenAIResponse
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		writeGeminiError(w, http.StatusBadGateway, "decode upstream OpenAI response: "+err.Error())
+		return
+	}
+	gemResp := translator.ResponseToGemini(&openAIResp, model, includeThoughts)
+	if meta != nil {
+		gemResp.ResponseID = meta.RequestID
+	}
+	out, _ := json.Marshal(gemResp)
+
+	switch {
+	case stream && sse:
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "data: %s\r\n\r\n", out)
+	case stream:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "[%s]", out)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(out)
+	}
+
+	// recordUsage also feeds the budget guard.
+	usage := extractUsageFromBody(body)
+	usage.ReasoningTokens = int64(translator.ReasoningTokens(&openAIResp))
+	s.recordUsage(meta, model, usage, resp.StatusCode, false)
+}
+
+func (s *Server) streamGemini(
+	w http.ResponseWriter, resp *http.Response,
+	meta *RequestMeta, model string, sse, includeThoughts bool,
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.bufferedGemini(w, resp, meta, model, true, sse, includeThoughts)
+		return
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	respID := ""
+	if meta != nil {
+		respID = meta.RequestID
+	}
+	tr := translator.NewGeminiStreamTranslator(respID, model, sse, includeThoughts)
+	if err := tr.Run(resp.Body, w, flusher.Flush); err != nil {
+		var si *streamInterrupted
+		if errors.As(err, &si) {
+			if sse {
+				writeStreamError(w, DialectGemini, si.Error())
+			}
+			s.recordInterrupted(meta, model, UsageFromResponse{}, si)
+			return
+		}
+		s.recordError(meta, model, "gemini stream: "+err.Error())
+		return
+	}
+
+	// recordUsage also feeds the budget guard.
+	u := tr.Usage()
+	s.recordUsage(meta, model, UsageFromResponse{
+		PromptTokens:     int64(u.PromptTokens),
+		CompletionTokens: int64(u.CompletionTokens),
+		TotalTokens:      int64(u.TotalTokens),
+		CachedTokens:     int64(u.PromptTokensDetails.CachedTokens),
+		ReasoningTokens:  int64(u.CompletionTokensDetails.ReasoningTokens),
+	}, http.StatusOK, true)
+}
+
+// chainWithoutProtocol drops entries speaking protocol p.
+func chainWithoutProtocol(chain []FallbackEntry, p relay.Protocol) []FallbackEntry {
+	out := chain[:0:0]
+	for _, e := range chain {
+		if e.Protocol != p {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// writeGeminiError emits Google's error envelope:
+// {"error":{"code":400,"message":"…","status":"INVALID_ARGUMENT"}}.
+func writeGeminiError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	out, _ := json.Marshal(translator.GeminiError{Error: translator.GeminiErrorBody{
+		Code:    status,
+		Message: msg,
+		Status:  geminiStatusForCode(status),
+	}})
+	w.Write(out)
+}
+
+// geminiStatusForCode maps an HTTP status onto the google.rpc.Code name
+// Gemini clients switch on (RESOURCE_EXHAUSTED triggers their retry /
+// quota handling, for instance).
+func geminiStatusForCode(status int) string {
+	switch {
+	case status == 400:
+		return "INVALID_ARGUMENT"
+	case status == 401:
+		return "UNAUTHENTICATED"
+	case status == 403, status == 451:
+		return "PERMISSION_DENIED"
+	case status == 404:
+		return "NOT_FOUND"
+	case status == 402, status == 405:
+		return "FAILED_PRECONDITION"
+	case status == 429:
+		return "RESOURCE_EXHAUSTED"
+	case status == 503, status == 502:
+		return "UNAVAILABLE"
+	case status == 504:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGemini_GenerateContentBridgesToOpenAIUpstream(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gemini-2.5-pro",
+			"choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}}]},
+				"finish_reason":"tool_calls"}],
+			"usage":{"prompt_tokens":40,"completion_tokens":8,"total_tokens":48,"prompt_tokens_details":{"cached_tokens":30}}}`)
+	})
+	defer upstream.Close()
+	app, err := reg.Register("Gemini CLI", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-pro:generateContent", strings.NewReader(`{
+		"contents":[{"role":"user","parts":[{"text":"open a.go"}]}],
+		"tools":[{"functionDeclarations":[{"name":"read_file","parameters":{"type":"OBJECT"}}]}]
+	}`))
+	req.Header.Set("X-Goog-Api-Key", app.Token)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if gotPath != "/v1/chat/completions" || gotAuth != "Bearer test-upstream-token" {
+		t.Errorf("upstream saw path=%q auth=%q", gotPath, gotAuth)
+	}
+	if !strings.Contains(gotBody, `"model":"gemini-2.5-pro"`) || !strings.Contains(gotBody, `"tools"`) {
+		t.Errorf("upstream body not OpenAI-shaped: %s", gotBody)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`"functionCall":{"id":"call_1","name":"read_file","args":{"path":"a.go"}}`, `"promptTokenCount":40`, `"cachedContentTokenCount":30`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("response missing %s: %s", want, body)
+		}
+	}
+
+	meter.Flush()
+	recs := meter.RecentRecords(1)
+	if len(recs) != 1 || recs[0].TokensIn != 10 || recs[0].CacheReadTokens != 30 || recs[0].TokensOut != 8 {
+		t.Errorf("records = %+v, want in 10 / cacheRead 30 / out 8", recs)
+	}
+}
+
+func TestGemini_StreamGenerateContentSSE(t *testing.T) {
+	var gotBody string
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2,\"total_tokens\":11}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Gemini CLI", "", "")
+
+	req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse&key="+app.Token,
+		strings.NewReader(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`))
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var oreq map[string]any
+	_ = json.Unmarshal([]byte(gotBody), &oreq)
+	if oreq["stream"] != true || !strings.Contains(gotBody, `"include_usage":true`) {
+		t.Errorf("upstream should be asked to stream with usage: %s", gotBody)
+	}
+	got := w.Body.String()
+	for _, want := range []string{`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hi"}]}`, `"finishReason":"STOP"`, `"totalTokenCount":11`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stream missing %s:\n%s", want, got)
+		}
+	}
+	if s := meter.TodaySummary(); s.TokensIn != 9 || s.TokensOut != 2 {
+		t.Errorf("metered in:%d out:%d, want 9/2", s.TokensIn, s.TokensOut)
+	}
+}
+
+func TestGemini_ErrorsUseGoogleEnvelope(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"message":"bad model"}}`)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Gemini CLI", "", "")
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	cases := []struct {
+		path, status string
+		code         int
+	}{
+		{"/v1beta/models/gemini-x:generateContent", "INVALID_ARGUMENT", 400},
+		{"/v1beta/models/gemini-x:countTokens", "NOT_FOUND", 404},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("POST", tc.path, strings.NewReader(`{"contents":[{"parts":[{"text":"x"}]}]}`))
+		req.Header.Set("X-Goog-Api-Key", app.Token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var env struct {
+			Error struct {
+				Code   int    `json:"code"`
+				Status string `json:"status"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &env)
+		if w.Code != tc.code || env.Error.Code != tc.code || env.Error.Status != tc.status {
+			t.Errorf("%s: status=%d envelope=%+v, want %d %s", tc.path, w.Code, env.Error, tc.code, tc.status)
+		}
+	}
+}
+
+func TestExtractGoogAPIKey_QueryOnlyOnGeminiRoutes(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/models?key=tok", nil)
+	if got := extractGoogAPIKey(r); got != "" {
+		t.Errorf("?key= must not authenticate OpenAI routes, got %q", got)
+	}
+	r = httptest.NewRequest("POST", "/v1beta/models/m:generateContent?key=tok", nil)
+	if got := extractGoogAPIKey(r); got != "tok" {
+		t.Errorf("got %q, want tok", got)
+	}
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Hedged requests. A relay rule with hedge_percentile set trades a
+// little duplicate spend for tail latency on short interactive calls:
+// buildChainFromRouter stamps the chain's first entry with HedgeAfter —
+// that percentile of the endpoint's observed header latency, from the
+// circuit breaker's history — and race sends the request there first.
+// If no headers have come back by HedgeAfter, the same request also
+// goes to the next entry. The first served response wins and the other
+// leg is cancelled, so only the winner reaches the front door and is
+// metered. Without enough latency history there is no HedgeAfter and
+// the chain runs sequentially as usual.
+
+// errHedgeLost is the cancel cause of a hedge leg that lost its race.
+var errHedgeLost = errors.New("gateway: hedged request lost the race")
+
+// lostHedge reports whether ctx belongs to a hedge leg cancelled
+// because the other leg won — not a failure of its endpoint.
+func lostHedge(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+// attemptFunc sends the request to one chain entry, filling in the
+// pooled key it went out on; see the attempt closure in
+// TryUpstreamChain.
+type attemptFunc func(ctx context.Context, entry *FallbackEntry) (resp *http.Response, servedModel string, ok bool, err error)
+
+// SetHedgeObserver wires a callback for hedged races: it fires whenever
+// both legs were in flight and one answered first (winner), with the
+// entry of the leg that was cancelled (loser).
+func (fc *FallbackChain) SetHedgeObserver(fn func(winner, loser FallbackEntry)) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.hedgeObserver = fn
+}
+
+// hedgeLeg is one leg's outcome.
+type hedgeLeg struct {
+	i     int
+	entry FallbackEntry // chain[i] as attempted
+	resp  *http.Response
+	ok    bool
+	err   error
+}
+
+// race runs the chain with up to two legs in flight: chain[0] first,
+// and once it has gone HedgeAfter without answering, the next entry
+// too. A leg that fails is replaced by the next untried entry, so the
+// race degrades to ordinary failover. won is the index of the served
+// response and served that entry as attempted, or won is -1 when every
+// entry failed — resp / err are then the last failure.
+func race(ctx context.Context, chain []FallbackEntry, attempt attemptFunc, onHedge func(winner, loser FallbackEntry)) (resp *http.Response, won int, served FallbackEntry, err error) {
+	legs := make(chan hedgeLeg, 2)
+	cancels := map[int]context.CancelCauseFunc{}
+	inFlight := map[int]bool{}
+	next := 0
+	launchNext := func() {
+		for next < len(chain) {
+			i := next
+			next++
+			if chain[i].URL == "" {
+				continue
+			}
+			legCtx, cancel := context.WithCancelCause(ctx)
+			cancels[i], inFlight[i] = cancel, true
+			go func() {
+				entry := chain[i]
+				resp, _, ok, err := attempt(legCtx, &entry)
+				legs <- hedgeLeg{i: i, entry: entry, resp: resp, ok: ok, err: err}
+			}()
+			return
+		}
+	}
+
+	launchNext()
+	timer := time.NewTimer(chain[0].HedgeAfter)
+	defer timer.Stop()
+	hedge := timer.C
+	for len(inFlight) > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			launchNext()
+		case leg := <-legs:
+			delete(inFlight, leg.i)
+			if leg.i == 0 {
+				hedge = nil // only the first entry is hedged
+			}
+			if !leg.ok {
+				resp, err = leg.resp, leg.err
+				cancels[leg.i](nil)
+				launchNext()
+				continue
+			}
+			for loser := range inFlight {
+				cancels[loser](errHedgeLost)
+				// The loser may have been served in the meantime; close
+				// it so it releases its in-flight slot.
+				go func() {
+					if l := <-legs; l.ok {
+						l.resp.Body.Close()
+					}
+				}()
+				if onHedge != nil {
+					onHedge(leg.entry, chain[loser])
+				}
+			}
+			cancel := cancels[leg.i]
+			leg.resp.Body = &releasingBody{ReadCloser: leg.resp.Body, release: func() { cancel(nil) }}
+			return leg.resp, leg.i, leg.entry, nil
+		}
+	}
+	return resp, -1, FallbackEntry{}, err
+}
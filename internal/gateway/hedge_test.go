@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/relay"
+)
+
+// slowUpstream answers after delay, or gives up when the request is
+// cancelled (a lost hedge leg). cancelled counts the latter.
+func slowUpstream(delay time.Duration, body string, cancelled *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // lets the server notice a cancelled request
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			cancelled.Add(1)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+}
+
+func TestHedge_BackupWinsWhenPrimaryIsSlow(t *testing.T) {
+	var cancelled atomic.Int32
+	slow := slowUpstream(2*time.Second, `{"from":"slow"}`, &cancelled)
+	defer slow.Close()
+	fast := slowUpstream(0, `{"from":"fast"}`, &cancelled)
+	defer fast.Close()
+
+	fc := NewFallbackChain(nil)
+	obs := &observed{}
+	fc.SetObserver(obs.observe)
+	var hedges []string
+	fc.SetHedgeObserver(func(winner, loser FallbackEntry) { hedges = append(hedges, winner.Name+">"+loser.Name) })
+
+	start := time.Now()
+	resp, served, err := fc.TryUpstreamChain(context.Background(), "POST", "/v1/chat/completions", "", []byte(`{}`), http.Header{}, []FallbackEntry{
+		{Name: "slow", URL: slow.URL, HedgeAfter: 20 * time.Millisecond},
+		{Name: "fast", URL: fast.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if served.Name != "fast" || string(body) != `{"from":"fast"}` {
+		t.Errorf("served by %q with %s, want fast", served.Name, body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged request took %v, should not wait on the slow leg", elapsed)
+	}
+	if len(hedges) != 1 || hedges[0] != "fast>slow" {
+		t.Errorf("hedge observer = %v, want [fast>slow]", hedges)
+	}
+	// The cancelled leg is not an endpoint failure.
+	if got := obs.String(); got != "fast:ok" {
+		t.Errorf("observer calls = %s, want only fast:ok", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for cancelled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if cancelled.Load() != 1 {
+		t.Error("the losing leg should be cancelled")
+	}
+}
+
+func TestHedge_FastPrimaryNeverFiresBackup(t *testing.T) {
+	var cancelled atomic.Int32
+	primary := slowUpstream(0, `{"from":"primary"}`, &cancelled)
+	defer primary.Close()
+	var backupHits atomic.Int32
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { backupHits.Add(1) }))
+	defer backup.Close()
+
+	fc := NewFallbackChain(nil)
+	resp, served, err := fc.TryUpstreamChain(context.Background(), "POST", "/v1/chat/completions", "", []byte(`{}`), http.Header{}, []FallbackEntry{
+		{Name: "primary", URL: primary.URL, HedgeAfter: time.Second},
+		{Name: "backup", URL: backup.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if served.Name != "primary" || backupHits.Load() != 0 {
+		t.Errorf("served by %q, backup hits %d; want primary alone", served.Name, backupHits.Load())
+	}
+}
+
+func TestHedge_FailedLegsFallThroughToRest(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	var cancelled atomic.Int32
+	third := slowUpstream(0, `{"from":"third"}`, &cancelled)
+	defer third.Close()
+
+	fc := NewFallbackChain(nil)
+	resp, served, err := fc.TryUpstreamChain(context.Background(), "POST", "/v1/chat/completions", "", []byte(`{}`), http.Header{}, []FallbackEntry{
+		{Name: "a", URL: down.URL, HedgeAfter: time.Second},
+		{Name: "b", URL: down.URL},
+		{Name: "c", URL: third.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if served.Name != "c" {
+		t.Errorf("served by %q, want c", served.Name)
+	}
+}
+
+// Through the router: a hedge_percentile rule hedges off the breaker's
+// latency history, and only the winning response is metered.
+func TestProxy_HedgedRuleMetersOnlyTheWinner(t *testing.T) {
+	const reply = `{"id":"x","model":"gpt-4o","usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`
+	var cancelled atomic.Int32
+	slow := slowUpstream(2*time.Second, reply, &cancelled)
+	defer slow.Close()
+	fast := slowUpstream(0, reply, &cancelled)
+	defer fast.Close()
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, err := relay.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range []relay.RelayEndpoint{
+		{ID: "slow", Name: "slow", URL: slow.URL, APIKey: "k", LatencyMs: 1},
+		{ID: "fast", Name: "fast", URL: fast.URL, APIKey: "k", LatencyMs: 2},
+	} {
+		if err := store.SaveEndpoint(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	breaker := relay.NewCircuitBreaker()
+	for i := 0; i < 3; i++ {
+		breaker.RecordFailure("lurus-api", "offline in tests") // keep the builtin out of the chain
+	}
+	for i := 0; i < 20; i++ {
+		breaker.RecordLatency("slow", 20*time.Millisecond)
+	}
+	router, err := relay.NewRouter(dir, store, breaker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := router.LoadRulesYAML(`
+rules:
+  - name: interactive
+    match_model_prefix: gpt
+    prefer_endpoint_id: slow
+    hedge_percentile: 90
+`); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://127.0.0.1:1"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	app, _ := reg.Register("Codex", "", "")
+
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"total_tokens":12`) {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	meter.Flush()
+	recs := meter.RecentRecords(10)
+	if len(recs) != 1 || recs[0].ServedBy != "fast" || recs[0].TokensOut != 7 {
+		t.Errorf("records = %+v, want one booking for the fast leg", recs)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
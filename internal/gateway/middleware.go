@@ -0,0 +1,470 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"lurus-switch/internal/budget"
+)
+
+// Request pipeline. Every front door (OpenAI, Anthropic Messages,
+// Gemini generateContent) runs the same ordered list of Middleware
+// stages around its upstream round trip:
+//
+//	request phase   OnRequest   client body read, before translation / routing
+//	response phase  OnResponse  upstream answered, body not yet consumed
+//	chunk phase     OnChunk     every byte written back to the client
+//
+// DLP, the Budget Wall, usage scanning and the thinking-budget
+// rectifier are the built-in stages (see BuiltinMiddleware). Org-specific
+// stages — header stamping, prompt prefixes, extra audit — are spliced
+// into the same list with SetMiddleware, so the handlers never need to
+// learn about them.
+
+// Dialect names the API shape a front door speaks. Exchange.Body and
+// the bytes OnChunk sees are in this shape.
+type Dialect string
+
+const (
+	DialectOpenAI    Dialect = "openai"    // /v1/chat/completions and the rest of /v1/*
+	DialectResponses Dialect = "responses" // /v1/responses (OpenAI Responses API)
+	DialectAnthropic Dialect = "anthropic" // /v1/messages
+	DialectGemini    Dialect = "gemini"    // /v1beta/models/{model}:generateContent
+)
+
+// Middleware is one pipeline stage. Embed MiddlewareBase to implement
+// only the hooks a stage needs.
+type Middleware interface {
+	// Name identifies the stage in logs and error messages.
+	Name() string
+
+	// OnRequest runs once the client body is read. It may rewrite
+	// ex.Body or ex.Header; returning an error rejects the request
+	// (a *StageError picks the status the client sees).
+	OnRequest(ex *Exchange) error
+
+	// OnResponse runs when an upstream has answered, before the body is
+	// consumed. A stage that reads resp.Body must put the bytes back. It
+	// may ask for one retry with a rewritten body via ex.RetryWith.
+	OnResponse(ex *Exchange, resp *http.Response) error
+
+	// OnChunk sees every write to the client — each stream chunk, or a
+	// buffered body in one piece — after translation, in ex.Dialect's
+	// shape. It returns the bytes to write in its place; an error aborts
+	// the response.
+	OnChunk(ex *Exchange, chunk []byte) ([]byte, error)
+}
+
+// MiddlewareBase is a no-op Middleware for embedding.
+type MiddlewareBase struct{}
+
+func (MiddlewareBase) OnRequest(*Exchange) error                  { return nil }
+func (MiddlewareBase) OnResponse(*Exchange, *http.Response) error { return nil }
+func (MiddlewareBase) OnChunk(_ *Exchange, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+// Exchange is one client request as it moves through the pipeline.
+type Exchange struct {
+	Request *http.Request // the client's request; its body has been read into Body
+	Meta    *RequestMeta  // nil outside withAuth (tests)
+	Dialect Dialect
+	Path    string
+	Model   string // model the client asked for
+
+	// Body is the client's request body in Dialect's shape. Request-phase
+	// stages may rewrite it; translation and routing read it afterwards.
+	Body []byte
+	// Header holds the headers forwarded upstream.
+	Header http.Header
+	// Streaming is set before the response phase: the upstream answered
+	// with an event stream.
+	Streaming bool
+
+	retryBody []byte              // set by RetryWith
+	usage     *sseUsageScanner    // fed by the usage stage on OpenAI passthrough
+	dlpOut    *dlpResponseScanner // outbound scanning state of the dlp stage
+	cache     *cacheState         // set by the cache stage for cacheable requests
+}
+
+// RetryWith asks the pipeline to send the request again with body in
+// place of ex.Body once the response phase finishes. Only one retry is
+// made per request; if it fails the original response stands.
+func (ex *Exchange) RetryWith(body []byte) {
+	ex.retryBody = body
+}
+
+// scannedUsage returns the usage the usage stage read off the bytes sent
+// to the client; zero when the stage is not installed or saw none.
+func (ex *Exchange) scannedUsage() UsageFromResponse {
+	if ex.usage == nil {
+		return UsageFromResponse{}
+	}
+	return ex.usage.finish()
+}
+
+// StageError rejects a request with an explicit status. Front doors
+// render it in their own error envelope; Code is used where the dialect
+// has a machine-readable error code (OpenAI).
+type StageError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *StageError) Error() string { return e.Message }
+
+// asStageError wraps a plain error returned by stage into a 500.
+func asStageError(stage string, err error) *StageError {
+	var se *StageError
+	if errors.As(err, &se) {
+		return se
+	}
+	return &StageError{
+		Status:  http.StatusInternalServerError,
+		Code:    "middleware_error",
+		Message: fmt.Sprintf("gateway middleware %s: %v", stage, err),
+	}
+}
+
+// SetMiddleware replaces the request pipeline. Stages run in slice order
+// in every phase. Start from BuiltinMiddleware to keep DLP, the Budget
+// Wall and metering — dropping the usage stage leaves OpenAI-protocol
+// traffic unmetered. Safe to call after Start; the next request picks up
+// the change.
+func (s *Server) SetMiddleware(stages ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append([]Middleware(nil), stages...)
+}
+
+// BuiltinMiddleware returns the default pipeline in its default order:
+// DLP, response cache, Budget Wall, usage scanning, thinking-budget
+// rectifier.
+func (s *Server) BuiltinMiddleware() []Middleware {
+	return []Middleware{
+		dlpStage{s: s},
+		cacheStage{s: s},
+		budgetStage{s: s},
+		usageStage{},
+		rectifierStage{},
+	}
+}
+
+// pipeline snapshots the configured stages for one request.
+func (s *Server) pipeline() pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.middleware
+}
+
+type pipeline []Middleware
+
+// request runs the request phase; a non-nil result rejects the request.
+func (p pipeline) request(ex *Exchange) *StageError {
+	for _, m := range p {
+		if err := m.OnRequest(ex); err != nil {
+			return asStageError(m.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (p pipeline) response(ex *Exchange, resp *http.Response) error {
+	ex.Streaming = strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+	for _, m := range p {
+		if err := m.OnResponse(ex, resp); err != nil {
+			return asStageError(m.Name(), err)
+		}
+	}
+	return nil
+}
+
+// roundTrip calls dispatch (translate, route, send ex.Body upstream) and
+// runs the response phase, retrying once if a stage asked for it. A
+// rejected response is closed and its *StageError returned; dispatch
+// errors are returned as-is.
+func (p pipeline) roundTrip(ex *Exchange, dispatch func() (*http.Response, error)) (*http.Response, error) {
+	resp, err := dispatch()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.response(ex, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if ex.retryBody == nil {
+		return resp, nil
+	}
+
+	orig := ex.Body
+	ex.Body, ex.retryBody = ex.retryBody, nil
+	retryResp, err := dispatch()
+	if err != nil {
+		ex.Body = orig
+		return resp, nil
+	}
+	resp.Body.Close()
+	if err := p.response(ex, retryResp); err != nil {
+		retryResp.Body.Close()
+		return nil, err
+	}
+	ex.retryBody = nil
+	return retryResp, nil
+}
+
+// ChunkFinisher is an optional Middleware extension for stages that
+// hold bytes back in OnChunk. FinishChunks runs once after the handler
+// has written its last byte; what it returns is passed through the
+// stages after it and written to the client.
+type ChunkFinisher interface {
+	FinishChunks(ex *Exchange) ([]byte, error)
+}
+
+// wrap routes every write to the client through the chunk hooks. The
+// returned finish func must run when the handler is done writing.
+func (p pipeline) wrap(ex *Exchange, w http.ResponseWriter) (http.ResponseWriter, func()) {
+	if len(p) == 0 {
+		return w, func() {}
+	}
+	pw := &pipelineWriter{ResponseWriter: w, ex: ex, stages: p}
+	return pw, pw.finish
+}
+
+// pipelineWriter is the http.ResponseWriter handlers write through.
+// Flush is always available so streaming handlers keep streaming; it is
+// a no-op when the underlying writer cannot flush.
+type pipelineWriter struct {
+	http.ResponseWriter
+	ex     *Exchange
+	stages pipeline
+}
+
+func (w *pipelineWriter) Write(p []byte) (int, error) {
+	if err := w.writeFrom(0, p); err != nil {
+		return 0, err
+	}
+	// Callers wrote p; what the stages did with it is not a short write.
+	return len(p), nil
+}
+
+// writeFrom passes chunk through the chunk hooks of stages[i:] and
+// writes the result.
+func (w *pipelineWriter) writeFrom(i int, chunk []byte) error {
+	for _, m := range w.stages[i:] {
+		var err error
+		if chunk, err = m.OnChunk(w.ex, chunk); err != nil {
+			return asStageError(m.Name(), err)
+		}
+	}
+	if len(chunk) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(chunk)
+	return err
+}
+
+func (w *pipelineWriter) finish() {
+	for i, m := range w.stages {
+		f, ok := m.(ChunkFinisher)
+		if !ok {
+			continue
+		}
+		if tail, err := f.FinishChunks(w.ex); err == nil && len(tail) > 0 {
+			if w.writeFrom(i+1, tail) == nil {
+				w.Flush()
+			}
+		}
+	}
+}
+
+func (w *pipelineWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *pipelineWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// --- built-in stages ---
+
+// dlpStage scans the inbound body: block policies reject with 451,
+// redact policies swap the body for the masked version. On the way out
+// it masks hits in the client-bound bytes (see dlp_response.go).
+type dlpStage struct {
+	MiddlewareBase
+	s *Server
+}
+
+func (dlpStage) Name() string { return "dlp" }
+
+func (d dlpStage) OnRequest(ex *Exchange) error {
+	body, blocked, reason := d.s.applyDLPRequest(ex.Body, ex.Path)
+	if blocked {
+		return &StageError{Status: http.StatusUnavailableForLegalReasons, Code: "dlp_blocked", Message: reason}
+	}
+	ex.Body = body
+	return nil
+}
+
+func (d dlpStage) OnResponse(ex *Exchange, _ *http.Response) error {
+	ex.dlpOut = newDLPResponseScanner(d.s, ex)
+	return nil
+}
+
+func (dlpStage) OnChunk(ex *Exchange, chunk []byte) ([]byte, error) {
+	if ex.dlpOut == nil {
+		return chunk, nil
+	}
+	return ex.dlpOut.feed(chunk), nil
+}
+
+func (dlpStage) FinishChunks(ex *Exchange) ([]byte, error) {
+	if ex.dlpOut == nil {
+		return nil, nil
+	}
+	return ex.dlpOut.finish(), nil
+}
+
+// budgetStage is the Active Budget Wall: bail out before paying upstream
+// tokens when the spend cap is already reached, or when the request's
+// worst case (estimated input plus its max output tokens) would take a
+// USD cap past its limit. Usage is fed back into the guard by
+// recordUsage once the response is booked. Cache hits cost nothing and
+// are let through.
+type budgetStage struct {
+	MiddlewareBase
+	s *Server
+}
+
+func (budgetStage) Name() string { return "budget" }
+
+func (b budgetStage) OnRequest(ex *Exchange) error {
+	if ex.cacheHit() {
+		return nil
+	}
+	b.s.mu.Lock()
+	guard := b.s.guard
+	b.s.mu.Unlock()
+	if guard == nil {
+		return nil
+	}
+	var sub budget.Subject
+	if m := ex.Meta; m != nil {
+		sub = budget.Subject{AppID: m.AppID, EmployeeID: m.OwnerEmployeeID, CostCenter: m.CostCenter}
+	}
+	est := budget.Estimate{
+		Model:           ex.Model,
+		InputTokens:     estimateTokens(ex.Body),
+		MaxOutputTokens: maxOutputTokens(ex.Dialect, ex.Body),
+	}
+	if v := guard.Check(sub, est); !v.Allowed {
+		return &StageError{
+			Status: http.StatusTooManyRequests,
+			Code:   "spend_cap_reached",
+			Message: fmt.Sprintf("Lurus Switch budget wall: %s. Raise the limit or click 'reset session' in the Budget panel.",
+				v.Reason),
+		}
+	}
+	return nil
+}
+
+// maxOutputTokens reads the request's output cap in its dialect's field;
+// 0 when the request sets none.
+func maxOutputTokens(d Dialect, body []byte) int64 {
+	var probe struct {
+		MaxTokens           int64 `json:"max_tokens"`
+		MaxCompletionTokens int64 `json:"max_completion_tokens"`
+		MaxOutputTokens     int64 `json:"max_output_tokens"`
+		GenerationConfig    struct {
+			MaxOutputTokens int64 `json:"maxOutputTokens"`
+		} `json:"generationConfig"`
+	}
+	_ = json.Unmarshal(body, &probe)
+	switch d {
+	case DialectResponses:
+		return probe.MaxOutputTokens
+	case DialectGemini:
+		return probe.GenerationConfig.MaxOutputTokens
+	case DialectOpenAI:
+		if probe.MaxCompletionTokens > 0 {
+			return probe.MaxCompletionTokens
+		}
+	}
+	return probe.MaxTokens
+}
+
+// usageStage makes OpenAI-protocol traffic meterable. On the way in it
+// injects stream_options.include_usage into streaming requests: without
+// it upstreams emit no usage chunk, the scanner books 0 tokens and
+// OpenAI streaming silently bypasses the spend wall. On the way out it
+// reads usage off the bytes relayed to the client (the Anthropic and
+// Gemini front doors take usage from their translators instead).
+// Responses API streams always end with usage, so their requests are
+// left alone.
+type usageStage struct{ MiddlewareBase }
+
+func (usageStage) Name() string { return "usage" }
+
+func (usageStage) OnRequest(ex *Exchange) error {
+	if ex.Dialect == DialectOpenAI {
+		ex.Body = ensureStreamUsage(ex.Body)
+	}
+	return nil
+}
+
+func (usageStage) OnResponse(ex *Exchange, _ *http.Response) error {
+	switch ex.Dialect {
+	case DialectOpenAI:
+		ex.usage = &sseUsageScanner{}
+	case DialectResponses:
+		ex.usage = &sseUsageScanner{responses: true}
+	}
+	return nil
+}
+
+func (usageStage) OnChunk(ex *Exchange, chunk []byte) ([]byte, error) {
+	switch {
+	case ex.usage == nil:
+	case ex.Streaming:
+		ex.usage.feed(chunk)
+	default:
+		if u := ex.usage.extractBody(chunk); usageNonZero(u) {
+			ex.usage.last = u
+		}
+	}
+	return chunk, nil
+}
+
+// rectifierStage is the Thinking Budget Rectifier: when an upstream
+// rejects a request over its thinking budget, clamp the budget and retry
+// once (inspired by CC-Switch).
+type rectifierStage struct{ MiddlewareBase }
+
+// rectifierPeekBytes bounds how much of an error body is read to decide.
+const rectifierPeekBytes = 4096
+
+func (rectifierStage) Name() string { return "rectifier" }
+
+func (rectifierStage) OnResponse(ex *Exchange, resp *http.Response) error {
+	if resp.StatusCode != http.StatusBadRequest {
+		return nil
+	}
+	errBody, err := io.ReadAll(io.LimitReader(resp.Body, rectifierPeekBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(errBody), resp.Body), resp.Body}
+	if err != nil || !ShouldRectifyThinkingBudget(string(errBody)) {
+		return nil
+	}
+	if rectified, result := RectifyThinkingBudget(ex.Body); result.Applied {
+		ex.RetryWith(rectified)
+	}
+	return nil
+}
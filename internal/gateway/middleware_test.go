@@ -0,0 +1,229 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/budget"
+)
+
+// stampStage is the kind of org-specific stage the pipeline exists for:
+// it stamps an outbound header and prefixes the OpenAI system prompt.
+type stampStage struct {
+	MiddlewareBase
+	order *[]string
+}
+
+func (stampStage) Name() string { return "stamp" }
+
+func (st stampStage) OnRequest(ex *Exchange) error {
+	*st.order = append(*st.order, "stamp")
+	ex.Header.Set("X-Org", "acme")
+	if ex.Dialect == DialectOpenAI {
+		ex.Body = bytes.Replace(ex.Body, []byte(`"messages":[`),
+			[]byte(`"messages":[{"role":"system","content":"ACME policy"},`), 1)
+	}
+	return nil
+}
+
+// upperStage rewrites every client-bound chunk.
+type upperStage struct{ MiddlewareBase }
+
+func (upperStage) Name() string { return "upper" }
+
+func (upperStage) OnChunk(_ *Exchange, chunk []byte) ([]byte, error) {
+	return bytes.ReplaceAll(chunk, []byte("hello"), []byte("HELLO")), nil
+}
+
+// rejectStage refuses everything with a 403.
+type rejectStage struct{ MiddlewareBase }
+
+func (rejectStage) Name() string { return "reject" }
+
+func (rejectStage) OnRequest(*Exchange) error {
+	return &StageError{Status: http.StatusForbidden, Code: "org_policy", Message: "blocked by org policy"}
+}
+
+func serve(srv *Server, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_CustomStagesRunAroundBuiltins(t *testing.T) {
+	var gotHeader, gotBody string
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Org")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","choices":[{"message":{"content":"hello there"}}],"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}`)
+	})
+	defer upstream.Close()
+
+	var order []string
+	srv.SetMiddleware(append([]Middleware{stampStage{order: &order}, upperStage{}}, srv.BuiltinMiddleware()...)...)
+	app, _ := reg.Register("Test App", "", "")
+
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if gotHeader != "acme" {
+		t.Errorf("X-Org upstream = %q", gotHeader)
+	}
+	if !strings.Contains(gotBody, `"content":"ACME policy"`) {
+		t.Errorf("prompt prefix missing upstream: %s", gotBody)
+	}
+	if !strings.Contains(w.Body.String(), "HELLO there") {
+		t.Errorf("chunk hook not applied: %s", w.Body.String())
+	}
+	if len(order) != 1 {
+		t.Errorf("request hook ran %d times", len(order))
+	}
+	// The usage stage still reads the (rewritten) body and books it.
+	if s := meter.TodaySummary(); s.TotalCalls != 1 || s.TokensIn != 4 || s.TokensOut != 2 {
+		t.Errorf("metered calls/in/out = %d/%d/%d", s.TotalCalls, s.TokensIn, s.TokensOut)
+	}
+}
+
+func TestMiddleware_StageErrorUsesFrontDoorEnvelope(t *testing.T) {
+	var hits int32
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	})
+	defer upstream.Close()
+	srv.SetMiddleware(rejectStage{})
+	app, _ := reg.Register("Test App", "", "")
+
+	w := serve(srv, "/v1/messages", app.Token, `{"model":"claude-x","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status=%d", w.Code)
+	}
+	var env struct {
+		Type  string `json:"type"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil || env.Type != "error" || env.Error.Message != "blocked by org policy" {
+		t.Errorf("want an Anthropic error envelope, got %s", w.Body.String())
+	}
+
+	w = serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"org_policy"`) {
+		t.Errorf("OpenAI front door: status=%d body=%s", w.Code, w.Body.String())
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Error("upstream must not be called for a rejected request")
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestMiddleware_RectifierRetriesOnce(t *testing.T) {
+	var bodies []string
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"thinking.budget_tokens must be less than max_tokens"}}`)
+			return
+		}
+		io.WriteString(w, `{"id":"c2","choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Test App", "", "")
+
+	w := serve(srv, "/v1/chat/completions", app.Token,
+		`{"model":"claude-x","max_tokens":1000,"thinking":{"type":"enabled","budget_tokens":50000},"messages":[]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("upstream calls = %d, want 2", len(bodies))
+	}
+	if !strings.Contains(bodies[1], `"budget_tokens":32000`) {
+		t.Errorf("retry body not rectified: %s", bodies[1])
+	}
+}
+
+func TestMiddleware_RectifierLeavesOtherErrorsAlone(t *testing.T) {
+	calls := 0
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"message":"unknown field foo"}}`)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Test App", "", "")
+
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unknown field foo") {
+		t.Errorf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls)
+	}
+}
+
+func TestMiddleware_BudgetWallScopedToApp(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, okUpstream)
+	defer upstream.Close()
+	capped, _ := reg.Register("Capped", "", "")
+	other, _ := reg.Register("Other", "", "")
+	guard, _ := budget.New("", nil)
+	guard.SetConfig(budget.Config{Enabled: true, Limits: []budget.Limit{
+		{Scope: budget.ScopeApp, Key: capped.ID, Window: budget.WindowDaily, Tokens: 500},
+	}})
+	srv.SetBudgetGuard(guard)
+
+	serve(srv, "/v1/chat/completions", capped.Token, `{"model":"gpt-4o","messages":[]}`) // books 700 tokens
+	w := serve(srv, "/v1/chat/completions", capped.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), capped.ID) {
+		t.Errorf("capped app: status %d body %s", w.Code, w.Body.String())
+	}
+	if w := serve(srv, "/v1/chat/completions", other.Token, `{"model":"gpt-4o","messages":[]}`); w.Code != http.StatusOK {
+		t.Errorf("other app blocked: status %d", w.Code)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestMiddleware_BudgetWallRefusesCostlyRequestPreFlight(t *testing.T) {
+	var hits int32
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		okUpstream(w, r)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Claude Code", "", "")
+	guard, _ := budget.New("", nil)
+	guard.SetConfig(budget.Config{Enabled: true, SessionUSD: 0.50})
+	srv.SetBudgetGuard(guard)
+
+	// 32K output tokens on opus could cost $2.40 — refused before any upstream call.
+	w := serve(srv, "/v1/messages", app.Token, `{"model":"claude-opus-4-1","max_tokens":32000,"messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "would be exceeded") {
+		t.Errorf("status %d body %s", w.Code, w.Body.String())
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Error("upstream was called for a refused request")
+	}
+	if w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o-mini","max_tokens":500,"messages":[]}`); w.Code != http.StatusOK {
+		t.Errorf("cheap request: status %d body %s", w.Code, w.Body.String())
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
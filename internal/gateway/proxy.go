@@ -3,6 +3,7 @@ package gateway
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -22,11 +23,21 @@ const (
 // swapping the per-app token for the user's Lurus Cloud token.
 // Supports both streaming (SSE) and non-streaming responses.
 func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
+	s.proxyOpenAI(w, r, DialectOpenAI)
+}
+
+// proxyOpenAI is the pass-through path shared by the OpenAI-protocol
+// front doors; dialect selects how usage is read off the response.
+func (s *Server) proxyOpenAI(w http.ResponseWriter, r *http.Request, dialect Dialect) {
 	s.activeReqs.Add(1)
 	defer s.activeReqs.Add(-1)
 	s.totalReqs.Add(1)
 
 	meta := getMeta(r)
+	mw := s.pipeline()
+	ex := &Exchange{Request: r, Meta: meta, Dialect: dialect, Path: r.URL.Path}
+	w, finish := mw.wrap(ex, w)
+	defer finish()
 
 	// Read request body (needed to parse model name for metering).
 	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
@@ -35,29 +46,29 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	r.Body.Close()
+	ex.Body = body
+	ex.Model = extractModelFromBody(body)
+	if dialect == DialectResponses && meta != nil {
+		meta.SessionID = s.responseSessions.lookup(previousResponseID(body))
+	}
 
-	// DLP middleware — scan the raw body before any further processing.
-	// Block policy returns 451 immediately; redact policy swaps the body
-	// so downstream forwarding (and metering) sees the masked version.
-	body, dlpBlocked, dlpReason := s.applyDLPRequest(body, r.URL.Path)
-	if dlpBlocked {
-		writeOpenAIError(w, http.StatusUnavailableForLegalReasons, "dlp_blocked", dlpReason)
-		s.recordError(meta, "", dlpReason)
+	// Collect request headers for upstream (swap auth token).
+	ex.Header = make(http.Header)
+	copyRequestHeaders2(ex.Header, r)
+
+	// Request phase: DLP (451 on block, masked body on redact), the
+	// Budget Wall, include_usage injection, and any org stages.
+	if se := mw.request(ex); se != nil {
+		writeOpenAIError(w, se.Status, se.Code, se.Message)
+		s.recordError(meta, ex.Model, se.Message)
+		return
+	}
+	if s.serveCached(w, mw, ex) {
 		return
 	}
-
-	// Streaming budget-wall fix: OpenAI-protocol streaming responses only
-	// emit a usage chunk when the request asks for it via
-	// stream_options.include_usage. Without it the sseUsageScanner finds no
-	// usage line, books 0 tokens, and the budget guard never increments —
-	// i.e. OpenAI streaming silently bypasses the spend wall. (The Anthropic
-	// path requests usage during translation, so only Anthropic was covered
-	// before.) Inject include_usage for streaming requests so the existing
-	// scanner has a usage line to read.
-	body = ensureStreamUsage(body)
 
 	// Extract model from request body for metering.
-	model := extractModelFromBody(body)
+	model := extractModelFromBody(ex.Body)
 	if meta != nil {
 		meta.Model = model
 	}
@@ -65,7 +76,7 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 	// Check upstream is configured.
 	s.mu.Lock()
 	upstreamURL := s.cfg.UpstreamURL
-	userToken := s.cfg.UserToken
+	userToken := usableUserToken(s.cfg)
 	s.mu.Unlock()
 
 	if upstreamURL == "" {
@@ -79,104 +90,87 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Active Budget Wall — bail out *before* paying upstream tokens
-	// when the user-configured spend cap is already reached.
-	s.mu.Lock()
-	guard := s.guard
-	s.mu.Unlock()
-	if guard != nil {
-		if v := guard.Check(); !v.Allowed {
-			writeOpenAIError(w, http.StatusTooManyRequests, "spend_cap_reached",
-				fmt.Sprintf("Lurus Switch budget wall: %s. Raise the limit or click 'reset session' in the Budget panel.", v.Reason))
-			s.recordError(meta, model, v.Reason)
-			return
-		}
-	}
-
 	// Normalize base URL: strip /v1 to prevent path duplication.
 	normalizedURL := NormalizeChannelBaseURL(upstreamURL)
 
-	// Collect request headers for upstream (swap auth token).
-	outHeaders := make(http.Header)
-	copyRequestHeaders2(outHeaders, r)
-
 	// Build the upstream chain. If the relay router has healthy
 	// endpoints + a matching rule (or tool→mapping), use that as the
 	// authoritative chain. Otherwise fall back to the cfg-driven path
 	// (UpstreamURL + persisted FallbackChain entries) for zero
-	// behaviour change in unconfigured installs.
-	chain, matchedBy, routerOK := s.buildChainFromRouter(
-		toolFromRequest(r),
-		model,
-		estimateTokens(body),
-		bodyHasTools(body),
-		userToken,
-	)
-
-	var resp *http.Response
-	var servedBy string
-	if routerOK {
-		if meta != nil {
-			meta.MatchedBy = matchedBy
-		}
-		resp, servedBy, err = s.fallback.TryUpstreamChain(
-			r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
-			body, outHeaders,
-			chain,
-		)
-	} else {
-		resp, servedBy, err = s.fallback.TryUpstream(
-			r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
-			body, outHeaders,
-			normalizedURL, userToken,
+	// behaviour change in unconfigured installs. Re-run on a pipeline
+	// retry, so a rectified body is re-translated for every entry.
+	var chain []FallbackEntry
+	var routerOK bool
+	var served FallbackEntry
+	dispatch := func() (*http.Response, error) {
+		routerChain, matchedBy, ok := s.buildChainFromRouter(
+			toolFromRequest(r),
+			model,
+			estimateTokens(ex.Body),
+			bodyHasTools(ex.Body),
+			meta,
+			userToken,
 		)
+		// Anthropic-protocol endpoints get a translated body (chat) or are
+		// dropped (paths with no Messages-API analog).
+		if ok {
+			chain, ok = chainForOpenAIRequest(routerChain, r.URL.Path, ex.Body)
+		}
+		routerOK = ok
+
+		var resp *http.Response
+		var err error
+		if routerOK {
+			if meta != nil {
+				meta.MatchedBy = matchedBy
+			}
+			resp, served, err = s.fallback.TryUpstreamChain(
+				r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
+				ex.Body, ex.Header,
+				chain,
+			)
+		} else {
+			resp, served, err = s.fallback.TryUpstream(
+				r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
+				ex.Body, ex.Header,
+				normalizedURL, userToken,
+			)
+		}
+		return resp, err
 	}
+
+	// Response phase: the rectifier retries thinking-budget rejections
+	// once with a clamped body.
+	resp, err := mw.roundTrip(ex, dispatch)
 	if err != nil {
-		writeOpenAIError(w, http.StatusBadGateway, "upstream_error",
-			fmt.Sprintf("all upstreams failed: %v", err))
+		var se *StageError
+		if errors.As(err, &se) {
+			writeOpenAIError(w, se.Status, se.Code, se.Message)
+		} else {
+			writeOpenAIError(w, http.StatusBadGateway, "upstream_error",
+				fmt.Sprintf("all upstreams failed: %v", err))
+		}
 		s.recordError(meta, model, err.Error())
 		return
 	}
 	defer resp.Body.Close()
 
 	if meta != nil {
-		meta.ServedBy = servedBy
+		meta.ServedBy = served.Name
+		meta.ServedModel = s.servedModel(served, model)
+		meta.ServedKeyID = served.KeyID
+	}
+
+	// An Anthropic-protocol upstream answered: translate its response
+	// back to the OpenAI shape.
+	if routerOK && servedByAnthropic(served) {
+		s.proxyFromAnthropic(w, resp, meta, model, ex.Body)
+		return
 	}
 
-	// Thinking Budget Rectifier: if upstream returns a budget constraint error,
-	// auto-fix the request and retry once (inspired by CC-Switch).
+	// A 400 the rectifier could not fix — return the upstream error as-is.
 	if resp.StatusCode == http.StatusBadRequest {
-		errBody, readErr := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		resp.Body.Close()
-		if readErr == nil && ShouldRectifyThinkingBudget(string(errBody)) {
-			rectifiedBody, result := RectifyThinkingBudget(body)
-			if result.Applied {
-				var retryResp *http.Response
-				var retryErr error
-				// Retry via the same chain we used the first time.
-				if routerOK {
-					retryResp, _, retryErr = s.fallback.TryUpstreamChain(
-						r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
-						rectifiedBody, outHeaders,
-						chain,
-					)
-				} else {
-					retryResp, _, retryErr = s.fallback.TryUpstream(
-						r.Context(), r.Method, r.URL.Path, r.URL.RawQuery,
-						rectifiedBody, outHeaders,
-						normalizedURL, userToken,
-					)
-				}
-				if retryErr == nil {
-					defer retryResp.Body.Close()
-					resp = retryResp
-					body = rectifiedBody
-					// Fall through to normal response handling below
-					goto handleResponse
-				}
-			}
-		}
-		// Budget rectifier didn't apply or retry failed — return original error
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, rectifierPeekBytes))
 		copyResponseHeaders(w, resp)
 		w.WriteHeader(resp.StatusCode)
 		w.Write(errBody)
@@ -184,19 +178,16 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-handleResponse:
-	// Determine if this is a streaming response.
-	isStreaming := strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
-
-	if isStreaming {
-		s.proxyStreaming(w, resp, meta, model)
+	if ex.Streaming {
+		s.proxyStreaming(w, resp, meta, model, ex)
 	} else {
-		s.proxyBuffered(w, resp, meta, model)
+		s.proxyBuffered(w, resp, meta, model, ex)
 	}
 }
 
-// proxyBuffered handles non-streaming responses: read full body, extract usage, forward.
-func (s *Server) proxyBuffered(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string) {
+// proxyBuffered handles non-streaming responses: read full body, forward,
+// then book the usage the usage stage read off it.
+func (s *Server) proxyBuffered(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string, ex *Exchange) {
 	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestBodySize))
 	if err != nil {
 		writeOpenAIError(w, http.StatusBadGateway, "upstream_read_error",
@@ -207,19 +198,20 @@ func (s *Server) proxyBuffered(w http.ResponseWriter, resp *http.Response, meta
 	// Copy response headers.
 	copyResponseHeaders(w, resp)
 	w.WriteHeader(resp.StatusCode)
-	w.Write(respBody)
+	if _, err := w.Write(respBody); err != nil {
+		s.recordError(meta, model, err.Error())
+		return
+	}
 
-	// Extract usage for metering.
-	usage := extractUsageFromBody(respBody)
-	s.recordUsage(meta, model, usage, resp.StatusCode, false)
+	s.recordUsage(meta, model, ex.scannedUsage(), resp.StatusCode, false)
 }
 
 // proxyStreaming pipes SSE chunks from upstream to client in real-time.
-func (s *Server) proxyStreaming(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string) {
+func (s *Server) proxyStreaming(w http.ResponseWriter, resp *http.Response, meta *RequestMeta, model string, ex *Exchange) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		// Fallback to buffered if flushing is not supported.
-		s.proxyBuffered(w, resp, meta, model)
+		s.proxyBuffered(w, resp, meta, model, ex)
 		return
 	}
 
@@ -227,23 +219,28 @@ func (s *Server) proxyStreaming(w http.ResponseWriter, resp *http.Response, meta
 	copyResponseHeaders(w, resp)
 	w.WriteHeader(resp.StatusCode)
 
-	var scanner sseUsageScanner
 	buf := make([]byte, 4096)
-
 	for {
 		n, readErr := resp.Body.Read(buf)
 		if n > 0 {
-			chunk := buf[:n]
-			w.Write(chunk)
+			if _, err := w.Write(buf[:n]); err != nil {
+				s.recordError(meta, model, "stream: "+err.Error())
+				return
+			}
 			flusher.Flush()
-			scanner.feed(chunk)
 		}
 		if readErr != nil {
+			var si *streamInterrupted
+			if errors.As(readErr, &si) {
+				writeStreamError(w, ex.Dialect, si.Error())
+				s.recordInterrupted(meta, model, ex.scannedUsage(), si)
+				return
+			}
 			break
 		}
 	}
 
-	s.recordUsage(meta, model, scanner.finish(), resp.StatusCode, true)
+	s.recordUsage(meta, model, ex.scannedUsage(), resp.StatusCode, true)
 }
 
 // --- metering helpers ---
@@ -255,15 +252,20 @@ func (s *Server) recordUsage(meta *RequestMeta, model string, usage UsageFromRes
 	if usage.Model != "" {
 		model = usage.Model
 	}
+	if usage.ResponseID != "" {
+		meta.SessionID = s.responseSessions.link(usage.ResponseID, meta.SessionID)
+	}
 
 	// Normalize the OpenAI-shape usage into billable streams. OpenAI reports
 	// cached_tokens as a SUBSET already inside prompt_tokens, so billing
 	// prompt_tokens as fresh input AND cached_tokens as cache-read would
 	// double-count the cached portion. Subtract it out: fresh input is
 	// (prompt − cached), and cached lands on the discounted cache-read stream.
-	// CacheCreate is 0 — OpenAI doesn't bill a separate cache-write. Reasoning
-	// is display-only (already inside completion_tokens), never billed twice.
-	tokensIn := usage.PromptTokens - usage.CachedTokens
+	// CacheCreate is 0 — OpenAI doesn't bill a separate cache-write — except
+	// when an Anthropic-protocol upstream served the request, whose cache
+	// writes are folded into PromptTokens the same way. Reasoning is
+	// display-only (already inside completion_tokens), never billed twice.
+	tokensIn := usage.PromptTokens - usage.CachedTokens - usage.CacheCreateTokens
 	if tokensIn < 0 {
 		tokensIn = 0 // defensive: a malformed upstream can't drive billing negative
 	}
@@ -275,6 +277,7 @@ func (s *Server) recordUsage(meta *RequestMeta, model string, usage UsageFromRes
 		TokensIn:          tokensIn,
 		TokensOut:         usage.CompletionTokens,
 		CacheReadTokens:   usage.CachedTokens,
+		CacheCreateTokens: usage.CacheCreateTokens,
 		ReasoningTokens:   usage.ReasoningTokens,
 		LatencyMs:         time.Since(meta.StartTime).Milliseconds(),
 		StatusCode:        statusCode,
@@ -283,10 +286,14 @@ func (s *Server) recordUsage(meta *RequestMeta, model string, usage UsageFromRes
 		EmployeeID: meta.OwnerEmployeeID,
 		CostCenter: meta.CostCenter,
 		// Routing — populated when the relay router served this request.
-		ServedBy:  meta.ServedBy,
-		MatchedBy: meta.MatchedBy,
+		ServedBy:    meta.ServedBy,
+		MatchedBy:   meta.MatchedBy,
+		KeyID:       meta.ServedKeyID,
+		ServedModel: meta.ServedModel,
+		SessionID:   meta.SessionID,
 	}
 	s.meter.Record(rec)
+	s.spendTokens(rec)
 
 	// Mirror the same facts into the optional OTel recorder (no-op unless
 	// observability is enabled). Built from rec so the two stay consistent.
@@ -335,6 +342,9 @@ func (s *Server) recordError(meta *RequestMeta, model, errMsg string) {
 		Timestamp:    time.Now(),
 		ServedBy:     meta.ServedBy,
 		MatchedBy:    meta.MatchedBy,
+		KeyID:        meta.ServedKeyID,
+		ServedModel:  meta.ServedModel,
+		SessionID:    meta.SessionID,
 	}
 	s.meter.Record(rec)
 
@@ -567,13 +577,15 @@ const maxSSELineBuf = 1 << 20 // 1 MB
 type sseUsageScanner struct {
 	buf  []byte            // unterminated trailing bytes not yet scanned
 	last UsageFromResponse // most recent non-zero usage seen
+
+	responses bool // Responses API shapes instead of Chat Completions
 }
 
 // feed appends a chunk and scans every newly completed line for usage.
 func (s *sseUsageScanner) feed(chunk []byte) {
 	s.buf = append(s.buf, chunk...)
 	if idx := bytes.LastIndexByte(s.buf, '\n'); idx >= 0 {
-		if u := extractUsageFromSSEChunk(s.buf[:idx+1]); usageNonZero(u) {
+		if u := s.extractLines(s.buf[:idx+1]); usageNonZero(u) {
 			s.last = u
 		}
 		// Retain only the unterminated remainder after the last newline.
@@ -588,13 +600,28 @@ func (s *sseUsageScanner) feed(chunk []byte) {
 // returns the final observed usage.
 func (s *sseUsageScanner) finish() UsageFromResponse {
 	if len(s.buf) > 0 {
-		if u := extractUsageFromSSEChunk(s.buf); usageNonZero(u) {
+		if u := s.extractLines(s.buf); usageNonZero(u) {
 			s.last = u
 		}
 	}
 	return s.last
 }
 
+func (s *sseUsageScanner) extractLines(lines []byte) UsageFromResponse {
+	if s.responses {
+		return extractResponsesUsageFromSSEChunk(lines)
+	}
+	return extractUsageFromSSEChunk(lines)
+}
+
+// extractBody reads usage off a buffered (non-stream) response body.
+func (s *sseUsageScanner) extractBody(body []byte) UsageFromResponse {
+	if s.responses {
+		return extractResponsesUsage(body)
+	}
+	return extractUsageFromBody(body)
+}
+
 // extractUsageFromSSEChunk attempts to parse usage from an SSE data line.
 // SSE format: "data: {json}\n\n"
 func extractUsageFromSSEChunk(chunk []byte) UsageFromResponse {
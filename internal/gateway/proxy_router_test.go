@@ -301,3 +301,310 @@ rules:
 		t.Fatalf("backup endpoint hit count = %d, want 1 (401 primary should cascade)", got)
 	}
 }
+
+// TestAnthropic_MediaForwardedOnlyToVisionEndpoints verifies the
+// per-upstream translation: a text-only endpoint receives the stub-note
+// body while a vision-capable peer in the same chain receives the image
+// as an OpenAI image_url content part.
+func TestAnthropic_MediaForwardedOnlyToVisionEndpoints(t *testing.T) {
+	var textBody, visionBody string
+	textOnly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		textBody = string(b)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer textOnly.Close()
+	vision := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		visionBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-vision",
+			"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": "a cat"}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 3, "completion_tokens": 2, "total_tokens": 5},
+		})
+	}))
+	defer vision.Close()
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, err := relay.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range []relay.RelayEndpoint{
+		{ID: "text", Name: "text", URL: textOnly.URL, APIKey: "k1", LatencyMs: 1},
+		{ID: "vision", Name: "vision", URL: vision.URL, APIKey: "k2", LatencyMs: 2, Vision: true},
+	} {
+		if err := store.SaveEndpoint(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router, err := relay.NewRouter(dir, store, relay.NewCircuitBreaker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := router.LoadRulesYAML("rules:\n  - name: to-text\n    prefer_endpoint_id: text\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://127.0.0.1:1"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	app, err := reg.Register("Claude Code", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"model":"gpt-4o","max_tokens":64,"messages":[{"role":"user","content":[
+		{"type":"text","text":"what is this?"},
+		{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}
+	]}]}`
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+app.Token)
+	req.Header.Set("Content-Type", "application/json")
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(textBody, "not forwarded to this upstream") || strings.Contains(textBody, "image_url") {
+		t.Errorf("text-only endpoint should get the stub note, got %s", textBody)
+	}
+	if !strings.Contains(visionBody, `"image_url"`) || !strings.Contains(visionBody, "data:image/png;base64,iVBORw0KGgo=") {
+		t.Errorf("vision endpoint should get the image part, got %s", visionBody)
+	}
+}
+
+// TestProxy_KeyPoolRotatesPastRejectedKey gives the preferred endpoint a
+// two-key pool whose first key the upstream rejects. The 401 opens only
+// that key — the endpoint's own breaker never hears of it — and later
+// requests go out on the other key, which metering records by its ID.
+func TestProxy_KeyPoolRotatesPastRejectedKey(t *testing.T) {
+	var keysSeen []string
+	pooled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		keysSeen = append(keysSeen, auth)
+		if auth != "Bearer k-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":    "ok",
+			"model": "x",
+			"usage": map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+	}))
+	defer pooled.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"b","model":"x","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer backup.Close()
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, _ := relay.NewStore(dir)
+	store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "pool", Name: "pool", URL: pooled.URL, APIKey: "k-bad", APIKeys: []string{"k-good"}, Healthy: true, LatencyMs: 10,
+	})
+	store.SaveEndpoint(relay.RelayEndpoint{ID: "backup", Name: "backup", URL: backup.URL, APIKey: "k2", Healthy: true, LatencyMs: 20})
+	breaker := relay.NewCircuitBreaker()
+	router, _ := relay.NewRouter(dir, store, breaker)
+	if err := router.LoadRulesYAML("rules:\n  - name: pin-pool\n    match_model_prefix: x\n    prefer_endpoint_id: pool\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://ignored.invalid"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	var endpointFailures int32
+	srv.fallback.SetObserver(func(e FallbackEntry, ok bool, _ string, _ int64) {
+		if e.ID == "pool" && !ok {
+			atomic.AddInt32(&endpointFailures, 1)
+		}
+	})
+	srv.fallback.SetKeyObserver(breaker.RecordKeyResult)
+	app, _ := reg.Register("X", "", "")
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"x","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer "+app.Token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, w.Code)
+		}
+	}
+
+	// Round-robin starts on k-bad; after its 401 only k-good is used.
+	if want := []string{"Bearer k-bad", "Bearer k-good", "Bearer k-good"}; strings.Join(keysSeen, ",") != strings.Join(want, ",") {
+		t.Errorf("keys sent = %v, want %v", keysSeen, want)
+	}
+	if n := atomic.LoadInt32(&endpointFailures); n != 0 {
+		t.Errorf("endpoint observer saw %d failures; a key-scoped 401 should stay with the key", n)
+	}
+	st := breaker.Snapshot()["pool"]
+	if len(st.Keys) != 2 {
+		t.Fatalf("key states = %+v", st.Keys)
+	}
+	for _, ks := range st.Keys {
+		wantOpen := ks.KeyID == relay.KeyID("k-bad")
+		if (ks.Status == relay.StatusOpen) != wantOpen {
+			t.Errorf("key %s status %s", ks.KeyID, ks.Status)
+		}
+	}
+
+	var good int
+	for _, rec := range meter.RecentRecords(3) {
+		if rec.ServedBy == "pool" && rec.KeyID == relay.KeyID("k-good") {
+			good++
+		}
+	}
+	if good != 2 {
+		t.Errorf("records served on k-good = %d, want 2", good)
+	}
+}
+
+// TestProxy_KeyPickedOnlyWhenAttempted puts a two-key pool behind the
+// preferred endpoint. While the preferred endpoint serves, the pool's
+// keys see no use; once it fails, the request that reaches the pool
+// counts against exactly one key.
+func TestProxy_KeyPickedOnlyWhenAttempted(t *testing.T) {
+	var primaryDown atomic.Bool
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"ok","model":"x","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if primaryDown.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		ok(w, r)
+	}))
+	defer primary.Close()
+	pooled := httptest.NewServer(http.HandlerFunc(ok))
+	defer pooled.Close()
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, _ := relay.NewStore(dir)
+	store.SaveEndpoint(relay.RelayEndpoint{ID: "primary", Name: "primary", URL: primary.URL, APIKey: "k1", Healthy: true, LatencyMs: 10})
+	store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "pool", Name: "pool", URL: pooled.URL, APIKey: "k-a", APIKeys: []string{"k-b"}, Healthy: true, LatencyMs: 20,
+	})
+	breaker := relay.NewCircuitBreaker()
+	router, _ := relay.NewRouter(dir, store, breaker)
+	if err := router.LoadRulesYAML("rules:\n  - name: pin-primary\n    match_model_prefix: x\n    prefer_endpoint_id: primary\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://ignored.invalid"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	app, _ := reg.Register("X", "", "")
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	send := func() {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"x","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer "+app.Token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d", w.Code)
+		}
+	}
+	poolUses := func() (n int64) {
+		for _, ks := range breaker.Snapshot()["pool"].Keys {
+			n += ks.Uses
+		}
+		return n
+	}
+
+	send()
+	send()
+	if n := poolUses(); n != 0 {
+		t.Errorf("pool key uses = %d while the preferred endpoint served, want 0", n)
+	}
+	primaryDown.Store(true)
+	send()
+	if n := poolUses(); n != 1 {
+		t.Errorf("pool key uses = %d after one request failed over to it, want 1", n)
+	}
+}
+
+// TestProxy_ServedEntryMatchedByIDNotName: two endpoints share a display
+// name, and the request fails over from the first to the second. Key
+// attribution, the served model and breaker feedback must follow the
+// entry that actually served, by endpoint ID.
+func TestProxy_ServedEntryMatchedByIDNotName(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	var seen []string
+	up := httptest.NewServer(echoModelUpstream(t, &seen))
+	defer up.Close()
+
+	dir := t.TempDir()
+	reg, _ := appreg.NewRegistry(dir)
+	meter, _ := metering.NewStore(dir)
+	store, _ := relay.NewStore(dir)
+	store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "first", Name: "dup", URL: down.URL, APIKey: "k1", APIKeys: []string{"k2"}, Healthy: true, LatencyMs: 10,
+	})
+	store.SaveEndpoint(relay.RelayEndpoint{
+		ID: "second", Name: "dup", URL: up.URL, APIKey: "k3", APIKeys: []string{"k4"}, Healthy: true, LatencyMs: 20,
+		ModelAliases: map[string]string{"x": "x-served"},
+	})
+	breaker := relay.NewCircuitBreaker()
+	for i := 0; i < 3; i++ {
+		breaker.RecordFailure("lurus-api", "offline in tests") // keep the builtin out of the chain
+	}
+	router, _ := relay.NewRouter(dir, store, breaker)
+
+	srv := NewServer(dir, reg, meter)
+	srv.cfg.UpstreamURL = "http://ignored.invalid"
+	srv.cfg.UserToken = "user-token"
+	srv.SetRelayRouter(router)
+	var observed []string
+	srv.fallback.SetObserver(func(e FallbackEntry, ok bool, _ string, _ int64) {
+		observed = append(observed, e.ID+map[bool]string{true: ":ok", false: ":fail"}[ok])
+	})
+	app, _ := reg.Register("X", "", "")
+
+	if w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"x","messages":[]}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if len(seen) != 1 || seen[0] != "x-served" {
+		t.Fatalf("second endpoint saw %v", seen)
+	}
+	if strings.Join(observed, ",") != "first:fail,second:ok" {
+		t.Errorf("observer = %v", observed)
+	}
+	meter.Flush()
+	recs := meter.RecentRecords(1)
+	if len(recs) != 1 {
+		t.Fatalf("records = %+v", recs)
+	}
+	rec := recs[0]
+	if rec.ServedBy != "dup" || rec.ServedModel != "x-served" {
+		t.Errorf("record served by %q as %q, want dup as x-served", rec.ServedBy, rec.ServedModel)
+	}
+	if rec.KeyID != relay.KeyID("k3") && rec.KeyID != relay.KeyID("k4") {
+		t.Errorf("record key %q is not one of the second endpoint's keys", rec.KeyID)
+	}
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/metering"
+)
+
+// Per-app rate limits. Each app gets two token buckets — requests per
+// minute and tokens per minute — plus a cap on concurrent requests.
+// Limits come from the app's own appreg.App.Limits, else from the
+// gateway's Config.TierLimits for the app's tier; zero fields are
+// unlimited. Requests are admitted in withAuth, before any upstream
+// work. Token usage is only known after the response, so the TPM
+// bucket is debited then and may go negative: the app is refused until
+// the debt refills, and the Retry-After it is told says when.
+
+// inFlightRetryAfter is what an app over its concurrency cap is told to
+// wait; a slot usually frees within a request's lifetime.
+const inFlightRetryAfter = time.Second
+
+// rateLimiter holds every app's bucket state.
+type rateLimiter struct {
+	mu   sync.Mutex
+	apps map[string]*appLimitState
+	now  func() time.Time // swapped in tests
+}
+
+type appLimitState struct {
+	requests tokenBucket
+	tokens   tokenBucket
+	inFlight int
+}
+
+// tokenBucket refills at its per-minute limit up to a burst of one
+// minute's worth. level may drop below zero (TPM debt).
+type tokenBucket struct {
+	level  float64
+	limit  int64 // per minute; the bucket is reset when this changes
+	last   time.Time
+	primed bool
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{apps: map[string]*appLimitState{}, now: time.Now}
+}
+
+// refill brings b up to date for limit at now.
+func (b *tokenBucket) refill(limit int64, now time.Time) {
+	if !b.primed || b.limit != limit {
+		*b = tokenBucket{level: float64(limit), limit: limit, last: now, primed: true}
+		return
+	}
+	b.level = math.Min(float64(limit), b.level+now.Sub(b.last).Minutes()*float64(limit))
+	b.last = now
+}
+
+// wait is how long until the bucket holds need tokens.
+func (b *tokenBucket) wait(need float64) time.Duration {
+	short := need - b.level
+	if short <= 0 {
+		return 0
+	}
+	return time.Duration(short / float64(b.limit) * float64(time.Minute))
+}
+
+// admit reserves a request slot for appID under lim. On success it
+// returns a release func to call when the request finishes; otherwise
+// release is nil and retryAfter / reason say why.
+func (rl *rateLimiter) admit(appID string, lim appreg.RateLimits) (release func(), retryAfter time.Duration, reason string) {
+	if lim.IsZero() {
+		return func() {}, 0, ""
+	}
+	now := rl.now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	st := rl.apps[appID]
+	if st == nil {
+		st = &appLimitState{}
+		rl.apps[appID] = st
+	}
+
+	if lim.MaxInFlight > 0 && st.inFlight >= lim.MaxInFlight {
+		return nil, inFlightRetryAfter, fmt.Sprintf("too many concurrent requests (limit %d)", lim.MaxInFlight)
+	}
+	if lim.TokensPerMinute > 0 {
+		st.tokens.refill(lim.TokensPerMinute, now)
+		if st.tokens.level <= 0 {
+			// Wait until there is headroom for at least one token.
+			return nil, st.tokens.wait(1), fmt.Sprintf("token rate limit reached (%d tokens/min)", lim.TokensPerMinute)
+		}
+	}
+	if lim.RequestsPerMinute > 0 {
+		st.requests.refill(lim.RequestsPerMinute, now)
+		if st.requests.level < 1 {
+			return nil, st.requests.wait(1), fmt.Sprintf("request rate limit reached (%d requests/min)", lim.RequestsPerMinute)
+		}
+		st.requests.level--
+	}
+
+	st.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			rl.mu.Lock()
+			st.inFlight--
+			rl.mu.Unlock()
+		})
+	}, 0, ""
+}
+
+// spend debits tokens from appID's TPM bucket. Apps without a TPM
+// limit have no primed bucket and are skipped.
+func (rl *rateLimiter) spend(appID string, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	now := rl.now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	st := rl.apps[appID]
+	if st == nil || !st.tokens.primed {
+		return
+	}
+	st.tokens.refill(st.tokens.limit, now)
+	st.tokens.level -= float64(tokens)
+}
+
+// limitsFor resolves appID's effective limits: its own, else its tier's.
+func (s *Server) limitsFor(appID string) appreg.RateLimits {
+	tier, own := s.registry.LookupLimits(appID)
+	if own != nil {
+		return *own
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.TierLimits[tier]
+}
+
+// spendTokens books a metered response against the app's TPM bucket
+// and the budget guard's scoped walls. Every token the upstream
+// processed counts, cached reads included.
+func (s *Server) spendTokens(rec metering.Record) {
+	s.limiter.spend(rec.AppID, rec.TokensIn+rec.CacheReadTokens+rec.CacheCreateTokens+rec.TokensOut)
+	s.mu.Lock()
+	guard := s.guard
+	s.mu.Unlock()
+	if guard != nil {
+		guard.Book(rec)
+	}
+}
+
+// writeRateLimited answers an over-limit request with 429 and a
+// Retry-After header, in the error shape of the front door it hit.
+func writeRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, reason string) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	msg := fmt.Sprintf("Rate limit exceeded for this app: %s. Retry after %ds.", reason, secs)
+	switch {
+	case r.URL.Path == "/v1/messages":
+		writeAnthropicError(w, http.StatusTooManyRequests, "rate_limit_error", msg)
+	case strings.HasPrefix(r.URL.Path, geminiModelsPrefix):
+		writeGeminiError(w, http.StatusTooManyRequests, msg)
+	default:
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate_limit_exceeded", msg)
+	}
+}
@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/appreg"
+)
+
+func okUpstream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":"c1","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":600,"completion_tokens":100}}`))
+}
+
+func TestRateLimit_RequestsPerMinuteInBothShapes(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, okUpstream)
+	defer upstream.Close()
+	app, _ := reg.Register("Script", "", "")
+	reg.SetLimits(app.ID, &appreg.RateLimits{RequestsPerMinute: 2})
+
+	for i := 0; i < 2; i++ {
+		if w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, w.Code)
+		}
+	}
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("openai: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"type":"rate_limit_exceeded"`) {
+		t.Errorf("openai body = %s", w.Body.String())
+	}
+
+	w = serve(srv, "/v1/messages", app.Token, `{"model":"claude-sonnet-4-5","max_tokens":10,"messages":[]}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("anthropic: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"type":"error"`) || !strings.Contains(w.Body.String(), `"rate_limit_error"`) {
+		t.Errorf("anthropic body = %s", w.Body.String())
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestRateLimit_TierDefaultsAndAppOverride(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, okUpstream)
+	defer upstream.Close()
+	cfg := srv.GetConfig()
+	cfg.TierLimits = map[appreg.Tier]appreg.RateLimits{appreg.TierManual: {RequestsPerMinute: 1}}
+	if err := srv.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	tiered, _ := reg.Register("Tiered", "", "")
+	own, _ := reg.Register("Own limits", "", "")
+	reg.SetLimits(own.ID, &appreg.RateLimits{RequestsPerMinute: 5})
+
+	serve(srv, "/v1/chat/completions", tiered.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w := serve(srv, "/v1/chat/completions", tiered.Token, `{"model":"gpt-4o","messages":[]}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("tier default not applied: status %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve(srv, "/v1/chat/completions", own.Token, `{"model":"gpt-4o","messages":[]}`); w.Code != http.StatusOK {
+			t.Errorf("app override request %d: status %d", i+1, w.Code)
+		}
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestRateLimit_MaxInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+		okUpstream(w, r)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Parallel", "", "")
+	reg.SetLimits(app.ID, &appreg.RateLimits{MaxInFlight: 1})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	}()
+	<-entered
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("second concurrent request: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	close(unblock)
+	wg.Wait()
+
+	go func() { <-entered }()
+	if w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","messages":[]}`); w.Code != http.StatusOK {
+		t.Errorf("slot not released: status %d", w.Code)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestRateLimiter_TokenDebtRefills(t *testing.T) {
+	rl := newRateLimiter()
+	now := time.Unix(1_700_000_000, 0)
+	rl.now = func() time.Time { return now }
+	lim := appreg.RateLimits{TokensPerMinute: 1000}
+
+	release, _, _ := rl.admit("a", lim)
+	release()
+	rl.spend("a", 1500) // one big response overdraws the bucket by 500
+
+	if release, wait, _ := rl.admit("a", lim); release != nil || wait != 30*time.Second+60*time.Millisecond {
+		t.Fatalf("in debt: admitted=%v wait=%v", release != nil, wait)
+	}
+	now = now.Add(31 * time.Second)
+	if release, _, reason := rl.admit("a", lim); release == nil {
+		t.Fatalf("debt refilled but still refused: %s", reason)
+	}
+}
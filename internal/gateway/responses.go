@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The OpenAI Responses API (/v1/responses) is what current Codex
+// releases speak. Requests and responses pass through unchanged — the
+// router, DLP, cache and budget stages work on them as on chat — but
+// usage has its own shape (input_tokens / output_tokens, reported once
+// in the response.completed event when streaming), and a conversation
+// is a chain of responses linked by previous_response_id rather than a
+// resent message list.
+
+// handleResponses is the /v1/responses front door.
+func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
+	s.proxyOpenAI(w, r, DialectResponses)
+}
+
+// responsesUsage is the usage object of a Responses API response.
+type responsesUsage struct {
+	InputTokens        int64 `json:"input_tokens"`
+	OutputTokens       int64 `json:"output_tokens"`
+	TotalTokens        int64 `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokensDetails struct {
+		ReasoningTokens int64 `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+type responsesObject struct {
+	ID    string          `json:"id"`
+	Model string          `json:"model"`
+	Usage *responsesUsage `json:"usage"`
+}
+
+func (o responsesObject) usage() UsageFromResponse {
+	out := UsageFromResponse{Model: o.Model, ResponseID: o.ID}
+	if u := o.Usage; u != nil {
+		// Same subset semantics as chat: cached_tokens is inside
+		// input_tokens, reasoning_tokens inside output_tokens.
+		out.PromptTokens = u.InputTokens
+		out.CompletionTokens = u.OutputTokens
+		out.TotalTokens = u.TotalTokens
+		out.CachedTokens = u.InputTokensDetails.CachedTokens
+		out.ReasoningTokens = u.OutputTokensDetails.ReasoningTokens
+	}
+	return out
+}
+
+// extractResponsesUsage reads usage off a buffered Responses API body.
+func extractResponsesUsage(body []byte) UsageFromResponse {
+	var obj responsesObject
+	if json.Unmarshal(body, &obj) != nil {
+		return UsageFromResponse{}
+	}
+	return obj.usage()
+}
+
+// extractResponsesUsageFromSSEChunk reads usage off complete Responses
+// stream lines. The terminal response.completed (or .incomplete /
+// .failed) event wraps the whole response object, usage included.
+func extractResponsesUsageFromSSEChunk(chunk []byte) UsageFromResponse {
+	var out UsageFromResponse
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Response *responsesObject `json:"response"`
+		}
+		if json.Unmarshal(bytes.TrimSpace(data), &ev) != nil || ev.Response == nil || ev.Response.Usage == nil {
+			continue
+		}
+		out = ev.Response.usage()
+	}
+	return out
+}
+
+// previousResponseID returns the request's previous_response_id.
+func previousResponseID(body []byte) string {
+	var probe struct {
+		PreviousResponseID string `json:"previous_response_id"`
+	}
+	_ = json.Unmarshal(body, &probe)
+	return probe.PreviousResponseID
+}
+
+// Bounds on the response→session index: an entry outlives any
+// plausible pause between turns, and the map can't grow without limit
+// under a long-running daemon.
+const (
+	responseSessionTTL = 24 * time.Hour
+	maxResponseLinks   = 10000
+)
+
+// responseSessions maps Responses API response IDs to the session that
+// chain of turns belongs to. A session is named after the ID of its
+// first response; each later turn names its predecessor in
+// previous_response_id and inherits that session.
+type responseSessions struct {
+	mu    sync.Mutex
+	links map[string]responseLink
+}
+
+type responseLink struct {
+	session string
+	seen    time.Time
+}
+
+func newResponseSessions() *responseSessions {
+	return &responseSessions{links: map[string]responseLink{}}
+}
+
+// lookup returns the session a new turn continuing previousID belongs
+// to. A predecessor the index doesn't know (pruned, or from before a
+// restart) starts the session at itself, so the rest of the chain still
+// groups together.
+func (rs *responseSessions) lookup(previousID string) string {
+	if previousID == "" {
+		return ""
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if l, ok := rs.links[previousID]; ok {
+		return l.session
+	}
+	return previousID
+}
+
+// link records that responseID belongs to session ("" starts a new
+// session named after responseID) and returns the session.
+func (rs *responseSessions) link(responseID, session string) string {
+	if session == "" {
+		session = responseID
+	}
+	now := time.Now()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.links[responseID] = responseLink{session: session, seen: now}
+	if len(rs.links) > maxResponseLinks {
+		for id, l := range rs.links {
+			if now.Sub(l.seen) > responseSessionTTL {
+				delete(rs.links, id)
+			}
+		}
+		for id := range rs.links { // still full: drop arbitrary entries
+			if len(rs.links) <= maxResponseLinks {
+				break
+			}
+			if id != responseID {
+				delete(rs.links, id)
+			}
+		}
+	}
+	return session
+}
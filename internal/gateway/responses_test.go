@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const responsesStream = "event: response.created\n" +
+	"data: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_s1\",\"model\":\"gpt-5-codex\",\"usage\":null}}\n\n" +
+	"event: response.output_text.delta\n" +
+	"data: {\"type\":\"response.output_text.delta\",\"item_id\":\"msg_1\",\"content_index\":0,\"delta\":\"Hi\"}\n\n" +
+	"event: response.completed\n" +
+	"data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_s1\",\"model\":\"gpt-5-codex\"," +
+	"\"usage\":{\"input_tokens\":120,\"input_tokens_details\":{\"cached_tokens\":100},\"output_tokens\":30," +
+	"\"output_tokens_details\":{\"reasoning_tokens\":12},\"total_tokens\":150}}}\n\n"
+
+func TestResponses_BufferedUsageAndSessionChain(t *testing.T) {
+	var n int32
+	var bodies []string
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/responses" {
+			t.Errorf("upstream path = %s", r.URL.Path)
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		id := atomic.AddInt32(&n, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"resp_%d","object":"response","model":"gpt-5-codex","output":[],`+
+			`"usage":{"input_tokens":40,"input_tokens_details":{"cached_tokens":10},"output_tokens":8,`+
+			`"output_tokens_details":{"reasoning_tokens":3},"total_tokens":48}}`, id)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Codex", "", "")
+
+	serve(srv, "/v1/responses", app.Token, `{"model":"gpt-5-codex","input":"hi"}`)
+	serve(srv, "/v1/responses", app.Token, `{"model":"gpt-5-codex","input":"more","previous_response_id":"resp_1"}`)
+	serve(srv, "/v1/responses", app.Token, `{"model":"gpt-5-codex","input":"again","previous_response_id":"resp_2"}`)
+	serve(srv, "/v1/responses", app.Token, `{"model":"gpt-5-codex","input":"new chat"}`)
+
+	recs := meter.RecentRecords(4)
+	if len(recs) != 4 {
+		t.Fatalf("records = %d", len(recs))
+	}
+	if r := recs[0]; r.TokensIn != 30 || r.CacheReadTokens != 10 || r.TokensOut != 8 || r.ReasoningTokens != 3 {
+		t.Errorf("record = %+v", r)
+	}
+	for i, want := range []string{"resp_1", "resp_1", "resp_1", "resp_4"} {
+		if recs[i].SessionID != want {
+			t.Errorf("turn %d session = %q, want %q", i+1, recs[i].SessionID, want)
+		}
+	}
+	if strings.Contains(bodies[0], "stream_options") {
+		t.Errorf("Responses request must not get stream_options: %s", bodies[0])
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestResponses_StreamUsageFromCompletedEvent(t *testing.T) {
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Split the terminal event across writes.
+		half := len(responsesStream) - 40
+		io.WriteString(w, responsesStream[:half])
+		w.(http.Flusher).Flush()
+		io.WriteString(w, responsesStream[half:])
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Codex", "", "")
+
+	w := serve(srv, "/v1/responses", app.Token, `{"model":"gpt-5-codex","input":"hi","stream":true}`)
+	if w.Body.String() != responsesStream {
+		t.Errorf("stream altered:\n%s", w.Body.String())
+	}
+	s := meter.TodaySummary()
+	if s.TokensIn != 20 || s.TokensOut != 30 {
+		t.Errorf("summary = %+v, want 20 fresh in / 30 out", s)
+	}
+	if recs := meter.RecentRecords(1); recs[0].SessionID != "resp_s1" || recs[0].CacheReadTokens != 100 {
+		t.Errorf("record = %+v", recs[0])
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestResponses_StreamCachedOnlyWhenCompleted(t *testing.T) {
+	if !completeResponse(DialectResponses, true, []byte(responsesStream)) {
+		t.Error("a stream with response.completed is complete")
+	}
+	if completeResponse(DialectResponses, true, []byte(responsesStream[:200])) {
+		t.Error("a truncated Responses stream is incomplete")
+	}
+}
@@ -3,6 +3,7 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -14,10 +15,12 @@ import (
 
 	"lurus-switch/internal/appreg"
 	"lurus-switch/internal/budget"
+	"lurus-switch/internal/capability"
 	"lurus-switch/internal/dlp"
 	"lurus-switch/internal/metering"
 	"lurus-switch/internal/obs"
 	"lurus-switch/internal/relay"
+	"lurus-switch/internal/secrets"
 )
 
 const (
@@ -27,6 +30,13 @@ const (
 	initialRestartDelay  = 2 * time.Second
 )
 
+// Vault entries for the tokens gateway.json references (see
+// sealConfigSecrets).
+const (
+	userTokenSecretName    = "gateway/userToken"
+	adminTokenSecretPrefix = "gateway/adminToken/"
+)
+
 // CrashCallback is invoked when the gateway crashes and auto-restarts.
 // attempt is the current restart attempt number, err is the crash error.
 type CrashCallback func(attempt int, err error)
@@ -47,6 +57,19 @@ type Server struct {
 	fallback   *FallbackChain // cascade through backup upstreams on failure
 	guard      *budget.Guard  // optional spend wall — nil = disabled
 	dlpScanner *dlp.Scanner   // optional DLP middleware — nil = disabled
+	cache      *responseCache // on-disk response cache; used when cfg.Cache.Enabled
+
+	// responseSessions links Responses API turns into sessions.
+	responseSessions *responseSessions
+
+	// limiter enforces per-app request, token and concurrency limits.
+	limiter *rateLimiter
+
+	// Team-sharing LAN listener (share.go); nil unless cfg.Share is
+	// enabled and the gateway is running.
+	shareServer *http.Server
+	shareURL    string
+	shareDir    string // generated CA lives here
 
 	// dlpAuditFn is called whenever DLP middleware blocks or redacts a
 	// request. The injected fn is responsible for writing the audit
@@ -55,6 +78,14 @@ type Server struct {
 	// sessionID / messageUUID) when present in the request body.
 	dlpAuditFn func(op, target string, payload any, metadata map[string]string)
 
+	// adminAuditFn journals admin API mutations and denials (admin.go).
+	adminAuditFn func(tok capability.Token, op, target string, before, after any, err error)
+
+	// middleware is the request pipeline every front door runs (see
+	// middleware.go). Defaults to BuiltinMiddleware; SetMiddleware
+	// replaces it.
+	middleware []Middleware
+
 	// Optional relay router. When wired, the gateway records upstream
 	// success / failure into its circuit breaker after each fallback
 	// chain attempt. Picking is still owned by FallbackChain in the
@@ -91,9 +122,18 @@ func NewServer(appDataDir string, registry *appreg.Registry, meter *metering.Sto
 		registry: registry,
 		meter:    meter,
 		fallback: NewFallbackChain(nil),
+		cache:    newResponseCache(filepath.Join(appDataDir, cacheDirName)),
 		obs:      obs.Noop(),
+		shareDir: filepath.Join(appDataDir, shareTLSDirName),
+
+		responseSessions: newResponseSessions(),
+		limiter:          newRateLimiter(),
 	}
+	s.middleware = s.BuiltinMiddleware()
 	s.cfg = s.loadConfig()
+	s.fallback.SetModelAliases(s.cfg.ModelAliases)
+	s.fallback.SetStreamIdleTimeout(s.cfg.streamIdleTimeout())
+	s.fallback.SetCapture(s.cfg.Capture)
 	return s
 }
 
@@ -106,11 +146,18 @@ func (s *Server) GetFallbackChain() *FallbackChain {
 
 // SetRelayRouter wires the optional relay router into the gateway. The
 // router's circuit breaker is updated on every upstream attempt so the
-// tray badge / RelayPage circuit-state column reflect live conditions.
+// tray badge / RelayPage circuit-state column reflect live conditions,
+// and every router-built attempt is counted in flight against its
+// endpoint for the least_in_flight strategy.
 func (s *Server) SetRelayRouter(r *relay.Router) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.router = r
+	if r == nil {
+		s.fallback.SetTracker(nil)
+		return
+	}
+	s.fallback.SetTracker(func(e FallbackEntry) func() { return r.Acquire(e.ID) })
 }
 
 // buildChainFromRouter asks the relay router for an ordered chain of
@@ -123,10 +170,14 @@ func (s *Server) SetRelayRouter(r *relay.Router) {
 // bindings_relay.go ApplyAllToolRelays behaviour where ep.APIKey == ""
 // means "fall through to the Lurus user token". Keeps a single
 // token-swap policy across config-apply and runtime.
+//
+// meta supplies the calling app's ID and cost center for rules that
+// match on them; nil leaves those predicates unmatched.
 func (s *Server) buildChainFromRouter(
 	tool, model string,
 	estTokens int64,
 	hasTools bool,
+	meta *RequestMeta,
 	userToken string,
 ) (chain []FallbackEntry, matchedBy string, ok bool) {
 	s.mu.Lock()
@@ -135,35 +186,72 @@ func (s *Server) buildChainFromRouter(
 	if router == nil || !router.IsActive() {
 		return nil, "", false
 	}
-	res, err := router.Pick(tool, relay.PickHint{
+	hint := relay.PickHint{
 		Model:                model,
 		EstimatedInputTokens: estTokens,
 		HasTools:             hasTools,
-	})
+	}
+	if meta != nil {
+		hint.AppID, hint.CostCenter = meta.AppID, meta.CostCenter
+	}
+	res, err := router.Pick(tool, hint)
 	if err != nil || len(res.Ordered) == 0 {
 		return nil, "", false
 	}
+	b := router.Breaker()
 	out := make([]FallbackEntry, 0, len(res.Ordered))
 	for _, ep := range res.Ordered {
 		if ep.URL == "" {
 			continue
 		}
-		token := ep.APIKey
-		if token == "" {
-			token = userToken
+		entry := entryFromEndpoint(ep, userToken)
+		if b != nil {
+			// An endpoint with a key pool sends this request on one
+			// of its keys (see relay/keys.go), picked only if the
+			// request gets that far down the chain. AnyKeyAllowed is
+			// also false for an endpoint whose key the vault couldn't
+			// resolve.
+			if !b.AnyKeyAllowed(ep) {
+				continue
+			}
+			if len(ep.Keys()) > 1 {
+				entry.pickKey = func() (string, string, bool) { return b.PickKey(ep) }
+			}
+		} else if relay.KeysLocked(ep) {
+			continue
 		}
-		out = append(out, FallbackEntry{
-			Name:  endpointDisplayName(ep),
-			URL:   NormalizeChannelBaseURL(ep.URL),
-			Token: token,
-		})
+		out = append(out, entry)
 	}
 	if len(out) == 0 {
 		return nil, "", false
 	}
+	if b != nil && res.HedgePercentile > 0 && len(out) > 1 {
+		if d, ok := b.LatencyPercentile(out[0].ID, res.HedgePercentile); ok {
+			out[0].HedgeAfter = d
+		}
+	}
 	return out, res.MatchedBy, true
 }
 
+// entryFromEndpoint turns a relay endpoint into a chain entry; an
+// endpoint without an API key of its own uses userToken.
+func entryFromEndpoint(ep relay.RelayEndpoint, userToken string) FallbackEntry {
+	token := ep.APIKey
+	if token == "" {
+		token = userToken
+	}
+	return FallbackEntry{
+		ID:           ep.ID,
+		Name:         endpointDisplayName(ep),
+		URL:          NormalizeChannelBaseURL(ep.URL),
+		Token:        token,
+		Vision:       ep.Vision,
+		Protocol:     ep.Protocol,
+		Reasoning:    ep.Reasoning,
+		ModelAliases: ep.ModelAliases,
+	}
+}
+
 // endpointDisplayName falls back to the endpoint ID when Name is
 // unset, so the observer / metering always have a stable identifier.
 func endpointDisplayName(ep relay.RelayEndpoint) string {
@@ -245,6 +333,12 @@ func (s *Server) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("port %d is in use by another process — try a different port in Settings, or close the conflicting program: %w", s.cfg.Port, err)
 	}
+	if s.cfg.Share.Enabled {
+		if err := s.startShareLocked(); err != nil {
+			ln.Close()
+			return err
+		}
+	}
 
 	s.startTime = time.Now()
 	s.running.Store(true)
@@ -352,6 +446,7 @@ func (s *Server) Stop() error {
 		err = s.server.Shutdown(ctx)
 		s.server = nil
 	}
+	err = errors.Join(err, s.stopShareLocked(ctx))
 	s.running.Store(false)
 
 	// Flush metering buffer.
@@ -371,10 +466,14 @@ func (s *Server) Status() Status {
 		url = fmt.Sprintf("http://localhost:%d", s.cfg.Port)
 		uptime = int64(time.Since(s.startTime).Seconds())
 	}
+	s.mu.Lock()
+	shareURL := s.shareURL
+	s.mu.Unlock()
 	return Status{
 		Running:       running,
 		Port:          s.cfg.Port,
 		URL:           url,
+		ShareURL:      shareURL,
 		Uptime:        uptime,
 		TotalRequests: s.totalReqs.Load(),
 		ActiveConns:   s.activeReqs.Load(),
@@ -395,10 +494,126 @@ func (s *Server) SaveConfig(cfg Config) error {
 	if cfg.Port == 0 {
 		cfg.Port = DefaultConfig().Port
 	}
-	s.cfg = cfg
+	s.applyConfigLocked(cfg)
 	return s.saveConfigLocked()
 }
 
+// applyConfigLocked makes cfg current, pushing its live settings into
+// the fallback chain. Callers hold s.mu.
+func (s *Server) applyConfigLocked(cfg Config) {
+	s.cfg = cfg
+	s.fallback.SetModelAliases(cfg.ModelAliases)
+	s.fallback.SetStreamIdleTimeout(cfg.streamIdleTimeout())
+	s.fallback.SetCapture(cfg.Capture)
+}
+
+// ReloadConfig re-reads gateway.json, e.g. after it was edited by hand
+// on a headless host. Like SaveConfig the new port only applies on the
+// next Start; portChanged tells the caller a restart is needed. A
+// changed team-sharing config applies at once: a running gateway stops
+// its LAN listener and, if sharing is still enabled, starts it anew. A
+// file that no longer parses is an error and leaves the config as it
+// was.
+func (s *Server) ReloadConfig() (portChanged bool, err error) {
+	data, err := os.ReadFile(s.cfgPath)
+	if err != nil {
+		return false, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, fmt.Errorf("parse %s: %w", s.cfgPath, err)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultConfig().Port
+	}
+	revealConfigSecrets(&cfg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	portChanged = cfg.Port != s.cfg.Port
+	shareChanged := cfg.Share != s.cfg.Share
+	s.applyConfigLocked(cfg)
+	if shareChanged && s.running.Load() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = s.stopShareLocked(ctx)
+		if cfg.Share.Enabled {
+			err = errors.Join(err, s.startShareLocked())
+		}
+	}
+	return portChanged, err
+}
+
+// MigrateSecrets moves user and admin tokens still stored in plaintext
+// in gateway.json into the secrets vault. Reports whether it moved any.
+func (s *Server) MigrateSecrets() (bool, error) {
+	data, err := os.ReadFile(s.cfgPath)
+	if err != nil {
+		return false, nil // nothing saved yet
+	}
+	var stored Config
+	if json.Unmarshal(data, &stored) != nil {
+		return false, nil
+	}
+	plaintext := secrets.NeedsSeal(stored.UserToken)
+	for _, t := range stored.AdminTokens {
+		plaintext = plaintext || secrets.NeedsSeal(t.Token)
+	}
+	if !plaintext {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return true, s.saveConfigLocked()
+}
+
+// revealConfigSecrets resolves the vault references in a loaded config.
+// Unresolvable ones (locked vault) stay references so saving the config
+// keeps them; unresolvedToken keeps them from ever being used.
+func revealConfigSecrets(cfg *Config) {
+	if tok, err := secrets.Resolve(cfg.UserToken); err == nil {
+		cfg.UserToken = tok
+	}
+	for i := range cfg.AdminTokens {
+		if tok, err := secrets.Resolve(cfg.AdminTokens[i].Token); err == nil {
+			cfg.AdminTokens[i].Token = tok
+		}
+	}
+}
+
+// unresolvedToken reports whether a loaded token is still a vault
+// reference. The reference string is guessable, so such a token must
+// neither authenticate a caller nor go upstream as a credential.
+func unresolvedToken(tok string) bool { return secrets.IsRef(tok) }
+
+// usableUserToken is cfg's user token, or "" while it can't be resolved.
+func usableUserToken(cfg Config) string {
+	if unresolvedToken(cfg.UserToken) {
+		return ""
+	}
+	return cfg.UserToken
+}
+
+// sealConfigSecrets returns cfg with its tokens replaced by vault
+// references, for writing to disk. Admin tokens are keyed by name.
+func sealConfigSecrets(cfg Config) (Config, error) {
+	token, err := secrets.Seal(userTokenSecretName, cfg.UserToken)
+	if err != nil {
+		return cfg, fmt.Errorf("seal user token: %w", err)
+	}
+	cfg.UserToken = token
+	admins := make([]AdminToken, len(cfg.AdminTokens))
+	for i, t := range cfg.AdminTokens {
+		if t.Token, err = secrets.Seal(adminTokenSecretPrefix+t.Name, t.Token); err != nil {
+			return cfg, fmt.Errorf("seal admin token %q: %w", t.Name, err)
+		}
+		admins[i] = t
+	}
+	if cfg.AdminTokens != nil {
+		cfg.AdminTokens = admins
+	}
+	return cfg, nil
+}
+
 // UpdateUpstream updates the upstream URL and user token without full config save.
 // Used when proxy settings change.
 func (s *Server) UpdateUpstream(upstreamURL, userToken string) {
@@ -412,10 +627,25 @@ func (s *Server) UpdateUpstream(upstreamURL, userToken string) {
 // --- route registration ---
 
 func (s *Server) registerRoutes(mux *http.ServeMux) {
+	s.registerProxyRoutes(mux)
+
+	// Health and control endpoints (no auth).
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/switch/v1/status", s.handleSwitchStatus)
+	mux.HandleFunc("/switch/v1/balance", s.handleSwitchBalance)
+
+	// Management API for scripts and headless hosts (admin token auth).
+	s.registerAdminRoutes(mux)
+}
+
+// registerProxyRoutes registers the token-authenticated model API routes
+// — all the team-sharing listener serves besides /health.
+func (s *Server) registerProxyRoutes(mux *http.ServeMux) {
 	// OpenAI-compatible API routes (auth required).
 	mux.HandleFunc("/v1/chat/completions", s.withAuth(s.handleProxy))
 	mux.HandleFunc("/v1/completions", s.withAuth(s.handleProxy))
 	mux.HandleFunc("/v1/embeddings", s.withAuth(s.handleProxy))
+	mux.HandleFunc("/v1/responses", s.withAuth(s.handleResponses))
 	mux.HandleFunc("/v1/models", s.withAuth(s.handleProxy))
 
 	// Anthropic Messages API → translate to OpenAI then forward. Lets
@@ -426,10 +656,10 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 
 	mux.HandleFunc("/v1/", s.withAuth(s.handleProxy)) // catch-all for other v1 endpoints
 
-	// Health and control endpoints (no auth).
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/switch/v1/status", s.handleSwitchStatus)
-	mux.HandleFunc("/switch/v1/balance", s.handleSwitchBalance)
+	// Gemini generateContent protocol → translate to OpenAI then
+	// forward, so Gemini CLI gets the same DLP / Budget Wall / metering.
+	mux.HandleFunc(geminiModelsPrefix, s.withAuth(s.handleGemini))
+
 	mux.HandleFunc("/switch/v1/models", s.withAuth(s.handleProxy)) // alias
 }
 
@@ -483,14 +713,21 @@ func (s *Server) loadConfig() Config {
 	if cfg.Port == 0 {
 		cfg.Port = DefaultConfig().Port
 	}
+	revealConfigSecrets(&cfg)
 	return cfg
 }
 
+// saveConfigLocked writes gateway.json with the user and admin tokens
+// sealed into the secrets vault. Callers hold s.mu.
 func (s *Server) saveConfigLocked() error {
 	if err := os.MkdirAll(filepath.Dir(s.cfgPath), 0o755); err != nil {
 		return err
 	}
-	data, err := json.MarshalIndent(s.cfg, "", "  ")
+	cfg, err := sealConfigSecrets(s.cfg)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,301 @@
+package gateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Team sharing: a second, TLS-only listener on a LAN interface so one
+// Switch instance can serve several teammates' machines. It serves only
+// the authenticated proxy routes (plus /health) — the status, balance
+// and admin endpoints stay on localhost. Only KindMember tokens from
+// appreg are accepted on it, each checked against its IP allow-list on
+// every request, so deleting a member or editing its allow-list takes
+// effect on the next request without restarting the listener. Member
+// traffic is metered like any app's and lands in the chargeback views
+// under the member's employee ID.
+//
+// TLS uses the configured cert/key pair when set; otherwise a
+// self-signed CA is generated once under share-tls/ and a fresh server
+// certificate for this host's addresses is issued from it on every
+// start. Teammates trust share-tls/ca.pem (see ShareCACert).
+
+const (
+	// DefaultShareListenAddr binds every interface on a port next to
+	// the default localhost one.
+	DefaultShareListenAddr = "0.0.0.0:19443"
+
+	shareTLSDirName = "share-tls"
+	shareCACertFile = "ca.pem"
+	shareCAKeyFile  = "ca-key.pem"
+
+	shareCAValidity   = 10 * 365 * 24 * time.Hour
+	shareCertValidity = 365 * 24 * time.Hour
+)
+
+// shareKey marks requests that arrived on the LAN listener.
+const shareKey contextKey = "via_share"
+
+// ShareConfig opens the gateway to teammates on the LAN.
+type ShareConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// ListenAddr is the "host:port" to bind; "" = DefaultShareListenAddr.
+	// Use a LAN interface address to keep the gateway off other networks.
+	ListenAddr string `json:"listenAddr,omitempty"`
+
+	// CertFile / KeyFile are a PEM certificate and key to serve instead
+	// of the generated self-signed one. Both or neither.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+func (c ShareConfig) listenAddr() string {
+	if c.ListenAddr == "" {
+		return DefaultShareListenAddr
+	}
+	return c.ListenAddr
+}
+
+// startShareLocked starts the LAN listener. Callers hold s.mu.
+func (s *Server) startShareLocked() error {
+	share := s.cfg.Share
+	addr := share.listenAddr()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("team sharing: listen address %q: %w", addr, err)
+	}
+	cert, err := s.shareCertificate(share, host)
+	if err != nil {
+		return fmt.Errorf("team sharing: %w", err)
+	}
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return fmt.Errorf("team sharing: %s is unavailable: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	s.registerProxyRoutes(mux)
+	mux.HandleFunc("/health", s.handleHealth)
+	s.shareServer = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shareKey, true)))
+		}),
+		ReadTimeout:  5 * time.Minute,
+		WriteTimeout: 10 * time.Minute,
+		IdleTimeout:  120 * time.Second,
+	}
+	s.shareURL = "https://" + net.JoinHostPort(shareHost(host), port)
+
+	srv := s.shareServer
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "gateway team-sharing listener stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// stopShareLocked shuts the LAN listener down. Callers hold s.mu.
+func (s *Server) stopShareLocked(ctx context.Context) error {
+	if s.shareServer == nil {
+		return nil
+	}
+	err := s.shareServer.Shutdown(ctx)
+	s.shareServer = nil
+	s.shareURL = ""
+	return err
+}
+
+// checkShareAccess enforces team-sharing access for an authenticated
+// request: the LAN listener only takes member tokens, and a member token
+// only works from its allowed addresses. Returns "" when the request
+// may proceed, otherwise the reason it may not.
+func (s *Server) checkShareAccess(r *http.Request, appID string) string {
+	viaShare := r.Context().Value(shareKey) != nil
+	addr := remoteAddr(r)
+	member, allowed := s.registry.LookupAccess(appID, addr)
+	switch {
+	case viaShare && !member:
+		return "This token only works on the Switch host itself. Ask the Switch owner for a team member token."
+	case member && !allowed:
+		return fmt.Sprintf("Requests from %s are not allowed for this team member token.", addr)
+	}
+	return ""
+}
+
+// remoteAddr is the request's peer address. Forwarding headers are
+// ignored: the allow-list is about who is connected, not who says so.
+func remoteAddr(r *http.Request) netip.Addr {
+	ap, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
+// shareHost is the host teammates should connect to: the bound address,
+// or this machine's first LAN address when bound to every interface.
+func shareHost(host string) string {
+	if ip, err := netip.ParseAddr(host); err == nil && !ip.IsUnspecified() {
+		return host
+	} else if err != nil && host != "" {
+		return host // a hostname
+	}
+	for _, ip := range lanAddrs() {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	return "localhost"
+}
+
+// lanAddrs lists this machine's non-loopback interface addresses.
+func lanAddrs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var out []net.IP
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && !n.IP.IsLoopback() && !n.IP.IsLinkLocalUnicast() {
+			out = append(out, n.IP)
+		}
+	}
+	return out
+}
+
+// shareCertificate returns the configured certificate, or one freshly
+// issued from the generated CA for host (or, when host is unspecified,
+// every address of this machine).
+func (s *Server) shareCertificate(share ShareConfig, host string) (tls.Certificate, error) {
+	if share.CertFile != "" || share.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(share.CertFile, share.KeyFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("load certificate: %w", err)
+		}
+		return cert, nil
+	}
+	ca, caKey, err := loadOrCreateShareCA(s.shareDir)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: "Lurus Switch gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(shareCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !ip.IsUnspecified() {
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip.AsSlice())
+	} else if err != nil && host != "" {
+		tmpl.DNSNames = append(tmpl.DNSNames, host)
+	} else {
+		tmpl.IPAddresses = append(tmpl.IPAddresses, lanAddrs()...)
+		if name, err := os.Hostname(); err == nil {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("issue certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, ca.Raw}, PrivateKey: key}, nil
+}
+
+// ShareCACert returns the PEM certificate of the generated team-sharing
+// CA, creating the CA on first use. Teammates add it to their trust
+// store (or point their tool's CA bundle at it) once.
+func (s *Server) ShareCACert() ([]byte, error) {
+	if _, _, err := loadOrCreateShareCA(s.shareDir); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.shareDir, shareCACertFile))
+}
+
+// loadOrCreateShareCA loads the CA under dir, generating it the first
+// time. The key is written 0600.
+func loadOrCreateShareCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, shareCACertFile)
+	keyPath := filepath.Join(dir, shareCAKeyFile)
+	if pair, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if err == nil && ok && time.Now().Before(cert.NotAfter) {
+			return cert, key, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("load CA: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	name, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "Lurus Switch team CA (" + name + ")"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(shareCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create CA: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func randomSerial() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	return n
+}
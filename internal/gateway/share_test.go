@@ -0,0 +1,161 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestShare_TLSListenerTakesOnlyMemberTokens(t *testing.T) {
+	srv, reg, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"list","data":[]}`)
+	})
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(freeAddr(t))
+	shareAddr := freeAddr(t)
+	srv.cfg.Port, _ = strconv.Atoi(port)
+	srv.cfg.Share = ShareConfig{Enabled: true, ListenAddr: shareAddr}
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	if want := "https://" + shareAddr; srv.Status().ShareURL != want {
+		t.Errorf("ShareURL = %q, want %q", srv.Status().ShareURL, want)
+	}
+
+	caPEM, err := srv.ShareCACert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatal("CA PEM does not parse")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	get := func(path, token string) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "https://"+shareAddr+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	member, err := reg.IssueMember("alice", "", "", []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := get("/v1/models", member.Token); code != http.StatusOK {
+		t.Errorf("member over TLS: status %d, want 200", code)
+	}
+	local := reg.Get("claude")
+	if code := get("/v1/models", local.Token); code != http.StatusForbidden {
+		t.Errorf("local app token on the LAN listener: status %d, want 403", code)
+	}
+	if code := get("/switch/v1/status", member.Token); code != http.StatusNotFound {
+		t.Errorf("status endpoint on the LAN listener: status %d, want 404", code)
+	}
+
+	// Allow-list and revocation apply to the running listener.
+	if _, err := reg.SetAllowedIPs(member.ID, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := get("/v1/models", member.Token); code != http.StatusForbidden {
+		t.Errorf("outside allow-list: status %d, want 403", code)
+	}
+	reg.SetAllowedIPs(member.ID, nil)
+	if err := reg.Delete(member.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := get("/v1/models", member.Token); code != http.StatusUnauthorized {
+		t.Errorf("revoked member: status %d, want 401", code)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestShare_MemberTokenAttributedOnLocalListener(t *testing.T) {
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"x","model":"gpt-4o","usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+	})
+	defer upstream.Close()
+	member, _ := reg.IssueMember("bob", "", "eng", nil)
+
+	w := serve(srv, "/v1/chat/completions", member.Token, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	meter.Flush()
+	sums := meter.EmployeeSummaries(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if len(sums) != 1 || sums[0].EmployeeID != member.ID || sums[0].CostCenter != "eng" {
+		t.Errorf("employee summaries = %+v, want bob's usage under his member ID", sums)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestShare_ReloadAppliesShareConfig(t *testing.T) {
+	srv, _, _, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(freeAddr(t))
+	srv.cfg.Port, _ = strconv.Atoi(port)
+	if err := srv.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	reload := func(share ShareConfig) {
+		t.Helper()
+		cfg := srv.GetConfig()
+		cfg.Share = share
+		data, _ := json.Marshal(cfg)
+		if err := os.WriteFile(srv.cfgPath, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if portChanged, err := srv.ReloadConfig(); err != nil || portChanged {
+			t.Fatalf("ReloadConfig = %v, %v", portChanged, err)
+		}
+	}
+	listening := func(addr string) bool {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}
+
+	first, second := freeAddr(t), freeAddr(t)
+	reload(ShareConfig{Enabled: true, ListenAddr: first})
+	if srv.Status().ShareURL != "https://"+first || !listening(first) {
+		t.Errorf("enabling sharing: ShareURL %q, want a listener on %s", srv.Status().ShareURL, first)
+	}
+	reload(ShareConfig{Enabled: true, ListenAddr: second})
+	if srv.Status().ShareURL != "https://"+second || !listening(second) || listening(first) {
+		t.Errorf("moving sharing: ShareURL %q, want the listener moved to %s", srv.Status().ShareURL, second)
+	}
+	reload(ShareConfig{ListenAddr: second})
+	if srv.Status().ShareURL != "" || listening(second) {
+		t.Errorf("disabling sharing: ShareURL %q, want no listener", srv.Status().ShareURL)
+	}
+}
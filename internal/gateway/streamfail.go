@@ -0,0 +1,486 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/obs"
+	"lurus-switch/internal/relay"
+)
+
+// Mid-stream failure handling. TryUpstreamChain only sees the status
+// line, so an upstream that answers 200 and then resets the connection
+// or stalls part-way through an event stream used to surface as a
+// truncated response the CLI could not tell from a finished one — and
+// was booked as a clean success.
+//
+// failoverStream watches a served event stream event by event. When it
+// breaks (read error, EOF before the protocol's terminal event, or no
+// bytes for the idle timeout) the endpoint is reported to the observer
+// as failed, then:
+//
+//   - if no content has reached the client yet, the request is
+//     re-issued to the next entry of the chain speaking the same
+//     protocol and its events are spliced in, minus the preamble
+//     (role chunk, message_start, response.created) the client already
+//     has;
+//   - otherwise the stream ends with *streamInterrupted, and the front
+//     door writes a well-formed error event in its dialect
+//     (writeStreamError) and books the partial output
+//     (recordInterrupted).
+
+// DefaultStreamIdleTimeout is the idle timeout when
+// Config.StreamIdleTimeoutSec is unset. Reasoning models can think
+// silently for a while, so it is generous.
+const DefaultStreamIdleTimeout = 2 * time.Minute
+
+// streamInterrupted is the read error a failoverStream ends with when
+// the stream broke after content was delivered and could not be resumed.
+type streamInterrupted struct {
+	reason       string
+	inputTokens  int64 // estimated from the request body
+	outputTokens int64 // estimated from the text delivered
+}
+
+func (e *streamInterrupted) Error() string {
+	return "upstream stream interrupted: " + e.reason
+}
+
+// streamFormat is the event grammar of an upstream stream.
+type streamFormat int
+
+const (
+	formatChat      streamFormat = iota // OpenAI chat.completion.chunk
+	formatResponses                     // OpenAI Responses API events
+	formatAnthropic                     // Anthropic Messages events
+)
+
+// streamFormatFor is the format entry streams for a request on path.
+func streamFormatFor(entry FallbackEntry, path string) streamFormat {
+	if entry.Protocol == relay.ProtocolAnthropic {
+		return formatAnthropic
+	}
+	if entry.Path != "" {
+		path = entry.Path
+	}
+	if strings.Contains(path, "/responses") {
+		return formatResponses
+	}
+	return formatChat
+}
+
+// isEventStream reports whether resp is a served SSE response.
+func isEventStream(resp *http.Response) bool {
+	return resp.StatusCode >= 200 && resp.StatusCode < 300 &&
+		strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// classify reads one SSE event. preamble events carry no content and
+// are safe to drop when a resumed stream repeats them; terminal marks
+// the protocol's normal end; textLen is the generated text it carries.
+func classify(format streamFormat, event []byte) (preamble, terminal bool, textLen int) {
+	var name string
+	var data []byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if v, ok := bytes.CutPrefix(line, []byte("event:")); ok {
+			name = string(bytes.TrimSpace(v))
+		} else if v, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = append(data, bytes.TrimSpace(v)...)
+		}
+	}
+	if len(data) == 0 {
+		return true, false, 0 // comment / keep-alive
+	}
+
+	switch format {
+	case formatChat:
+		if bytes.Equal(data, []byte("[DONE]")) {
+			return false, true, 0
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+					ToolCalls        []struct {
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				Text         string  `json:"text"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage json.RawMessage `json:"usage"`
+		}
+		if json.Unmarshal(data, &chunk) != nil {
+			return false, false, 0
+		}
+		content := false
+		for _, c := range chunk.Choices {
+			n := len(c.Delta.Content) + len(c.Delta.ReasoningContent) + len(c.Text)
+			for _, tc := range c.Delta.ToolCalls {
+				n += len(tc.Function.Name) + len(tc.Function.Arguments)
+			}
+			textLen += n
+			content = content || n > 0 || len(c.Delta.ToolCalls) > 0
+			if c.FinishReason != nil && *c.FinishReason != "" {
+				terminal = true
+			}
+		}
+		return !content && !terminal && len(chunk.Usage) == 0, terminal, textLen
+
+	case formatResponses:
+		var ev struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+		}
+		_ = json.Unmarshal(data, &ev)
+		if ev.Type == "" {
+			ev.Type = name
+		}
+		switch ev.Type {
+		case "response.created", "response.in_progress":
+			return true, false, 0
+		case "response.completed", "response.incomplete", "response.failed":
+			return false, true, 0
+		}
+		return false, false, len(ev.Delta)
+
+	case formatAnthropic:
+		var ev struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+				Thinking    string `json:"thinking"`
+			} `json:"delta"`
+		}
+		_ = json.Unmarshal(data, &ev)
+		if ev.Type == "" {
+			ev.Type = name
+		}
+		switch ev.Type {
+		case "message_start", "ping":
+			return true, false, 0
+		case "message_stop":
+			return false, true, 0
+		}
+		return false, false, len(ev.Delta.Text) + len(ev.Delta.PartialJSON) + len(ev.Delta.Thinking)
+	}
+	return false, false, 0
+}
+
+// nextEvent splits the first complete SSE event (terminator included)
+// off buf.
+func nextEvent(buf []byte) (event, rest []byte, ok bool) {
+	i := bytes.Index(buf, []byte("\n\n"))
+	j := bytes.Index(buf, []byte("\r\n\r\n"))
+	switch {
+	case i < 0 && j < 0:
+		return nil, buf, false
+	case j >= 0 && (i < 0 || j < i):
+		return buf[:j+4], buf[j+4:], true
+	}
+	return buf[:i+2], buf[i+2:], true
+}
+
+// streamChunk is one read off the current upstream body.
+type streamChunk struct {
+	data []byte
+	err  error
+}
+
+// failoverStream is the body TryUpstreamChain hands back for a served
+// event stream. A pump goroutine reads the current upstream body so
+// Read can give up on a silent one; Read itself is single-goroutine,
+// like any response body.
+type failoverStream struct {
+	ctx         context.Context
+	idle        time.Duration
+	format      streamFormat
+	entry       FallbackEntry   // the entry currently streaming
+	rest        []FallbackEntry // untried entries after it
+	attempt     attemptFunc
+	observer    func(entry FallbackEntry, ok bool, errMsg string, latencyMs int64)
+	inputTokens int64
+
+	mu     sync.Mutex // guards body and done against Close
+	body   io.ReadCloser
+	done   chan struct{} // closed to stop the current pump
+	chunks chan streamChunk
+
+	buf       []byte // bytes of an event not yet complete
+	out       []byte // complete events ready for the caller
+	sentAny   bool   // some event reached the caller
+	delivered bool   // a content event reached the caller
+	suppress  bool   // dropping a resumed stream's repeated preamble
+	terminal  bool   // the protocol's end event was seen
+	passThru  bool   // an event outgrew maxSSELineBuf; bytes go out unparsed
+	textLen   int64
+	err       error
+}
+
+// start begins pumping body.
+func (fs *failoverStream) start(body io.ReadCloser) {
+	done := make(chan struct{})
+	chunks := make(chan streamChunk)
+	fs.mu.Lock()
+	fs.body, fs.done, fs.chunks = body, done, chunks
+	fs.mu.Unlock()
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := body.Read(buf)
+			select {
+			case chunks <- streamChunk{data: buf[:n], err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// stop closes the current upstream body and its pump.
+func (fs *failoverStream) stop() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.body == nil {
+		return
+	}
+	close(fs.done)
+	fs.body.Close()
+	fs.body = nil
+}
+
+func (fs *failoverStream) Read(p []byte) (int, error) {
+	for len(fs.out) == 0 && fs.err == nil {
+		var idle <-chan time.Time
+		var timer *time.Timer
+		if fs.idle > 0 {
+			timer = time.NewTimer(fs.idle)
+			idle = timer.C
+		}
+		select {
+		case c := <-fs.chunks:
+			fs.feed(c.data)
+			switch {
+			case c.err == nil:
+			case fs.terminal, fs.passThru && c.err == io.EOF:
+				fs.out = append(fs.out, fs.buf...)
+				fs.buf = nil
+				fs.err = io.EOF
+			case fs.ctx.Err() != nil:
+				fs.err = fs.ctx.Err()
+			case c.err == io.EOF:
+				fs.fail("upstream closed the stream before it finished")
+			default:
+				fs.fail(c.err.Error())
+			}
+		case <-idle:
+			fs.fail(fmt.Sprintf("no data for %s", fs.idle))
+		case <-fs.ctx.Done():
+			fs.err = fs.ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	if len(fs.out) > 0 {
+		n := copy(p, fs.out)
+		fs.out = fs.out[n:]
+		return n, nil
+	}
+	return 0, fs.err
+}
+
+// feed queues the complete events in data for the caller.
+func (fs *failoverStream) feed(data []byte) {
+	if fs.passThru {
+		fs.out = append(fs.out, data...)
+		return
+	}
+	fs.buf = append(fs.buf, data...)
+	for {
+		event, rest, ok := nextEvent(fs.buf)
+		if !ok {
+			break
+		}
+		fs.buf = rest
+		preamble, terminal, n := classify(fs.format, event)
+		fs.terminal = fs.terminal || terminal
+		if preamble && fs.suppress {
+			continue
+		}
+		if !preamble {
+			fs.suppress = false
+			fs.delivered = true
+		}
+		fs.sentAny = true
+		fs.textLen += int64(n)
+		fs.out = append(fs.out, event...)
+	}
+	if len(fs.buf) > maxSSELineBuf {
+		// An event this large can't be held back to fail over on: send
+		// it and the rest of the stream through as it comes. The stream
+		// can no longer resume elsewhere, and its end is upstream's EOF.
+		fs.out = append(fs.out, fs.buf...)
+		fs.buf = nil
+		fs.passThru, fs.sentAny, fs.delivered = true, true, true
+	}
+}
+
+// fail handles a broken stream: resume on the next entry if nothing
+// the client can't be re-sent has gone out, else end with
+// *streamInterrupted after the events already queued.
+func (fs *failoverStream) fail(reason string) {
+	if fs.observer != nil {
+		fs.observer(fs.entry, false, "stream: "+reason, 0)
+	}
+	fs.stop()
+	fs.buf = nil
+
+	for !fs.delivered && len(fs.rest) > 0 {
+		next := fs.rest[0]
+		fs.rest = fs.rest[1:]
+		if next.URL == "" || next.Protocol != fs.entry.Protocol {
+			continue
+		}
+		resp, servedModel, ok, _ := fs.attempt(fs.ctx, &next)
+		if !ok {
+			continue
+		}
+		if !isEventStream(resp) {
+			resp.Body.Close()
+			continue
+		}
+		fs.entry = next
+		fs.suppress = fs.sentAny
+		if meta, _ := fs.ctx.Value(metaKey).(*RequestMeta); meta != nil {
+			meta.ServedBy = next.Name
+			meta.ServedModel = servedModel
+			meta.ServedKeyID = next.KeyID
+		}
+		fs.start(resp.Body)
+		return
+	}
+	fs.err = &streamInterrupted{reason: reason, inputTokens: fs.inputTokens, outputTokens: fs.textLen / 4}
+}
+
+func (fs *failoverStream) Close() error {
+	fs.stop()
+	return nil
+}
+
+// writeStreamError ends an interrupted event stream with an error event
+// in the client's dialect, so the CLI reports a failure instead of
+// taking the truncated output as complete. Gemini's JSON-array stream
+// has no error framing and is left as is.
+func writeStreamError(w io.Writer, d Dialect, msg string) {
+	var event string
+	switch d {
+	case DialectAnthropic:
+		out, _ := json.Marshal(map[string]any{
+			"type":  "error",
+			"error": map[string]string{"type": "api_error", "message": msg},
+		})
+		event = "event: error\ndata: " + string(out) + "\n\n"
+	case DialectResponses:
+		out, _ := json.Marshal(map[string]any{
+			"type": "response.failed",
+			"response": map[string]any{
+				"status": "failed",
+				"error":  map[string]string{"code": "server_error", "message": msg},
+			},
+		})
+		event = "event: response.failed\ndata: " + string(out) + "\n\n"
+	case DialectGemini:
+		out, _ := json.Marshal(map[string]any{
+			"error": map[string]any{"code": http.StatusBadGateway, "message": msg, "status": geminiStatusForCode(http.StatusBadGateway)},
+		})
+		event = "data: " + string(out) + "\n\n"
+	default:
+		out, _ := json.Marshal(map[string]any{
+			"error": map[string]string{"message": msg, "type": "upstream_error", "code": "stream_interrupted"},
+		})
+		event = "data: " + string(out) + "\n\n"
+	}
+	io.WriteString(w, event)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// recordInterrupted books a stream that broke part-way: a failed
+// request (502, with the reason) that still carries the tokens the
+// partial output cost, so the budget wall and rate limits see them.
+// usage is whatever the stream reported before it broke; when empty
+// the tokens are estimated from the request and the delivered text.
+func (s *Server) recordInterrupted(meta *RequestMeta, model string, usage UsageFromResponse, si *streamInterrupted) {
+	if s.meter == nil || meta == nil {
+		return
+	}
+	if !usageNonZero(usage) {
+		usage = UsageFromResponse{PromptTokens: si.inputTokens, CompletionTokens: si.outputTokens}
+	}
+	tokensIn := usage.PromptTokens - usage.CachedTokens - usage.CacheCreateTokens
+	if tokensIn < 0 {
+		tokensIn = 0
+	}
+	// No RequestID, as in recordError: the client's retry must still book.
+	rec := metering.Record{
+		AppID:             meta.AppID,
+		Model:             model,
+		TokensIn:          tokensIn,
+		TokensOut:         usage.CompletionTokens,
+		CacheReadTokens:   usage.CachedTokens,
+		CacheCreateTokens: usage.CacheCreateTokens,
+		LatencyMs:         time.Since(meta.StartTime).Milliseconds(),
+		StatusCode:        http.StatusBadGateway,
+		ErrorMessage:      si.Error(),
+		Timestamp:         time.Now(),
+		EmployeeID:        meta.OwnerEmployeeID,
+		CostCenter:        meta.CostCenter,
+		ServedBy:          meta.ServedBy,
+		MatchedBy:         meta.MatchedBy,
+		KeyID:             meta.ServedKeyID,
+		ServedModel:       meta.ServedModel,
+		SessionID:         meta.SessionID,
+	}
+	s.meter.Record(rec)
+	s.spendTokens(rec)
+
+	s.observe(obs.RequestObservation{
+		Operation:  "chat",
+		Model:      model,
+		ServedBy:   meta.ServedBy,
+		MatchedBy:  meta.MatchedBy,
+		TokensIn:   rec.TokensIn,
+		TokensOut:  rec.TokensOut,
+		StartTime:  meta.StartTime,
+		LatencyMs:  rec.LatencyMs,
+		StatusCode: rec.StatusCode,
+		Streaming:  true,
+		Err:        rec.ErrorMessage,
+	})
+
+	s.mu.Lock()
+	guard := s.guard
+	s.mu.Unlock()
+	if guard != nil {
+		guard.RecordUsage(usage.PromptTokens, usage.CompletionTokens)
+	}
+}
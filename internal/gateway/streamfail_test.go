@@ -0,0 +1,216 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	roleChunk    = "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n"
+	contentChunk = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello there\"}}]}\n\n"
+	finishChunk  = "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"
+)
+
+// sseUpstream serves the given SSE events, flushing each, then returns.
+func sseUpstream(events ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			io.WriteString(w, ev)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+// observed records observer calls.
+type observed struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (o *observed) observe(e FallbackEntry, ok bool, _ string, _ int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ok {
+		o.calls = append(o.calls, e.Name+":ok")
+	} else {
+		o.calls = append(o.calls, e.Name+":fail")
+	}
+}
+
+func (o *observed) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.Join(o.calls, ",")
+}
+
+func streamChain(t *testing.T, idle time.Duration, chain []FallbackEntry) (string, *RequestMeta, *observed, error) {
+	t.Helper()
+	fc := NewFallbackChain(nil)
+	fc.SetStreamIdleTimeout(idle)
+	obs := &observed{}
+	fc.SetObserver(obs.observe)
+
+	meta := &RequestMeta{}
+	ctx := context.WithValue(context.Background(), metaKey, meta)
+	resp, served, err := fc.TryUpstreamChain(ctx, "POST", "/v1/chat/completions", "",
+		[]byte(`{"model":"m","stream":true}`), http.Header{}, chain)
+	if err != nil {
+		t.Fatalf("TryUpstreamChain: %v", err)
+	}
+	defer resp.Body.Close()
+	meta.ServedBy = served.Name // as the front doors do
+	body, readErr := io.ReadAll(resp.Body)
+	return string(body), meta, obs, readErr
+}
+
+// A stream that drops before any content resumes on the next entry,
+// without replaying the role chunk the client already has.
+func TestFailoverStream_ResumesBeforeContent(t *testing.T) {
+	broken := sseUpstream(roleChunk)
+	defer broken.Close()
+	good := sseUpstream(roleChunk, contentChunk, finishChunk)
+	defer good.Close()
+
+	body, meta, obs, err := streamChain(t, 0, []FallbackEntry{
+		{Name: "broken", URL: broken.URL},
+		{Name: "good", URL: good.URL},
+	})
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if want := roleChunk + contentChunk + finishChunk; body != want {
+		t.Errorf("body =\n%q\nwant\n%q", body, want)
+	}
+	if meta.ServedBy != "good" {
+		t.Errorf("ServedBy = %q, want good", meta.ServedBy)
+	}
+	if got := obs.String(); got != "broken:ok,broken:fail,good:ok" {
+		t.Errorf("observer calls = %s", got)
+	}
+}
+
+// A stream that stalls past the idle timeout counts as broken too.
+func TestFailoverStream_IdleTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, roleChunk)
+		w.(http.Flusher).Flush()
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stalled.Close()
+	defer close(unblock)
+	good := sseUpstream(roleChunk, contentChunk, finishChunk)
+	defer good.Close()
+
+	body, _, obs, err := streamChain(t, 50*time.Millisecond, []FallbackEntry{
+		{Name: "stalled", URL: stalled.URL},
+		{Name: "good", URL: good.URL},
+	})
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if !strings.Contains(body, "hello there") || strings.Count(body, `"role"`) != 1 {
+		t.Errorf("body = %q, want one role chunk then the good stream", body)
+	}
+	if got := obs.String(); got != "stalled:ok,stalled:fail,good:ok" {
+		t.Errorf("observer calls = %s", got)
+	}
+}
+
+// Once content has gone out the stream can't be resumed: it ends with
+// *streamInterrupted and the next entry is never tried.
+func TestFailoverStream_InterruptsAfterContent(t *testing.T) {
+	broken := sseUpstream(roleChunk, contentChunk)
+	defer broken.Close()
+	var tried atomic.Bool
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tried.Store(true) }))
+	defer next.Close()
+
+	body, _, obs, err := streamChain(t, 0, []FallbackEntry{
+		{Name: "broken", URL: broken.URL},
+		{Name: "next", URL: next.URL},
+	})
+	var si *streamInterrupted
+	if !errors.As(err, &si) {
+		t.Fatalf("read error = %v, want *streamInterrupted", err)
+	}
+	if body != roleChunk+contentChunk {
+		t.Errorf("body = %q, want the events delivered before the break", body)
+	}
+	if si.outputTokens == 0 || si.inputTokens == 0 {
+		t.Errorf("estimates in:%d out:%d, want both > 0", si.inputTokens, si.outputTokens)
+	}
+	if tried.Load() {
+		t.Error("next entry should not be tried after content was delivered")
+	}
+	if got := obs.String(); got != "broken:ok,broken:fail" {
+		t.Errorf("observer calls = %s", got)
+	}
+}
+
+// An event larger than maxSSELineBuf is passed through whole, and so
+// is everything after it, rather than being cut from the stream.
+func TestFailoverStream_OversizedEventPassedThrough(t *testing.T) {
+	huge := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"" + strings.Repeat("x", 2*maxSSELineBuf) + "\"}}]}\n\n"
+	up := sseUpstream(roleChunk, huge, contentChunk, finishChunk)
+	defer up.Close()
+
+	body, _, obs, err := streamChain(t, 0, []FallbackEntry{{Name: "up", URL: up.URL}})
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if want := roleChunk + huge + contentChunk + finishChunk; body != want {
+		t.Errorf("body is %d bytes, want the %d upstream sent", len(body), len(want))
+	}
+	if got := obs.String(); got != "up:ok" {
+		t.Errorf("observer calls = %s", got)
+	}
+}
+
+// Through the gateway the client gets a well-formed error event and the
+// partial output is booked as a failed, but billed, request.
+func TestProxy_StreamInterruptedEndsWithErrorEvent(t *testing.T) {
+	srv, reg, meter, upstream := setupTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, roleChunk+contentChunk)
+	})
+	defer upstream.Close()
+	app, _ := reg.Register("Codex", "", "")
+
+	w := serve(srv, "/v1/chat/completions", app.Token, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	got := w.Body.String()
+	if !strings.HasPrefix(got, roleChunk+contentChunk) {
+		t.Errorf("partial content missing:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\n\n") || !strings.Contains(got, `"code":"stream_interrupted"`) {
+		t.Errorf("stream should end with a stream_interrupted error event:\n%s", got)
+	}
+	meter.Flush()
+	recs := meter.RecentRecords(1)
+	if len(recs) != 1 || recs[0].StatusCode != http.StatusBadGateway || recs[0].ErrorMessage == "" || recs[0].TokensOut == 0 {
+		t.Errorf("records = %+v, want one 502 carrying the partial output", recs)
+	}
+	time.Sleep(10 * time.Millisecond) // let the async TouchLastSeen finish before TempDir cleanup
+}
+
+func TestWriteStreamError_Anthropic(t *testing.T) {
+	var b strings.Builder
+	writeStreamError(&b, DialectAnthropic, `upstream "x" broke`)
+	want := "event: error\ndata: {\"error\":{\"message\":\"upstream \\\"x\\\" broke\",\"type\":\"api_error\"},\"type\":\"error\"}\n\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
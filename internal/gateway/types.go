@@ -1,6 +1,10 @@
 package gateway
 
-import "time"
+import (
+	"time"
+
+	"lurus-switch/internal/appreg"
+)
 
 // Config holds persistent gateway configuration.
 type Config struct {
@@ -15,6 +19,47 @@ type Config struct {
 	// reads it again. The field stays for back-compat decoding of older
 	// gateway.json files. `omitempty` lets new saves drop it cleanly.
 	Fallbacks []FallbackEntry `json:"fallbacks,omitempty"`
+
+	// Cache enables the on-disk response cache for temperature-0
+	// requests (see cache.go). Off by default.
+	Cache CacheConfig `json:"cache"`
+
+	// ModelAliases rewrites requested model names before forwarding
+	// (requested → upstream ID) on every upstream without an alias of
+	// its own for the model (see alias.go).
+	ModelAliases map[string]string `json:"modelAliases,omitempty"`
+
+	// TierLimits are the default rate limits for apps of each tier that
+	// have no appreg.App.Limits of their own (see ratelimit.go).
+	TierLimits map[appreg.Tier]appreg.RateLimits `json:"tierLimits,omitempty"`
+
+	// StreamIdleTimeoutSec is how long an upstream event stream may go
+	// silent before the gateway treats it as broken and fails over (see
+	// streamfail.go). 0 = DefaultStreamIdleTimeout; negative disables.
+	StreamIdleTimeoutSec int `json:"streamIdleTimeoutSec,omitempty"`
+
+	// Capture records recent upstream exchanges for debugging, export
+	// and replay (see capture.go). Off by default.
+	Capture CaptureConfig `json:"capture"`
+
+	// AdminTokens authenticate callers of the /switch/v1/admin API, each
+	// with its own capability set (see admin.go). None = API closed.
+	AdminTokens []AdminToken `json:"adminTokens,omitempty"`
+
+	// Share serves teammates' machines over a TLS listener on the LAN
+	// (see share.go). Off by default; applies on the next Start.
+	Share ShareConfig `json:"share"`
+}
+
+// streamIdleTimeout resolves StreamIdleTimeoutSec.
+func (c Config) streamIdleTimeout() time.Duration {
+	switch {
+	case c.StreamIdleTimeoutSec < 0:
+		return 0
+	case c.StreamIdleTimeoutSec == 0:
+		return DefaultStreamIdleTimeout
+	}
+	return time.Duration(c.StreamIdleTimeoutSec) * time.Second
 }
 
 // DefaultConfig returns production defaults.
@@ -35,6 +80,10 @@ type Status struct {
 	Uptime        int64  `json:"uptime"`        // seconds since start
 	TotalRequests int64  `json:"totalRequests"` // lifetime request count
 	ActiveConns   int32  `json:"activeConns"`   // current in-flight requests
+
+	// ShareURL is the team-sharing "https://LAN-IP:PORT" while that
+	// listener runs (see share.go), else "".
+	ShareURL string `json:"shareUrl,omitempty"`
 }
 
 // UsageFromResponse captures token usage parsed from an upstream API response.
@@ -51,6 +100,15 @@ type UsageFromResponse struct {
 	TotalTokens      int64
 	CachedTokens     int64 // prompt_tokens_details.cached_tokens (subset of PromptTokens)
 	ReasoningTokens  int64 // completion_tokens_details.reasoning_tokens (subset of CompletionTokens)
+
+	// CacheCreateTokens is only set when an Anthropic-protocol upstream
+	// served the request: its cache_creation_input_tokens, folded into
+	// PromptTokens like CachedTokens so the same subtraction applies.
+	CacheCreateTokens int64
+
+	// ResponseID is the Responses API response id ("resp_…"), used to
+	// chain previous_response_id turns into one session.
+	ResponseID string
 }
 
 // RequestMeta holds per-request context passed through middleware.
@@ -69,6 +127,18 @@ type RequestMeta struct {
 	ServedBy  string // which upstream actually served this request ("primary" or fallback name)
 	MatchedBy string // relay rule name that selected the primary upstream; empty for cfg / mapping defaults
 
+	// ServedModel is the model the upstream was asked for when a model
+	// alias rewrote it; empty when it matches the requested model.
+	ServedModel string
+
+	// ServedKeyID fingerprints the pooled key the serving endpoint was
+	// called with; empty when it has no key pool.
+	ServedKeyID string
+
+	// SessionID groups the turns of one Responses API conversation: the
+	// id of its first response (see responses.go). Empty elsewhere.
+	SessionID string
+
 	// Enterprise dimensions sourced from the per-app registry record.
 	// Empty in Personal/Reseller installs; the chargeback report
 	// buckets unattributed traffic separately.
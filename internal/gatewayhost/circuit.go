@@ -0,0 +1,104 @@
+package gatewayhost
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lurus-switch/internal/notify"
+	"lurus-switch/internal/relay"
+)
+
+// relayPublishTimeout bounds one relay event's delivery to the notify
+// transports.
+const relayPublishTimeout = 15 * time.Second
+
+// NotifyRelayEvents publishes relay breaker opens and closes
+// (notify.KindCircuit) and the health monitor's down and recovery
+// reports (notify.KindRelayHealth) on the bus bus returns at the time
+// (nil while notify is disabled). Delivery runs in the background so
+// neither request paths nor sweeps wait on a webhook.
+func (h *Host) NotifyRelayEvents(bus func() *notify.Bus) {
+	publish := func(ev notify.Event) {
+		b := bus()
+		if b == nil {
+			return
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), relayPublishTimeout)
+			defer cancel()
+			b.Publish(ctx, ev)
+		}()
+	}
+	if h.Router != nil {
+		h.Router.Breaker().SetTransitionHook(func(t relay.CircuitTransition) {
+			publish(h.CircuitEvent(t))
+		})
+	}
+	if h.Monitor != nil {
+		h.Monitor.SetChangeHook(func(ch relay.HealthChange) {
+			publish(HealthEvent(ch))
+		})
+	}
+}
+
+// CircuitEvent renders a breaker transition as a notify event, naming
+// the endpoint by its display name when the relay store knows it.
+func (h *Host) CircuitEvent(t relay.CircuitTransition) notify.Event {
+	name := t.EndpointID
+	if h.RelayStore != nil {
+		if eps, err := h.RelayStore.ListEndpoints(); err == nil {
+			for _, ep := range eps {
+				if ep.ID == t.EndpointID && ep.Name != "" {
+					name = ep.Name
+				}
+			}
+		}
+	}
+	ev := notify.Event{
+		ID:      fmt.Sprintf("circuit:%s:%s:%d", t.EndpointID, t.To, t.At.UnixMilli()),
+		Time:    t.At,
+		Kind:    notify.KindCircuit,
+		Project: "lurus-switch",
+	}
+	if t.To == relay.StatusOpen {
+		ev.Severity = notify.SeverityWarning
+		if t.Trips > 1 {
+			ev.Severity = notify.SeverityError
+		}
+		ev.Title = fmt.Sprintf("中转端点 %s 已熔断", name)
+		ev.Body = fmt.Sprintf("原因:%s\n第 %d 次熔断,%s 后再试探", t.Reason, t.Trips, t.Cooldown.Round(time.Second))
+		return ev
+	}
+	ev.Severity = notify.SeveritySuccess
+	ev.Title = fmt.Sprintf("中转端点 %s 熔断已解除", name)
+	ev.Body = "请求重新成功,端点已回到路由中"
+	return ev
+}
+
+// HealthEvent renders a health monitor report as a notify event.
+func HealthEvent(ch relay.HealthChange) notify.Event {
+	name := ch.Name
+	if name == "" {
+		name = ch.EndpointID
+	}
+	ev := notify.Event{
+		ID:      fmt.Sprintf("relay-health:%s:%t:%d", ch.EndpointID, ch.Down, ch.At.UnixMilli()),
+		Time:    ch.At,
+		Kind:    notify.KindRelayHealth,
+		Project: "lurus-switch",
+	}
+	if ch.Down {
+		ev.Severity = notify.SeverityError
+		ev.Title = fmt.Sprintf("中转端点 %s 不可用", name)
+		ev.Body = fmt.Sprintf("连续 %d 次健康探测失败:%s", ch.Failures, ch.Reason)
+		if ch.StatusCode != 0 {
+			ev.Body += fmt.Sprintf("(HTTP %d)", ch.StatusCode)
+		}
+		return ev
+	}
+	ev.Severity = notify.SeveritySuccess
+	ev.Title = fmt.Sprintf("中转端点 %s 已恢复可用", name)
+	ev.Body = fmt.Sprintf("健康探测重新通过,此前不可用约 %s", ch.DownFor.Round(time.Second))
+	return ev
+}
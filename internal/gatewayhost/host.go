@@ -0,0 +1,357 @@
+// Package gatewayhost opens the local API gateway together with the
+// stores and services it runs with — secrets vault, relay router, budget
+// guard, DLP scanner, metering, audit journal and the optional OTLP
+// exporter — all from one app-data directory. The desktop app and the headless daemon
+// (cmd/lurus-switchd) both build their gateway through Open so the two
+// never drift apart in how traffic is routed, capped and recorded.
+package gatewayhost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"lurus-switch/internal/appconfig"
+	"lurus-switch/internal/appreg"
+	"lurus-switch/internal/audit"
+	"lurus-switch/internal/budget"
+	"lurus-switch/internal/capability"
+	"lurus-switch/internal/dlp"
+	"lurus-switch/internal/gateway"
+	"lurus-switch/internal/metering"
+	"lurus-switch/internal/notify/store"
+	"lurus-switch/internal/obs"
+	"lurus-switch/internal/relay"
+	"lurus-switch/internal/secrets"
+)
+
+// Host holds the gateway and its dependencies. Any field may be nil when
+// its store failed to open; Open reports why in its warnings.
+type Host struct {
+	// Vault holds the credentials the stores below reference; it is
+	// installed as secrets.Default. A passphrase-protected vault opens
+	// locked — see Unlock.
+	Vault *secrets.Vault
+
+	RelayStore *relay.Store
+	Router     *relay.Router
+	Registry   *appreg.Registry
+	Meter      *metering.Store
+	Journal    *audit.Journal
+	DLP        *dlp.Scanner
+
+	// Monitor probes the relay endpoints in the background once
+	// StartMonitor runs; nil when the relay store failed to open.
+	Monitor *relay.Monitor
+
+	// Gateway is nil unless both Registry and Meter opened.
+	Gateway *gateway.Server
+	Guard   *budget.Guard
+
+	// ObsShutdown flushes + tears down the OpenTelemetry exporters when
+	// observability is enabled; nil otherwise.
+	ObsShutdown func(context.Context) error
+
+	dataDir        string
+	monitorStarted atomic.Bool
+}
+
+// Open opens every store under appDataDir and wires them into a gateway
+// server that is ready to Start. Failures are collected as warnings so
+// the caller can run with whatever did open.
+func Open(appDataDir, version string) (*Host, []string) {
+	var warnings []string
+	h := &Host{DLP: dlp.NewScanner(), dataDir: appDataDir}
+
+	// The vault comes first: the stores resolve their keys from it as
+	// they load.
+	vault, err := secrets.Open(appDataDir)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("secrets vault: %v", err))
+	} else {
+		h.Vault = vault
+		secrets.SetDefault(vault)
+	}
+
+	relayStr, err := relay.NewStore(appDataDir)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("relay store: %v", err))
+	} else {
+		h.RelayStore = relayStr
+		breaker := relay.NewCircuitBreaker()
+		if err := breaker.EnablePersistence(filepath.Join(appDataDir, relay.CircuitStateFile)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("relay breaker state: %v", err))
+		}
+		r, rErr := relay.NewRouter(appDataDir, relayStr, breaker)
+		if rErr != nil {
+			warnings = append(warnings, fmt.Sprintf("relay router: %v", rErr))
+		}
+		h.Router = r
+		hist, hErr := relay.NewHistory(appDataDir)
+		if hErr != nil {
+			warnings = append(warnings, fmt.Sprintf("relay health history: %v", hErr))
+		}
+		h.Monitor = relay.NewMonitor(relayStr, breaker, hist)
+	}
+
+	if h.Registry, err = appreg.NewRegistry(appDataDir); err != nil {
+		warnings = append(warnings, fmt.Sprintf("app registry: %v", err))
+	}
+	if h.Meter, err = metering.NewStore(appDataDir); err != nil {
+		warnings = append(warnings, fmt.Sprintf("metering store: %v", err))
+	}
+	if h.Journal, err = audit.NewJournal(appDataDir); err != nil {
+		warnings = append(warnings, fmt.Sprintf("audit journal: %v", err))
+	}
+
+	if h.Registry == nil || h.Meter == nil {
+		return h, warnings
+	}
+	h.Gateway = gateway.NewServer(appDataDir, h.Registry, h.Meter)
+	warnings = append(warnings, h.migrateLegacyFallbacks()...)
+	warnings = append(warnings, h.MigrateSecrets()...)
+
+	// Active Budget Wall — persisted config alongside other gateway
+	// state. The guard delegates "today's tokens" to the meter so daily
+	// limits track all traffic, not just this process's session.
+	meter := h.Meter
+	guard, gErr := budget.New(
+		filepath.Join(appDataDir, "budget.json"),
+		func() metering.DailySummary { return meter.TodaySummary() },
+	)
+	if gErr == nil {
+		// Scoped walls count the whole current week / month, not just
+		// this process's lifetime.
+		now := time.Now()
+		guard.Seed(meter.Records(budget.HistoryStart(now), now))
+		h.Guard = guard
+		h.Gateway.SetBudgetGuard(guard)
+	} else {
+		warnings = append(warnings, fmt.Sprintf("budget guard init failed: %v", gErr))
+	}
+
+	if h.Router != nil {
+		h.wireRouter()
+	}
+
+	// The same Scanner is exposed to the admin surfaces, so policy
+	// changes made there immediately apply to live traffic.
+	h.Gateway.SetDLPScanner(h.DLP)
+	if journal := h.Journal; journal != nil {
+		// Every block / redact event lands in the durable journal
+		// alongside binding mutations.
+		h.Gateway.SetDLPAuditFn(func(op, target string, payload any, metadata map[string]string) {
+			entry := journal.RecordSystem("gateway", op, target, nil, payload, nil)
+			// Stamp the conversation-correlation metadata onto the
+			// freshly-written entry. RecordSystem returns a copy, so
+			// we re-attach via the journal's metadata helper which
+			// mutates the hot ring in place.
+			if len(metadata) > 0 {
+				journal.AttachMetadata(entry.ID, metadata)
+			}
+		})
+		// Admin API calls are journaled under the caller's own token.
+		h.Gateway.SetAdminAuditFn(func(tok capability.Token, op, target string, before, after any, err error) {
+			journal.RecordAs(tok, op, target, before, after, err)
+		})
+	}
+
+	// Optional OpenTelemetry export of gateway GenAI traffic. Default
+	// off — only wired when the user enables it and gives an OTLP
+	// endpoint. The gateway depends on the obs.Recorder interface, not
+	// otel; obs.New connects lazily so an unreachable collector never
+	// blocks startup.
+	if settings, sErr := appconfig.LoadAppSettingsFrom(appDataDir); sErr == nil &&
+		settings.Observability.Enabled && settings.Observability.Endpoint != "" {
+		rec, shutdown, oErr := obs.New(obs.Config{
+			ServiceName:    "lurus-switch",
+			ServiceVersion: version,
+			Endpoint:       settings.Observability.Endpoint,
+			Headers:        settings.Observability.Headers,
+		})
+		if oErr != nil {
+			warnings = append(warnings, fmt.Sprintf("observability init failed: %v", oErr))
+		} else {
+			h.Gateway.SetObserver(rec)
+			h.ObsShutdown = shutdown
+		}
+	}
+	return h, warnings
+}
+
+// migrateLegacyFallbacks is a one-shot migration: pre-W4.2 cfg.Fallbacks
+// → relay store user endpoints. Idempotent — Migrate returns (0, nil)
+// when any user endpoint already exists, so reboots and re-runs are safe.
+// After a successful migration we persist an empty Fallbacks slice so
+// the next save drops the legacy field thanks to omitempty.
+func (h *Host) migrateLegacyFallbacks() []string {
+	if h.RelayStore == nil {
+		return nil
+	}
+	gwCfg := h.Gateway.GetConfig()
+	if len(gwCfg.Fallbacks) == 0 {
+		return nil
+	}
+	legacy := make([]relay.LegacyFallback, 0, len(gwCfg.Fallbacks))
+	for _, f := range gwCfg.Fallbacks {
+		legacy = append(legacy, relay.LegacyFallback{
+			Name: f.Name, URL: f.URL, Token: f.Token,
+		})
+	}
+	migrated, err := h.RelayStore.MigrateLegacyFallbacks(legacy)
+	if err != nil {
+		return []string{fmt.Sprintf("migrate gateway fallbacks: %v", err)}
+	}
+	if migrated > 0 {
+		gwCfg.Fallbacks = nil
+		if err := h.Gateway.SaveConfig(gwCfg); err != nil {
+			return []string{fmt.Sprintf("clear migrated fallbacks: %v", err)}
+		}
+	}
+	return nil
+}
+
+// MigrateSecrets moves the credentials the gateway's stores still hold
+// in plaintext — relay endpoint keys, gateway.json tokens and notify
+// transport credentials — into the vault, leaving references behind.
+// Idempotent; does nothing while the vault is missing or locked.
+func (h *Host) MigrateSecrets() []string {
+	if h.Vault == nil || h.Vault.Status().Locked {
+		return nil
+	}
+	var warnings []string
+	if h.RelayStore != nil {
+		if _, err := h.RelayStore.MigrateSecrets(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("move relay keys to the secrets vault: %v", err))
+		}
+	}
+	if h.Gateway != nil {
+		if _, err := h.Gateway.MigrateSecrets(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("move gateway tokens to the secrets vault: %v", err))
+		}
+	}
+	if _, err := store.MigrateSecrets(h.dataDir); err != nil {
+		warnings = append(warnings, fmt.Sprintf("move notify credentials to the secrets vault: %v", err))
+	}
+	return warnings
+}
+
+// Unlock opens a passphrase-protected vault, re-reads gateway.json so
+// its tokens resolve, and migrates anything still in plaintext. Stores
+// that read from disk on every call (relay endpoints, notify.json) pick
+// the values up on their next read.
+func (h *Host) Unlock(passphrase string) error {
+	if h.Vault == nil {
+		return fmt.Errorf("secrets vault unavailable")
+	}
+	if err := h.Vault.Unlock(passphrase); err != nil {
+		return err
+	}
+	var errs []error
+	if h.Gateway != nil {
+		if _, err := h.Gateway.ReloadConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("gateway config: %w", err))
+		}
+	}
+	for _, w := range h.MigrateSecrets() {
+		errs = append(errs, errors.New(w))
+	}
+	return errors.Join(errs...)
+}
+
+// wireRouter connects the relay router so the gateway's fallback
+// observer records circuit transitions per endpoint. Router-built chain
+// entries carry their RelayEndpoint ID; entries without one (the cfg
+// primary and persisted fallbacks) aren't relay endpoints and feed no
+// breaker.
+func (h *Host) wireRouter() {
+	h.Gateway.SetRelayRouter(h.Router)
+	breaker := h.Router.Breaker()
+	relayStore := h.RelayStore
+	h.Gateway.GetFallbackChain().SetObserver(func(e gateway.FallbackEntry, ok bool, errMsg string, latencyMs int64) {
+		if e.ID == "" {
+			return
+		}
+		if ok {
+			breaker.RecordSuccess(e.ID)
+			// Feed measured latency back into the relay store so
+			// Pick()'s ascending-latency sort reflects live traffic,
+			// not just the last manual health check.
+			if latencyMs > 0 {
+				_ = relayStore.UpdateEndpointLatency(e.ID, latencyMs)
+				breaker.RecordLatency(e.ID, time.Duration(latencyMs)*time.Millisecond)
+			}
+		} else {
+			breaker.RecordFailure(e.ID, errMsg)
+		}
+	})
+	h.Gateway.GetFallbackChain().SetKeyObserver(breaker.RecordKeyResult)
+	h.Gateway.GetFallbackChain().SetHedgeObserver(func(winner, loser gateway.FallbackEntry) {
+		if winner.ID != "" && loser.ID != "" {
+			breaker.RecordHedge(winner.ID, loser.ID)
+		}
+	})
+}
+
+// Reload re-reads the on-disk config of every reloadable service:
+// gateway.json, the relay rules, the budget config and, once it has
+// been started, the relay monitor config. Each failure leaves that
+// service as it was and is joined into the returned error. portChanged
+// reports whether a running gateway must be restarted to move to a new
+// port.
+func (h *Host) Reload() (portChanged bool, err error) {
+	var errs []error
+	if h.Gateway != nil {
+		pc, gErr := h.Gateway.ReloadConfig()
+		if gErr != nil {
+			errs = append(errs, fmt.Errorf("gateway config: %w", gErr))
+		}
+		portChanged = pc
+	}
+	if h.Router != nil {
+		if rErr := h.Router.ReloadRules(); rErr != nil {
+			errs = append(errs, fmt.Errorf("relay rules: %w", rErr))
+		}
+	}
+	if h.Guard != nil {
+		if bErr := h.Guard.Reload(); bErr != nil {
+			errs = append(errs, fmt.Errorf("budget config: %w", bErr))
+		}
+	}
+	if h.monitorStarted.Load() {
+		if mErr := h.StartMonitor(); mErr != nil {
+			errs = append(errs, mErr)
+		}
+	}
+	return portChanged, errors.Join(errs...)
+}
+
+// StartMonitor (re)starts the relay health monitor with the saved
+// relay-monitor.json; Reload restarts it from then on. A config that
+// fails to load leaves the monitor on its defaults and is reported.
+func (h *Host) StartMonitor() error {
+	if h.Monitor == nil {
+		return nil
+	}
+	h.monitorStarted.Store(true)
+	cfg, err := relay.LoadMonitorConfig(h.dataDir)
+	h.Monitor.Start(cfg)
+	return err
+}
+
+// SetMonitorConfig saves cfg and restarts the monitor with it.
+func (h *Host) SetMonitorConfig(cfg relay.MonitorConfig) error {
+	if h.Monitor == nil {
+		return fmt.Errorf("relay monitor not initialized")
+	}
+	if err := relay.SaveMonitorConfig(h.dataDir, cfg); err != nil {
+		return err
+	}
+	h.monitorStarted.Store(true)
+	h.Monitor.Start(cfg)
+	return nil
+}
@@ -0,0 +1,126 @@
+package gatewayhost
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/notify"
+	"lurus-switch/internal/relay"
+)
+
+func TestOpen_WiresGatewayServices(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	h, warnings := Open(dir, "test")
+	if len(warnings) != 0 {
+		t.Fatalf("warnings = %v", warnings)
+	}
+	if h.Gateway == nil || h.Router == nil || h.Guard == nil || h.Journal == nil || h.DLP == nil || h.Vault == nil {
+		t.Fatalf("host = %+v, want every service opened", h)
+	}
+}
+
+func TestHost_Reload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	h, _ := Open(dir, "test")
+	port := h.Gateway.GetConfig().Port
+
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("gateway.json", `{"port":19999}`)
+	write("relay-rules.yaml", "rules:\n  - name: big\n    min_tokens: 1000\n    prefer_endpoint_id: lurus-api\n")
+	write("budget.json", `{"enabled":true,"dailyTokens":4000}`)
+
+	portChanged, err := h.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !portChanged || port == 19999 || h.Gateway.GetConfig().Port != 19999 {
+		t.Errorf("portChanged = %v, port %d → %d", portChanged, port, h.Gateway.GetConfig().Port)
+	}
+	if !h.Router.IsActive() {
+		t.Error("reloaded relay rules should activate the router")
+	}
+	if c := h.Guard.GetConfig(); !c.Enabled || c.DailyTokens != 4000 {
+		t.Errorf("budget = %+v", c)
+	}
+
+	// A broken file is reported and leaves that service untouched.
+	write("budget.json", `{`)
+	if _, err := h.Reload(); err == nil {
+		t.Error("a corrupt budget.json should fail the reload")
+	}
+	if c := h.Guard.GetConfig(); c.DailyTokens != 4000 {
+		t.Errorf("budget after failed reload = %+v", c)
+	}
+}
+
+func TestHost_CircuitTransitionsReachNotify(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	h, _ := Open(dir, "test")
+	if err := h.RelayStore.SaveEndpoint(relay.RelayEndpoint{ID: "r1", Name: "Relay One", URL: "https://r1.test"}); err != nil {
+		t.Fatal(err)
+	}
+	bus := notify.NewBus()
+	h.NotifyRelayEvents(func() *notify.Bus { return bus })
+
+	breaker := h.Router.Breaker()
+	for i := 0; i < 3; i++ {
+		breaker.RecordFailure("r1", "connection refused")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(bus.Recent()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	recent := bus.Recent()
+	if len(recent) != 1 || recent[0].Kind != notify.KindCircuit || !strings.Contains(recent[0].Title, "Relay One") {
+		t.Fatalf("recent = %+v, want one circuit event naming the endpoint", recent)
+	}
+
+	// The open state outlives the host.
+	if _, err := os.Stat(filepath.Join(dir, relay.CircuitStateFile)); err != nil {
+		t.Errorf("breaker state not persisted: %v", err)
+	}
+	h2, _ := Open(dir, "test")
+	if h2.Router.Breaker().Allow("r1") {
+		t.Error("r1 should still be open after reopening the host")
+	}
+}
+
+func TestHost_MonitorConfigAndHealthEvents(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	h, _ := Open(dir, "test")
+	if h.Monitor == nil {
+		t.Fatal("monitor not created")
+	}
+	cfg := relay.MonitorConfig{Enabled: false, IntervalSec: 600, Mode: relay.ProbeModels}
+	if err := h.SetMonitorConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Monitor.Stop()
+	if got := h.Monitor.Config(); got != cfg {
+		t.Errorf("monitor config = %+v, want %+v", got, cfg)
+	}
+	if err := h.SetMonitorConfig(relay.MonitorConfig{Enabled: true, IntervalSec: 5}); err == nil {
+		t.Error("a 5s interval should be rejected")
+	}
+
+	down := HealthEvent(relay.HealthChange{EndpointID: "r1", Name: "Relay One", Down: true, Failures: 2, Reason: "HTTP 502", StatusCode: 502, At: time.Now()})
+	if down.Kind != notify.KindRelayHealth || down.Severity != notify.SeverityError || !strings.Contains(down.Title, "Relay One") {
+		t.Errorf("down event = %+v", down)
+	}
+	up := HealthEvent(relay.HealthChange{EndpointID: "r1", DownFor: 10 * time.Minute, At: time.Now()})
+	if up.Severity != notify.SeveritySuccess || !strings.Contains(up.Body, "10m0s") || up.ID == down.ID {
+		t.Errorf("recovery event = %+v", up)
+	}
+}
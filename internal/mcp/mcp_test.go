@@ -1,7 +1,12 @@
 package mcp
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"lurus-switch/internal/secrets"
 )
 
 // mcpEnv redirects the MCP preset dir to a per-test tempdir via APPDATA
@@ -140,3 +145,32 @@ func TestStore_SavePreset_AutoGeneratesID(t *testing.T) {
 		t.Errorf("auto-generated ID looks wrong: %q", list[0].ID)
 	}
 }
+
+func TestStore_EnvValuesLiveInSecretsVault(t *testing.T) {
+	mcpEnv(t)
+	v, _ := secrets.Open(t.TempDir())
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	s, err := NewStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := MCPPreset{ID: "user-gh", Name: "GitHub", Server: MCPServer{Name: "github", Type: "stdio", Env: map[string]string{"GITHUB_TOKEN": "ghp-123"}}}
+	if err := s.SavePreset(p); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(s.dir, "user-gh.json"))
+	if strings.Contains(string(raw), "ghp-123") {
+		t.Fatalf("preset file holds the env value:\n%s", raw)
+	}
+	presets, _ := s.ListPresets()
+	if len(presets) != 1 || presets[0].Server.Env["GITHUB_TOKEN"] != "ghp-123" {
+		t.Errorf("ListPresets = %+v, want the resolved env value", presets)
+	}
+	if err := s.DeletePreset("user-gh"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := v.Names(); len(names) != 0 {
+		t.Errorf("vault after delete = %v", names)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"lurus-switch/internal/secrets"
 )
 
 // Store manages MCP preset persistence
@@ -74,6 +76,9 @@ func (s *Store) ListPresets() ([]MCPPreset, error) {
 		if err := json.Unmarshal(data, &p); err != nil {
 			continue
 		}
+		for k, v := range p.Server.Env {
+			p.Server.Env[k] = secrets.Reveal(v)
+		}
 		presets = append(presets, p)
 	}
 	return presets, nil
@@ -92,6 +97,20 @@ func (s *Store) SavePreset(p MCPPreset) error {
 		return fmt.Errorf("invalid preset ID: %q", p.ID)
 	}
 
+	// Env values are usually tokens (GITHUB_PERSONAL_ACCESS_TOKEN, …), so
+	// every one of them is kept in the secrets vault.
+	if len(p.Server.Env) > 0 {
+		env := make(map[string]string, len(p.Server.Env))
+		for k, v := range p.Server.Env {
+			ref, err := secrets.Seal(envSecretName(p.ID, k), v)
+			if err != nil {
+				return fmt.Errorf("failed to seal env %s: %w", k, err)
+			}
+			env[k] = ref
+		}
+		p.Server.Env = env
+	}
+
 	data, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal preset: %w", err)
@@ -110,11 +129,56 @@ func (s *Store) DeletePreset(id string) error {
 		return fmt.Errorf("invalid preset ID: %q", id)
 	}
 	path := filepath.Join(s.dir, id+".json")
+	var p MCPPreset
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &p)
+	}
 	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("preset not found: %s", id)
 		}
 		return fmt.Errorf("failed to delete preset: %w", err)
 	}
+	// Best effort: a locked vault just keeps the orphaned values.
+	for _, v := range p.Server.Env {
+		_ = secrets.Forget(v)
+	}
 	return nil
 }
+
+// MigrateSecrets moves env values still stored in plaintext in preset
+// files into the secrets vault. Returns how many presets were rewritten.
+func (s *Store) MigrateSecrets() (int, error) {
+	presets, err := s.ListPresets()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, p := range presets {
+		data, err := os.ReadFile(filepath.Join(s.dir, p.ID+".json"))
+		if err != nil {
+			continue
+		}
+		var stored MCPPreset
+		if json.Unmarshal(data, &stored) != nil {
+			continue
+		}
+		plaintext := false
+		for _, v := range stored.Server.Env {
+			plaintext = plaintext || secrets.NeedsSeal(v)
+		}
+		if !plaintext {
+			continue
+		}
+		if err := s.SavePreset(p); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// envSecretName is the vault entry holding one env value of a preset.
+func envSecretName(presetID, key string) string {
+	return "mcp/" + presetID + "/env/" + key
+}
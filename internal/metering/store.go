@@ -160,7 +160,7 @@ func (s *Store) AppSummaries(from, to time.Time) []AppSummary {
 		if r.CachedHit {
 			as.CacheHits++
 		}
-		as.CostUSD += pricing.Cost(r.Model, r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
+		as.CostUSD += pricing.Cost(r.BilledModel(), r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
 	}
 	out := make([]AppSummary, 0, len(byApp))
 	for _, as := range byApp {
@@ -253,7 +253,7 @@ func (s *Store) ModelSummaries(from, to time.Time) []ModelSummary {
 		ms.TotalCalls++
 		ms.TokensIn += r.TokensIn
 		ms.TokensOut += r.TokensOut
-		ms.CostUSD += pricing.Cost(r.Model, r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
+		ms.CostUSD += pricing.Cost(r.BilledModel(), r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
 	}
 	out := make([]ModelSummary, 0, len(byModel))
 	for _, ms := range byModel {
@@ -311,7 +311,7 @@ func (s *Store) Insights(from, to time.Time) InsightsRaw {
 		}
 		ins.ModelTokensIn[r.Model] += r.TokensIn
 		ins.ModelTokensOut[r.Model] += r.TokensOut
-		ins.TotalCostUSD += pricing.Cost(r.Model, r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
+		ins.TotalCostUSD += pricing.Cost(r.BilledModel(), r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
 	}
 	if ins.TotalCalls > 0 {
 		ins.AvgLatencyMs = ins.TotalLatencyMs / ins.TotalCalls
@@ -367,11 +367,16 @@ func (s *Store) daySummary(day string) DailySummary {
 		if r.CachedHit {
 			sum.CacheHits++
 		}
-		sum.CostUSD += pricing.Cost(r.Model, r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
+		sum.CostUSD += pricing.Cost(r.BilledModel(), r.TokensIn, r.TokensOut, r.CacheCreateTokens, r.CacheReadTokens)
 	}
 	return sum
 }
 
+// Records returns every record stamped within [from, to].
+func (s *Store) Records(from, to time.Time) []Record {
+	return s.recordsInRange(from, to)
+}
+
 func (s *Store) recordsInRange(from, to time.Time) []Record {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
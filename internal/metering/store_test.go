@@ -140,6 +140,31 @@ func TestStore_ModelSummariesIncludeCostUSD(t *testing.T) {
 	}
 }
 
+// TestStore_AliasedRecordsPricedAtServedModel verifies that a record
+// whose model was rewritten by a gateway alias is grouped under the
+// requested name but priced at the model the upstream billed.
+func TestStore_AliasedRecordsPricedAtServedModel(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Record(Record{AppID: "x", Model: "claude-sonnet-4-6", ServedModel: "claude-haiku-4-5", TokensIn: 1_000_000, TokensOut: 1_000_000})
+
+	now := time.Now()
+	models := store.ModelSummaries(now.Add(-time.Hour), now.Add(time.Hour))
+	if len(models) != 1 || models[0].Model != "claude-sonnet-4-6" {
+		t.Fatalf("summaries = %+v", models)
+	}
+	want := pricing.Cost("claude-haiku-4-5", 1_000_000, 1_000_000, 0, 0)
+	if got := models[0].CostUSD; got != want {
+		t.Errorf("CostUSD = %v, want %v (haiku rate)", got, want)
+	}
+	if got := store.TodaySummary().CostUSD; got != want {
+		t.Errorf("today CostUSD = %v, want %v", got, want)
+	}
+}
+
 // TestStore_RoutingDimensionsRoundTrip verifies that the routing
 // fields added in W3.2 (ServedBy + MatchedBy) survive flush + reload.
 // Without this the request log would silently lose "served by X · rule
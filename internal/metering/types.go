@@ -45,6 +45,28 @@ type Record struct {
 	// primary. Both empty when the cfg-driven path served the request.
 	ServedBy  string `json:"servedBy,omitempty"`
 	MatchedBy string `json:"matchedBy,omitempty"`
+
+	// KeyID fingerprints the pooled API key the serving endpoint was
+	// called with (relay.KeyID); empty for endpoints with a single key.
+	KeyID string `json:"keyId,omitempty"`
+
+	// ServedModel is the model the upstream actually billed when a
+	// gateway model alias rewrote Model (the name the tool asked for);
+	// empty when no alias applied.
+	ServedModel string `json:"servedModel,omitempty"`
+
+	// SessionID groups the turns of one multi-turn conversation the
+	// gateway can follow (Responses API previous_response_id chains).
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// BilledModel is the model the record is priced at: ServedModel when an
+// alias applied, else Model.
+func (r Record) BilledModel() string {
+	if r.ServedModel != "" {
+		return r.ServedModel
+	}
+	return r.Model
 }
 
 // DailySummary aggregates usage for one day. CostUSD is computed at
@@ -47,6 +47,7 @@ type ModelAuthResult struct {
 	ReportedModel  string      `json:"reportedModel"`
 	Verdict        AuthVerdict `json:"verdict"`
 	LatencyMs      int64       `json:"latencyMs"`
+	HTTPStatus     int         `json:"httpStatus,omitempty"` // 0 when no response arrived
 	Note           string      `json:"note,omitempty"`
 	TestedAt       time.Time   `json:"testedAt"`
 }
@@ -145,6 +146,7 @@ func probeOne(ctx context.Context, ep ProviderEndpoint, model string) ModelAuthR
 		return res
 	}
 	defer resp.Body.Close()
+	res.HTTPStatus = resp.StatusCode
 
 	switch {
 	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
@@ -180,6 +182,18 @@ func probeOne(ctx context.Context, ep ProviderEndpoint, model string) ModelAuthR
 	return res
 }
 
+// ModelListed reports whether model appears in an endpoint's advertised
+// model list, with the same dated-suffix tolerance as the authenticity
+// verdicts.
+func ModelListed(models []string, model string) bool {
+	for _, m := range models {
+		if modelMatches(model, m) {
+			return true
+		}
+	}
+	return false
+}
+
 // modelMatches treats prefix relationships as a match — many upstreams
 // canonicalise "claude-sonnet-4-6" into "claude-sonnet-4-6-20250601"
 // when echoing the model id back, which would otherwise flag as a
@@ -42,6 +42,7 @@ type TestResult struct {
 	ProviderName string    `json:"providerName"`
 	Status       Status    `json:"status"`
 	LatencyMs    int64     `json:"latencyMs"`
+	HTTPStatus   int       `json:"httpStatus,omitempty"` // 0 when no response arrived
 	Models       []string  `json:"models"`
 	Error        string    `json:"error,omitempty"`
 	TestedAt     time.Time `json:"testedAt"`
@@ -102,6 +103,16 @@ func (t *Tester) RunHealthCheck(ctx context.Context, endpoints []ProviderEndpoin
 	return out
 }
 
+// ProbeEndpoint runs the /v1/models probe against a single endpoint
+// within budget, for callers that schedule their own probes (the relay
+// health checks).
+func ProbeEndpoint(ctx context.Context, ep ProviderEndpoint, budget time.Duration) TestResult {
+	if budget <= 0 {
+		budget = defaultProbeBudget
+	}
+	return probe(ctx, ep, budget)
+}
+
 // probe performs a single endpoint health check.
 func probe(ctx context.Context, ep ProviderEndpoint, budget time.Duration) TestResult {
 	res := TestResult{
@@ -143,6 +154,7 @@ func probe(ctx context.Context, ep ProviderEndpoint, budget time.Duration) TestR
 		return res
 	}
 	defer resp.Body.Close()
+	res.HTTPStatus = resp.StatusCode
 
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	switch {
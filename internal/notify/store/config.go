@@ -18,10 +18,12 @@ import (
 	"sync"
 	"time"
 
+	"lurus-switch/internal/notify"
 	"lurus-switch/internal/notify/feishu"
 	"lurus-switch/internal/notify/rules"
 	"lurus-switch/internal/notify/slack"
 	"lurus-switch/internal/notify/telegram"
+	"lurus-switch/internal/secrets"
 )
 
 // configFilename is what we persist on disk under appDataBaseDir. Keep it
@@ -98,6 +100,22 @@ func (r RulesPersist) ToRulesConfig() rules.Config {
 	return out
 }
 
+// NewBus builds a Bus with a transport registered for every channel
+// that has credentials filled in.
+func (c AppConfig) NewBus() *notify.Bus {
+	bus := notify.NewBus()
+	if c.Feishu.WebhookURL != "" {
+		bus.Register(feishu.New(c.Feishu))
+	}
+	if c.Telegram.BotToken != "" && c.Telegram.ChatID != "" {
+		bus.Register(telegram.New(c.Telegram))
+	}
+	if c.Slack.WebhookURL != "" {
+		bus.Register(slack.New(c.Slack))
+	}
+	return bus
+}
+
 var configMu sync.Mutex
 
 // Load reads notify.json from dir. Missing file → DefaultAppConfig
@@ -128,18 +146,71 @@ func Load(dir string) (AppConfig, error) {
 	if cfg.Rules.IdleAfterSec == 0 {
 		cfg.Rules.IdleAfterSec = def.Rules.IdleAfterSec
 	}
+	for _, f := range cfg.credentials() {
+		*f.value = secrets.Reveal(*f.value)
+	}
 	return cfg, nil
 }
 
+// credential is one transport credential field and its vault entry.
+type credential struct {
+	name  string
+	value *string
+}
+
+// credentials lists the transport fields kept in the secrets vault:
+// webhook URLs embed their auth token, so they count.
+func (c *AppConfig) credentials() []credential {
+	return []credential{
+		{"notify/feishu/webhookUrl", &c.Feishu.WebhookURL},
+		{"notify/feishu/secret", &c.Feishu.Secret},
+		{"notify/telegram/botToken", &c.Telegram.BotToken},
+		{"notify/slack/webhookUrl", &c.Slack.WebhookURL},
+	}
+}
+
+// MigrateSecrets moves transport credentials still stored in plaintext
+// in notify.json into the secrets vault. Reports whether it moved any.
+func MigrateSecrets(dir string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, configFilename))
+	if err != nil {
+		return false, nil // nothing saved yet
+	}
+	var stored AppConfig
+	if json.Unmarshal(data, &stored) != nil {
+		return false, nil // Load reports corrupt files
+	}
+	plaintext := false
+	for _, f := range stored.credentials() {
+		plaintext = plaintext || secrets.NeedsSeal(*f.value)
+	}
+	if !plaintext {
+		return false, nil
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		return false, err
+	}
+	return true, Save(dir, cfg)
+}
+
 // Save writes notify.json atomically (.tmp + rename) — same pattern as
 // internal/toolmanifest/overrides.go so a crash mid-write can't leave a
-// half-truncated file.
+// half-truncated file. Transport credentials go to the secrets vault and
+// only their references are written.
 func Save(dir string, cfg AppConfig) error {
 	configMu.Lock()
 	defer configMu.Unlock()
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
 	}
+	for _, f := range cfg.credentials() {
+		ref, err := secrets.Seal(f.name, *f.value)
+		if err != nil {
+			return fmt.Errorf("seal %s: %w", f.name, err)
+		}
+		*f.value = ref
+	}
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
@@ -3,8 +3,13 @@ package store
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"lurus-switch/internal/notify/slack"
+	"lurus-switch/internal/notify/telegram"
+	"lurus-switch/internal/secrets"
 )
 
 func TestLoad_MissingFileReturnsDefault(t *testing.T) {
@@ -85,3 +90,35 @@ func TestRulesPersist_ToRulesConfig_BackfillsZero(t *testing.T) {
 		t.Errorf("zero IdleAfterSec must fall back to default, got %s", cfg.IdleAfter)
 	}
 }
+
+func TestSave_CredentialsLiveInSecretsVault(t *testing.T) {
+	dir := t.TempDir()
+	in := DefaultAppConfig()
+	in.Telegram = telegram.Config{BotToken: "123:ABC", ChatID: "-100"}
+	in.Slack = slack.Config{WebhookURL: "https://hooks.slack.test/T/B/X"}
+	if err := Save(dir, in); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := secrets.Open(dir)
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	if moved, err := MigrateSecrets(dir); err != nil || !moved {
+		t.Fatalf("MigrateSecrets = %v, %v", moved, err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, configFilename))
+	if strings.Contains(string(raw), "123:ABC") || strings.Contains(string(raw), "hooks.slack.test") {
+		t.Fatalf("notify.json still holds credentials:\n%s", raw)
+	}
+	if !strings.Contains(string(raw), `"chatId": "-100"`) {
+		t.Errorf("non-secret fields should stay inline:\n%s", raw)
+	}
+
+	out, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Telegram.BotToken != "123:ABC" || out.Slack.WebhookURL != "https://hooks.slack.test/T/B/X" {
+		t.Errorf("loaded credentials = %+v / %+v", out.Telegram, out.Slack)
+	}
+}
@@ -53,6 +53,12 @@ const (
 	// KindBashGuardApproval — a Bash-Guard rule matched a dangerous
 	// command and is requesting human approval to allow it.
 	KindBashGuardApproval Kind = "bashguard_approval"
+	// KindCircuit — a relay endpoint's circuit breaker opened (it's out
+	// of routing) or closed again.
+	KindCircuit Kind = "circuit"
+	// KindRelayHealth — the background health monitor found a relay
+	// endpoint down, or up again.
+	KindRelayHealth Kind = "relay_health"
 	// KindTest — synthetic event the Settings page sends to verify the
 	// transport credentials are wired correctly.
 	KindTest Kind = "test"
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"lurus-switch/internal/secrets"
 )
 
 const (
@@ -21,8 +23,9 @@ const (
 // built-in presets it is persisted to disk and editable at runtime, so an
 // enterprise can point Switch at a private deployment without a code change.
 //
-// APIKey is stored base64-obfuscated on disk (NOT encryption — see Store).
-// In memory and across the Wails boundary it is the plaintext key.
+// APIKey is stored in the secrets vault, with only a reference on disk
+// (base64-obfuscated when no vault is open — see diskRecord). In memory
+// and across the Wails boundary it is the plaintext key.
 type CustomProvider struct {
 	ID            string            `json:"id"`
 	Name          string            `json:"name"`
@@ -130,20 +133,59 @@ func (s *CustomStore) Delete(id string) error {
 		return nil
 	}
 	delete(s.cache, id)
-	return s.persist()
+	if err := s.persist(); err != nil {
+		return err
+	}
+	// Best effort: a locked vault just keeps the orphaned key.
+	_ = secrets.Forget(secrets.Ref(apiKeySecretName(id)))
+	return nil
+}
+
+// Reload re-reads the file, e.g. once the secrets vault is unlocked and
+// the keys that were loaded as references can be resolved.
+func (s *CustomStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]CustomProvider)
+	return s.load()
+}
+
+// MigrateSecrets moves API keys still stored base64-obfuscated into the
+// secrets vault. Returns how many keys moved; a no-op without a vault.
+func (s *CustomStore) MigrateSecrets() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records, err := s.readRecords()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, r := range records {
+		// An apiKeyRef holding a plain key comes from a bundle exported
+		// with keys (configsync).
+		if secrets.NeedsSeal(r.APIKeyB64) || secrets.NeedsSeal(r.APIKeyRef) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	return moved, s.persist()
 }
 
 // --- persistence ---------------------------------------------------------
 
-// diskRecord mirrors CustomProvider but with the API key base64-obfuscated.
-// This is intentionally NOT encryption — it stops a casual `cat` / backup
-// sync from leaking the key in plaintext, paired with 0600 file perms. OS
-// keyring integration is tracked for a later wave.
+// diskRecord mirrors CustomProvider but with the API key replaced by a
+// secrets vault reference. Without a vault the key falls back to the
+// older base64 obfuscation — intentionally NOT encryption, it only stops
+// a casual `cat` / backup sync from leaking the key in plaintext, paired
+// with 0600 file perms.
 type diskRecord struct {
 	ID            string            `json:"id"`
 	Name          string            `json:"name"`
 	BaseURL       string            `json:"baseUrl"`
-	APIKeyB64     string            `json:"apiKeyB64"`
+	APIKeyRef     string            `json:"apiKeyRef,omitempty"`
+	APIKeyB64     string            `json:"apiKeyB64,omitempty"`
 	DefaultModels []string          `json:"defaultModels"`
 	Headers       map[string]string `json:"headers,omitempty"`
 	DocsURL       string            `json:"docsUrl,omitempty"`
@@ -152,20 +194,13 @@ type diskRecord struct {
 }
 
 func (s *CustomStore) load() error {
-	data, err := os.ReadFile(s.path)
+	records, err := s.readRecords()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // fresh install
-		}
-		return fmt.Errorf("read custom providers: %w", err)
-	}
-	var records []diskRecord
-	if err := json.Unmarshal(data, &records); err != nil {
-		return fmt.Errorf("parse custom providers: %w", err)
+		return err
 	}
 	for _, r := range records {
-		key := ""
-		if r.APIKeyB64 != "" {
+		key := secrets.Reveal(r.APIKeyRef)
+		if r.APIKeyRef == "" && r.APIKeyB64 != "" {
 			if dec, derr := base64.StdEncoding.DecodeString(r.APIKeyB64); derr == nil {
 				key = string(dec)
 			}
@@ -185,21 +220,47 @@ func (s *CustomStore) load() error {
 	return nil
 }
 
-// persist rewrites the whole file. Caller must hold s.mu.
+// readRecords reads the file as stored. A missing file is no records.
+func (s *CustomStore) readRecords() ([]diskRecord, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // fresh install
+		}
+		return nil, fmt.Errorf("read custom providers: %w", err)
+	}
+	var records []diskRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse custom providers: %w", err)
+	}
+	return records, nil
+}
+
+// persist rewrites the whole file, sealing API keys into the secrets
+// vault. Caller must hold s.mu.
 func (s *CustomStore) persist() error {
 	records := make([]diskRecord, 0, len(s.cache))
 	for _, p := range s.cache {
-		records = append(records, diskRecord{
+		r := diskRecord{
 			ID:            p.ID,
 			Name:          p.Name,
 			BaseURL:       p.BaseURL,
-			APIKeyB64:     base64.StdEncoding.EncodeToString([]byte(p.APIKey)),
 			DefaultModels: p.DefaultModels,
 			Headers:       p.Headers,
 			DocsURL:       p.DocsURL,
 			Description:   p.Description,
 			CreatedAt:     p.CreatedAt,
-		})
+		}
+		ref, err := secrets.Seal(apiKeySecretName(p.ID), p.APIKey)
+		if err != nil {
+			return fmt.Errorf("seal API key of %s: %w", p.ID, err)
+		}
+		if secrets.IsRef(ref) {
+			r.APIKeyRef = ref
+		} else if p.APIKey != "" {
+			r.APIKeyB64 = base64.StdEncoding.EncodeToString([]byte(p.APIKey))
+		}
+		records = append(records, r)
 	}
 	sort.Slice(records, func(i, j int) bool {
 		return records[i].CreatedAt.Before(records[j].CreatedAt)
@@ -217,6 +278,9 @@ func (s *CustomStore) persist() error {
 	return nil
 }
 
+// apiKeySecretName is the vault entry holding a provider's API key.
+func apiKeySecretName(id string) string { return "provider/" + id + "/apiKey" }
+
 // --- id helpers ----------------------------------------------------------
 
 // newCustomID derives a stable, readable ID from the name (or host),
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"lurus-switch/internal/secrets"
 )
 
 func TestCustomStore_SaveListDelete(t *testing.T) {
@@ -170,3 +172,34 @@ func TestDiskRecord_HasNoPlaintextKeyField(t *testing.T) {
 		t.Error("diskRecord must not serialize a plaintext apiKey field")
 	}
 }
+
+func TestCustomStore_KeysLiveInSecretsVault(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewCustomStore(dir)
+	p, _ := s.Save(CustomProvider{Name: "Acme", BaseURL: "https://acme.test", APIKey: "sk-acme"})
+
+	v, _ := secrets.Open(dir)
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	if n, err := s.MigrateSecrets(); err != nil || n != 1 {
+		t.Fatalf("MigrateSecrets = %d, %v", n, err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, customProvidersFile))
+	if strings.Contains(string(raw), "apiKeyB64") || !strings.Contains(string(raw), `"apiKeyRef": "secret://provider/`+p.ID+`/apiKey"`) {
+		t.Fatalf("custom-providers.json should hold a reference:\n%s", raw)
+	}
+
+	reopened, err := NewCustomStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reopened.Get(p.ID); got.APIKey != "sk-acme" {
+		t.Errorf("APIKey after reopen = %q", got.APIKey)
+	}
+	if err := reopened.Delete(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := v.Names(); len(names) != 0 {
+		t.Errorf("vault after delete = %v", names)
+	}
+}
@@ -51,7 +51,16 @@ func NewProxyManager() (*ProxyManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine proxy config path: %w", err)
 	}
+	return newProxyManagerAt(configPath), nil
+}
+
+// NewProxyManagerIn is NewProxyManager for the proxy.json in dir rather
+// than the platform config directory.
+func NewProxyManagerIn(dir string) *ProxyManager {
+	return newProxyManagerAt(filepath.Join(dir, "proxy.json"))
+}
 
+func newProxyManagerAt(configPath string) *ProxyManager {
 	pm := &ProxyManager{
 		configPath: configPath,
 		settings:   &ProxySettings{},
@@ -65,7 +74,7 @@ func NewProxyManager() (*ProxyManager, error) {
 		}
 	}
 
-	return pm, nil
+	return pm
 }
 
 // GetSettings returns a copy of the current proxy settings
@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
@@ -28,6 +29,25 @@ type CircuitState struct {
 	LastFailureMs      int64         `json:"lastFailureMs,omitempty"` // unix-millis
 	NextProbeMs        int64         `json:"nextProbeMs,omitempty"`   // unix-millis, half-open at-or-after
 	LastError          string        `json:"lastError,omitempty"`
+
+	// Hedged-request outcomes (see RecordHedge): races this endpoint won
+	// as either leg, and races it lost and was cancelled in.
+	HedgeWins   int `json:"hedgeWins,omitempty"`
+	HedgeLosses int `json:"hedgeLosses,omitempty"`
+
+	// Probe summarizes the endpoint's recent active health probes (see
+	// RecordProbe); nil until it has been probed.
+	Probe *ProbeStats `json:"probe,omitempty"`
+
+	// Keys is the per-key state of an endpoint with a key pool (see
+	// keys.go), by key ID.
+	Keys []KeyState `json:"keys,omitempty"`
+
+	// Trips counts the openings since the endpoint last served a
+	// request; each one doubles the cooldown (see policy.go).
+	// TripReason says which rule opened it last.
+	Trips      int    `json:"trips,omitempty"`
+	TripReason string `json:"tripReason,omitempty"`
 }
 
 // CircuitBreaker keeps a state machine per endpoint ID. Safe for
@@ -38,7 +58,18 @@ type CircuitBreaker struct {
 	states           map[string]*CircuitState
 	failureThreshold int
 	cooldown         time.Duration
-	now              func() time.Time // injectable for tests
+	now              func() time.Time        // injectable for tests
+	latencies        map[string]*latencyRing // see latency.go
+	probes           map[string]*probeRing   // see probe.go
+	keyPools         map[string]*keyPool     // see keys.go
+
+	// Adaptive tripping and transition reporting; see policy.go.
+	policies     map[string]BreakerPolicy
+	outcomes     map[string]*outcomeRing
+	onTransition func(CircuitTransition)
+
+	persistPath string     // see persist.go
+	saveMu      sync.Mutex // serialises state file writes
 }
 
 // NewCircuitBreaker returns a breaker with defaults that match what
@@ -86,41 +117,64 @@ func (b *CircuitBreaker) Allow(endpointID string) bool {
 
 // RecordSuccess closes the breaker for endpointID.
 func (b *CircuitBreaker) RecordSuccess(endpointID string) {
+	b.reported(b.recordSuccess(endpointID))
+}
+
+func (b *CircuitBreaker) recordSuccess(endpointID string) *CircuitTransition {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.recordOutcomeLocked(endpointID, false, b.policyLocked(endpointID).window)
 	st, ok := b.states[endpointID]
 	if !ok {
-		return
+		return nil
 	}
-	st.Status = StatusClosed
 	st.ConsecutiveFailures = 0
 	st.LastError = ""
+	st.Trips = 0
+	st.TripReason = ""
+	return b.setStatusLocked(st, StatusClosed, "request succeeded", 0)
 }
 
-// RecordFailure increments the consecutive-failures counter and trips
-// the breaker once it crosses the threshold.
+// RecordFailure counts a failed request and trips the breaker when the
+// endpoint's policy says so: its consecutive failures reached the
+// threshold, its error rate over the recent window did, or it failed the
+// half-open probe.
 func (b *CircuitBreaker) RecordFailure(endpointID, errMsg string) {
+	b.reported(b.recordFailure(endpointID, errMsg))
+}
+
+func (b *CircuitBreaker) recordFailure(endpointID, errMsg string) *CircuitTransition {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	st, ok := b.states[endpointID]
-	if !ok {
-		st = &CircuitState{EndpointID: endpointID, Status: StatusClosed}
-		b.states[endpointID] = st
-	}
+	p := b.policyLocked(endpointID)
+	rate, n := b.recordOutcomeLocked(endpointID, true, p.window)
+	st := b.state(endpointID)
 	st.ConsecutiveFailures++
 	st.LastFailureMs = b.now().UnixMilli()
 	st.LastError = errMsg
-	if st.ConsecutiveFailures >= b.failureThreshold {
-		st.Status = StatusOpen
-		st.NextProbeMs = b.now().Add(b.cooldown).UnixMilli()
+	switch {
+	case st.Status == StatusOpen:
+		return nil // a request that was already in flight when it opened
+	case st.Status == StatusHalfOpen:
+		return b.tripLocked(st, p, "half-open probe failed: "+errMsg)
+	case st.ConsecutiveFailures >= p.threshold:
+		return b.tripLocked(st, p, fmt.Sprintf("%d consecutive failures: %s", st.ConsecutiveFailures, errMsg))
+	case p.errorRate > 0 && n >= max(p.window/2, 2) && rate >= p.errorRate:
+		return b.tripLocked(st, p, fmt.Sprintf("%.0f%% of the last %d requests failed", rate*100, n))
 	}
+	return nil
 }
 
 // Reset clears state for one endpoint, returning it to closed.
 func (b *CircuitBreaker) Reset(endpointID string) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
 	delete(b.states, endpointID)
+	delete(b.latencies, endpointID)
+	delete(b.probes, endpointID)
+	delete(b.keyPools, endpointID)
+	delete(b.outcomes, endpointID)
+	b.mu.Unlock()
+	b.persist()
 }
 
 // Snapshot returns the current per-endpoint state map. The returned map
@@ -130,7 +184,12 @@ func (b *CircuitBreaker) Snapshot() map[string]CircuitState {
 	defer b.mu.RUnlock()
 	out := make(map[string]CircuitState, len(b.states))
 	for k, v := range b.states {
-		out[k] = *v
+		st := *v
+		if ps, ok := b.probeStatsLocked(k); ok {
+			st.Probe = &ps
+		}
+		st.Keys = b.keyStatesLocked(k)
+		out[k] = st
 	}
 	return out
 }
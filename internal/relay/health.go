@@ -3,7 +3,6 @@ package relay
 import (
 	"context"
 	"net/http"
-	"sync"
 	"time"
 )
 
@@ -13,29 +12,16 @@ const healthTimeout = 5 * time.Second
 const HealthCheckTimeout = healthTimeout
 
 // CheckHealth pings all endpoints concurrently and returns updated copies with
-// LatencyMs, Healthy, and LastChecked populated.
+// LatencyMs, Healthy, and LastChecked populated. It is CheckHealthMode
+// with ProbeReachability and no breaker.
 func CheckHealth(ctx context.Context, endpoints []RelayEndpoint) []RelayEndpoint {
-	results := make([]RelayEndpoint, len(endpoints))
-	copy(results, endpoints)
-
-	var wg sync.WaitGroup
-	for i := range results {
-		wg.Add(1)
-		go func(idx int) {
-			defer wg.Done()
-			ep := &results[idx]
-			ep.LatencyMs, ep.Healthy = ping(ctx, ep.URL)
-			ep.LastChecked = time.Now().Format(time.RFC3339)
-		}(i)
-	}
-	wg.Wait()
-	return results
+	return CheckHealthMode(ctx, endpoints, ProbeReachability, nil)
 }
 
-// ping attempts a HEAD/GET request to the endpoint URL and returns (latencyMs, ok).
-func ping(ctx context.Context, rawURL string) (int64, bool) {
+// ping attempts a HEAD/GET request to the endpoint URL and returns (latencyMs, status, ok).
+func ping(ctx context.Context, rawURL string) (int64, int, bool) {
 	if rawURL == "" {
-		return -1, false
+		return -1, 0, false
 	}
 	client := &http.Client{
 		Timeout: healthTimeout,
@@ -45,15 +31,15 @@ func ping(ctx context.Context, rawURL string) (int64, bool) {
 	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return -1, false
+		return -1, 0, false
 	}
 
 	start := time.Now()
 	resp, err := client.Do(req)
 	latency := time.Since(start).Milliseconds()
 	if err != nil {
-		return -1, false
+		return -1, 0, false
 	}
 	resp.Body.Close()
-	return latency, resp.StatusCode < 500
+	return latency, resp.StatusCode, resp.StatusCode < 500
 }
@@ -0,0 +1,299 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/modelcatalog"
+)
+
+// Health history. The background monitor (see monitor.go) appends each
+// probe result to a per-endpoint time series on disk — one JSON line per
+// sample in relay-health/<endpoint>.jsonl — so uptime and latency
+// percentiles survive restarts and cover days rather than the breaker's
+// in-memory window. Each file is bounded: samples older than
+// HistoryRetention go, as does anything past historyMaxSamples.
+
+const (
+	// HistoryDir is the history directory under the app-data dir.
+	HistoryDir = "relay-health"
+	// HistoryRetention is how far back the history reaches.
+	HistoryRetention = 7 * 24 * time.Hour
+
+	// historyMaxSamples caps one endpoint's file: a week of samples at
+	// the monitor's shortest interval.
+	historyMaxSamples = int(HistoryRetention / minMonitorInterval)
+	// historyCompactSlack is how far a file may outgrow its bounds before
+	// it is rewritten, so compaction isn't paid on every append.
+	historyCompactSlack = 256
+)
+
+// HealthSample is one probe in an endpoint's history.
+type HealthSample struct {
+	At         int64                    `json:"t"` // unix-millis
+	OK         bool                     `json:"ok"`
+	LatencyMs  int64                    `json:"ms,omitempty"`
+	StatusCode int                      `json:"code,omitempty"` // 0: no response
+	Verdict    modelcatalog.AuthVerdict `json:"verdict,omitempty"`
+}
+
+// sampleOf converts a probe result into a history sample.
+func sampleOf(res ProbeResult) HealthSample {
+	s := HealthSample{At: res.TestedAt.UnixMilli(), OK: res.Healthy, StatusCode: res.StatusCode, Verdict: res.Verdict}
+	if res.LatencyMs > 0 {
+		s.LatencyMs = res.LatencyMs
+	}
+	return s
+}
+
+// HealthStats summarizes an endpoint's history over one window.
+type HealthStats struct {
+	Samples   int     `json:"samples"`
+	UptimePct float64 `json:"uptimePct"`       // passing share, 0..100
+	P50Ms     int64   `json:"p50Ms,omitempty"` // over passing probes
+	P95Ms     int64   `json:"p95Ms,omitempty"`
+	// StatusCodes counts the HTTP statuses probes got; 0 counts probes
+	// that got no response.
+	StatusCodes map[int]int `json:"statusCodes,omitempty"`
+}
+
+// HealthSummary is an endpoint's history at a glance.
+type HealthSummary struct {
+	EndpointID string        `json:"endpointID"`
+	Day        HealthStats   `json:"day"`  // last 24h
+	Week       HealthStats   `json:"week"` // last 7 days
+	Last       *HealthSample `json:"last,omitempty"`
+}
+
+// History is the on-disk store of endpoint health samples. Safe for
+// concurrent use.
+type History struct {
+	mu  sync.Mutex
+	dir string
+	now func() time.Time
+	// counts and oldest track each file loaded so far, to decide when
+	// it needs compacting without rereading it on every append.
+	counts map[string]int
+	oldest map[string]int64
+}
+
+// NewHistory opens the history under appDataDir.
+func NewHistory(appDataDir string) (*History, error) {
+	dir := filepath.Join(appDataDir, HistoryDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("relay history: create dir: %w", err)
+	}
+	return &History{dir: dir, now: time.Now, counts: map[string]int{}, oldest: map[string]int64{}}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (h *History) file(endpointID string) string {
+	return filepath.Join(h.dir, unsafeFileChars.ReplaceAllString(endpointID, "_")+".jsonl")
+}
+
+// Append adds one sample to endpointID's history, compacting the file
+// once it has outgrown its bounds.
+func (h *History) Append(endpointID string, s HealthSample) error {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.counts[endpointID]; !ok {
+		samples, err := h.read(endpointID)
+		if err != nil {
+			return err
+		}
+		h.track(endpointID, samples)
+	}
+	f, err := os.OpenFile(h.file(endpointID), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("relay history: %w", err)
+	}
+	// A line a crash left torn must not swallow this one.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("relay history: %w", err)
+	}
+	if h.counts[endpointID] == 0 {
+		h.oldest[endpointID] = s.At
+	}
+	h.counts[endpointID]++
+
+	expired := h.oldest[endpointID] < h.now().Add(-HistoryRetention-24*time.Hour).UnixMilli()
+	if h.counts[endpointID] > historyMaxSamples+historyCompactSlack || expired {
+		return h.compact(endpointID)
+	}
+	return nil
+}
+
+// compact rewrites endpointID's file within its bounds. Callers hold
+// h.mu.
+func (h *History) compact(endpointID string) error {
+	samples, err := h.read(endpointID)
+	if err != nil {
+		return err
+	}
+	cutoff := h.now().Add(-HistoryRetention).UnixMilli()
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].At >= cutoff })
+	samples = samples[max(i, len(samples)-historyMaxSamples):]
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range samples {
+		enc.Encode(s)
+	}
+	path := h.file(endpointID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("relay history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("relay history: %w", err)
+	}
+	h.track(endpointID, samples)
+	return nil
+}
+
+func (h *History) track(endpointID string, samples []HealthSample) {
+	h.counts[endpointID] = len(samples)
+	if len(samples) > 0 {
+		h.oldest[endpointID] = samples[0].At
+	}
+}
+
+// read loads endpointID's samples, oldest first, skipping lines a crash
+// left torn. Callers hold h.mu.
+func (h *History) read(endpointID string) ([]HealthSample, error) {
+	f, err := os.Open(h.file(endpointID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("relay history: %w", err)
+	}
+	defer f.Close()
+	var out []HealthSample
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s HealthSample
+		if json.Unmarshal(sc.Bytes(), &s) == nil {
+			out = append(out, s)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At < out[j].At })
+	return out, sc.Err()
+}
+
+// Samples returns endpointID's samples taken at or after since, oldest
+// first.
+func (h *History) Samples(endpointID string, since time.Time) ([]HealthSample, error) {
+	h.mu.Lock()
+	samples, err := h.read(endpointID)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := since.UnixMilli()
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].At >= cutoff })
+	return samples[i:], nil
+}
+
+// Stats summarizes endpointID's samples from the last window.
+func (h *History) Stats(endpointID string, window time.Duration) (HealthStats, error) {
+	samples, err := h.Samples(endpointID, h.now().Add(-window))
+	if err != nil {
+		return HealthStats{}, err
+	}
+	return healthStats(samples), nil
+}
+
+// Summary returns endpointID's 24h and 7-day stats and its latest
+// sample.
+func (h *History) Summary(endpointID string) (HealthSummary, error) {
+	week, err := h.Samples(endpointID, h.now().Add(-HistoryRetention))
+	if err != nil {
+		return HealthSummary{}, err
+	}
+	cutoff := h.now().Add(-24 * time.Hour).UnixMilli()
+	i := sort.Search(len(week), func(i int) bool { return week[i].At >= cutoff })
+	sum := HealthSummary{EndpointID: endpointID, Day: healthStats(week[i:]), Week: healthStats(week)}
+	if len(week) > 0 {
+		last := week[len(week)-1]
+		sum.Last = &last
+	}
+	return sum, nil
+}
+
+func healthStats(samples []HealthSample) HealthStats {
+	st := HealthStats{Samples: len(samples)}
+	if len(samples) == 0 {
+		return st
+	}
+	st.StatusCodes = map[int]int{}
+	var ok []time.Duration
+	for _, s := range samples {
+		st.StatusCodes[s.StatusCode]++
+		if s.OK {
+			ok = append(ok, time.Duration(s.LatencyMs)*time.Millisecond)
+		}
+	}
+	st.UptimePct = 100 * float64(len(ok)) / float64(len(samples))
+	if len(ok) > 0 {
+		sort.Slice(ok, func(i, j int) bool { return ok[i] < ok[j] })
+		st.P50Ms = percentile(ok, 50).Milliseconds()
+		st.P95Ms = percentile(ok, 95).Milliseconds()
+	}
+	return st
+}
+
+// Prune deletes the history of every endpoint not in keep, so
+// endpoints removed from the store don't leave files behind.
+func (h *History) Prune(keep []RelayEndpoint) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	files, err := filepath.Glob(filepath.Join(h.dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	live := map[string]bool{}
+	for _, ep := range keep {
+		live[h.file(ep.ID)] = true
+	}
+	for _, f := range files {
+		if live[f] {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("relay history: %w", err)
+		}
+	}
+	for id := range h.counts {
+		if !live[h.file(id)] {
+			delete(h.counts, id)
+			delete(h.oldest, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package relay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"lurus-switch/internal/secrets"
+)
+
+// Key pools. An endpoint may carry several API keys for the same
+// provider (APIKey plus APIKeys) to spread rate limits. Each request goes
+// out on one key, chosen round-robin or least-used among the keys whose
+// own breaker is closed: a 401/402/403/429 answered on a pooled key says
+// that key is revoked, out of credit or throttled — not that the
+// endpoint is down — so it opens only that key's breaker and the next
+// request uses another. The endpoint drops out of routing once every key
+// in its pool is open. Endpoints with a single key keep the plain
+// endpoint breaker and report no key ID.
+
+// KeySelection picks which pooled key serves a request.
+type KeySelection string
+
+const (
+	// KeyRoundRobin cycles through the pool. The default; "" means it too.
+	KeyRoundRobin KeySelection = "round_robin"
+	// KeyLeastUsed picks the key that has served the fewest requests
+	// since the gateway started.
+	KeyLeastUsed KeySelection = "least_used"
+)
+
+func (s KeySelection) valid() bool {
+	return s == "" || s == KeyRoundRobin || s == KeyLeastUsed
+}
+
+// authKeyCooldown is how long a key the upstream rejected (401/402/403)
+// stays out of rotation; rejections rarely clear on their own, so this
+// is much longer than a throttled key's breaker cooldown.
+const authKeyCooldown = 10 * time.Minute
+
+// Keys returns ep's key pool: APIKey followed by APIKeys, with blanks and
+// duplicates dropped.
+func (ep RelayEndpoint) Keys() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, k := range append([]string{ep.APIKey}, ep.APIKeys...) {
+		if k = strings.TrimSpace(k); k != "" && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// keyUsable reports whether key may go upstream. The store keeps a key
+// the vault couldn't resolve (it was locked at load) as its reference so
+// saving doesn't lose it; that reference string is never a credential.
+func keyUsable(key string) bool { return !secrets.IsRef(key) }
+
+// KeysLocked reports whether ep has a key but none the vault resolved,
+// so no request or authenticated probe can go out on it.
+func KeysLocked(ep RelayEndpoint) bool {
+	keys := ep.Keys()
+	for _, k := range keys {
+		if keyUsable(k) {
+			return false
+		}
+	}
+	return len(keys) > 0
+}
+
+// firstUsableKey is the key authenticated probes use: ep's first key the
+// vault resolved, or "".
+func firstUsableKey(ep RelayEndpoint) string {
+	for _, k := range ep.Keys() {
+		if keyUsable(k) {
+			return k
+		}
+	}
+	return ""
+}
+
+// KeyID is a short, non-secret fingerprint of key. The breaker, metering
+// records and the UI identify pooled keys by it.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// KeyScopedStatus reports whether an upstream status code indicts the
+// key a request went out on rather than the endpoint.
+func KeyScopedStatus(code int) bool {
+	switch code {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// KeyState is one pooled key's breaker state.
+type KeyState struct {
+	KeyID       string        `json:"keyId"`
+	Status      CircuitStatus `json:"status"`
+	Uses        int64         `json:"uses"` // requests sent on the key since start
+	Failures    int           `json:"failures"`
+	LastStatus  int           `json:"lastStatus,omitempty"` // HTTP status that last opened it
+	LastError   string        `json:"lastError,omitempty"`
+	NextProbeMs int64         `json:"nextProbeMs,omitempty"` // unix-millis, half-open at-or-after
+}
+
+// keyPool is an endpoint's per-key state.
+type keyPool struct {
+	keys map[string]*KeyState
+	next int // round-robin cursor
+}
+
+// keyPoolFor returns endpointID's pool state, creating it. Callers hold
+// b.mu.
+func (b *CircuitBreaker) keyPoolFor(endpointID string) *keyPool {
+	if b.keyPools == nil {
+		b.keyPools = map[string]*keyPool{}
+	}
+	p := b.keyPools[endpointID]
+	if p == nil {
+		p = &keyPool{keys: map[string]*KeyState{}}
+		b.keyPools[endpointID] = p
+		b.state(endpointID) // list it in Snapshot
+	}
+	return p
+}
+
+// keyState returns one key's state, creating a closed one. Callers hold
+// b.mu.
+func (b *CircuitBreaker) keyState(endpointID, keyID string) *KeyState {
+	p := b.keyPoolFor(endpointID)
+	ks := p.keys[keyID]
+	if ks == nil {
+		ks = &KeyState{KeyID: keyID, Status: StatusClosed}
+		p.keys[keyID] = ks
+	}
+	return ks
+}
+
+// keyAllowed is Allow for one key. With transition, an open key whose
+// cooldown has passed moves to half-open. Callers hold b.mu.
+func (b *CircuitBreaker) keyAllowed(endpointID, keyID string, transition bool) bool {
+	p := b.keyPools[endpointID]
+	if p == nil || p.keys[keyID] == nil {
+		return true
+	}
+	ks := p.keys[keyID]
+	if ks.Status != StatusOpen {
+		return true
+	}
+	if b.now().UnixMilli() < ks.NextProbeMs {
+		return false
+	}
+	if transition {
+		ks.Status = StatusHalfOpen
+	}
+	return true
+}
+
+// AnyKeyAllowed reports whether at least one of ep's pooled keys may
+// take a request. Endpoints without a pool only fail it while their key
+// is locked in the vault.
+func (b *CircuitBreaker) AnyKeyAllowed(ep RelayEndpoint) bool {
+	keys := ep.Keys()
+	if len(keys) < 2 {
+		return !KeysLocked(ep)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, k := range keys {
+		if keyUsable(k) && b.keyAllowed(ep.ID, KeyID(k), false) {
+			return true
+		}
+	}
+	return false
+}
+
+// PickKey chooses the key one request to ep goes out on, per its
+// KeySelection, and counts the use. keyID is empty for endpoints without
+// a pool, and key for endpoints without any. ok is false when every
+// pooled key's breaker is open or no key was resolved from the vault.
+func (b *CircuitBreaker) PickKey(ep RelayEndpoint) (key, keyID string, ok bool) {
+	keys := ep.Keys()
+	if len(keys) < 2 {
+		if KeysLocked(ep) {
+			return "", "", false
+		}
+		if len(keys) == 1 {
+			key = keys[0]
+		}
+		return key, "", true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var allowed []int
+	for i, k := range keys {
+		if keyUsable(k) && b.keyAllowed(ep.ID, KeyID(k), false) {
+			allowed = append(allowed, i)
+		}
+	}
+	if len(allowed) == 0 {
+		return "", "", false
+	}
+	pick := allowed[0]
+	switch ep.KeySelection {
+	case KeyLeastUsed:
+		for _, i := range allowed[1:] {
+			if b.keyState(ep.ID, KeyID(keys[i])).Uses < b.keyState(ep.ID, KeyID(keys[pick])).Uses {
+				pick = i
+			}
+		}
+	default:
+		// The first allowed key at or after the cursor, wrapping.
+		p := b.keyPoolFor(ep.ID)
+		for _, i := range allowed {
+			if i >= p.next {
+				pick = i
+				break
+			}
+		}
+		p.next = (pick + 1) % len(keys)
+	}
+	keyID = KeyID(keys[pick])
+	b.keyAllowed(ep.ID, keyID, true)
+	b.keyState(ep.ID, keyID).Uses++
+	return keys[pick], keyID, true
+}
+
+// RecordKeyResult records the upstream status a pooled key's request
+// got; status 0 means no response. A key-scoped status opens the key's
+// breaker at once — for authKeyCooldown on a rejection, the usual
+// cooldown on a 429 — and a success closes it. Other failures are the
+// endpoint's and leave the key alone.
+func (b *CircuitBreaker) RecordKeyResult(endpointID, keyID string, status int, errMsg string) {
+	if keyID == "" {
+		return
+	}
+	b.mu.Lock()
+	ks := b.keyState(endpointID, keyID)
+	was := ks.Status
+	switch {
+	case KeyScopedStatus(status):
+		cooldown := b.cooldown
+		if status != http.StatusTooManyRequests {
+			cooldown = authKeyCooldown
+		}
+		ks.Status = StatusOpen
+		ks.Failures++
+		ks.LastStatus = status
+		ks.LastError = errMsg
+		ks.NextProbeMs = b.now().Add(cooldown).UnixMilli()
+	case status > 0 && status < 400:
+		ks.Status = StatusClosed
+		ks.LastError = ""
+	}
+	changed := ks.Status != was
+	b.mu.Unlock()
+	if changed {
+		b.persist()
+	}
+}
+
+// keyStatesLocked lists endpointID's key states by key ID. Callers hold
+// b.mu.
+func (b *CircuitBreaker) keyStatesLocked(endpointID string) []KeyState {
+	p := b.keyPools[endpointID]
+	if p == nil {
+		return nil
+	}
+	out := make([]KeyState, 0, len(p.keys))
+	for _, ks := range p.keys {
+		out = append(out, *ks)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyID < out[j].KeyID })
+	return out
+}
+
+// validateEndpoint rejects a KeySelection or breaker policy the router
+// wouldn't apply.
+func validateEndpoint(ep RelayEndpoint) error {
+	if !ep.KeySelection.valid() {
+		return fmt.Errorf("endpoint %q: unknown key selection %q (want %s or %s)", ep.ID, ep.KeySelection, KeyRoundRobin, KeyLeastUsed)
+	}
+	if ep.Breaker != nil {
+		if err := ep.Breaker.validate(); err != nil {
+			return fmt.Errorf("endpoint %q: %w", ep.ID, err)
+		}
+	}
+	return nil
+}
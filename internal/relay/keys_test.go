@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/modelcatalog"
+	"lurus-switch/internal/secrets"
+)
+
+func TestKeyPool_Selection(t *testing.T) {
+	ep := RelayEndpoint{ID: "p", APIKey: "k1", APIKeys: []string{"k2", "k1", " ", "k3"}}
+	if got := strings.Join(ep.Keys(), ","); got != "k1,k2,k3" {
+		t.Fatalf("Keys() = %s", got)
+	}
+
+	b := NewCircuitBreaker()
+	var got []string
+	for i := 0; i < 4; i++ {
+		key, id, ok := b.PickKey(ep)
+		if !ok || id != KeyID(key) {
+			t.Fatalf("PickKey = %q, %q, %v", key, id, ok)
+		}
+		got = append(got, key)
+	}
+	if strings.Join(got, ",") != "k1,k2,k3,k1" {
+		t.Errorf("round robin = %v", got)
+	}
+
+	// least_used evens the counts out: k1 has 2 uses, so k2 then k3.
+	ep.KeySelection = KeyLeastUsed
+	k, _, _ := b.PickKey(ep)
+	k2, _, _ := b.PickKey(ep)
+	if k != "k2" || k2 != "k3" {
+		t.Errorf("least used = %s, %s; want k2, k3", k, k2)
+	}
+
+	// A single key isn't a pool: no key ID, no state.
+	solo := RelayEndpoint{ID: "solo", APIKey: "only"}
+	if key, id, ok := b.PickKey(solo); key != "only" || id != "" || !ok {
+		t.Errorf("PickKey(solo) = %q, %q, %v", key, id, ok)
+	}
+	if _, ok := b.Snapshot()["solo"]; ok {
+		t.Error("a single-key endpoint should get no key state")
+	}
+}
+
+func TestKeyPool_PerKeyBreakers(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewCircuitBreakerForTest(3, 30*time.Second, func() time.Time { return now })
+	ep := RelayEndpoint{ID: "p", APIKey: "k1", APIKeys: []string{"k2"}}
+
+	b.RecordKeyResult("p", KeyID("k1"), 429, "status 429")
+	b.RecordKeyResult("p", KeyID("k1"), 503, "status 503") // the endpoint's fault, not the key's
+	for i := 0; i < 3; i++ {
+		if key, _, _ := b.PickKey(ep); key != "k2" {
+			t.Fatalf("pick %d = %s while k1 is throttled", i, key)
+		}
+	}
+	if !b.Allow("p") {
+		t.Error("a throttled key must not open the endpoint's breaker")
+	}
+
+	b.RecordKeyResult("p", KeyID("k2"), 401, "status 401")
+	if b.AnyKeyAllowed(ep) {
+		t.Error("every key open: the endpoint should be out")
+	}
+	if _, _, ok := b.PickKey(ep); ok {
+		t.Error("PickKey with every key open should fail")
+	}
+
+	// The throttled key comes back after the breaker cooldown; the
+	// rejected one stays out far longer.
+	now = now.Add(31 * time.Second)
+	if key, _, ok := b.PickKey(ep); !ok || key != "k1" {
+		t.Errorf("after cooldown PickKey = %q, %v; want k1", key, ok)
+	}
+	b.RecordKeyResult("p", KeyID("k1"), 200, "")
+	for _, ks := range b.Snapshot()["p"].Keys {
+		want := StatusClosed
+		if ks.KeyID == KeyID("k2") {
+			want = StatusOpen
+		}
+		if ks.Status != want {
+			t.Errorf("key %s status %s, want %s", ks.KeyID, ks.Status, want)
+		}
+	}
+}
+
+func TestRouter_SkipsEndpointWithEveryKeyOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStore(dir)
+	store.SaveEndpoint(RelayEndpoint{ID: "pool", URL: "https://p.test", APIKey: "k1", APIKeys: []string{"k2"}, LatencyMs: 10})
+	store.SaveEndpoint(RelayEndpoint{ID: "other", URL: "https://o.test", LatencyMs: 50})
+	b := NewCircuitBreaker()
+	router, err := NewRouter(dir, store, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.RecordKeyResult("pool", KeyID("k1"), 401, "")
+	b.RecordKeyResult("pool", KeyID("k2"), 402, "")
+	res, err := router.Pick("claude", PickHint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range res.Healthy {
+		if ep.ID == "pool" {
+			t.Error("an endpoint whose whole key pool is open should not be routable")
+		}
+	}
+	if err := store.SaveEndpoint(RelayEndpoint{ID: "x", URL: "https://x.test", KeySelection: "random"}); err == nil {
+		t.Error("SaveEndpoint should reject an unknown key selection")
+	}
+}
+
+func TestStore_PoolKeysLiveInSecretsVault(t *testing.T) {
+	dir := t.TempDir()
+	v, _ := secrets.Open(dir)
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+
+	store, _ := NewStore(dir)
+	if err := store.SaveEndpoint(RelayEndpoint{ID: "p", URL: "https://p.test", APIKey: "sk-a", APIKeys: []string{"sk-b", "sk-c"}}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, endpointsFile))
+	if strings.Contains(string(raw), "sk-b") || !strings.Contains(string(raw), "secret://relay/p/apiKeys/"+KeyID("sk-b")) {
+		t.Fatalf("pooled keys not sealed:\n%s", raw)
+	}
+	eps, _ := store.ListEndpoints()
+	for _, ep := range eps {
+		if ep.ID == "p" && strings.Join(ep.APIKeys, ",") != "sk-b,sk-c" {
+			t.Errorf("APIKeys after load = %v", ep.APIKeys)
+		}
+	}
+
+	// Dropping a key from the pool forgets its vault entry.
+	if err := store.SaveEndpoint(RelayEndpoint{ID: "p", URL: "https://p.test", APIKey: "sk-a", APIKeys: []string{"sk-c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Get("relay/p/apiKeys/" + KeyID("sk-b")); err == nil {
+		t.Error("a dropped pool key should leave the vault")
+	}
+}
+
+func TestKeyPool_LockedVaultKeysAreUnusable(t *testing.T) {
+	b := NewCircuitBreaker()
+	ref := secrets.Ref("relay/p/apiKey")
+
+	pool := RelayEndpoint{ID: "p", APIKey: ref, APIKeys: []string{"sk-b"}}
+	for i := 0; i < 3; i++ {
+		if key, _, ok := b.PickKey(pool); !ok || key != "sk-b" {
+			t.Fatalf("PickKey = %q, %v; want the resolved sk-b", key, ok)
+		}
+	}
+	single := RelayEndpoint{ID: "s", APIKey: ref}
+	if key, _, ok := b.PickKey(single); ok {
+		t.Errorf("PickKey on a locked key = %q, want no key", key)
+	}
+	if b.AnyKeyAllowed(single) || !KeysLocked(single) {
+		t.Error("an endpoint whose only key is locked should leave routing")
+	}
+	if res := Probe(context.Background(), single, ProbeModels); res.Healthy || res.Verdict != modelcatalog.VerdictAuth {
+		t.Errorf("probe with a locked key = %+v", res)
+	}
+	if KeysLocked(RelayEndpoint{ID: "free"}) {
+		t.Error("an endpoint without keys isn't locked")
+	}
+}
@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Header-latency history. The breaker keeps each endpoint's most recent
+// successful round-trip times (request sent → response headers) so the
+// gateway's hedging can wait "as long as this endpoint usually takes"
+// before firing a backup request, rather than a fixed guess.
+
+const (
+	// latencyWindow is how many samples each endpoint keeps.
+	latencyWindow = 100
+	// minLatencySamples is the history LatencyPercentile needs before it
+	// answers; below it a percentile is noise.
+	minLatencySamples = 10
+)
+
+// latencyRing is a fixed-size ring of samples.
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func (r *latencyRing) add(d time.Duration) {
+	if len(r.samples) < latencyWindow {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % latencyWindow
+}
+
+// RecordLatency adds one successful attempt's header latency to
+// endpointID's history, tripping the breaker when the endpoint's policy
+// sets SlowP95Ms and the recent p95 has reached it.
+func (b *CircuitBreaker) RecordLatency(endpointID string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	b.reported(b.recordLatency(endpointID, d))
+}
+
+func (b *CircuitBreaker) recordLatency(endpointID string, d time.Duration) *CircuitTransition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.latencies == nil {
+		b.latencies = map[string]*latencyRing{}
+	}
+	r := b.latencies[endpointID]
+	if r == nil {
+		r = &latencyRing{}
+		b.latencies[endpointID] = r
+	}
+	r.add(d)
+	p := b.policyLocked(endpointID)
+	p95, slow := b.slowLocked(endpointID, p.slow)
+	if !slow {
+		return nil
+	}
+	st := b.state(endpointID)
+	if st.Status == StatusOpen {
+		return nil
+	}
+	return b.tripLocked(st, p, fmt.Sprintf("p95 latency %v reached %v", p95.Round(time.Millisecond), p.slow))
+}
+
+// LatencyPercentile returns the p-th percentile (0 < p <= 100) of
+// endpointID's recent header latencies. ok is false until the endpoint
+// has minLatencySamples of history.
+func (b *CircuitBreaker) LatencyPercentile(endpointID string, p float64) (d time.Duration, ok bool) {
+	if p <= 0 || p > 100 {
+		return 0, false
+	}
+	b.mu.RLock()
+	r := b.latencies[endpointID]
+	var samples []time.Duration
+	if r != nil {
+		samples = append(samples, r.samples...)
+	}
+	b.mu.RUnlock()
+	if len(samples) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(math.Ceil(p/100*float64(len(samples)))) - 1
+	return samples[max(i, 0)], true
+}
+
+// RecordHedge counts a hedged race: winnerID answered first and loserID
+// was cancelled.
+func (b *CircuitBreaker) RecordHedge(winnerID, loserID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state(winnerID).HedgeWins++
+	b.state(loserID).HedgeLosses++
+}
+
+// state returns endpointID's state, creating a closed one. Callers hold
+// b.mu.
+func (b *CircuitBreaker) state(endpointID string) *CircuitState {
+	st, ok := b.states[endpointID]
+	if !ok {
+		st = &CircuitState{EndpointID: endpointID, Status: StatusClosed}
+		b.states[endpointID] = st
+	}
+	return st
+}
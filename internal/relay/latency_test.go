@@ -0,0 +1,62 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuit_LatencyPercentile(t *testing.T) {
+	b := NewCircuitBreaker()
+	for i := 1; i < minLatencySamples; i++ {
+		b.RecordLatency("a", time.Duration(i)*time.Millisecond)
+	}
+	if _, ok := b.LatencyPercentile("a", 90); ok {
+		t.Fatal("percentile needs minLatencySamples of history")
+	}
+	for i := minLatencySamples; i <= 2*latencyWindow; i++ {
+		b.RecordLatency("a", time.Duration(i)*time.Millisecond)
+	}
+	// The ring holds the last latencyWindow samples: 101ms..200ms.
+	if d, ok := b.LatencyPercentile("a", 90); !ok || d != 190*time.Millisecond {
+		t.Errorf("p90 = %v, %v; want 190ms", d, ok)
+	}
+	if d, _ := b.LatencyPercentile("a", 100); d != 200*time.Millisecond {
+		t.Errorf("p100 = %v, want 200ms", d)
+	}
+	b.Reset("a")
+	if _, ok := b.LatencyPercentile("a", 90); ok {
+		t.Error("Reset should clear latency history")
+	}
+}
+
+func TestCircuit_RecordHedge(t *testing.T) {
+	b := NewCircuitBreaker()
+	b.RecordHedge("fast", "slow")
+	b.RecordHedge("fast", "slow")
+	snap := b.Snapshot()
+	if snap["fast"].HedgeWins != 2 || snap["slow"].HedgeLosses != 2 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if snap["slow"].Status != StatusClosed {
+		t.Errorf("a hedge loss must not affect breaker status, got %s", snap["slow"].Status)
+	}
+}
+
+func TestRouter_HedgePercentileFromRule(t *testing.T) {
+	router := newStrategyRouter(t, `
+rules:
+  - name: interactive
+    match_model_prefix: gpt
+    prefer_endpoint_id: a
+    hedge_percentile: 90
+`, RelayEndpoint{ID: "a", URL: "http://a"}, RelayEndpoint{ID: "b", URL: "http://b"})
+	if res, _ := router.Pick("", PickHint{Model: "gpt-4o"}); res.HedgePercentile != 90 {
+		t.Errorf("HedgePercentile = %v, want 90", res.HedgePercentile)
+	}
+	if res, _ := router.Pick("", PickHint{Model: "claude"}); res.HedgePercentile != 0 {
+		t.Errorf("unmatched request HedgePercentile = %v, want 0", res.HedgePercentile)
+	}
+	if err := router.LoadRulesYAML("rules:\n  - name: bad\n    hedge_percentile: 150\n"); err == nil {
+		t.Error("hedge_percentile above 100 should be rejected")
+	}
+}
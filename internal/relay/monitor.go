@@ -0,0 +1,325 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Background health monitor. Without it endpoint health is refreshed only
+// when someone presses "check" in the UI. The Monitor probes every
+// endpoint on an interval, feeds the results to the breaker's probe
+// stats and the store's latency like ProbeRelayHealth does, appends them
+// to the on-disk History, and reports an endpoint going down (DownAfter
+// failed probes in a row) and recovering (its first passing probe after).
+
+const (
+	// MonitorConfigFile holds the monitor settings under the app-data dir.
+	MonitorConfigFile = "relay-monitor.json"
+
+	defaultMonitorInterval = 5 * time.Minute
+	minMonitorInterval     = time.Minute
+	defaultDownAfter       = 2
+)
+
+// MonitorConfig configures the background health monitor.
+type MonitorConfig struct {
+	Enabled bool `json:"enabled"`
+	// IntervalSec is the time between sweeps (default 300, at least 60).
+	IntervalSec int `json:"intervalSec,omitempty"`
+	// Mode is the probe each sweep runs; "" is ProbeReachability.
+	Mode ProbeMode `json:"mode,omitempty"`
+	// DownAfter is the failed probes in a row that mark an endpoint down
+	// (default 2), so one dropped packet doesn't page anyone.
+	DownAfter int `json:"downAfter,omitempty"`
+}
+
+// DefaultMonitorConfig is the config used before the user saves one:
+// reachability probes every five minutes.
+func DefaultMonitorConfig() MonitorConfig {
+	return MonitorConfig{Enabled: true, IntervalSec: int(defaultMonitorInterval / time.Second), Mode: ProbeReachability, DownAfter: defaultDownAfter}
+}
+
+func (c MonitorConfig) validate() error {
+	if _, err := ParseProbeMode(string(c.Mode)); err != nil {
+		return err
+	}
+	if c.IntervalSec != 0 && time.Duration(c.IntervalSec)*time.Second < minMonitorInterval {
+		return fmt.Errorf("monitor interval %ds is below the %v minimum", c.IntervalSec, minMonitorInterval)
+	}
+	if c.DownAfter < 0 {
+		return fmt.Errorf("monitor downAfter must not be negative")
+	}
+	return nil
+}
+
+func (c MonitorConfig) interval() time.Duration {
+	if c.IntervalSec <= 0 {
+		return defaultMonitorInterval
+	}
+	return max(time.Duration(c.IntervalSec)*time.Second, minMonitorInterval)
+}
+
+func (c MonitorConfig) downAfter() int {
+	if c.DownAfter <= 0 {
+		return defaultDownAfter
+	}
+	return c.DownAfter
+}
+
+// LoadMonitorConfig reads the monitor config under appDataDir, falling
+// back to DefaultMonitorConfig when none was saved.
+func LoadMonitorConfig(appDataDir string) (MonitorConfig, error) {
+	data, err := os.ReadFile(filepath.Join(appDataDir, MonitorConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultMonitorConfig(), nil
+	}
+	if err != nil {
+		return DefaultMonitorConfig(), fmt.Errorf("read relay monitor config: %w", err)
+	}
+	cfg := DefaultMonitorConfig()
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return DefaultMonitorConfig(), fmt.Errorf("parse relay monitor config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return DefaultMonitorConfig(), fmt.Errorf("relay monitor config: %w", err)
+	}
+	return cfg, nil
+}
+
+// SaveMonitorConfig validates and writes cfg under appDataDir.
+func SaveMonitorConfig(appDataDir string, cfg MonitorConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal relay monitor config: %w", err)
+	}
+	return os.WriteFile(filepath.Join(appDataDir, MonitorConfigFile), data, 0o600)
+}
+
+// HealthChange is an endpoint going down or coming back, as the monitor
+// saw it.
+type HealthChange struct {
+	EndpointID string `json:"endpointID"`
+	Name       string `json:"name"`
+	Down       bool   `json:"down"`
+	// Failures is the failed probes in a row that marked it down; Reason
+	// and StatusCode describe the last of them.
+	Failures   int    `json:"failures,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	// DownFor is how long a recovered endpoint was down.
+	DownFor time.Duration `json:"downFor,omitempty"`
+	At      time.Time     `json:"at"`
+}
+
+// endpointHealth is the monitor's up/down bookkeeping for one endpoint.
+type endpointHealth struct {
+	failures  int
+	down      bool
+	downSince int64 // unix-millis of the first failed probe in the run
+}
+
+// Monitor runs the background health sweeps. Safe for concurrent use.
+type Monitor struct {
+	store   *Store
+	breaker *CircuitBreaker // may be nil
+	history *History        // may be nil
+
+	mu       sync.Mutex
+	cfg      MonitorConfig
+	cancel   context.CancelFunc
+	done     chan struct{}
+	onChange func(HealthChange)
+
+	sweepMu sync.Mutex // one sweep at a time; guards health
+	health  map[string]*endpointHealth
+}
+
+// NewMonitor returns a stopped monitor over store's endpoints. Results go
+// to breaker's probe stats and to history when those are non-nil.
+func NewMonitor(store *Store, breaker *CircuitBreaker, history *History) *Monitor {
+	return &Monitor{store: store, breaker: breaker, history: history, cfg: DefaultMonitorConfig(), health: map[string]*endpointHealth{}}
+}
+
+// SetChangeHook registers fn to hear endpoints going down and
+// recovering. fn runs on the sweep's goroutine; nil clears it.
+func (m *Monitor) SetChangeHook(fn func(HealthChange)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// History returns the store the monitor writes samples to.
+func (m *Monitor) History() *History { return m.history }
+
+// Config returns the config the monitor last started with.
+func (m *Monitor) Config() MonitorConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cfg
+}
+
+// Start (re)starts the sweep loop with cfg; the first sweep runs at once.
+// A disabled cfg just stops it.
+func (m *Monitor) Start(cfg MonitorConfig) {
+	m.Stop()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	if !cfg.Enabled {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel, m.done = cancel, make(chan struct{})
+	go m.loop(ctx, cfg.interval(), m.done)
+}
+
+// Stop ends the sweep loop, waiting for a sweep in progress to finish.
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (m *Monitor) loop(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := m.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("relay monitor: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce probes every endpoint now, records the results and reports
+// up/down changes. It returns the probed endpoints; the error joins the
+// history writes that failed.
+func (m *Monitor) RunOnce(ctx context.Context) ([]RelayEndpoint, error) {
+	m.sweepMu.Lock()
+	defer m.sweepMu.Unlock()
+	endpoints, err := m.store.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	cfg := m.Config()
+	mode, _ := ParseProbeMode(string(cfg.Mode))
+
+	probeCtx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	results := CheckHealthMode(probeCtx, endpoints, mode, m.breaker)
+	cancel()
+	if ctx.Err() != nil {
+		return results, nil // stopped mid-sweep: the results aren't real failures
+	}
+
+	var errs []error
+	var changes []HealthChange
+	for _, ep := range results {
+		if ep.Probe == nil {
+			continue
+		}
+		if ep.Healthy {
+			if err := m.store.UpdateEndpointLatency(ep.ID, ep.LatencyMs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if ch, ok := m.observe(ep, cfg.downAfter()); ok {
+			changes = append(changes, ch)
+		}
+		if m.history != nil {
+			if err := m.history.Append(ep.ID, sampleOf(*ep.Probe)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if m.history != nil {
+		if err := m.history.Prune(endpoints); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	m.mu.Lock()
+	hook := m.onChange
+	m.mu.Unlock()
+	if hook != nil {
+		for _, ch := range changes {
+			hook(ch)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// observe updates ep's up/down state with its latest probe and returns
+// the change, if any. Callers hold m.sweepMu.
+func (m *Monitor) observe(ep RelayEndpoint, downAfter int) (HealthChange, bool) {
+	res := *ep.Probe
+	h := m.health[ep.ID]
+	if h == nil {
+		h = m.seed(ep.ID, downAfter)
+		m.health[ep.ID] = h
+	}
+	ch := HealthChange{EndpointID: ep.ID, Name: ep.Name, At: res.TestedAt}
+	if ep.Healthy {
+		h.failures = 0
+		if !h.down {
+			return ch, false
+		}
+		h.down = false
+		ch.DownFor = res.TestedAt.Sub(time.UnixMilli(h.downSince))
+		return ch, true
+	}
+	if h.failures == 0 {
+		h.downSince = res.TestedAt.UnixMilli()
+	}
+	h.failures++
+	if h.down || h.failures < downAfter {
+		return ch, false
+	}
+	h.down = true
+	ch.Down, ch.Failures, ch.StatusCode = true, h.failures, res.StatusCode
+	ch.Reason = res.Note
+	if ch.Reason == "" {
+		ch.Reason = string(res.Verdict)
+	}
+	return ch, true
+}
+
+// seed restores an endpoint's up/down state from its history's failing
+// tail the first time a monitor sees it, so a restart neither repeats a
+// down alert nor misses the recovery. The sample just appended for this
+// sweep isn't in it yet; observe counts it.
+func (m *Monitor) seed(endpointID string, downAfter int) *endpointHealth {
+	h := &endpointHealth{}
+	if m.history == nil {
+		return h
+	}
+	samples, err := m.history.Samples(endpointID, time.Now().Add(-HistoryRetention))
+	if err != nil {
+		return h
+	}
+	for i := len(samples) - 1; i >= 0 && !samples[i].OK; i-- {
+		h.failures++
+		h.downSince = samples[i].At
+	}
+	h.down = h.failures >= downAfter
+	return h
+}
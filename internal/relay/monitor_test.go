@@ -0,0 +1,161 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistory_StatsAndBounds(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	h.now = func() time.Time { return now }
+
+	add := func(ago time.Duration, ok bool, ms int64, code int) {
+		t.Helper()
+		if err := h.Append("r/1", HealthSample{At: now.Add(-ago).UnixMilli(), OK: ok, LatencyMs: ms, StatusCode: code}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Three days ago: down. Today: three fast, one slow, one 502.
+	add(72*time.Hour, false, 0, 0)
+	add(71*time.Hour, false, 0, 503)
+	for i, ms := range []int64{100, 120, 110, 900} {
+		add(time.Duration(5-i)*time.Hour, true, ms, 404)
+	}
+	add(time.Hour, false, 0, 502)
+
+	sum, err := h.Summary("r/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Day.Samples != 5 || sum.Day.UptimePct != 80 || sum.Day.P50Ms != 110 || sum.Day.P95Ms != 900 {
+		t.Errorf("day = %+v", sum.Day)
+	}
+	if sum.Week.Samples != 7 || sum.Week.StatusCodes[0] != 1 || sum.Week.StatusCodes[404] != 4 {
+		t.Errorf("week = %+v", sum.Week)
+	}
+	if sum.Last == nil || sum.Last.StatusCode != 502 {
+		t.Errorf("last = %+v", sum.Last)
+	}
+
+	// A torn last line (a crash mid-append) is skipped, not fatal.
+	f, _ := os.OpenFile(h.file("r/1"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"t":17`)
+	f.Close()
+	if st, err := h.Stats("r/1", 24*time.Hour); err != nil || st.Samples != 5 {
+		t.Errorf("stats with torn line = %+v, %v", st, err)
+	}
+
+	// Once the oldest sample is a day past the retention, the next
+	// append compacts the file down to it.
+	now = now.Add(5*24*time.Hour + time.Hour)
+	add(0, true, 50, 200)
+	if got, _ := h.Samples("r/1", time.Time{}); len(got) != 6 {
+		t.Errorf("after expiry %d samples remain, want the 6 from the last week", len(got))
+	}
+
+	h.Prune(nil)
+	if files, _ := filepath.Glob(filepath.Join(dir, HistoryDir, "*")); len(files) != 0 {
+		t.Errorf("Prune left %v", files)
+	}
+}
+
+func TestMonitor_ReportsDownAndRecovery(t *testing.T) {
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	store, _ := NewStore(dir)
+	store.SaveEndpoint(RelayEndpoint{ID: "r1", Name: "Relay One", URL: srv.URL})
+	store.builtin = nil // keep the sweep off the network
+	hist, _ := NewHistory(dir)
+	b := NewCircuitBreaker()
+	m := NewMonitor(store, b, hist)
+	var changes []HealthChange
+	m.SetChangeHook(func(ch HealthChange) { changes = append(changes, ch) })
+
+	sweep := func() {
+		t.Helper()
+		if _, err := m.RunOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sweep()
+	failing.Store(true)
+	sweep() // one failure isn't down yet
+	if len(changes) != 0 {
+		t.Fatalf("changes after one failure = %+v", changes)
+	}
+	sweep()
+	if len(changes) != 1 || !changes[0].Down || changes[0].StatusCode != 502 || changes[0].Name != "Relay One" {
+		t.Fatalf("changes = %+v, want r1 down with 502", changes)
+	}
+	sweep() // still down: no repeat
+
+	// A restarted monitor picks the down state up from the history.
+	m2 := NewMonitor(store, b, hist)
+	m2.SetChangeHook(func(ch HealthChange) { changes = append(changes, ch) })
+	failing.Store(false)
+	if _, err := m2.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[1].Down || changes[1].DownFor <= 0 {
+		t.Fatalf("changes = %+v, want one recovery after the restart", changes)
+	}
+
+	eps, _ := store.ListEndpoints()
+	if !eps[0].Healthy || eps[0].LastChecked == "" {
+		t.Errorf("store not updated: %+v", eps[0])
+	}
+	if ps, ok := b.ProbeStats("r1"); !ok || ps.Samples != 5 {
+		t.Errorf("probe stats = %+v", ps)
+	}
+	sum, _ := hist.Summary("r1")
+	if sum.Day.Samples != 5 || sum.Day.UptimePct != 40 || sum.Day.StatusCodes[502] != 3 {
+		t.Errorf("summary = %+v", sum.Day)
+	}
+}
+
+func TestMonitorConfig_LoadSave(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := LoadMonitorConfig(dir)
+	if err != nil || cfg != DefaultMonitorConfig() {
+		t.Fatalf("default = %+v, %v", cfg, err)
+	}
+	if err := SaveMonitorConfig(dir, MonitorConfig{Enabled: true, IntervalSec: 10}); err == nil || !strings.Contains(err.Error(), "minimum") {
+		t.Errorf("a 10s interval should be rejected, got %v", err)
+	}
+	if err := SaveMonitorConfig(dir, MonitorConfig{Mode: "ping"}); err == nil {
+		t.Error("an unknown mode should be rejected")
+	}
+	want := MonitorConfig{Enabled: false, IntervalSec: 120, Mode: ProbeModels, DownAfter: 3}
+	if err := SaveMonitorConfig(dir, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LoadMonitorConfig(dir); got != want {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+
+	// Starting disabled runs nothing; Stop is safe either way.
+	m := NewMonitor(nil, nil, nil)
+	m.Start(want)
+	m.Stop()
+}
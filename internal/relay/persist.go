@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Breaker persistence. Without it a restart forgets which endpoints are
+// open and which pooled keys were rejected, and the first requests after
+// it walk straight back into them. The state file holds each endpoint's
+// CircuitState with its key states; the rolling outcome, latency and
+// probe windows are rebuilt from live traffic.
+
+// CircuitStateFile is the breaker state file under the app-data dir.
+const CircuitStateFile = "relay-circuit.json"
+
+// circuitFile is CircuitStateFile's layout.
+type circuitFile struct {
+	Version   int                     `json:"version"`
+	Endpoints map[string]CircuitState `json:"endpoints"`
+}
+
+// EnablePersistence loads the breaker state saved in path, if any, and
+// saves it there after every open or close transition, key breaker
+// change and Reset. Saving is best effort: a failed write leaves the
+// previous file in place.
+func (b *CircuitBreaker) EnablePersistence(path string) error {
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.persistPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read breaker state: %w", err)
+	}
+	var f circuitFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse breaker state %s: %w", path, err)
+	}
+	for id, st := range f.Endpoints {
+		keys := st.Keys
+		st.EndpointID, st.Keys, st.Probe = id, nil, nil
+		if st.Status == StatusHalfOpen {
+			st.Status = StatusOpen // its probe died with the process
+		}
+		b.states[id] = &st
+		for _, ks := range keys {
+			if ks.Status == StatusHalfOpen {
+				ks.Status = StatusOpen
+			}
+			*b.keyState(id, ks.KeyID) = ks
+		}
+	}
+	return nil
+}
+
+// persist writes the state file when persistence is enabled. Called
+// without b.mu held.
+func (b *CircuitBreaker) persist() {
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+	b.mu.RLock()
+	path := b.persistPath
+	if path == "" {
+		b.mu.RUnlock()
+		return
+	}
+	f := circuitFile{Version: 1, Endpoints: make(map[string]CircuitState, len(b.states))}
+	for id, v := range b.states {
+		st := *v
+		st.Keys = b.keyStatesLocked(id)
+		f.Endpoints[id] = st
+	}
+	b.mu.RUnlock()
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
@@ -0,0 +1,235 @@
+package relay
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Adaptive tripping. Besides the consecutive-failure rule, an endpoint's
+// breaker opens when too large a share of its recent requests failed
+// (an endpoint failing every other request never strings three failures
+// together) or, when its policy sets a ceiling, when its recent p95
+// header latency climbs past it. Every reopen after a failed half-open
+// probe doubles the cooldown up to a cap, so an endpoint that stays dead
+// is retried less and less often. Open and close transitions go to the
+// hook set with SetTransitionHook.
+
+const (
+	defaultMaxCooldown = 10 * time.Minute
+	defaultErrorRate   = 0.5
+	defaultErrorWindow = 20
+)
+
+// BreakerPolicy overrides the circuit breaker's tripping rules for one
+// endpoint. Zero fields keep the breaker's defaults.
+type BreakerPolicy struct {
+	// FailureThreshold is the consecutive failures that open the
+	// breaker (default 3).
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// CooldownSec is how long the first opening lasts (default 30);
+	// each consecutive reopen doubles it up to MaxCooldownSec
+	// (default 600).
+	CooldownSec    int `json:"cooldownSec,omitempty"`
+	MaxCooldownSec int `json:"maxCooldownSec,omitempty"`
+	// ErrorRate opens the breaker once this share (0..1) of the last
+	// ErrorWindow requests failed, counted from half a window on.
+	// Defaults 0.5 over 20; a negative rate disables the rule.
+	ErrorRate   float64 `json:"errorRate,omitempty"`
+	ErrorWindow int     `json:"errorWindow,omitempty"`
+	// SlowP95Ms opens the breaker once the p95 of the endpoint's recent
+	// header latencies reaches it. 0 disables the rule.
+	SlowP95Ms int64 `json:"slowP95Ms,omitempty"`
+}
+
+func (p BreakerPolicy) validate() error {
+	switch {
+	case p.FailureThreshold < 0, p.CooldownSec < 0, p.MaxCooldownSec < 0, p.ErrorWindow < 0, p.SlowP95Ms < 0:
+		return fmt.Errorf("breaker policy: negative threshold")
+	case p.ErrorRate > 1:
+		return fmt.Errorf("breaker policy: error rate %v above 1", p.ErrorRate)
+	case p.ErrorWindow == 1:
+		return fmt.Errorf("breaker policy: error window must be at least 2")
+	}
+	return nil
+}
+
+// policy is a BreakerPolicy with the breaker's defaults filled in.
+type policy struct {
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	errorRate   float64 // <= 0 disables
+	window      int
+	slow        time.Duration // 0 disables
+}
+
+// policyLocked resolves endpointID's policy. Callers hold b.mu.
+func (b *CircuitBreaker) policyLocked(endpointID string) policy {
+	p := policy{
+		threshold:   b.failureThreshold,
+		cooldown:    b.cooldown,
+		maxCooldown: max(defaultMaxCooldown, b.cooldown),
+		errorRate:   defaultErrorRate,
+		window:      defaultErrorWindow,
+	}
+	o, ok := b.policies[endpointID]
+	if !ok {
+		return p
+	}
+	if o.FailureThreshold > 0 {
+		p.threshold = o.FailureThreshold
+	}
+	if o.CooldownSec > 0 {
+		p.cooldown = time.Duration(o.CooldownSec) * time.Second
+	}
+	if o.MaxCooldownSec > 0 {
+		p.maxCooldown = time.Duration(o.MaxCooldownSec) * time.Second
+	}
+	p.maxCooldown = max(p.maxCooldown, p.cooldown)
+	if o.ErrorRate != 0 {
+		p.errorRate = o.ErrorRate
+	}
+	if o.ErrorWindow > 0 {
+		p.window = o.ErrorWindow
+	}
+	p.slow = time.Duration(o.SlowP95Ms) * time.Millisecond
+	return p
+}
+
+// SyncPolicies installs the breaker policies of endpoints, dropping
+// those of endpoints no longer listed. The Router calls it on every
+// pick with the endpoints it routes over.
+func (b *CircuitBreaker) SyncPolicies(endpoints []RelayEndpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.policies = make(map[string]BreakerPolicy, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Breaker != nil {
+			b.policies[ep.ID] = *ep.Breaker
+		}
+	}
+}
+
+// outcomeRing is an endpoint's recent request outcomes for the error
+// rate rule.
+type outcomeRing struct {
+	failed []bool
+	next   int
+}
+
+// recordOutcomeLocked adds one outcome and returns the failed share and
+// sample count of the window. Callers hold b.mu.
+func (b *CircuitBreaker) recordOutcomeLocked(endpointID string, failed bool, window int) (rate float64, n int) {
+	if b.outcomes == nil {
+		b.outcomes = map[string]*outcomeRing{}
+	}
+	r := b.outcomes[endpointID]
+	if r == nil {
+		r = &outcomeRing{}
+		b.outcomes[endpointID] = r
+	}
+	if len(r.failed) > window { // the policy's window shrank
+		r.failed, r.next = r.failed[len(r.failed)-window:], 0
+	}
+	if len(r.failed) < window {
+		r.failed = append(r.failed, failed)
+	} else {
+		r.failed[r.next] = failed
+		r.next = (r.next + 1) % window
+	}
+	bad := 0
+	for _, f := range r.failed {
+		if f {
+			bad++
+		}
+	}
+	return float64(bad) / float64(len(r.failed)), len(r.failed)
+}
+
+// slowLocked reports endpointID's recent p95 header latency when it has
+// reached limit. Callers hold b.mu.
+func (b *CircuitBreaker) slowLocked(endpointID string, limit time.Duration) (time.Duration, bool) {
+	r := b.latencies[endpointID]
+	if limit <= 0 || r == nil || len(r.samples) < minLatencySamples {
+		return 0, false
+	}
+	samples := append([]time.Duration(nil), r.samples...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	p95 := percentile(samples, 95)
+	return p95, p95 >= limit
+}
+
+// tripLocked opens st's breaker. Consecutive trips without a success in
+// between double the cooldown, up to the policy's cap. The outcome and
+// latency windows restart so the endpoint is judged on fresh traffic
+// once it half-opens. Callers hold b.mu.
+func (b *CircuitBreaker) tripLocked(st *CircuitState, p policy, reason string) *CircuitTransition {
+	st.Trips++
+	cooldown := p.cooldown
+	for i := 1; i < st.Trips && cooldown < p.maxCooldown; i++ {
+		cooldown *= 2
+	}
+	cooldown = min(cooldown, p.maxCooldown)
+	st.NextProbeMs = b.now().Add(cooldown).UnixMilli()
+	st.TripReason = reason
+	delete(b.outcomes, st.EndpointID)
+	delete(b.latencies, st.EndpointID)
+	return b.setStatusLocked(st, StatusOpen, reason, cooldown)
+}
+
+// CircuitTransition is an endpoint's breaker opening or closing.
+// Half-open moves aren't reported: they're the breaker's own probing.
+type CircuitTransition struct {
+	EndpointID string        `json:"endpointID"`
+	From       CircuitStatus `json:"from"`
+	To         CircuitStatus `json:"to"`
+	Reason     string        `json:"reason"`
+	// Cooldown is how long an opening lasts; Trips counts the openings
+	// since the endpoint last served a request.
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+	Trips    int           `json:"trips,omitempty"`
+	At       time.Time     `json:"at"`
+}
+
+// SetTransitionHook registers fn to hear every open and close
+// transition. fn runs outside the breaker's lock; nil clears it.
+func (b *CircuitBreaker) SetTransitionHook(fn func(CircuitTransition)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onTransition = fn
+}
+
+// setStatusLocked moves st to status, returning the transition to report
+// when it opened or closed. Callers hold b.mu.
+func (b *CircuitBreaker) setStatusLocked(st *CircuitState, status CircuitStatus, reason string, cooldown time.Duration) *CircuitTransition {
+	from := st.Status
+	st.Status = status
+	if from == status && status != StatusOpen || status == StatusHalfOpen {
+		return nil
+	}
+	return &CircuitTransition{
+		EndpointID: st.EndpointID,
+		From:       from,
+		To:         status,
+		Reason:     reason,
+		Cooldown:   cooldown,
+		Trips:      st.Trips,
+		At:         b.now(),
+	}
+}
+
+// reported delivers a transition (if any) to the hook and persists the
+// new state. Called after b.mu is released.
+func (b *CircuitBreaker) reported(t *CircuitTransition) {
+	if t == nil {
+		return
+	}
+	b.mu.RLock()
+	hook := b.onTransition
+	b.mu.RUnlock()
+	b.persist()
+	if hook != nil {
+		hook(*t)
+	}
+}
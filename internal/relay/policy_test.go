@@ -0,0 +1,175 @@
+package relay
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreaker_ErrorRateTrips(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewCircuitBreakerForTest(3, 10*time.Second, func() time.Time { return now })
+	b.SyncPolicies([]RelayEndpoint{{ID: "flaky", Breaker: &BreakerPolicy{ErrorWindow: 10}}})
+
+	// Failing every other request never reaches three in a row, but half
+	// of a half-full window is enough.
+	for i := 0; i < 2; i++ {
+		b.RecordSuccess("flaky")
+		b.RecordFailure("flaky", "502")
+		if !b.Allow("flaky") {
+			t.Fatalf("opened after %d requests, before the window had 5 samples", 2*i+2)
+		}
+	}
+	b.RecordSuccess("flaky")
+	b.RecordFailure("flaky", "502")
+	st := b.Snapshot()["flaky"]
+	if st.Status != StatusOpen || st.TripReason != "50% of the last 6 requests failed" {
+		t.Fatalf("state = %+v, want opened on error rate", st)
+	}
+
+	// A negative rate turns the rule off.
+	b.SyncPolicies([]RelayEndpoint{{ID: "lax", Breaker: &BreakerPolicy{ErrorRate: -1}}})
+	for i := 0; i < 20; i++ {
+		b.RecordSuccess("lax")
+		b.RecordFailure("lax", "502")
+	}
+	if !b.Allow("lax") {
+		t.Error("error rate rule should be disabled")
+	}
+}
+
+func TestBreaker_PerEndpointThresholdAndBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewCircuitBreakerForTest(3, 10*time.Second, func() time.Time { return now })
+	b.SyncPolicies([]RelayEndpoint{{ID: "a", Breaker: &BreakerPolicy{FailureThreshold: 1, CooldownSec: 5, MaxCooldownSec: 15}}})
+
+	b.RecordFailure("a", "boom")
+	if b.Allow("a") {
+		t.Fatal("threshold 1 should open on the first failure")
+	}
+	// Each failed half-open probe doubles the cooldown: 5s, 10s, then
+	// capped at 15s.
+	for _, want := range []time.Duration{10 * time.Second, 15 * time.Second, 15 * time.Second} {
+		st := b.Snapshot()["a"]
+		now = time.UnixMilli(st.NextProbeMs)
+		if !b.Allow("a") {
+			t.Fatal("should half-open after the cooldown")
+		}
+		b.RecordFailure("a", "still down")
+		if got := time.UnixMilli(b.Snapshot()["a"].NextProbeMs).Sub(now); got != want {
+			t.Errorf("cooldown = %v, want %v", got, want)
+		}
+	}
+	now = time.UnixMilli(b.Snapshot()["a"].NextProbeMs)
+	b.Allow("a")
+	b.RecordSuccess("a")
+	if st := b.Snapshot()["a"]; st.Status != StatusClosed || st.Trips != 0 {
+		t.Errorf("after recovery = %+v, want closed with trips reset", st)
+	}
+
+	// Endpoints without a policy keep the breaker's defaults.
+	b.RecordFailure("b", "x")
+	b.RecordFailure("b", "x")
+	if !b.Allow("b") {
+		t.Error("default threshold is 3")
+	}
+}
+
+func TestBreaker_LatencyTrips(t *testing.T) {
+	b := NewCircuitBreaker()
+	b.SyncPolicies([]RelayEndpoint{{ID: "slow", Breaker: &BreakerPolicy{SlowP95Ms: 2000}}})
+	for i := 0; i < minLatencySamples-1; i++ {
+		b.RecordLatency("slow", 3*time.Second)
+	}
+	if !b.Allow("slow") {
+		t.Fatal("tripped before minLatencySamples")
+	}
+	b.RecordLatency("slow", 3*time.Second)
+	if st := b.Snapshot()["slow"]; st.Status != StatusOpen || !strings.HasPrefix(st.TripReason, "p95 latency 3s") {
+		t.Errorf("state = %+v, want opened on latency", st)
+	}
+
+	// Without SlowP95Ms latency never trips.
+	for i := 0; i < 20; i++ {
+		b.RecordLatency("other", time.Minute)
+	}
+	if !b.Allow("other") {
+		t.Error("latency rule should be off by default")
+	}
+	if err := (BreakerPolicy{ErrorRate: 1.5}).validate(); err == nil {
+		t.Error("an error rate above 1 should be rejected")
+	}
+}
+
+func TestBreaker_TransitionHook(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := NewCircuitBreakerForTest(2, 10*time.Second, func() time.Time { return now })
+	var got []CircuitTransition
+	b.SetTransitionHook(func(tr CircuitTransition) {
+		b.Snapshot() // the hook runs outside the lock
+		got = append(got, tr)
+	})
+
+	b.RecordFailure("a", "x")
+	b.RecordFailure("a", "x") // opens
+	b.RecordFailure("a", "x") // already open: no event
+	now = now.Add(11 * time.Second)
+	b.Allow("a") // half-open: no event
+	b.RecordFailure("a", "x")
+	now = now.Add(21 * time.Second)
+	b.Allow("a")
+	b.RecordSuccess("a")
+	b.RecordSuccess("a") // already closed: no event
+
+	if len(got) != 3 {
+		t.Fatalf("transitions = %+v, want open, reopen, close", got)
+	}
+	if got[0].To != StatusOpen || got[0].Trips != 1 || got[0].Cooldown != 10*time.Second {
+		t.Errorf("first = %+v", got[0])
+	}
+	if got[1].From != StatusHalfOpen || got[1].Trips != 2 || got[1].Cooldown != 20*time.Second {
+		t.Errorf("reopen = %+v", got[1])
+	}
+	if got[2].To != StatusClosed || got[2].From != StatusHalfOpen {
+		t.Errorf("close = %+v", got[2])
+	}
+}
+
+func TestBreaker_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), CircuitStateFile)
+	now := time.Unix(1_700_000_000, 0)
+	clock := func() time.Time { return now }
+
+	b := NewCircuitBreakerForTest(1, time.Minute, clock)
+	if err := b.EnablePersistence(path); err != nil {
+		t.Fatal(err)
+	}
+	b.RecordFailure("down", "connection refused")
+	b.RecordKeyResult("pool", KeyID("k1"), 401, "status 401")
+
+	restarted := NewCircuitBreakerForTest(1, time.Minute, clock)
+	if err := restarted.EnablePersistence(path); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Allow("down") {
+		t.Error("an endpoint open before the restart should stay open")
+	}
+	st := restarted.Snapshot()["down"]
+	if st.Trips != 1 || st.LastError != "connection refused" {
+		t.Errorf("restored state = %+v", st)
+	}
+	ep := RelayEndpoint{ID: "pool", APIKey: "k1", APIKeys: []string{"k2"}}
+	if key, _, _ := restarted.PickKey(ep); key != "k2" {
+		t.Errorf("PickKey after restart = %s, want the rejected k1 skipped", key)
+	}
+
+	now = now.Add(2 * time.Minute)
+	restarted.Allow("down")
+	restarted.RecordSuccess("down")
+	again := NewCircuitBreakerForTest(1, time.Minute, clock)
+	again.EnablePersistence(path)
+	if st := again.Snapshot()["down"]; st.Status != StatusClosed {
+		t.Errorf("recovery not persisted: %+v", st)
+	}
+}
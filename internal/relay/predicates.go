@@ -0,0 +1,257 @@
+package relay
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rule predicates beyond model prefix and token count. Every predicate
+// is optional and they AND together; string matchers take shell globs
+// ("ENG-*", "codex") and compare case-insensitively.
+
+// TimeWindow restricts a rule to certain days and hours. From/To are
+// "HH:MM"; a window whose To is not after From wraps past midnight, and
+// its early-morning part belongs to the previous day ("mon-fri
+// 20:00-08:00" matches Saturday 03:00 but not Monday 03:00). Missing
+// From/To mean the whole day. Timezone is an IANA name; empty means the
+// machine's local time.
+type TimeWindow struct {
+	Days     []string `yaml:"days,omitempty" json:"days,omitempty"` // mon..sun, weekdays, weekends
+	From     string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To       string   `yaml:"to,omitempty" json:"to,omitempty"`
+	Timezone string   `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	days     [7]bool // indexed by time.Weekday; all false = every day
+	from, to int     // minutes since midnight
+	loc      *time.Location
+}
+
+var dayNames = map[string][]time.Weekday{
+	"sun": {time.Sunday}, "mon": {time.Monday}, "tue": {time.Tuesday},
+	"wed": {time.Wednesday}, "thu": {time.Thursday}, "fri": {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+func (w *TimeWindow) compile() error {
+	for _, d := range w.Days {
+		days, ok := dayNames[strings.ToLower(strings.TrimSpace(d))]
+		if !ok {
+			return fmt.Errorf("unknown day %q (want mon..sun, weekdays or weekends)", d)
+		}
+		for _, wd := range days {
+			w.days[wd] = true
+		}
+	}
+	var err error
+	if w.from, err = parseClock(w.From, 0); err != nil {
+		return err
+	}
+	if w.to, err = parseClock(w.To, 24*60); err != nil {
+		return err
+	}
+	w.loc = time.Local
+	if w.Timezone != "" {
+		if w.loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("timezone %q: %w", w.Timezone, err)
+		}
+	}
+	return nil
+}
+
+func parseClock(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time %q: want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether now falls inside the window.
+func (w *TimeWindow) contains(now time.Time) bool {
+	now = now.In(w.loc)
+	minute := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+	if w.to <= w.from { // wraps midnight
+		switch {
+		case minute >= w.from:
+		case minute < w.to:
+			day = (day + 6) % 7 // the night started yesterday
+		default:
+			return false
+		}
+	} else if minute < w.from || minute >= w.to {
+		return false
+	}
+	return w.dayAllowed(day)
+}
+
+func (w *TimeWindow) dayAllowed(d time.Weekday) bool {
+	for _, ok := range w.days {
+		if ok {
+			return w.days[d]
+		}
+	}
+	return true
+}
+
+// compile validates every rule and prepares its matchers. Called on
+// load so Pick never sees a half-valid rule.
+func (rs *Rules) compile() error {
+	if err := rs.validate(); err != nil {
+		return err
+	}
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if rule.MatchModelRegex != "" {
+			re, err := regexp.Compile(rule.MatchModelRegex)
+			if err != nil {
+				return fmt.Errorf("rule %q: match_model_regex: %w", rule.Name, err)
+			}
+			rule.modelRe = re
+		}
+		for key, glob := range map[string]string{
+			"match_tool": rule.MatchTool, "match_app_id": rule.MatchAppID, "match_cost_center": rule.MatchCostCenter,
+		} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("rule %q: %s: bad pattern %q", rule.Name, key, glob)
+			}
+		}
+		if rule.TimeWindow != nil {
+			if err := rule.TimeWindow.compile(); err != nil {
+				return fmt.Errorf("rule %q: time_window: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mismatches lists the predicates of rule that the request fails, in
+// YAML order; empty means the rule matches.
+func (rule Rule) mismatches(tool string, hint PickHint, now time.Time) []string {
+	var out []string
+	if rule.MatchModelPrefix != "" && !strings.HasPrefix(hint.Model, rule.MatchModelPrefix) {
+		out = append(out, fmt.Sprintf("model %q lacks prefix %q", hint.Model, rule.MatchModelPrefix))
+	}
+	if rule.modelRe != nil && !rule.modelRe.MatchString(hint.Model) {
+		out = append(out, fmt.Sprintf("model %q does not match /%s/", hint.Model, rule.MatchModelRegex))
+	}
+	if rule.MinTokens > 0 && hint.EstimatedInputTokens < rule.MinTokens {
+		out = append(out, fmt.Sprintf("%d estimated tokens < min_tokens %d", hint.EstimatedInputTokens, rule.MinTokens))
+	}
+	if !globMatch(rule.MatchTool, tool) {
+		out = append(out, fmt.Sprintf("tool %q does not match %q", tool, rule.MatchTool))
+	}
+	if !globMatch(rule.MatchAppID, hint.AppID) {
+		out = append(out, fmt.Sprintf("app %q does not match %q", hint.AppID, rule.MatchAppID))
+	}
+	if !globMatch(rule.MatchCostCenter, hint.CostCenter) {
+		out = append(out, fmt.Sprintf("cost center %q does not match %q", hint.CostCenter, rule.MatchCostCenter))
+	}
+	if rule.HasTools != nil && *rule.HasTools != hint.HasTools {
+		if hint.HasTools {
+			out = append(out, "request has tools")
+		} else {
+			out = append(out, "request has no tools")
+		}
+	}
+	if w := rule.TimeWindow; w != nil && w.loc != nil && !w.contains(now) {
+		out = append(out, fmt.Sprintf("%s is outside the time window", now.In(w.loc).Format("Mon 15:04 MST")))
+	}
+	return out
+}
+
+// globMatch reports whether value matches a case-insensitive shell glob.
+// An empty pattern matches anything.
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return ok
+}
+
+// RuleTrace is one rule's verdict in an Explain run.
+type RuleTrace struct {
+	Name       string   `json:"name"`
+	Matched    bool     `json:"matched"`
+	Fired      bool     `json:"fired"` // the first match — the rule that decides
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// Explanation is the router's reasoning for a hypothetical request.
+type Explanation struct {
+	Rules []RuleTrace `json:"rules"`
+	// Decision summarises how the endpoint was chosen in one line.
+	Decision string      `json:"decision"`
+	Result   *PickResult `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Explain evaluates every rule against a hypothetical request and
+// reports which fired and why the others didn't, plus the pick the
+// router would make. Strategy state is left untouched: a dry run never
+// advances a round-robin rotation.
+func (r *Router) Explain(tool string, hint PickHint) Explanation {
+	var ex Explanation
+	if r == nil || r.store == nil {
+		ex.Error = "router not initialised"
+		return ex
+	}
+	r.mu.RLock()
+	rules := r.rules.Rules
+	r.mu.RUnlock()
+
+	now := hint.now()
+	fired := -1
+	for i, rule := range rules {
+		miss := rule.mismatches(tool, hint, now)
+		t := RuleTrace{Name: rule.Name, Matched: len(miss) == 0, Mismatches: miss}
+		if t.Matched && fired < 0 {
+			t.Fired, fired = true, i
+		}
+		ex.Rules = append(ex.Rules, t)
+	}
+
+	res, err := r.pick(tool, hint, false)
+	if err != nil {
+		ex.Error = err.Error()
+	} else {
+		ex.Result = &res
+	}
+	switch {
+	case err != nil:
+		ex.Decision = "no healthy endpoint"
+	case fired >= 0 && res.Strategy != StrategyPreferred:
+		ex.Decision = fmt.Sprintf("rule %q picked %s by %s", rules[fired].Name, res.Endpoint.ID, res.Strategy)
+	case fired >= 0 && res.Endpoint.ID == rules[fired].PreferEndpointID:
+		ex.Decision = fmt.Sprintf("rule %q prefers %s", rules[fired].Name, res.Endpoint.ID)
+	case fired >= 0:
+		ex.Decision = fmt.Sprintf("rule %q fired but its endpoint is unavailable; lowest latency %s", rules[fired].Name, res.Endpoint.ID)
+	case res.MatchedBy == "" && r.mappedEndpoint(tool) == res.Endpoint.ID:
+		ex.Decision = fmt.Sprintf("no rule fired; tool mapping for %q selects %s", tool, res.Endpoint.ID)
+	default:
+		ex.Decision = fmt.Sprintf("no rule fired; lowest latency %s", res.Endpoint.ID)
+	}
+	return ex
+}
+
+func (r *Router) mappedEndpoint(tool string) string {
+	mapping, _ := r.store.GetToolMapping()
+	return mapping[tool]
+}
+
+// now is the hint's clock: Time when set, else the wall clock.
+func (h PickHint) now() time.Time {
+	if h.Time.IsZero() {
+		return time.Now()
+	}
+	return h.Time
+}
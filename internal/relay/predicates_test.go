@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const predicateRules = `
+rules:
+  - name: codex-tools
+    match_tool: codex
+    has_tools: true
+    prefer_endpoint_id: a
+  - name: eng
+    match_cost_center: ENG-*
+    prefer_endpoint_id: inhouse
+  - name: nights
+    time_window: {days: [weekdays], from: "20:00", to: "08:00", timezone: UTC}
+    prefer_endpoint_id: cheap
+  - name: gpt5
+    match_model_regex: '^gpt-5(\.\d+)?(-mini)?$'
+    match_app_id: ci-*
+    prefer_endpoint_id: a
+`
+
+func predicateRouter(t *testing.T) *Router {
+	return newStrategyRouter(t, predicateRules,
+		RelayEndpoint{ID: "a", URL: "https://a.test", LatencyMs: 50},
+		RelayEndpoint{ID: "inhouse", URL: "https://in.test", LatencyMs: 60},
+		RelayEndpoint{ID: "cheap", URL: "https://cheap.test", LatencyMs: 70},
+	)
+}
+
+func TestRouter_Predicates(t *testing.T) {
+	router := predicateRouter(t)
+	const none = "lurus-api"                                 // no rule fired: the unprobed builtin is lowest latency
+	monNoon := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)  // Monday
+	monNight := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC) // Monday
+	satEarly := time.Date(2026, 3, 7, 3, 0, 0, 0, time.UTC)  // Saturday, Friday night
+	monEarly := time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)  // Monday, Sunday night
+	cases := []struct {
+		name string
+		tool string
+		hint PickHint
+		want string
+	}{
+		{"codex with tools", "Codex", PickHint{HasTools: true, Time: monNoon}, "a"},
+		{"codex without tools", "codex", PickHint{Time: monNoon}, none},
+		{"cost center glob", "claude", PickHint{CostCenter: "eng-platform", Time: monNoon}, "inhouse"},
+		{"weekday night", "claude", PickHint{Time: monNight}, "cheap"},
+		{"friday night spills into saturday", "claude", PickHint{Time: satEarly}, "cheap"},
+		{"sunday night is not a weekday night", "claude", PickHint{Time: monEarly}, none},
+		{"regex and app", "claude", PickHint{Model: "gpt-5.1-mini", AppID: "ci-nightly", Time: monNoon}, "a"},
+		{"regex miss", "claude", PickHint{Model: "gpt-5-turbo", AppID: "ci-nightly", Time: monNoon}, none},
+	}
+	for _, tc := range cases {
+		res, err := router.Pick(tc.tool, tc.hint)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if res.Endpoint.ID != tc.want {
+			t.Errorf("%s: picked %s (by %q), want %s", tc.name, res.Endpoint.ID, res.MatchedBy, tc.want)
+		}
+	}
+}
+
+func TestRouter_ExplainReportsEveryRule(t *testing.T) {
+	router := predicateRouter(t)
+	ex := router.Explain("claude", PickHint{
+		CostCenter: "ENG-9",
+		Time:       time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC),
+	})
+	if ex.Error != "" || ex.Result == nil || ex.Result.Endpoint.ID != "inhouse" {
+		t.Fatalf("explanation = %+v", ex)
+	}
+	if len(ex.Rules) != 4 {
+		t.Fatalf("traces = %d, want 4", len(ex.Rules))
+	}
+	codex, eng, nights := ex.Rules[0], ex.Rules[1], ex.Rules[2]
+	if codex.Matched || len(codex.Mismatches) != 2 || !strings.Contains(codex.Mismatches[0], `tool "claude"`) {
+		t.Errorf("codex-tools trace = %+v", codex)
+	}
+	if !eng.Fired || !eng.Matched {
+		t.Errorf("eng trace = %+v", eng)
+	}
+	// A later match is reported but doesn't fire.
+	if !nights.Matched || nights.Fired {
+		t.Errorf("nights trace = %+v", nights)
+	}
+	if !strings.Contains(ex.Decision, `rule "eng" prefers inhouse`) {
+		t.Errorf("Decision = %q", ex.Decision)
+	}
+}
+
+func TestRouter_ExplainDoesNotAdvanceRotation(t *testing.T) {
+	router := newStrategyRouter(t, `
+rules:
+  - name: rr
+    strategy: weighted_round_robin
+    weights: {a: 1, b: 1}
+`,
+		RelayEndpoint{ID: "a", URL: "https://a.test", LatencyMs: 10},
+		RelayEndpoint{ID: "b", URL: "https://b.test", LatencyMs: 20},
+	)
+	for i := 0; i < 3; i++ {
+		if ex := router.Explain("codex", PickHint{}); ex.Result.Endpoint.ID != "a" {
+			t.Fatalf("dry run %d picked %s", i, ex.Result.Endpoint.ID)
+		}
+	}
+	first, _ := router.Pick("codex", PickHint{})
+	second, _ := router.Pick("codex", PickHint{})
+	if first.Endpoint.ID != "a" || second.Endpoint.ID != "b" {
+		t.Errorf("rotation = %s, %s; want a, b", first.Endpoint.ID, second.Endpoint.ID)
+	}
+}
+
+func TestRouter_RejectsBadPredicates(t *testing.T) {
+	router := newStrategyRouter(t, "rules: []\n")
+	for _, bad := range []string{
+		"rules:\n  - name: re\n    match_model_regex: 'gpt-('\n",
+		"rules:\n  - name: glob\n    match_app_id: '[ci'\n",
+		"rules:\n  - name: day\n    time_window: {days: [funday]}\n",
+		"rules:\n  - name: clock\n    time_window: {from: '25:00'}\n",
+		"rules:\n  - name: tz\n    time_window: {timezone: Mars/Olympus}\n",
+	} {
+		if err := router.LoadRulesYAML(bad); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
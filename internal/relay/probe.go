@@ -0,0 +1,271 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"lurus-switch/internal/modelcatalog"
+)
+
+// Active health probes. The reachability probe (CheckHealth) only shows
+// that the host answers: a relay whose key was revoked, whose balance ran
+// out or which silently serves another model still passes it. The models
+// and completion probes authenticate with the endpoint's key and check
+// that its ProbeModel is really served, classifying the outcome with
+// modelcatalog's authenticity verdicts. Each result lands in a rolling
+// per-endpoint window on the circuit breaker — latency percentiles and
+// error rate — which the Router ranks and filters endpoints by.
+
+// ProbeMode selects what a health probe verifies.
+type ProbeMode string
+
+const (
+	// ProbeReachability GETs the base URL; anything below 500 passes.
+	ProbeReachability ProbeMode = "reachability"
+	// ProbeModels calls GET /v1/models with the endpoint's key and, when
+	// ProbeModel is set, requires it to be listed. Free on every relay
+	// we know of.
+	ProbeModels ProbeMode = "models"
+	// ProbeCompletion sends a 1-token chat completion for ProbeModel and
+	// compares the model the response reports. Costs a request per
+	// probe; falls back to ProbeModels for endpoints without a
+	// ProbeModel or that only speak the Anthropic protocol.
+	ProbeCompletion ProbeMode = "completion"
+)
+
+const (
+	// ProbeTimeout bounds a CheckHealthMode sweep; a completion probe
+	// alone may use modelcatalog's 10s budget.
+	ProbeTimeout = 15 * time.Second
+
+	// probeWindow is how many probe results each endpoint keeps.
+	probeWindow = 20
+	// minProbeSamples is the history needed before the probe error rate
+	// or latency percentiles steer routing.
+	minProbeSamples = 4
+	// probeErrorRateLimit takes an endpoint out of rotation once this
+	// share of its recent probes failed.
+	probeErrorRateLimit = 0.5
+)
+
+// ParseProbeMode validates a mode name; "" is ProbeReachability.
+func ParseProbeMode(s string) (ProbeMode, error) {
+	switch m := ProbeMode(s); m {
+	case "":
+		return ProbeReachability, nil
+	case ProbeReachability, ProbeModels, ProbeCompletion:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown probe mode %q (want reachability, models or completion)", s)
+}
+
+// ProbeResult is one probe's outcome.
+type ProbeResult struct {
+	Mode       ProbeMode                `json:"mode"` // the mode that ran, after any fallback
+	Model      string                   `json:"model,omitempty"`
+	Verdict    modelcatalog.AuthVerdict `json:"verdict"`
+	Healthy    bool                     `json:"healthy"`
+	LatencyMs  int64                    `json:"latencyMs"`
+	StatusCode int                      `json:"statusCode,omitempty"` // HTTP status; 0 when no response arrived
+	Note       string                   `json:"note,omitempty"`
+	TestedAt   time.Time                `json:"testedAt"`
+}
+
+// Probe checks one endpoint in mode.
+func Probe(ctx context.Context, ep RelayEndpoint, mode ProbeMode) ProbeResult {
+	if mode == ProbeCompletion && (ep.ProbeModel == "" || ep.Protocol == ProtocolAnthropic) {
+		mode = ProbeModels
+	}
+	pe := modelcatalog.ProviderEndpoint{ID: ep.ID, Name: ep.Name, BaseURL: ep.URL, APIKey: firstUsableKey(ep)}
+	res := ProbeResult{Mode: mode, TestedAt: time.Now()}
+	if mode != ProbeReachability && KeysLocked(ep) {
+		// Never authenticate with an unresolved vault reference.
+		res.Verdict, res.Note = modelcatalog.VerdictAuth, "API key is in the locked secrets vault"
+		return res
+	}
+
+	switch mode {
+	case ProbeCompletion:
+		r := modelcatalog.ProbeAuthenticity(ctx, pe, []string{ep.ProbeModel})[0]
+		res.Model, res.Verdict, res.LatencyMs, res.Note = ep.ProbeModel, r.Verdict, r.LatencyMs, r.Note
+		res.StatusCode = r.HTTPStatus
+		if r.Verdict == modelcatalog.VerdictMismatch {
+			res.Note = fmt.Sprintf("asked for %s, served %s", ep.ProbeModel, r.ReportedModel)
+		}
+	case ProbeModels:
+		r := modelcatalog.ProbeEndpoint(ctx, pe, healthTimeout)
+		res.Model, res.LatencyMs, res.Note = ep.ProbeModel, r.LatencyMs, r.Error
+		res.StatusCode = r.HTTPStatus
+		switch r.Status {
+		case modelcatalog.StatusOK:
+			switch {
+			case ep.ProbeModel == "":
+				res.Verdict = modelcatalog.VerdictInconclusive // key works; no model to check
+			case modelcatalog.ModelListed(r.Models, ep.ProbeModel):
+				res.Verdict = modelcatalog.VerdictMatch
+			default:
+				res.Verdict = modelcatalog.VerdictMismatch
+				res.Note = ep.ProbeModel + " is not in the endpoint's model list"
+			}
+		case modelcatalog.StatusAuth:
+			res.Verdict = modelcatalog.VerdictAuth
+		case modelcatalog.StatusUnreachable:
+			res.Verdict = modelcatalog.VerdictUnreachable
+		case modelcatalog.StatusTimeout:
+			res.Verdict = modelcatalog.VerdictTimeout
+		default:
+			res.Verdict = modelcatalog.VerdictError
+		}
+	default:
+		res.Mode = ProbeReachability
+		var ok bool
+		res.LatencyMs, res.StatusCode, ok = ping(ctx, ep.URL)
+		switch {
+		case ok:
+			res.Verdict = modelcatalog.VerdictInconclusive // up; nothing verified
+		case res.LatencyMs < 0:
+			res.Verdict = modelcatalog.VerdictUnreachable
+		default:
+			res.Verdict = modelcatalog.VerdictError
+		}
+	}
+	res.Healthy = res.Verdict == modelcatalog.VerdictMatch || res.Verdict == modelcatalog.VerdictInconclusive
+	return res
+}
+
+// CheckHealthMode probes all endpoints concurrently in mode and returns
+// updated copies with LatencyMs, Healthy, LastChecked and Probe
+// populated. A non-nil breaker records every result into the rolling
+// stats the Router routes by.
+func CheckHealthMode(ctx context.Context, endpoints []RelayEndpoint, mode ProbeMode, breaker *CircuitBreaker) []RelayEndpoint {
+	results := make([]RelayEndpoint, len(endpoints))
+	copy(results, endpoints)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(ep *RelayEndpoint) {
+			defer wg.Done()
+			res := Probe(ctx, *ep, mode)
+			ep.Probe = &res
+			ep.LatencyMs, ep.Healthy = res.LatencyMs, res.Healthy
+			ep.LastChecked = res.TestedAt.Format(time.RFC3339)
+			if breaker != nil {
+				breaker.RecordProbe(ep.ID, res)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// ProbeStats summarizes an endpoint's recent probes.
+type ProbeStats struct {
+	Samples     int                      `json:"samples"`
+	ErrorRate   float64                  `json:"errorRate"`       // failed share, 0..1
+	P50Ms       int64                    `json:"p50Ms,omitempty"` // over passing probes
+	P95Ms       int64                    `json:"p95Ms,omitempty"`
+	LastVerdict modelcatalog.AuthVerdict `json:"lastVerdict"`
+	LastProbeMs int64                    `json:"lastProbeMs"` // unix-millis
+}
+
+type probeSample struct {
+	latency time.Duration
+	ok      bool
+}
+
+// probeRing is an endpoint's recent probe results.
+type probeRing struct {
+	samples []probeSample
+	next    int
+	last    ProbeResult
+}
+
+// RecordProbe adds a probe result to endpointID's history.
+func (b *CircuitBreaker) RecordProbe(endpointID string, res ProbeResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probes == nil {
+		b.probes = map[string]*probeRing{}
+	}
+	r := b.probes[endpointID]
+	if r == nil {
+		r = &probeRing{}
+		b.probes[endpointID] = r
+	}
+	s := probeSample{latency: time.Duration(res.LatencyMs) * time.Millisecond, ok: res.Healthy}
+	if len(r.samples) < probeWindow {
+		r.samples = append(r.samples, s)
+	} else {
+		r.samples[r.next] = s
+		r.next = (r.next + 1) % probeWindow
+	}
+	r.last = res
+	b.state(endpointID) // list it in Snapshot
+}
+
+// ProbeStats returns endpointID's probe summary; ok is false before its
+// first probe.
+func (b *CircuitBreaker) ProbeStats(endpointID string) (ProbeStats, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.probeStatsLocked(endpointID)
+}
+
+func (b *CircuitBreaker) probeStatsLocked(endpointID string) (ProbeStats, bool) {
+	r := b.probes[endpointID]
+	if r == nil || len(r.samples) == 0 {
+		return ProbeStats{}, false
+	}
+	st := ProbeStats{
+		Samples:     len(r.samples),
+		LastVerdict: r.last.Verdict,
+		LastProbeMs: r.last.TestedAt.UnixMilli(),
+	}
+	var ok []time.Duration
+	failed := 0
+	for _, s := range r.samples {
+		if s.ok {
+			ok = append(ok, s.latency)
+		} else {
+			failed++
+		}
+	}
+	st.ErrorRate = float64(failed) / float64(len(r.samples))
+	if len(ok) > 0 {
+		sort.Slice(ok, func(i, j int) bool { return ok[i] < ok[j] })
+		st.P50Ms = percentile(ok, 50).Milliseconds()
+		st.P95Ms = percentile(ok, 95).Milliseconds()
+	}
+	return st, true
+}
+
+// percentile picks the nearest-rank p-th percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// probeUsable reports whether endpointID's probes leave it routable. The
+// last probe finding its key rejected or its model substituted rules it
+// out at once — retrying fixes neither — as does a mostly-failing window.
+func (st ProbeStats) probeUsable() bool {
+	switch st.LastVerdict {
+	case modelcatalog.VerdictAuth, modelcatalog.VerdictMismatch:
+		return false
+	}
+	return st.Samples < minProbeSamples || st.ErrorRate < probeErrorRateLimit
+}
+
+// rankLatency is the latency the Router orders ep by: the probe median
+// once there is enough probe history, else the last LatencyMs sample.
+func (st ProbeStats) rankLatency(ep RelayEndpoint) int64 {
+	if st.Samples >= minProbeSamples && st.P50Ms > 0 {
+		return st.P50Ms
+	}
+	return ep.LatencyMs
+}
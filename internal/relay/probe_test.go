@@ -0,0 +1,182 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lurus-switch/internal/modelcatalog"
+)
+
+// fakeRelay accepts key "good", lists claude-sonnet-4-6 and answers
+// completions as served.
+func fakeRelay(t *testing.T, served string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusNotFound) // what most relays answer the base URL with
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer good" {
+			http.Error(w, `{"error":"invalid key"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"claude-sonnet-4-6"}]}`))
+		case "/v1/chat/completions":
+			json.NewEncoder(w).Encode(map[string]any{"model": served, "choices": []any{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbe_ModelsMode(t *testing.T) {
+	srv := fakeRelay(t, "claude-sonnet-4-6")
+	ctx := context.Background()
+
+	cases := []struct {
+		name, key, model string
+		want             modelcatalog.AuthVerdict
+		healthy          bool
+	}{
+		{"listed", "good", "claude-sonnet-4-6", modelcatalog.VerdictMatch, true},
+		{"no probe model", "good", "", modelcatalog.VerdictInconclusive, true},
+		{"not listed", "good", "gpt-5", modelcatalog.VerdictMismatch, false},
+		{"revoked key", "revoked", "claude-sonnet-4-6", modelcatalog.VerdictAuth, false},
+	}
+	for _, tc := range cases {
+		ep := RelayEndpoint{ID: "r", URL: srv.URL, APIKey: tc.key, ProbeModel: tc.model}
+		res := Probe(ctx, ep, ProbeModels)
+		if res.Verdict != tc.want || res.Healthy != tc.healthy {
+			t.Errorf("%s: verdict %s healthy %v, want %s %v (%s)", tc.name, res.Verdict, res.Healthy, tc.want, tc.healthy, res.Note)
+		}
+	}
+
+	// The reachability probe can't tell a revoked key apart.
+	ep := RelayEndpoint{ID: "r", URL: srv.URL, APIKey: "revoked"}
+	if res := Probe(ctx, ep, ProbeReachability); !res.Healthy {
+		t.Errorf("reachability probe = %+v, want healthy", res)
+	}
+}
+
+func TestProbe_CompletionMode(t *testing.T) {
+	ctx := context.Background()
+
+	honest := fakeRelay(t, "claude-sonnet-4-6-20250929")
+	ep := RelayEndpoint{ID: "h", URL: honest.URL, APIKey: "good", ProbeModel: "claude-sonnet-4-6"}
+	if res := Probe(ctx, ep, ProbeCompletion); res.Verdict != modelcatalog.VerdictMatch || res.Mode != ProbeCompletion {
+		t.Errorf("honest relay = %+v", res)
+	}
+
+	swapped := fakeRelay(t, "glm-4.6")
+	ep = RelayEndpoint{ID: "s", URL: swapped.URL, APIKey: "good", ProbeModel: "claude-sonnet-4-6"}
+	res := Probe(ctx, ep, ProbeCompletion)
+	if res.Verdict != modelcatalog.VerdictMismatch || res.Healthy {
+		t.Errorf("substituting relay = %+v, want unhealthy mismatch", res)
+	}
+
+	// No probe model: a completion would have nothing to compare, so
+	// the models probe runs instead.
+	ep.ProbeModel = ""
+	if res := Probe(ctx, ep, ProbeCompletion); res.Mode != ProbeModels || !res.Healthy {
+		t.Errorf("completion without probe model = %+v, want a healthy models probe", res)
+	}
+}
+
+func TestCheckHealthMode_RecordsProbeStats(t *testing.T) {
+	srv := fakeRelay(t, "claude-sonnet-4-6")
+	b := NewCircuitBreaker()
+	eps := []RelayEndpoint{
+		{ID: "ok", URL: srv.URL, APIKey: "good", ProbeModel: "claude-sonnet-4-6"},
+		{ID: "revoked", URL: srv.URL, APIKey: "old"},
+	}
+	for i := 0; i < 3; i++ {
+		got := CheckHealthMode(context.Background(), eps, ProbeModels, b)
+		if !got[0].Healthy || got[1].Healthy || got[1].Probe == nil || got[1].Probe.Verdict != modelcatalog.VerdictAuth {
+			t.Fatalf("results = %+v / %+v", got[0], got[1])
+		}
+	}
+	snap := b.Snapshot()
+	if p := snap["ok"].Probe; p == nil || p.Samples != 3 || p.ErrorRate != 0 {
+		t.Errorf("ok stats = %+v", p)
+	}
+	if p := snap["revoked"].Probe; p == nil || p.ErrorRate != 1 || p.LastVerdict != modelcatalog.VerdictAuth {
+		t.Errorf("revoked stats = %+v", p)
+	}
+	if snap["revoked"].Status != StatusClosed {
+		t.Errorf("probes should not trip the breaker, got %s", snap["revoked"].Status)
+	}
+}
+
+func TestRouter_RoutesByProbeStats(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// By their last single sample "a" looks fastest.
+	store.SaveEndpoint(RelayEndpoint{ID: "a", URL: "https://a.test", Healthy: true, LatencyMs: 10})
+	store.SaveEndpoint(RelayEndpoint{ID: "b", URL: "https://b.test", Healthy: true, LatencyMs: 50})
+	b := NewCircuitBreaker()
+	router, err := NewRouter(dir, store, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := func(id string, ms int64, v modelcatalog.AuthVerdict) {
+		b.RecordProbe(id, ProbeResult{Verdict: v, Healthy: v == modelcatalog.VerdictMatch, LatencyMs: ms, TestedAt: time.Now()})
+	}
+	order := func() []string {
+		t.Helper()
+		res, err := router.Pick("claude", PickHint{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, ep := range res.Healthy {
+			if ep.ID == "a" || ep.ID == "b" {
+				ids = append(ids, ep.ID)
+			}
+		}
+		return ids
+	}
+
+	// Probe history says otherwise: "b" has the lower median.
+	for _, ms := range []int64{300, 320, 280, 310} {
+		probe("a", ms, modelcatalog.VerdictMatch)
+	}
+	for _, ms := range []int64{40, 45, 900, 42} {
+		probe("b", ms, modelcatalog.VerdictMatch)
+	}
+	if got := order(); len(got) != 2 || got[0] != "b" {
+		t.Errorf("order = %v, want b first by probe p50", got)
+	}
+	if ps, _ := b.ProbeStats("b"); ps.P50Ms != 42 || ps.P95Ms != 900 {
+		t.Errorf("b percentiles = %+v", ps)
+	}
+
+	// A rejected key takes "b" out at once.
+	probe("b", 30, modelcatalog.VerdictAuth)
+	if got := order(); len(got) != 1 || got[0] != "a" {
+		t.Errorf("order after auth failure = %v, want only a", got)
+	}
+
+	// "a" failing most probes goes too, leaving the unprobed builtin.
+	for i := 0; i < 5; i++ {
+		probe("a", 0, modelcatalog.VerdictUnreachable)
+	}
+	if got := order(); len(got) != 0 {
+		t.Errorf("order with a mostly failing = %v, want neither", got)
+	}
+	// When probes fail everywhere the breaker-allowed set stands rather
+	// than routing nowhere.
+	if got := router.healthyEndpoints([]RelayEndpoint{{ID: "a"}, {ID: "b"}}); len(got) != 2 {
+		t.Errorf("healthyEndpoints with every probe failing = %v, want both kept", got)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -20,15 +22,49 @@ type PickHint struct {
 	Model              string `json:"model,omitempty"`
 	EstimatedInputTokens int64 `json:"estimatedInputTokens,omitempty"`
 	HasTools           bool   `json:"hasTools,omitempty"`
+
+	// AppID and CostCenter identify the calling app (see gateway
+	// RequestMeta); Time is the clock time-window rules check, zero
+	// meaning now — set it to dry-run "weekday nights" from the UI.
+	AppID      string    `json:"appId,omitempty"`
+	CostCenter string    `json:"costCenter,omitempty"`
+	Time       time.Time `json:"time,omitempty"`
 }
 
 // Rule represents one entry in relay-rules.yaml. Predicates AND together;
-// the first rule whose predicates all match decides the EndpointID.
+// the first rule whose predicates all match decides the EndpointID —
+// prefer_endpoint_id by default, or the pick of its strategy (see
+// strategy.go).
 type Rule struct {
 	Name              string `yaml:"name" json:"name"`
 	MatchModelPrefix  string `yaml:"match_model_prefix,omitempty" json:"matchModelPrefix,omitempty"`
 	MinTokens         int64  `yaml:"min_tokens,omitempty" json:"minTokens,omitempty"`
-	PreferEndpointID  string `yaml:"prefer_endpoint_id" json:"preferEndpointID"`
+	PreferEndpointID  string `yaml:"prefer_endpoint_id,omitempty" json:"preferEndpointID"`
+
+	// Further predicates, see predicates.go. The match_* globs compare
+	// case-insensitively; has_tools matches only when set.
+	MatchModelRegex string      `yaml:"match_model_regex,omitempty" json:"matchModelRegex,omitempty"`
+	MatchTool       string      `yaml:"match_tool,omitempty" json:"matchTool,omitempty"`
+	MatchAppID      string      `yaml:"match_app_id,omitempty" json:"matchAppId,omitempty"`
+	MatchCostCenter string      `yaml:"match_cost_center,omitempty" json:"matchCostCenter,omitempty"`
+	HasTools        *bool       `yaml:"has_tools,omitempty" json:"hasTools,omitempty"`
+	TimeWindow      *TimeWindow `yaml:"time_window,omitempty" json:"timeWindow,omitempty"`
+
+	// Strategy spreads matching traffic over a pool of endpoints instead
+	// of pinning it to PreferEndpointID. Endpoints restricts the pool
+	// (default: every healthy endpoint); Weights sets per-endpoint
+	// weights for weighted_round_robin (default 1 each).
+	Strategy  Strategy       `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Endpoints []string       `yaml:"endpoints,omitempty" json:"endpoints,omitempty"`
+	Weights   map[string]int `yaml:"weights,omitempty" json:"weights,omitempty"`
+
+	// HedgePercentile opts the rule's traffic into hedged requests: when
+	// the picked endpoint hasn't returned headers within this percentile
+	// (e.g. 90) of its observed latency, the same request also goes to
+	// the next healthy endpoint and the first answer wins. 0 = off.
+	HedgePercentile float64 `yaml:"hedge_percentile,omitempty" json:"hedgePercentile,omitempty"`
+
+	modelRe *regexp.Regexp
 }
 
 // Rules is the deserialised on-disk YAML. Wrapped so we can carry extra
@@ -45,6 +81,7 @@ type Router struct {
 	rulesPath string
 	breaker   *CircuitBreaker
 	store     *Store
+	balancer  *balancer
 }
 
 // NewRouter loads (or creates) the rules YAML beside the rest of
@@ -56,6 +93,7 @@ func NewRouter(appDataDir string, store *Store, breaker *CircuitBreaker) (*Route
 		rulesPath: filepath.Join(appDataDir, routerRulesFile),
 		breaker:   breaker,
 		store:     store,
+		balancer:  newBalancer(),
 	}
 	if err := r.loadRules(); err != nil {
 		// Missing file is fine — empty rule set means "always pick the
@@ -76,6 +114,9 @@ func (r *Router) LoadRulesYAML(s string) error {
 	if err := dec.Decode(&parsed); err != nil {
 		return fmt.Errorf("relay router: parse rules: %w", err)
 	}
+	if err := parsed.compile(); err != nil {
+		return fmt.Errorf("relay router: %w", err)
+	}
 	r.mu.Lock()
 	r.rules = parsed
 	r.mu.Unlock()
@@ -96,23 +137,54 @@ func (r *Router) RulesYAML() string {
 }
 
 func (r *Router) loadRules() error {
-	data, err := os.ReadFile(r.rulesPath)
+	parsed, err := readRules(r.rulesPath)
 	if err != nil {
 		return err
 	}
-	var parsed Rules
-	if err := yaml.Unmarshal(data, &parsed); err != nil {
-		return err
+	r.rules = parsed
+	return nil
+}
+
+// ReloadRules re-reads the rules file, e.g. after it was edited outside
+// the app. A missing file clears the rules; one that fails to parse
+// leaves the current rules in place.
+func (r *Router) ReloadRules() error {
+	parsed, err := readRules(r.rulesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("relay router: reload rules: %w", err)
 	}
+	r.mu.Lock()
 	r.rules = parsed
+	r.mu.Unlock()
 	return nil
 }
 
+func readRules(path string) (Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	var parsed Rules
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return Rules{}, err
+	}
+	if err := parsed.compile(); err != nil {
+		return Rules{}, err
+	}
+	return parsed, nil
+}
+
 // PickResult is the router's verdict for one request.
 type PickResult struct {
-	Endpoint  RelayEndpoint
-	MatchedBy string // rule name; "" when only the tool→mapping default applied
-	Healthy   []RelayEndpoint
+	Endpoint RelayEndpoint
+	// MatchedBy is the rule name, suffixed with the strategy that picked
+	// the endpoint when the rule has one ("relays (cheapest)"); "" when
+	// only the tool→mapping default applied.
+	MatchedBy string
+	Strategy  Strategy
+	// HedgePercentile is the matched rule's (see Rule.HedgePercentile).
+	HedgePercentile float64
+	Healthy         []RelayEndpoint
 	// Ordered is the healthy set rearranged so the picked Endpoint is at
 	// index 0 and the remaining peers follow in ascending-latency order.
 	// Gateway uses this as a deterministic fallback chain when the
@@ -121,13 +193,24 @@ type PickResult struct {
 }
 
 // Pick decides which RelayEndpoint to route a request to. Strategy:
-//   1. Apply user rules in order; the first match yields preferEndpointID.
+//   1. Apply user rules in order; the first match yields preferEndpointID,
+//      or defers to the rule's strategy.
 //   2. Fall back to the tool→endpoint mapping the user set in Settings.
-//   3. Filter out endpoints whose circuit is open.
-//   4. Sort the remaining healthy peers by ascending latency and pick first.
+//   3. Filter out endpoints whose circuit (or every pooled key's) is open,
+//      or whose health probes found the key rejected, the model
+//      substituted, or mostly failed.
+//   4. A matched rule with a strategy picks from its healthy pool.
+//   5. Otherwise sort the healthy peers by ascending latency (probe p50
+//      when there is enough history) and pick first.
 //
 // Returns an error only when there's literally no healthy endpoint.
 func (r *Router) Pick(tool string, hint PickHint) (PickResult, error) {
+	return r.pick(tool, hint, true)
+}
+
+// pick is Pick; commit=false leaves the strategy state untouched so a dry
+// run doesn't shift the next real request's endpoint.
+func (r *Router) pick(tool string, hint PickHint, commit bool) (PickResult, error) {
 	if r == nil || r.store == nil {
 		return PickResult{}, fmt.Errorf("router not initialised")
 	}
@@ -135,6 +218,9 @@ func (r *Router) Pick(tool string, hint PickHint) (PickResult, error) {
 	if err != nil {
 		return PickResult{}, err
 	}
+	if r.breaker != nil {
+		r.breaker.SyncPolicies(endpoints)
+	}
 
 	r.mu.RLock()
 	rules := r.rules.Rules
@@ -142,15 +228,17 @@ func (r *Router) Pick(tool string, hint PickHint) (PickResult, error) {
 
 	preferred := ""
 	matchedBy := ""
-	for _, rule := range rules {
-		if rule.MatchModelPrefix != "" && !strings.HasPrefix(hint.Model, rule.MatchModelPrefix) {
-			continue
-		}
-		if rule.MinTokens > 0 && hint.EstimatedInputTokens < rule.MinTokens {
+	hedge := 0.0
+	var matched *Rule
+	now := hint.now()
+	for i, rule := range rules {
+		if len(rule.mismatches(tool, hint, now)) > 0 {
 			continue
 		}
 		preferred = rule.PreferEndpointID
 		matchedBy = rule.Name
+		hedge = rule.HedgePercentile
+		matched = &rules[i]
 		break
 	}
 
@@ -168,24 +256,46 @@ func (r *Router) Pick(tool string, hint PickHint) (PickResult, error) {
 	}
 
 	// Sort healthy peers by ascending latency once — used both for
-	// "lowest-latency wins" and as the ordered fallback tail.
+	// "lowest-latency wins" and as the ordered fallback tail. Probe
+	// history, when there is enough, stands in for the single sample.
+	rank := r.rankLatencies(healthy)
 	sort.SliceStable(healthy, func(i, j int) bool {
-		return healthy[i].LatencyMs < healthy[j].LatencyMs
+		return rank[healthy[i].ID] < rank[healthy[j].ID]
 	})
 
+	// A strategy rule picks from its pool; the rest of the healthy set
+	// stays behind it as the fallback tail.
+	if matched != nil && matched.Strategy != StrategyPreferred {
+		if pool := matched.pool(healthy); len(pool) > 0 {
+			ep := r.balancer.pick(*matched, pool, hint, commit)
+			for i := range healthy {
+				if healthy[i].ID == ep.ID {
+					return PickResult{
+						Endpoint:        ep,
+						MatchedBy:       fmt.Sprintf("%s (%s)", matchedBy, matched.Strategy),
+						Strategy:        matched.Strategy,
+						HedgePercentile: matched.HedgePercentile,
+						Healthy:         healthy,
+						Ordered:         orderedFromPreferred(healthy, i),
+					}, nil
+				}
+			}
+		}
+	}
+
 	// Prefer the explicitly-chosen endpoint when it's healthy.
 	if preferred != "" {
 		for i, ep := range healthy {
 			if ep.ID == preferred {
 				ordered := orderedFromPreferred(healthy, i)
-				return PickResult{Endpoint: ep, MatchedBy: matchedBy, Healthy: healthy, Ordered: ordered}, nil
+				return PickResult{Endpoint: ep, MatchedBy: matchedBy, HedgePercentile: hedge, Healthy: healthy, Ordered: ordered}, nil
 			}
 		}
 	}
 	// No preferred rule (or it isn't healthy) → take the lowest-latency.
 	ordered := make([]RelayEndpoint, len(healthy))
 	copy(ordered, healthy)
-	return PickResult{Endpoint: healthy[0], Healthy: healthy, Ordered: ordered}, nil
+	return PickResult{Endpoint: healthy[0], HedgePercentile: hedge, Healthy: healthy, Ordered: ordered}, nil
 }
 
 // orderedFromPreferred returns a slice with the preferred index first,
@@ -207,21 +317,67 @@ func (r *Router) healthyEndpoints(endpoints []RelayEndpoint) []RelayEndpoint {
 		return endpoints
 	}
 	out := make([]RelayEndpoint, 0, len(endpoints))
+	usable := make([]RelayEndpoint, 0, len(endpoints))
 	for _, ep := range endpoints {
-		if !r.breaker.Allow(ep.ID) {
+		if !r.breaker.Allow(ep.ID) || !r.breaker.AnyKeyAllowed(ep) {
 			continue
 		}
 		out = append(out, ep)
+		if st, ok := r.breaker.ProbeStats(ep.ID); !ok || st.probeUsable() {
+			usable = append(usable, ep)
+		}
+	}
+	// Probes failing everywhere more likely means our side is offline
+	// than every relay at once; don't let them black out routing.
+	if len(usable) > 0 {
+		return usable
 	}
 	return out
 }
 
+// rankLatencies maps each endpoint ID to the latency pick orders by.
+func (r *Router) rankLatencies(endpoints []RelayEndpoint) map[string]int64 {
+	rank := make(map[string]int64, len(endpoints))
+	for _, ep := range endpoints {
+		var st ProbeStats
+		if r.breaker != nil {
+			st, _ = r.breaker.ProbeStats(ep.ID)
+		}
+		rank[ep.ID] = st.rankLatency(ep)
+	}
+	return rank
+}
+
+// Acquire counts one in-flight request against an endpoint for the
+// least_in_flight strategy. Call release once the response is done;
+// extra calls are no-ops.
+func (r *Router) Acquire(endpointID string) (release func()) {
+	if r == nil || r.balancer == nil {
+		return func() {}
+	}
+	return r.balancer.acquire(endpointID)
+}
+
+// InFlight reports the requests currently counted against an endpoint.
+func (r *Router) InFlight(endpointID string) int {
+	if r == nil || r.balancer == nil {
+		return 0
+	}
+	return r.balancer.count(endpointID)
+}
+
 // Breaker exposes the embedded circuit breaker so the gateway can record
 // per-request outcomes after the upstream call returns.
 func (r *Router) Breaker() *CircuitBreaker {
 	return r.breaker
 }
 
+// Store exposes the endpoint store the router picks from, for the
+// gateway's admin API.
+func (r *Router) Store() *Store {
+	return r.store
+}
+
 // IsActive reports whether the user has configured anything that
 // should let the router take over routing from the legacy cfg path.
 // Returns true when ANY of the following are set:
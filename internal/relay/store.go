@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
+
+	"lurus-switch/internal/secrets"
 )
 
 const (
@@ -57,6 +60,9 @@ func (s *Store) SaveEndpoint(ep RelayEndpoint) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := validateEndpoint(ep); err != nil {
+		return err
+	}
 	if ep.ID == "" {
 		buf := make([]byte, 8)
 		if _, err := rand.Read(buf); err != nil {
@@ -71,8 +77,10 @@ func (s *Store) SaveEndpoint(ep RelayEndpoint) error {
 	}
 
 	found := false
+	var dropped []string
 	for i, e := range eps {
 		if e.ID == ep.ID {
+			dropped = droppedPoolKeys(e, ep)
 			eps[i] = ep
 			found = true
 			break
@@ -82,7 +90,13 @@ func (s *Store) SaveEndpoint(ep RelayEndpoint) error {
 		eps = append(eps, ep)
 	}
 
-	return s.saveUserEndpoints(eps)
+	if err := s.saveUserEndpoints(eps); err != nil {
+		return err
+	}
+	for _, k := range dropped {
+		_ = secrets.Forget(secrets.Ref(poolKeySecretName(ep.ID, k)))
+	}
+	return nil
 }
 
 // UpdateEndpointLatency stores a freshly-observed round-trip latency
@@ -193,12 +207,51 @@ func (s *Store) DeleteEndpoint(id string) error {
 	}
 
 	filtered := eps[:0]
+	var dropped []string
 	for _, e := range eps {
 		if e.ID != id {
 			filtered = append(filtered, e)
+		} else {
+			dropped = droppedPoolKeys(e, RelayEndpoint{})
+		}
+	}
+	if err := s.saveUserEndpoints(filtered); err != nil {
+		return err
+	}
+	// Best effort: a locked vault just keeps the orphaned keys.
+	_ = secrets.Forget(secrets.Ref(apiKeySecretName(id)))
+	for _, k := range dropped {
+		_ = secrets.Forget(secrets.Ref(poolKeySecretName(id, k)))
+	}
+	return nil
+}
+
+// MigrateSecrets moves API keys still stored in plaintext into the
+// secrets vault, leaving references in relay-endpoints.json. Returns how
+// many keys moved; a no-op without a vault.
+func (s *Store) MigrateSecrets() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	eps, err := s.readUserEndpoints()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, e := range eps {
+		if secrets.NeedsSeal(e.APIKey) {
+			moved++
+		}
+		for _, k := range e.APIKeys {
+			if secrets.NeedsSeal(k) {
+				moved++
+			}
 		}
 	}
-	return s.saveUserEndpoints(filtered)
+	if moved == 0 {
+		return 0, nil
+	}
+	return moved, s.saveUserEndpoints(eps)
 }
 
 // GetToolMapping returns the current tool→relay-ID mapping.
@@ -217,7 +270,40 @@ func (s *Store) SaveToolMapping(m ToolRelayMapping) error {
 
 // --- internal ---
 
+// apiKeySecretName is the vault entry holding an endpoint's API key.
+func apiKeySecretName(id string) string { return "relay/" + id + "/apiKey" }
+
+// poolKeySecretName is the vault entry holding one of an endpoint's
+// pooled APIKeys, named by its fingerprint so reordering the pool
+// doesn't shuffle values between entries.
+func poolKeySecretName(id, key string) string { return "relay/" + id + "/apiKeys/" + KeyID(key) }
+
+// droppedPoolKeys lists prev's pooled keys that next no longer has.
+func droppedPoolKeys(prev, next RelayEndpoint) []string {
+	var out []string
+	for _, k := range prev.APIKeys {
+		if !slices.Contains(next.APIKeys, k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// loadUserEndpoints reads the user endpoints with their API keys
+// resolved from the secrets vault.
 func (s *Store) loadUserEndpoints() ([]RelayEndpoint, error) {
+	eps, err := s.readUserEndpoints()
+	for i := range eps {
+		eps[i].APIKey = secrets.Reveal(eps[i].APIKey)
+		for j, k := range eps[i].APIKeys {
+			eps[i].APIKeys[j] = secrets.Reveal(k)
+		}
+	}
+	return eps, err
+}
+
+// readUserEndpoints reads relay-endpoints.json as stored.
+func (s *Store) readUserEndpoints() ([]RelayEndpoint, error) {
 	path := filepath.Join(s.dataDir, endpointsFile)
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
@@ -233,8 +319,28 @@ func (s *Store) loadUserEndpoints() ([]RelayEndpoint, error) {
 	return eps, nil
 }
 
+// saveUserEndpoints writes the user endpoints, sealing plaintext API
+// keys into the secrets vault first.
 func (s *Store) saveUserEndpoints(eps []RelayEndpoint) error {
-	data, err := json.MarshalIndent(eps, "", "  ")
+	sealed := make([]RelayEndpoint, len(eps))
+	for i, e := range eps {
+		ref, err := secrets.Seal(apiKeySecretName(e.ID), e.APIKey)
+		if err != nil {
+			return fmt.Errorf("seal API key of %s: %w", e.ID, err)
+		}
+		e.APIKey = ref
+		if len(e.APIKeys) > 0 {
+			pool := make([]string, len(e.APIKeys))
+			for j, k := range e.APIKeys {
+				if pool[j], err = secrets.Seal(poolKeySecretName(e.ID, k), k); err != nil {
+					return fmt.Errorf("seal pooled API key of %s: %w", e.ID, err)
+				}
+			}
+			e.APIKeys = pool
+		}
+		sealed[i] = e
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal relay endpoints: %w", err)
 	}
@@ -1,6 +1,13 @@
 package relay
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lurus-switch/internal/secrets"
+)
 
 // TestStore_UpdateEndpointLatency verifies the latency feedback loop
 // added in W3.2: the gateway's fallback observer calls this after every
@@ -140,3 +147,37 @@ func TestStore_MigrateLegacyFallbacks_EmptyAndNoop(t *testing.T) {
 		t.Fatalf("with existing user endpoint: got (%d, %v), want (0, nil)", n2, err)
 	}
 }
+
+func TestStore_KeysLiveInSecretsVault(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStore(dir)
+	// A key saved before the vault existed is migrated on startup.
+	if err := store.SaveEndpoint(RelayEndpoint{ID: "alpha", URL: "https://alpha.test", APIKey: "sk-alpha"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, _ := secrets.Open(dir)
+	secrets.SetDefault(v)
+	t.Cleanup(func() { secrets.SetDefault(nil) })
+	if n, err := store.MigrateSecrets(); err != nil || n != 1 {
+		t.Fatalf("MigrateSecrets = %d, %v", n, err)
+	}
+	if n, _ := store.MigrateSecrets(); n != 0 {
+		t.Errorf("second MigrateSecrets = %d, want 0", n)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, endpointsFile))
+	if strings.Contains(string(raw), "sk-alpha") || !strings.Contains(string(raw), "secret://relay/alpha/apiKey") {
+		t.Fatalf("relay-endpoints.json should hold a reference:\n%s", raw)
+	}
+	eps, _ := store.ListEndpoints()
+	if got := eps[len(eps)-1]; got.ID != "alpha" || got.APIKey != "sk-alpha" {
+		t.Errorf("listed endpoint = %+v, want the resolved key", got)
+	}
+
+	if err := store.DeleteEndpoint("alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := v.Names(); len(names) != 0 {
+		t.Errorf("vault after delete = %v, want the key gone", names)
+	}
+}
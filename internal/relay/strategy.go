@@ -0,0 +1,206 @@
+package relay
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"lurus-switch/internal/pricing"
+)
+
+// Strategy selects how a matching rule spreads traffic over its pool.
+type Strategy string
+
+const (
+	// StrategyPreferred is the default: the rule's prefer_endpoint_id
+	// when healthy, else the lowest-latency endpoint.
+	StrategyPreferred Strategy = ""
+	// StrategyWeighted rotates over the pool in proportion to the
+	// rule's per-endpoint weights (smooth weighted round-robin).
+	StrategyWeighted Strategy = "weighted_round_robin"
+	// StrategyCheapest picks the endpoint with the lowest estimated cost
+	// for the requested model: its own price table when it has one,
+	// internal/pricing otherwise.
+	StrategyCheapest Strategy = "cheapest"
+	// StrategyLeastInFlight picks the endpoint with the fewest requests
+	// currently being served through the gateway.
+	StrategyLeastInFlight Strategy = "least_in_flight"
+)
+
+// assumedOutputTokens stands in for the unknown completion length when
+// comparing endpoint prices: enough that output-heavy rate cards don't
+// look free next to cheap-input ones.
+const assumedOutputTokens = 1000
+
+func (s Strategy) valid() bool {
+	switch s {
+	case StrategyPreferred, StrategyWeighted, StrategyCheapest, StrategyLeastInFlight:
+		return true
+	}
+	return false
+}
+
+// validate rejects rule sets the router could only half-apply.
+func (rs Rules) validate() error {
+	for _, rule := range rs.Rules {
+		if !rule.Strategy.valid() {
+			return fmt.Errorf("rule %q: unknown strategy %q (want %s, %s or %s)",
+				rule.Name, rule.Strategy, StrategyWeighted, StrategyCheapest, StrategyLeastInFlight)
+		}
+		for id, w := range rule.Weights {
+			if w < 0 {
+				return fmt.Errorf("rule %q: negative weight %d for endpoint %q", rule.Name, w, id)
+			}
+		}
+		if rule.HedgePercentile < 0 || rule.HedgePercentile > 100 {
+			return fmt.Errorf("rule %q: hedge_percentile %v out of range (0-100)", rule.Name, rule.HedgePercentile)
+		}
+	}
+	return nil
+}
+
+// pool narrows the healthy endpoints (latency-sorted) to those the rule
+// spreads traffic over: its endpoints list when set, else for the
+// weighted strategy the endpoints it gives a weight, else all of them.
+func (rule Rule) pool(healthy []RelayEndpoint) []RelayEndpoint {
+	allowed := map[string]bool{}
+	for _, id := range rule.Endpoints {
+		allowed[id] = true
+	}
+	if len(allowed) == 0 && rule.Strategy == StrategyWeighted {
+		for id := range rule.Weights {
+			allowed[id] = true
+		}
+	}
+	var out []RelayEndpoint
+	for _, ep := range healthy {
+		if len(allowed) > 0 && !allowed[ep.ID] {
+			continue
+		}
+		if rule.Strategy == StrategyWeighted && rule.weight(ep.ID) == 0 {
+			continue
+		}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// weight is the rule's weight for an endpoint; 1 when the rule sets none.
+func (rule Rule) weight(id string) int {
+	if len(rule.Weights) == 0 {
+		return 1
+	}
+	return rule.Weights[id]
+}
+
+// balancer holds the router's mutable strategy state: round-robin
+// positions per rule and in-flight counts per endpoint.
+type balancer struct {
+	mu       sync.Mutex
+	current  map[string]map[string]int // rule name → endpoint ID → smooth WRR weight
+	inFlight map[string]int
+}
+
+func newBalancer() *balancer {
+	return &balancer{current: map[string]map[string]int{}, inFlight: map[string]int{}}
+}
+
+// pick applies rule's strategy to a non-empty, latency-sorted pool. Ties
+// go to the lower-latency endpoint. Without commit the round-robin
+// position is computed on a copy and not advanced.
+func (b *balancer) pick(rule Rule, pool []RelayEndpoint, hint PickHint, commit bool) RelayEndpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch rule.Strategy {
+	case StrategyWeighted:
+		cur := b.current[rule.Name]
+		if !commit {
+			cur = maps.Clone(cur)
+		}
+		if cur == nil {
+			cur = map[string]int{}
+			if commit {
+				b.current[rule.Name] = cur
+			}
+		}
+		total, best := 0, -1
+		for i, ep := range pool {
+			w := rule.weight(ep.ID)
+			total += w
+			cur[ep.ID] += w
+			if best < 0 || cur[ep.ID] > cur[pool[best].ID] {
+				best = i
+			}
+		}
+		cur[pool[best].ID] -= total
+		return pool[best]
+	case StrategyCheapest:
+		best, bestCost := 0, estimateCost(pool[0], hint)
+		for i, ep := range pool[1:] {
+			if c := estimateCost(ep, hint); c < bestCost {
+				best, bestCost = i+1, c
+			}
+		}
+		return pool[best]
+	case StrategyLeastInFlight:
+		best := 0
+		for i, ep := range pool[1:] {
+			if b.inFlight[ep.ID] < b.inFlight[pool[best].ID] {
+				best = i + 1
+			}
+		}
+		return pool[best]
+	}
+	return pool[0]
+}
+
+// acquire counts one request against an endpoint until release runs.
+func (b *balancer) acquire(id string) (release func()) {
+	b.mu.Lock()
+	b.inFlight[id]++
+	b.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if b.inFlight[id]--; b.inFlight[id] <= 0 {
+				delete(b.inFlight, id)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *balancer) count(id string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight[id]
+}
+
+// priceFor is the endpoint's rate for model: the longest matching entry
+// of its price table, else the internal/pricing rate card.
+func (ep RelayEndpoint) priceFor(model string) (in, out float64) {
+	m := strings.ToLower(model)
+	matched := -1
+	for _, p := range ep.Prices {
+		prefix := strings.ToLower(p.ModelPrefix)
+		if strings.HasPrefix(m, prefix) && len(prefix) > matched {
+			matched, in, out = len(prefix), p.InputPerMTok, p.OutputPerMTok
+		}
+	}
+	if matched >= 0 {
+		return in, out
+	}
+	p := pricing.PriceFor(model)
+	return p.InputPerMTok, p.OutputPerMTok
+}
+
+func estimateCost(ep RelayEndpoint, hint PickHint) float64 {
+	in, out := ep.priceFor(hint.Model)
+	tokensIn := hint.EstimatedInputTokens
+	if tokensIn <= 0 {
+		tokensIn = 1
+	}
+	return in*float64(tokensIn) + out*assumedOutputTokens
+}
@@ -0,0 +1,148 @@
+package relay
+
+import (
+	"strings"
+	"testing"
+)
+
+func newStrategyRouter(t *testing.T, rules string, eps ...RelayEndpoint) *Router {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range eps {
+		if err := store.SaveEndpoint(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	router, err := NewRouter(dir, store, NewCircuitBreaker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := router.LoadRulesYAML(rules); err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestRouter_WeightedRoundRobin(t *testing.T) {
+	router := newStrategyRouter(t, `
+rules:
+  - name: paid-relays
+    strategy: weighted_round_robin
+    weights: {a: 3, b: 1}
+`,
+		RelayEndpoint{ID: "a", URL: "https://a.test", LatencyMs: 50},
+		RelayEndpoint{ID: "b", URL: "https://b.test", LatencyMs: 10},
+	)
+
+	counts := map[string]int{}
+	var seq []string
+	for i := 0; i < 8; i++ {
+		res, err := router.Pick("claude", PickHint{Model: "gpt-4o"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[res.Endpoint.ID]++
+		seq = append(seq, res.Endpoint.ID)
+		if res.MatchedBy != "paid-relays (weighted_round_robin)" {
+			t.Fatalf("MatchedBy = %q", res.MatchedBy)
+		}
+		if res.Ordered[0].ID != res.Endpoint.ID {
+			t.Fatalf("Ordered must start with the pick")
+		}
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("counts = %v, want a:6 b:2", counts)
+	}
+	// Smooth WRR interleaves instead of sending bursts.
+	if strings.Contains(strings.Join(seq, ""), "aaaa") {
+		t.Errorf("sequence not smooth: %v", seq)
+	}
+}
+
+func TestRouter_CheapestUsesEndpointPriceTable(t *testing.T) {
+	router := newStrategyRouter(t, `
+rules:
+  - name: cheap
+    match_model_prefix: claude
+    strategy: cheapest
+    endpoints: [official, reseller, markup]
+`,
+		RelayEndpoint{ID: "official", URL: "https://o.test", LatencyMs: 10},
+		RelayEndpoint{ID: "reseller", URL: "https://r.test", LatencyMs: 90,
+			Prices: []EndpointPrice{{ModelPrefix: "claude", InputPerMTok: 1.5, OutputPerMTok: 7.5}}},
+		RelayEndpoint{ID: "markup", URL: "https://m.test", LatencyMs: 5,
+			Prices: []EndpointPrice{
+				{ModelPrefix: "claude", InputPerMTok: 0.5, OutputPerMTok: 2},
+				{ModelPrefix: "claude-sonnet", InputPerMTok: 6, OutputPerMTok: 30},
+			}},
+	)
+
+	res, err := router.Pick("claude", PickHint{Model: "claude-sonnet-4-6", EstimatedInputTokens: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// markup's longest-prefix row prices sonnet above the reseller.
+	if res.Endpoint.ID != "reseller" {
+		t.Errorf("cheapest = %s, want reseller", res.Endpoint.ID)
+	}
+	if res.Strategy != StrategyCheapest {
+		t.Errorf("Strategy = %q", res.Strategy)
+	}
+	res, _ = router.Pick("claude", PickHint{Model: "claude-haiku-4", EstimatedInputTokens: 2000})
+	if res.Endpoint.ID != "markup" {
+		t.Errorf("cheapest for haiku = %s, want markup", res.Endpoint.ID)
+	}
+}
+
+func TestRouter_LeastInFlight(t *testing.T) {
+	router := newStrategyRouter(t, `
+rules:
+  - name: spread
+    strategy: least_in_flight
+    endpoints: [x, y]
+`,
+		RelayEndpoint{ID: "x", URL: "https://x.test", LatencyMs: 10},
+		RelayEndpoint{ID: "y", URL: "https://y.test", LatencyMs: 20},
+	)
+
+	res, _ := router.Pick("codex", PickHint{})
+	if res.Endpoint.ID != "x" {
+		t.Fatalf("idle tie should go to the faster endpoint, got %s", res.Endpoint.ID)
+	}
+	release := router.Acquire("x")
+	if res, _ = router.Pick("codex", PickHint{}); res.Endpoint.ID != "y" {
+		t.Errorf("busy x should lose to idle y, got %s", res.Endpoint.ID)
+	}
+	release()
+	release() // idempotent
+	if router.InFlight("x") != 0 {
+		t.Errorf("InFlight(x) = %d after release", router.InFlight("x"))
+	}
+	if res, _ = router.Pick("codex", PickHint{}); res.Endpoint.ID != "x" {
+		t.Errorf("after release x should win again, got %s", res.Endpoint.ID)
+	}
+}
+
+func TestRouter_RejectsUnknownStrategy(t *testing.T) {
+	router := newStrategyRouter(t, "rules: []\n")
+	err := router.LoadRulesYAML(`
+rules:
+  - name: typo
+    strategy: round_robin
+`)
+	if err == nil || !strings.Contains(err.Error(), "unknown strategy") {
+		t.Errorf("err = %v", err)
+	}
+	if err := router.LoadRulesYAML(`
+rules:
+  - name: neg
+    strategy: weighted_round_robin
+    weights: {a: -1}
+`); err == nil {
+		t.Error("negative weight should be rejected")
+	}
+}
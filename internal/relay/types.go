@@ -9,6 +9,18 @@ const (
 	KindCustom     RelayKind = "custom"
 )
 
+// Protocol names the wire API an endpoint speaks.
+type Protocol string
+
+const (
+	// ProtocolOpenAI is the default: OpenAI Chat Completions and friends.
+	ProtocolOpenAI Protocol = "openai"
+	// ProtocolAnthropic marks an endpoint that only serves the Anthropic
+	// Messages API (POST /v1/messages). The gateway translates OpenAI-
+	// protocol chat traffic for it and forwards /v1/messages verbatim.
+	ProtocolAnthropic Protocol = "anthropic"
+)
+
 // RelayEndpoint represents a single API relay endpoint configuration.
 type RelayEndpoint struct {
 	ID          string    `json:"id"`
@@ -18,10 +30,59 @@ type RelayEndpoint struct {
 	APIKey      string    `json:"apiKey"`
 	Description string    `json:"description,omitempty"`
 
+	// APIKeys pools further keys for the same provider alongside APIKey
+	// to spread rate limits; requests rotate over them per KeySelection
+	// (see keys.go).
+	APIKeys      []string     `json:"apiKeys,omitempty"`
+	KeySelection KeySelection `json:"keySelection,omitempty"`
+
+	// Breaker overrides the circuit breaker's thresholds for this
+	// endpoint; nil keeps the defaults (see policy.go).
+	Breaker *BreakerPolicy `json:"breaker,omitempty"`
+
+	// Vision marks the endpoint as accepting multimodal input (OpenAI
+	// image_url / file content parts). When false the gateway's
+	// Anthropic→OpenAI translator replaces image and document blocks
+	// with stub notes so a text-only upstream doesn't 400.
+	Vision bool `json:"vision,omitempty"`
+
+	// Protocol is the wire API the endpoint speaks; empty means
+	// ProtocolOpenAI.
+	Protocol Protocol `json:"protocol,omitempty"`
+
+	// Reasoning names the request field this OpenAI-protocol endpoint
+	// takes a thinking budget in: "effort" (reasoning_effort),
+	// "openrouter", "qwen", or "off". Empty infers it from the model
+	// name. See translator.ReasoningStyle.
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// Prices overrides the internal/pricing rate card for models this
+	// endpoint serves, for rules using the cheapest strategy. Relays
+	// often resell at a markup or discount.
+	Prices []EndpointPrice `json:"prices,omitempty"`
+
+	// ModelAliases rewrites the requested model to the ID this endpoint
+	// serves it under ("claude-sonnet-4-5" → "anthropic/claude-sonnet-4.5").
+	// Takes precedence over the gateway-wide alias map.
+	ModelAliases map[string]string `json:"modelAliases,omitempty"`
+
+	// ProbeModel is the model the models and completion health probes
+	// check this endpoint serves. Empty limits them to checking the key.
+	ProbeModel string `json:"probeModel,omitempty"`
+
 	// Runtime fields — populated by health checks, not persisted.
-	LatencyMs   int64  `json:"latencyMs"`
-	Healthy     bool   `json:"healthy"`
-	LastChecked string `json:"lastChecked,omitempty"`
+	LatencyMs   int64        `json:"latencyMs"`
+	Healthy     bool         `json:"healthy"`
+	LastChecked string       `json:"lastChecked,omitempty"`
+	Probe       *ProbeResult `json:"probe,omitempty"`
+}
+
+// EndpointPrice is one row of an endpoint's price table, in USD per 1M
+// tokens. The longest ModelPrefix matching the requested model applies.
+type EndpointPrice struct {
+	ModelPrefix   string  `json:"modelPrefix"`
+	InputPerMTok  float64 `json:"inputPerMTok"`
+	OutputPerMTok float64 `json:"outputPerMTok"`
 }
 
 // ToolRelayMapping maps a tool name to the ID of its preferred relay endpoint.
@@ -0,0 +1,91 @@
+package secrets
+
+import (
+	"strings"
+	"sync"
+)
+
+// RefPrefix marks a string field whose value lives in the vault.
+const RefPrefix = "secret://"
+
+var (
+	defaultMu    sync.RWMutex
+	defaultVault *Vault
+)
+
+// SetDefault installs the process-wide vault the stores seal into and
+// resolve from. The desktop app and lurus-switchd install one at
+// startup; with none installed (tests, tools that don't open the app
+// data dir) Seal passes values through and the stores keep plaintext.
+func SetDefault(v *Vault) {
+	defaultMu.Lock()
+	defaultVault = v
+	defaultMu.Unlock()
+}
+
+// Default returns the installed vault, or nil.
+func Default() *Vault {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultVault
+}
+
+// Ref returns the reference string for name.
+func Ref(name string) string { return RefPrefix + name }
+
+// IsRef reports whether s is a vault reference.
+func IsRef(s string) bool { return strings.HasPrefix(s, RefPrefix) }
+
+// Seal stores value under name in the default vault and returns the
+// reference to persist in its place. Empty values and values that are
+// already references are returned unchanged, as is everything when no
+// vault is installed.
+func Seal(name, value string) (string, error) {
+	v := Default()
+	if v == nil || value == "" || IsRef(value) {
+		return value, nil
+	}
+	if err := v.Put(name, value); err != nil {
+		return "", err
+	}
+	return Ref(name), nil
+}
+
+// Resolve returns the value a reference points at, or s itself when it
+// isn't one.
+func Resolve(s string) (string, error) {
+	if !IsRef(s) {
+		return s, nil
+	}
+	v := Default()
+	if v == nil {
+		return "", ErrLocked
+	}
+	return v.Get(strings.TrimPrefix(s, RefPrefix))
+}
+
+// Reveal is Resolve for load paths: a reference that can't be resolved
+// (locked vault, missing entry) is returned as is, so saving the record
+// again keeps the reference instead of blanking the secret.
+func Reveal(s string) string {
+	if val, err := Resolve(s); err == nil {
+		return val
+	}
+	return s
+}
+
+// Forget deletes the secret a reference points at. Non-references and
+// a missing vault are ignored.
+func Forget(ref string) error {
+	v := Default()
+	if v == nil || !IsRef(ref) {
+		return nil
+	}
+	return v.Delete(strings.TrimPrefix(ref, RefPrefix))
+}
+
+// NeedsSeal reports whether s is a plaintext value that Seal would move
+// into the default vault — what the stores' MigrateSecrets look for.
+func NeedsSeal(s string) bool {
+	return s != "" && !IsRef(s) && Default() != nil
+}
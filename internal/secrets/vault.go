@@ -0,0 +1,409 @@
+// Package secrets is the single encrypted store for the credentials the
+// other stores used to keep in their own JSON files: relay endpoint keys,
+// custom provider keys, the gateway's user token, notify transport
+// credentials and MCP server env values.
+//
+// Those stores now persist a reference ("secret://relay/<id>/apiKey")
+// in place of the value and resolve it on load, so their files — and
+// anything that copies them, like a configsync bundle or a support
+// dump — carry no credentials.
+//
+// The vault is one file, secrets.json, holding the secrets as a single
+// AES-256-GCM blob under a random data key. The data key is itself
+// sealed with a key-encryption key derived either from this machine and
+// OS user (the default, unlocked automatically like auth.Session) or
+// from a user passphrase via PBKDF2, in which case the vault starts
+// locked and Unlock must be called before any reference resolves.
+// Rotate replaces the data key and re-encrypts every secret.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileName is the vault file under the app-data directory.
+const FileName = "secrets.json"
+
+const (
+	fileVersion = 1
+
+	protectionMachine    = "machine"
+	protectionPassphrase = "passphrase"
+
+	// pbkdf2Iterations follows the current OWASP guidance for
+	// PBKDF2-HMAC-SHA256.
+	pbkdf2Iterations = 600_000
+	saltSize         = 16
+	keySize          = 32
+)
+
+var (
+	// ErrLocked is returned while a passphrase-protected vault has not
+	// been unlocked.
+	ErrLocked = errors.New("secrets: vault is locked")
+	// ErrNotFound is returned for a name the vault holds no value for.
+	ErrNotFound = errors.New("secrets: not found")
+	// ErrWrongPassphrase is returned when a passphrase doesn't open the
+	// vault.
+	ErrWrongPassphrase = errors.New("secrets: wrong passphrase")
+)
+
+// vaultFile is the on-disk shape. []byte fields marshal as base64.
+type vaultFile struct {
+	Version int `json:"version"`
+
+	// Protection is "machine" or "passphrase"; Salt and Iterations only
+	// apply to the latter.
+	Protection string `json:"protection"`
+	Salt       []byte `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+
+	// WrappedKey is the data key sealed with the key-encryption key.
+	WrappedKey []byte `json:"wrappedKey"`
+	// Generation counts data keys; Rotate bumps it.
+	Generation int       `json:"generation"`
+	RotatedAt  time.Time `json:"rotatedAt"`
+
+	// Data is the JSON name→value map sealed with the data key.
+	Data []byte `json:"data,omitempty"`
+}
+
+// Status is the vault summary shown in Settings. It never carries a
+// secret value.
+type Status struct {
+	Locked        bool      `json:"locked"`
+	Passphrase    bool      `json:"passphrase"` // protected by a passphrase rather than this machine
+	Count         int       `json:"count"`      // -1 while locked
+	KeyGeneration int       `json:"keyGeneration"`
+	RotatedAt     time.Time `json:"rotatedAt"`
+}
+
+// Vault is the encrypted secret store. It is safe for concurrent use.
+type Vault struct {
+	mu   sync.Mutex
+	path string
+	file vaultFile
+
+	// kek, key and values are nil while locked.
+	kek    []byte
+	key    []byte
+	values map[string]string
+}
+
+// Open loads the vault under appDataDir, creating a machine-protected
+// one if there is none yet. A machine-protected vault comes back
+// unlocked; a passphrase-protected one comes back locked.
+func Open(appDataDir string) (*Vault, error) {
+	if appDataDir == "" {
+		return nil, fmt.Errorf("appDataDir is required")
+	}
+	v := &Vault{path: filepath.Join(appDataDir, FileName)}
+	data, err := os.ReadFile(v.path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := randomBytes(keySize)
+		if err != nil {
+			return nil, err
+		}
+		v.kek = machineKey()
+		v.key = key
+		v.values = map[string]string{}
+		v.file = vaultFile{Version: fileVersion, Protection: protectionMachine, Generation: 1, RotatedAt: time.Now().UTC()}
+		return v, nil // written on the first Put
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets vault: %w", err)
+	}
+	if err := json.Unmarshal(data, &v.file); err != nil {
+		return nil, fmt.Errorf("parse secrets vault: %w", err)
+	}
+	if v.file.Protection == protectionMachine {
+		if err := v.openLocked(machineKey()); err != nil {
+			return nil, fmt.Errorf("secrets vault was created by another machine or user: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// Status summarizes the vault.
+func (v *Vault) Status() Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	st := Status{
+		Locked:        v.key == nil,
+		Passphrase:    v.file.Protection == protectionPassphrase,
+		Count:         -1,
+		KeyGeneration: v.file.Generation,
+		RotatedAt:     v.file.RotatedAt,
+	}
+	if v.values != nil {
+		st.Count = len(v.values)
+	}
+	return st
+}
+
+// Unlock opens a passphrase-protected vault. Unlocking an unlocked vault
+// is a no-op.
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key != nil {
+		return nil
+	}
+	return v.openLocked(passphraseKey(passphrase, v.file.Salt, v.file.Iterations))
+}
+
+// Lock forgets the keys and decrypted values of a passphrase-protected
+// vault. A machine-protected vault can't be locked: it would reopen
+// with nothing but this machine's identity.
+func (v *Vault) Lock() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.file.Protection != protectionPassphrase {
+		return fmt.Errorf("secrets: set a passphrase before locking the vault")
+	}
+	v.kek, v.key, v.values = nil, nil, nil
+	return nil
+}
+
+// SetPassphrase re-protects the data key. current must open the vault
+// when it is passphrase-protected; an empty next switches back to
+// machine protection. The secrets themselves are not re-encrypted.
+func (v *Vault) SetPassphrase(current, next string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.file.Protection == protectionPassphrase {
+		kek := passphraseKey(current, v.file.Salt, v.file.Iterations)
+		if v.key == nil {
+			if err := v.openLocked(kek); err != nil {
+				return err
+			}
+		} else if _, err := decrypt(kek, v.file.WrappedKey); err != nil {
+			return ErrWrongPassphrase
+		}
+	}
+	if next == "" {
+		v.file.Protection, v.file.Salt, v.file.Iterations = protectionMachine, nil, 0
+		v.kek = machineKey()
+	} else {
+		salt, err := randomBytes(saltSize)
+		if err != nil {
+			return err
+		}
+		v.file.Protection, v.file.Salt, v.file.Iterations = protectionPassphrase, salt, pbkdf2Iterations
+		v.kek = passphraseKey(next, salt, pbkdf2Iterations)
+	}
+	return v.saveLocked()
+}
+
+// Rotate replaces the data key and re-encrypts every secret under it.
+func (v *Vault) Rotate() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	key, err := randomBytes(keySize)
+	if err != nil {
+		return err
+	}
+	v.key = key
+	v.file.Generation++
+	v.file.RotatedAt = time.Now().UTC()
+	return v.saveLocked()
+}
+
+// Get returns the value stored under name.
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	val, ok := v.values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return val, nil
+}
+
+// Put stores value under name, replacing any previous value.
+func (v *Vault) Put(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("secrets: name is required")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if prev, ok := v.values[name]; ok && prev == value {
+		return nil
+	}
+	v.values[name] = value
+	return v.saveLocked()
+}
+
+// Delete removes name. Deleting a missing name is not an error.
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if _, ok := v.values[name]; !ok {
+		return nil
+	}
+	delete(v.values, name)
+	return v.saveLocked()
+}
+
+// Names lists the stored names, sorted.
+func (v *Vault) Names() ([]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return nil, ErrLocked
+	}
+	names := make([]string, 0, len(v.values))
+	for n := range v.values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// openLocked unwraps the data key with kek and decrypts the values.
+func (v *Vault) openLocked(kek []byte) error {
+	key, err := decrypt(kek, v.file.WrappedKey)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	values := map[string]string{}
+	if len(v.file.Data) > 0 {
+		plain, err := decrypt(key, v.file.Data)
+		if err != nil {
+			return fmt.Errorf("decrypt secrets: %w", err)
+		}
+		if err := json.Unmarshal(plain, &values); err != nil {
+			return fmt.Errorf("parse secrets: %w", err)
+		}
+	}
+	v.kek, v.key, v.values = kek, key, values
+	return nil
+}
+
+// sealLocked re-encrypts the values and re-wraps the data key into
+// v.file without writing it.
+func (v *Vault) sealLocked() error {
+	wrapped, err := encrypt(v.kek, v.key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(v.values)
+	if err != nil {
+		return err
+	}
+	data, err := encrypt(v.key, plain)
+	if err != nil {
+		return err
+	}
+	v.file.WrappedKey, v.file.Data = wrapped, data
+	return nil
+}
+
+// saveLocked seals and atomically rewrites the vault file (0600).
+func (v *Vault) saveLocked() error {
+	if err := v.sealLocked(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o755); err != nil {
+		return err
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write secrets vault: %w", err)
+	}
+	return os.Rename(tmp, v.path)
+}
+
+// machineKey derives the default key-encryption key from the hostname
+// and OS user, as auth.Session does for its session file.
+func machineKey() []byte {
+	hostname, _ := os.Hostname()
+	username := ""
+	if u, _ := user.Current(); u != nil {
+		username = u.Username
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("lurus-switch:secrets:%s:%s", hostname, username)))
+	return key[:]
+}
+
+func passphraseKey(passphrase string, salt []byte, iterations int) []byte {
+	if iterations <= 0 {
+		iterations = pbkdf2Iterations
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, keySize)
+	if err != nil {
+		return nil // only for parameters FIPS mode rejects; fails to decrypt
+	}
+	return key
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, fmt.Errorf("secrets: random: %w", err)
+	}
+	return b, nil
+}
+
+// encrypt seals plaintext with AES-GCM, nonce prepended.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
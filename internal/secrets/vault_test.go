@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVault_PersistsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	v, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Put("relay/a/apiKey", "sk-live-123"); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, FileName))
+	if strings.Contains(string(raw), "sk-live-123") || strings.Contains(string(raw), "relay/a") {
+		t.Fatalf("vault file leaks a name or value:\n%s", raw)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get("relay/a/apiKey"); err != nil || got != "sk-live-123" {
+		t.Errorf("Get after reopen = %q, %v", got, err)
+	}
+	if _, err := reopened.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+	}
+	if err := reopened.Lock(); err == nil {
+		t.Error("a machine-protected vault should refuse to lock")
+	}
+}
+
+func TestVault_Passphrase(t *testing.T) {
+	dir := t.TempDir()
+	v, _ := Open(dir)
+	v.Put("k", "v1")
+	if err := v.SetPassphrase("", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := locked.Status(); !st.Locked || !st.Passphrase || st.Count != -1 {
+		t.Fatalf("status = %+v, want locked passphrase vault", st)
+	}
+	if _, err := locked.Get("k"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Get while locked err = %v", err)
+	}
+	if err := locked.Put("k", "x"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Put while locked err = %v", err)
+	}
+	if err := locked.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Unlock(wrong) err = %v", err)
+	}
+	if err := locked.Unlock("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := locked.Get("k"); got != "v1" {
+		t.Errorf("Get after unlock = %q", got)
+	}
+
+	// Changing the passphrase needs the current one; clearing it goes
+	// back to opening unlocked.
+	if err := locked.SetPassphrase("wrong", ""); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("SetPassphrase(wrong) err = %v", err)
+	}
+	if err := locked.SetPassphrase("hunter2", ""); err != nil {
+		t.Fatal(err)
+	}
+	machine, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := machine.Get("k"); got != "v1" || machine.Status().Locked {
+		t.Errorf("after clearing the passphrase: Get = %q, status %+v", got, machine.Status())
+	}
+}
+
+func TestVault_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	v, _ := Open(dir)
+	v.Put("a", "1")
+	v.Put("b", "2")
+	before, _ := os.ReadFile(filepath.Join(dir, FileName))
+	gen := v.Status().KeyGeneration
+
+	if err := v.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, FileName))
+	if string(before) == string(after) {
+		t.Error("rotation should rewrite the vault")
+	}
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := reopened.Status(); st.KeyGeneration != gen+1 || st.Count != 2 {
+		t.Errorf("status after rotate = %+v", st)
+	}
+	if got, _ := reopened.Get("b"); got != "2" {
+		t.Errorf("Get after rotate = %q", got)
+	}
+}
+
+func TestRefs(t *testing.T) {
+	// Without a vault values pass through untouched.
+	if ref, err := Seal("x", "plain"); err != nil || ref != "plain" {
+		t.Fatalf("Seal without vault = %q, %v", ref, err)
+	}
+	if NeedsSeal("plain") {
+		t.Error("NeedsSeal without vault")
+	}
+
+	v, _ := Open(t.TempDir())
+	SetDefault(v)
+	t.Cleanup(func() { SetDefault(nil) })
+
+	ref, err := Seal("notify/slack/webhookUrl", "https://hooks.slack.test/T/B/X")
+	if err != nil || ref != "secret://notify/slack/webhookUrl" {
+		t.Fatalf("Seal = %q, %v", ref, err)
+	}
+	if again, _ := Seal("other", ref); again != ref {
+		t.Errorf("sealing a reference = %q, want it unchanged", again)
+	}
+	if got, err := Resolve(ref); err != nil || got != "https://hooks.slack.test/T/B/X" {
+		t.Errorf("Resolve = %q, %v", got, err)
+	}
+	if got := Reveal("not-a-ref"); got != "not-a-ref" {
+		t.Errorf("Reveal(plain) = %q", got)
+	}
+	if err := Forget(ref); err != nil {
+		t.Fatal(err)
+	}
+	if got := Reveal(ref); got != ref {
+		t.Errorf("Reveal of a forgotten ref = %q, want the ref kept", got)
+	}
+}
@@ -8,6 +8,20 @@ import (
 	"strings"
 )
 
+// RequestOptions tunes translation for one specific upstream. The zero
+// value is the conservative text-only translation.
+type RequestOptions struct {
+	// ForwardMedia emits image / document blocks as OpenAI image_url /
+	// file content parts instead of stub notes. Only set it for upstreams
+	// known to accept them — blind base64 to a text-only model 400s.
+	// Plain-text documents are inlined as text either way.
+	ForwardMedia bool
+
+	// Reasoning picks the request field the thinking budget is sent in.
+	// The zero value infers it from the model name.
+	Reasoning ReasoningStyle
+}
+
 // RequestToOpenAI translates an Anthropic POST /v1/messages payload to
 // an OpenAI POST /v1/chat/completions payload. The returned struct can
 // be marshalled and forwarded to any OpenAI-compatible upstream.
@@ -16,6 +30,12 @@ import (
 // (missing model, missing messages array). Soft mismatches like
 // unknown content-block types are best-effort: we drop them and log.
 func RequestToOpenAI(in *AnthropicRequest) (*OpenAIRequest, error) {
+	return RequestToOpenAIWithOptions(in, RequestOptions{})
+}
+
+// RequestToOpenAIWithOptions is RequestToOpenAI with per-upstream
+// translation options (see RequestOptions).
+func RequestToOpenAIWithOptions(in *AnthropicRequest, opts RequestOptions) (*OpenAIRequest, error) {
 	if in == nil {
 		return nil, fmt.Errorf("nil anthropic request")
 	}
@@ -55,7 +75,7 @@ func RequestToOpenAI(in *AnthropicRequest) (*OpenAIRequest, error) {
 		if err != nil {
 			return nil, fmt.Errorf("message %s content: %w", m.Role, err)
 		}
-		out.Messages = append(out.Messages, blocksToOpenAI(m.Role, blocks)...)
+		out.Messages = append(out.Messages, blocksToOpenAI(m.Role, blocks, opts)...)
 	}
 
 	// 3) Tools → OpenAI function tools.
@@ -89,6 +109,11 @@ func RequestToOpenAI(in *AnthropicRequest) (*OpenAIRequest, error) {
 		}
 	}
 
+	// 5) Extended thinking → the upstream's reasoning parameter.
+	if in.Thinking != nil && in.Thinking.Type == "enabled" {
+		applyReasoning(out, in.Thinking.BudgetTokens, opts.Reasoning)
+	}
+
 	return out, nil
 }
 
@@ -148,7 +173,10 @@ func normalizeContentBlocks(raw json.RawMessage) ([]ContentBlock, error) {
 //   - mixed user (text + tool_result)     → tool messages first, then a user
 //     message for the residual text. (OpenAI doesn't allow tool messages to
 //     interleave with text inside a single message, so we split.)
-func blocksToOpenAI(role string, blocks []ContentBlock) []OpenAIMessage {
+//   - user + image / document (ForwardMedia) → 1 message with an array of
+//     content parts; images embedded in tool_results ride along in it too,
+//     since role=tool content must be a string.
+func blocksToOpenAI(role string, blocks []ContentBlock, opts RequestOptions) []OpenAIMessage {
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -156,10 +184,10 @@ func blocksToOpenAI(role string, blocks []ContentBlock) []OpenAIMessage {
 	case "assistant":
 		return assistantBlocksToOpenAI(blocks)
 	case "user":
-		return userBlocksToOpenAI(blocks)
+		return userBlocksToOpenAI(blocks, opts)
 	default:
 		// Unknown roles fall through as user messages with concat text.
-		return userBlocksToOpenAI(blocks)
+		return userBlocksToOpenAI(blocks, opts)
 	}
 }
 
@@ -185,7 +213,8 @@ func assistantBlocksToOpenAI(blocks []ContentBlock) []OpenAIMessage {
 				},
 			})
 		}
-		// Unknown / thinking blocks: ignored — no OpenAI-side analog.
+		// Unknown / thinking blocks: ignored. Replaying reasoning as
+		// reasoning_content 400s on DeepSeek, and nothing else reads it.
 	}
 	msg := OpenAIMessage{Role: "assistant"}
 	if len(textParts) > 0 {
@@ -202,19 +231,23 @@ func assistantBlocksToOpenAI(blocks []ContentBlock) []OpenAIMessage {
 	return []OpenAIMessage{msg}
 }
 
-func userBlocksToOpenAI(blocks []ContentBlock) []OpenAIMessage {
+func userBlocksToOpenAI(blocks []ContentBlock, opts RequestOptions) []OpenAIMessage {
 	var out []OpenAIMessage
-	var textParts []string
+	var parts []OpenAIContentPart
+	hasMedia := false
+	addText := func(t string) {
+		parts = append(parts, OpenAIContentPart{Type: "text", Text: t})
+	}
 	for _, b := range blocks {
 		switch b.Type {
 		case "text":
 			if b.Text != "" {
-				textParts = append(textParts, b.Text)
+				addText(b.Text)
 			}
 		case "tool_result":
 			// Flatten the tool_result content (string OR array of blocks)
 			// into one string — OpenAI's role=tool message body is a string.
-			body := flattenToolResultContent(b.Content)
+			body, media := flattenToolResultContent(b.Content, opts)
 			if b.IsError && body != "" {
 				body = "[ERROR] " + body
 			}
@@ -224,45 +257,80 @@ func userBlocksToOpenAI(blocks []ContentBlock) []OpenAIMessage {
 				ToolCallID: b.ToolUseID,
 				Content:    c,
 			})
+			if len(media) > 0 {
+				parts = append(parts, media...)
+				hasMedia = true
+			}
 		default:
-			// image / document / unknown: emit a stub text note so the
-			// model at least knows non-text content was present, rather
-			// than silently dropping it (the old behaviour, which left
-			// the upstream answering as if no screenshot was attached).
-			// Actually forwarding the bytes as OpenAI image_url parts is
-			// gated on a per-channel "vision capable" bit — a separate
-			// feature; blind base64 to a text-only model 400s.
+			if text, ok := textDocument(b); ok {
+				addText(text)
+				continue
+			}
+			if opts.ForwardMedia {
+				if p, ok := mediaPartForBlock(b); ok {
+					parts = append(parts, p)
+					hasMedia = true
+					continue
+				}
+			}
+			// image / document / unknown on a text-only upstream: emit a
+			// stub text note so the model at least knows non-text content
+			// was present, rather than silently dropping it and leaving
+			// the upstream answering as if no screenshot was attached.
 			if note := stubNoteForBlock(b); note != "" {
-				textParts = append(textParts, note)
+				addText(note)
 			}
 		}
 	}
-	if len(textParts) > 0 {
-		c, _ := json.Marshal(strings.Join(textParts, "\n\n"))
-		out = append(out, OpenAIMessage{Role: "user", Content: c})
+	if len(parts) == 0 {
+		return out
 	}
-	return out
+	var c json.RawMessage
+	if hasMedia {
+		c, _ = json.Marshal(parts)
+	} else {
+		// Text-only: keep the plain-string content form, which every
+		// OpenAI-compatible server accepts (some reject part arrays).
+		texts := make([]string, len(parts))
+		for i, p := range parts {
+			texts[i] = p.Text
+		}
+		c, _ = json.Marshal(strings.Join(texts, "\n\n"))
+	}
+	return append(out, OpenAIMessage{Role: "user", Content: c})
 }
 
-func flattenToolResultContent(raw json.RawMessage) string {
+// flattenToolResultContent returns the tool_result body as one string
+// plus, when opts.ForwardMedia is set, the content parts for any images
+// it embedded (e.g. a screenshot from a browser tool). The caller
+// attaches those parts to the following user message.
+func flattenToolResultContent(raw json.RawMessage, opts RequestOptions) (string, []OpenAIContentPart) {
 	if len(raw) == 0 {
-		return ""
+		return "", nil
 	}
 	var s string
 	if json.Unmarshal(raw, &s) == nil {
-		return s
+		return s, nil
 	}
 	var blocks []ContentBlock
 	if json.Unmarshal(raw, &blocks) != nil {
-		return string(raw)
+		return string(raw), nil
 	}
 	var b strings.Builder
+	var media []OpenAIContentPart
 	for _, blk := range blocks {
 		line := blk.Text
-		if blk.Type != "text" {
-			// Tool results sometimes embed image blocks (e.g. a screenshot
-			// from a browser tool). Note their presence instead of dropping.
+		if text, ok := textDocument(blk); ok {
+			line = text
+		} else if blk.Type != "text" {
 			line = stubNoteForBlock(blk)
+			if opts.ForwardMedia {
+				if p, ok := mediaPartForBlock(blk); ok {
+					media = append(media, p)
+					line = fmt.Sprintf("[%s attached: %s — forwarded in the next user message]",
+						blk.Type, mediaTypeOrUnknown(blk.Source))
+				}
+			}
 		}
 		if line == "" {
 			continue
@@ -272,7 +340,117 @@ func flattenToolResultContent(raw json.RawMessage) string {
 		}
 		b.WriteString(line)
 	}
-	return b.String()
+	return b.String(), media
+}
+
+// mediaPartForBlock maps an image / document block onto the matching
+// OpenAI content part. ok=false means the block has no OpenAI analog
+// (unknown type, missing payload, a remote-URL document) and the caller
+// should fall back to stubNoteForBlock.
+func mediaPartForBlock(b ContentBlock) (OpenAIContentPart, bool) {
+	src := b.Source
+	if src == nil {
+		return OpenAIContentPart{}, false
+	}
+	switch b.Type {
+	case "image":
+		switch src.Type {
+		case "base64":
+			if src.Data == "" {
+				return OpenAIContentPart{}, false
+			}
+			return OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{
+				URL: dataURI(src.MediaType, src.Data),
+			}}, true
+		case "url":
+			if src.URL == "" {
+				return OpenAIContentPart{}, false
+			}
+			return OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: src.URL}}, true
+		}
+	case "document":
+		switch src.Type {
+		case "base64":
+			if src.Data == "" {
+				return OpenAIContentPart{}, false
+			}
+			return OpenAIContentPart{Type: "file", File: &OpenAIFile{
+				Filename: documentFilename(b.Title, src.MediaType),
+				FileData: dataURI(src.MediaType, src.Data),
+			}}, true
+		case "text":
+			if text, ok := textDocument(b); ok {
+				return OpenAIContentPart{Type: "text", Text: text}, true
+			}
+		}
+	}
+	return OpenAIContentPart{}, false
+}
+
+// textDocument returns a plain-text document block's text, headed by
+// its title. Those need no multimodal support at all, so every upstream
+// gets them inline.
+func textDocument(b ContentBlock) (string, bool) {
+	if b.Type != "document" || b.Source == nil || b.Source.Type != "text" || b.Source.Data == "" {
+		return "", false
+	}
+	text := b.Source.Data
+	if b.Title != "" {
+		text = b.Title + "\n\n" + text
+	}
+	return text, true
+}
+
+func dataURI(mediaType, b64 string) string {
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	return "data:" + mediaType + ";base64," + b64
+}
+
+// documentFilename picks a filename for an OpenAI file part. Some
+// servers sniff the extension, so an untitled PDF still gets ".pdf".
+func documentFilename(title, mediaType string) string {
+	if title != "" {
+		return title
+	}
+	if mediaType == "application/pdf" {
+		return "document.pdf"
+	}
+	return "document"
+}
+
+// HasMediaBlocks reports whether any user message carries an image or
+// document block (top-level or inside a tool_result). The gateway uses
+// it to skip the second, media-forwarding translation when there is
+// nothing to forward.
+func HasMediaBlocks(in *AnthropicRequest) bool {
+	if in == nil {
+		return false
+	}
+	for _, m := range in.Messages {
+		blocks, err := normalizeContentBlocks(m.Content)
+		if err != nil {
+			continue
+		}
+		for _, b := range blocks {
+			if b.Type == "image" || b.Type == "document" {
+				return true
+			}
+			if b.Type == "tool_result" {
+				var inner []ContentBlock
+				if json.Unmarshal(b.Content, &inner) != nil {
+					continue
+				}
+				for _, ib := range inner {
+					if ib.Type == "image" || ib.Type == "document" {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
 }
 
 // stubNoteForBlock returns a human-readable placeholder for a content
@@ -324,6 +502,11 @@ func ResponseToAnthropic(in *OpenAIResponse, model string) *AnthropicResponse {
 	}
 	c := in.Choices[0]
 
+	// Reasoning text comes first, as a thinking block — same order a
+	// native Anthropic response uses.
+	if r := reasoningText(c.Message.ReasoningContent, c.Message.Reasoning); r != "" {
+		out.Content = append(out.Content, ContentBlock{Type: "thinking", Thinking: r})
+	}
 	if textContent := readContentString(c.Message.Content); textContent != "" {
 		out.Content = append(out.Content, ContentBlock{Type: "text", Text: textContent})
 	}
@@ -176,6 +176,110 @@ func TestRequestToOpenAI_ToolResultWithImage(t *testing.T) {
 	}
 }
 
+func TestRequestToOpenAI_ForwardMediaEmitsContentParts(t *testing.T) {
+	req := mustReq(t, `{
+		"model": "gpt-4o",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "What's in this screenshot?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+				{"type": "image", "source": {"type": "url", "url": "https://example.com/a.jpg"}},
+				{"type": "document", "title": "spec.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0="}}
+			]}
+		]
+	}`)
+	out, err := RequestToOpenAIWithOptions(req, RequestOptions{ForwardMedia: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Messages) != 1 {
+		t.Fatalf("expected 1 user message, got %d", len(out.Messages))
+	}
+	var parts []OpenAIContentPart
+	if err := json.Unmarshal(out.Messages[0].Content, &parts); err != nil {
+		t.Fatalf("content should be a part array: %v (%s)", err, out.Messages[0].Content)
+	}
+	if len(parts) != 4 {
+		t.Fatalf("got %d parts, want 4: %s", len(parts), out.Messages[0].Content)
+	}
+	if parts[0].Type != "text" || parts[0].Text != "What's in this screenshot?" {
+		t.Errorf("part 0 = %+v", parts[0])
+	}
+	if parts[1].ImageURL == nil || parts[1].ImageURL.URL != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("base64 image part = %+v", parts[1])
+	}
+	if parts[2].ImageURL == nil || parts[2].ImageURL.URL != "https://example.com/a.jpg" {
+		t.Errorf("url image part = %+v", parts[2])
+	}
+	if parts[3].Type != "file" || parts[3].File == nil ||
+		parts[3].File.Filename != "spec.pdf" ||
+		parts[3].File.FileData != "data:application/pdf;base64,JVBERi0=" {
+		t.Errorf("document part = %+v", parts[3])
+	}
+	if strings.Contains(string(out.Messages[0].Content), "not forwarded") {
+		t.Errorf("forwarded media must not also carry a stub note: %s", out.Messages[0].Content)
+	}
+}
+
+func TestRequestToOpenAI_ForwardMediaToolResultImage(t *testing.T) {
+	req := mustReq(t, `{
+		"model": "gpt-4o",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [
+					{"type": "text", "text": "Page loaded."},
+					{"type": "image", "source": {"type": "base64", "media_type": "image/jpeg", "data": "/9j/4AAQ"}}
+				]}
+			]}
+		]
+	}`)
+	out, _ := RequestToOpenAIWithOptions(req, RequestOptions{ForwardMedia: true})
+	// role=tool content must stay a string, so the screenshot rides in a
+	// follow-up user message.
+	if len(out.Messages) != 2 {
+		t.Fatalf("got %d messages, want tool + user", len(out.Messages))
+	}
+	if out.Messages[0].Role != "tool" || !strings.Contains(string(out.Messages[0].Content), "Page loaded.") {
+		t.Errorf("tool message = %+v", out.Messages[0])
+	}
+	if out.Messages[1].Role != "user" || !strings.Contains(string(out.Messages[1].Content), "data:image/jpeg;base64,/9j/4AAQ") {
+		t.Errorf("user message should carry the image part: %s", out.Messages[1].Content)
+	}
+}
+
+func TestRequestToOpenAI_ForwardMediaTextOnlyKeepsString(t *testing.T) {
+	req := mustReq(t, `{"model": "x", "max_tokens": 1, "messages": [{"role":"user","content":"hi"}]}`)
+	out, _ := RequestToOpenAIWithOptions(req, RequestOptions{ForwardMedia: true})
+	if string(out.Messages[0].Content) != `"hi"` {
+		t.Errorf("text-only content should stay a plain string, got %s", out.Messages[0].Content)
+	}
+	if HasMediaBlocks(req) {
+		t.Error("HasMediaBlocks should be false for a text-only request")
+	}
+}
+
+func TestRequestToOpenAI_TextDocumentInlinedWithoutForwardMedia(t *testing.T) {
+	req := mustReq(t, `{
+		"model": "deepseek-chat",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "document", "title": "notes.txt", "source": {"type": "text", "media_type": "text/plain", "data": "Ship on Friday."}},
+				{"type": "text", "text": "When do we ship?"}
+			]}
+		]
+	}`)
+	out, err := RequestToOpenAIWithOptions(req, RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out.Messages[0].Content), `"notes.txt\n\nShip on Friday.\n\nWhen do we ship?"`; got != want {
+		t.Errorf("content = %s, want %s", got, want)
+	}
+}
+
 func TestRequestToOpenAI_ThinkingBlockNotStubNoted(t *testing.T) {
 	// thinking / redacted_thinking are model-reasoning artifacts, not user
 	// content — they must be dropped silently (like the assistant path),
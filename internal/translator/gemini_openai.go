@@ -0,0 +1,370 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GeminiRequestToOpenAI translates a Gemini generateContent payload to
+// an OpenAI POST /v1/chat/completions payload. model comes from the URL
+// path ({model}:generateContent); the caller sets Stream for the
+// :streamGenerateContent variant.
+//
+// Gemini function calls carry no mandatory id, so calls without one get
+// a synthetic id and each functionResponse is paired with the oldest
+// unanswered call of the same name — the order Gemini clients replay
+// them in.
+func GeminiRequestToOpenAI(in *GeminiRequest, model string, opts RequestOptions) (*OpenAIRequest, error) {
+	if in == nil {
+		return nil, fmt.Errorf("nil gemini request")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if len(in.Contents) == 0 {
+		return nil, fmt.Errorf("contents is required")
+	}
+
+	out := &OpenAIRequest{Model: model}
+	if gc := in.GenerationConfig; gc != nil {
+		out.Temperature = gc.Temperature
+		out.TopP = gc.TopP
+		out.MaxTokens = gc.MaxOutputTokens
+		out.Stop = gc.StopSequences
+		if tc := gc.ThinkingConfig; tc != nil && tc.ThinkingBudget != nil {
+			budget := *tc.ThinkingBudget
+			if budget < 0 { // dynamic: let the upstream pick, at a middling effort
+				budget = mediumEffortBudget
+			}
+			applyReasoning(out, budget, opts.Reasoning)
+		}
+	}
+
+	// 1) System instruction → system message.
+	if in.SystemInstruction != nil {
+		if t := geminiPartsText(in.SystemInstruction.Parts); t != "" {
+			out.Messages = append(out.Messages, OpenAIMessage{
+				Role: "system", Content: mustMarshalString(t),
+			})
+		}
+	}
+
+	// 2) Contents → messages. A user turn can mix functionResponse parts
+	//    with fresh input; the tool messages go first because OpenAI
+	//    requires them directly after the assistant's tool_calls.
+	pending := map[string][]string{} // function name → unanswered call ids
+	callSeq := 0
+	for _, c := range in.Contents {
+		if c.Role == "model" {
+			msg := OpenAIMessage{Role: "assistant"}
+			var texts []string
+			for _, p := range c.Parts {
+				switch {
+				case p.FunctionCall != nil:
+					id := p.FunctionCall.ID
+					if id == "" {
+						callSeq++
+						id = fmt.Sprintf("call_%d", callSeq)
+					}
+					pending[p.FunctionCall.Name] = append(pending[p.FunctionCall.Name], id)
+					args := "{}"
+					var compact bytes.Buffer
+					if json.Compact(&compact, p.FunctionCall.Args) == nil && compact.Len() > 0 {
+						args = compact.String()
+					}
+					msg.ToolCalls = append(msg.ToolCalls, OpenAIToolCall{
+						ID:       id,
+						Type:     "function",
+						Function: OpenAIFunctionCall{Name: p.FunctionCall.Name, Arguments: args},
+					})
+				case p.Text != "" && !p.Thought:
+					texts = append(texts, p.Text)
+				}
+			}
+			if len(texts) > 0 {
+				msg.Content = mustMarshalString(strings.Join(texts, ""))
+			} else if len(msg.ToolCalls) == 0 {
+				continue
+			}
+			out.Messages = append(out.Messages, msg)
+			continue
+		}
+
+		var parts []OpenAIContentPart
+		var notes []string
+		hasMedia := false
+		for _, p := range c.Parts {
+			switch {
+			case p.FunctionResponse != nil:
+				fr := p.FunctionResponse
+				id := fr.ID
+				if ids := pending[fr.Name]; len(ids) > 0 {
+					if id == "" {
+						id = ids[0]
+					}
+					pending[fr.Name] = ids[1:]
+				}
+				if id == "" {
+					id = "call_" + fr.Name
+				}
+				out.Messages = append(out.Messages, OpenAIMessage{
+					Role:       "tool",
+					ToolCallID: id,
+					Content:    mustMarshalString(orDefault(string(fr.Response), "{}")),
+				})
+			case p.InlineData != nil || p.FileData != nil:
+				blk := geminiMediaBlock(p)
+				if opts.ForwardMedia {
+					if part, ok := mediaPartForBlock(blk); ok {
+						parts = append(parts, part)
+						hasMedia = true
+						continue
+					}
+				}
+				notes = append(notes, stubNoteForBlock(blk))
+			case p.Text != "" && !p.Thought:
+				parts = append(parts, OpenAIContentPart{Type: "text", Text: p.Text})
+			}
+		}
+		for _, n := range notes {
+			parts = append(parts, OpenAIContentPart{Type: "text", Text: n})
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		if hasMedia {
+			raw, _ := json.Marshal(parts)
+			out.Messages = append(out.Messages, OpenAIMessage{Role: "user", Content: raw})
+		} else {
+			texts := make([]string, 0, len(parts))
+			for _, p := range parts {
+				texts = append(texts, p.Text)
+			}
+			out.Messages = append(out.Messages, OpenAIMessage{
+				Role: "user", Content: mustMarshalString(strings.Join(texts, "\n\n")),
+			})
+		}
+	}
+
+	// 3) Function declarations → OpenAI tools.
+	for _, t := range in.Tools {
+		for _, fd := range t.FunctionDeclarations {
+			schema := fd.ParametersJSONSchema
+			if len(schema) == 0 {
+				schema = normalizeGeminiSchema(fd.Parameters)
+			}
+			if len(schema) == 0 || string(schema) == "null" {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			out.Tools = append(out.Tools, OpenAITool{
+				Type: "function",
+				Function: OpenAIToolFunction{
+					Name: fd.Name, Description: fd.Description, Parameters: schema,
+				},
+			})
+		}
+	}
+
+	// 4) Function-calling mode → tool_choice.
+	if in.ToolConfig != nil && in.ToolConfig.FunctionCallingConfig != nil && len(out.Tools) > 0 {
+		fc := in.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(fc.Mode) {
+		case "AUTO":
+			out.ToolChoice = json.RawMessage(`"auto"`)
+		case "NONE":
+			out.ToolChoice = json.RawMessage(`"none"`)
+		case "ANY":
+			if len(fc.AllowedFunctionNames) == 1 {
+				out.ToolChoice, _ = json.Marshal(map[string]any{
+					"type":     "function",
+					"function": map[string]string{"name": fc.AllowedFunctionNames[0]},
+				})
+			} else {
+				out.ToolChoice = json.RawMessage(`"required"`)
+			}
+		}
+	}
+
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("contents has no translatable parts")
+	}
+	return out, nil
+}
+
+// GeminiIncludeThoughts reports whether the client asked for reasoning
+// text back as thought parts.
+func GeminiIncludeThoughts(in *GeminiRequest) bool {
+	return in != nil && in.GenerationConfig != nil && in.GenerationConfig.ThinkingConfig != nil &&
+		in.GenerationConfig.ThinkingConfig.IncludeThoughts
+}
+
+// GeminiHasMedia reports whether any turn carries inline or file data.
+// Mirrors HasMediaBlocks for the gateway's vision-only forwarding.
+func GeminiHasMedia(in *GeminiRequest) bool {
+	if in == nil {
+		return false
+	}
+	for _, c := range in.Contents {
+		for _, p := range c.Parts {
+			if p.InlineData != nil || p.FileData != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// geminiMediaBlock recasts an inlineData / fileData part as the
+// equivalent Anthropic block so mediaPartForBlock and stubNoteForBlock
+// apply unchanged. Anything that isn't an image is treated as a
+// document.
+func geminiMediaBlock(p GeminiPart) ContentBlock {
+	var src ContentBlockSource
+	if p.InlineData != nil {
+		src = ContentBlockSource{Type: "base64", MediaType: p.InlineData.MimeType, Data: p.InlineData.Data}
+	} else {
+		src = ContentBlockSource{Type: "url", MediaType: p.FileData.MimeType, URL: p.FileData.FileURI}
+	}
+	typ := "document"
+	if strings.HasPrefix(src.MediaType, "image/") {
+		typ = "image"
+	}
+	return ContentBlock{Type: typ, Source: &src}
+}
+
+func geminiPartsText(parts []GeminiPart) string {
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" && !p.Thought {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// normalizeGeminiSchema lower-cases the OpenAPI type names Gemini uses
+// ("OBJECT", "STRING") so the schema is valid JSON Schema. Everything
+// else is kept as-is.
+func normalizeGeminiSchema(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var v any
+	if json.Unmarshal(raw, &v) != nil {
+		return raw
+	}
+	out, err := json.Marshal(lowerSchemaTypes(v))
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func lowerSchemaTypes(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if s, ok := child.(string); ok && k == "type" {
+				t[k] = strings.ToLower(s)
+				continue
+			}
+			t[k] = lowerSchemaTypes(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = lowerSchemaTypes(child)
+		}
+	}
+	return v
+}
+
+// ─── Response (non-stream) ─────────────────────────────────────────
+
+// ResponseToGemini wraps an OpenAI ChatCompletion in the
+// GenerateContentResponse shape Gemini clients read. Only choice[0] is
+// honored, matching ResponseToAnthropic. Reasoning text is returned as
+// a leading thought part when includeThoughts is set.
+func ResponseToGemini(in *OpenAIResponse, model string, includeThoughts bool) *GeminiResponse {
+	if model == "" {
+		model = in.Model
+	}
+	u := in.Usage
+	u.CompletionTokensDetails.ReasoningTokens = ReasoningTokens(in)
+	usage := GeminiUsageFromOpenAI(u)
+	out := &GeminiResponse{
+		ModelVersion:  model,
+		ResponseID:    in.ID,
+		UsageMetadata: &usage,
+	}
+	cand := GeminiCandidate{Content: GeminiContent{Role: "model", Parts: []GeminiPart{}}}
+	if len(in.Choices) > 0 {
+		c := in.Choices[0]
+		if r := reasoningText(c.Message.ReasoningContent, c.Message.Reasoning); r != "" && includeThoughts {
+			cand.Content.Parts = append(cand.Content.Parts, GeminiPart{Text: r, Thought: true})
+		}
+		if t := readContentString(c.Message.Content); t != "" {
+			cand.Content.Parts = append(cand.Content.Parts, GeminiPart{Text: t})
+		}
+		for _, tc := range c.Message.ToolCalls {
+			cand.Content.Parts = append(cand.Content.Parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+				ID: tc.ID, Name: tc.Function.Name, Args: geminiArgs(tc.Function.Arguments),
+			}})
+		}
+		cand.FinishReason = mapFinishReasonToGemini(c.FinishReason)
+	}
+	out.Candidates = []GeminiCandidate{cand}
+	return out
+}
+
+// GeminiUsageFromOpenAI converts OpenAI usage into usageMetadata. The
+// cached subset maps one-to-one; reasoning tokens move out of the
+// candidates count into thoughtsTokenCount, since Gemini reports them
+// separately.
+func GeminiUsageFromOpenAI(u OpenAIUsage) GeminiUsageMetadata {
+	thoughts := u.CompletionTokensDetails.ReasoningTokens
+	candidates := u.CompletionTokens - thoughts
+	if candidates < 0 {
+		candidates = 0
+	}
+	total := u.TotalTokens
+	if total == 0 {
+		total = u.PromptTokens + u.CompletionTokens
+	}
+	return GeminiUsageMetadata{
+		PromptTokenCount:        u.PromptTokens,
+		CandidatesTokenCount:    candidates,
+		TotalTokenCount:         total,
+		CachedContentTokenCount: u.PromptTokensDetails.CachedTokens,
+		ThoughtsTokenCount:      thoughts,
+	}
+}
+
+// geminiArgs turns an OpenAI arguments string into the JSON object
+// Gemini expects, keeping unparsable text under "_raw" rather than
+// dropping the call.
+func geminiArgs(args string) json.RawMessage {
+	raw := json.RawMessage(orDefault(args, "{}"))
+	if json.Valid(raw) {
+		return raw
+	}
+	wrapped, _ := json.Marshal(map[string]string{"_raw": args})
+	return wrapped
+}
+
+// mapFinishReasonToGemini maps OpenAI finish reasons to Gemini's enum.
+// Gemini has no tool-call reason: a turn that ends in function calls
+// finishes with STOP.
+func mapFinishReasonToGemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	case "":
+		return ""
+	default: // stop, tool_calls, function_call
+		return "STOP"
+	}
+}
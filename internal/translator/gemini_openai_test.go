@@ -0,0 +1,177 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGeminiRequestToOpenAI_FunctionCallingRoundTrip(t *testing.T) {
+	var req GeminiRequest
+	if err := json.Unmarshal([]byte(`{
+		"systemInstruction": {"parts": [{"text": "Be terse."}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Weather in Paris?"}]},
+			{"role": "model", "parts": [
+				{"text": "thinking…", "thought": true},
+				{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"name": "get_weather", "response": {"output": "Rain"}}},
+				{"text": "And tomorrow?"}
+			]}
+		],
+		"tools": [{"functionDeclarations": [{
+			"name": "get_weather",
+			"parameters": {"type": "OBJECT", "properties": {"city": {"type": "STRING"}}}
+		}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["get_weather"]}},
+		"generationConfig": {"maxOutputTokens": 256, "stopSequences": ["END"]}
+	}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	out, err := GeminiRequestToOpenAI(&req, "gemini-2.5-pro", RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Model != "gemini-2.5-pro" || out.MaxTokens != 256 || len(out.Stop) != 1 {
+		t.Errorf("model/max_tokens/stop = %s/%d/%v", out.Model, out.MaxTokens, out.Stop)
+	}
+	// system, user, assistant(tool_calls), tool, user
+	roles := make([]string, len(out.Messages))
+	for i, m := range out.Messages {
+		roles[i] = m.Role
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,tool,user" {
+		t.Fatalf("roles = %v", roles)
+	}
+	asst := out.Messages[2]
+	if len(asst.ToolCalls) != 1 || asst.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool_calls = %+v", asst.ToolCalls)
+	}
+	if len(asst.Content) != 0 {
+		t.Errorf("thought parts must not become assistant content: %s", asst.Content)
+	}
+	if out.Messages[3].ToolCallID != asst.ToolCalls[0].ID {
+		t.Errorf("functionResponse should pair with the synthetic call id %q, got %q",
+			asst.ToolCalls[0].ID, out.Messages[3].ToolCallID)
+	}
+	params := string(out.Tools[0].Function.Parameters)
+	if !strings.Contains(params, `"type":"object"`) || !strings.Contains(params, `"type":"string"`) {
+		t.Errorf("schema types should be lower-cased: %s", params)
+	}
+	if string(out.ToolChoice) != `{"function":{"name":"get_weather"},"type":"function"}` {
+		t.Errorf("tool_choice = %s", out.ToolChoice)
+	}
+}
+
+func TestGeminiRequestToOpenAI_InlineData(t *testing.T) {
+	req := &GeminiRequest{Contents: []GeminiContent{{Role: "user", Parts: []GeminiPart{
+		{Text: "what is this"},
+		{InlineData: &GeminiBlob{MimeType: "image/png", Data: "iVBORw0KGgo="}},
+	}}}}
+
+	stub, err := GeminiRequestToOpenAI(req, "m", RequestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readContentString(stub.Messages[0].Content); !strings.Contains(got, "[image attached: image/png") {
+		t.Errorf("text-only upstream should get a stub note, got %q", got)
+	}
+
+	media, _ := GeminiRequestToOpenAI(req, "m", RequestOptions{ForwardMedia: true})
+	if !strings.Contains(string(media.Messages[0].Content), `"url":"data:image/png;base64,iVBORw0KGgo="`) {
+		t.Errorf("vision upstream should get an image_url part: %s", media.Messages[0].Content)
+	}
+	if !GeminiHasMedia(req) {
+		t.Error("GeminiHasMedia should see inlineData")
+	}
+}
+
+func TestResponseToGemini_ToolCallsAndUsage(t *testing.T) {
+	in := &OpenAIResponse{
+		ID: "chatcmpl-1",
+		Choices: []OpenAIChoice{{
+			Message: OpenAIMessage{
+				Role:    "assistant",
+				Content: json.RawMessage(`"Checking."`),
+				ToolCalls: []OpenAIToolCall{{
+					ID: "call_9", Type: "function",
+					Function: OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Oslo"}`},
+				}},
+			},
+			FinishReason: "tool_calls",
+		}},
+		Usage: OpenAIUsage{
+			PromptTokens: 100, CompletionTokens: 30, TotalTokens: 130,
+			PromptTokensDetails:     OpenAIPromptTokensDetails{CachedTokens: 60},
+			CompletionTokensDetails: OpenAICompletionTokensDetails{ReasoningTokens: 10},
+		},
+	}
+	out := ResponseToGemini(in, "gemini-2.5-flash", false)
+	c := out.Candidates[0]
+	if c.FinishReason != "STOP" || c.Content.Role != "model" || len(c.Content.Parts) != 2 {
+		t.Fatalf("candidate = %+v", c)
+	}
+	if c.Content.Parts[0].Text != "Checking." {
+		t.Errorf("text part = %+v", c.Content.Parts[0])
+	}
+	fc := c.Content.Parts[1].FunctionCall
+	if fc == nil || fc.ID != "call_9" || string(fc.Args) != `{"city":"Oslo"}` {
+		t.Errorf("functionCall = %+v", fc)
+	}
+	u := out.UsageMetadata
+	if u.PromptTokenCount != 100 || u.CachedContentTokenCount != 60 ||
+		u.CandidatesTokenCount != 20 || u.ThoughtsTokenCount != 10 || u.TotalTokenCount != 130 {
+		t.Errorf("usageMetadata = %+v", u)
+	}
+}
+
+func TestGeminiStreamTranslator_SSE(t *testing.T) {
+	upstream := strings.NewReader(
+		`data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"content":"lo"}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"ls","arguments":"{\"dir\":"}}]}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"/\"}"}}]}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n" +
+			`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}` + "\n\n" +
+			"data: [DONE]\n\n",
+	)
+	tr := NewGeminiStreamTranslator("r1", "gemini-x", true, false)
+	var out bytes.Buffer
+	if err := tr.Run(upstream, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	mustHave(t, got, `"parts":[{"text":"Hel"}]`)
+	mustHave(t, got, `"parts":[{"text":"lo"}]`)
+	mustHave(t, got, `"functionCall":{"id":"call_1","name":"ls","args":{"dir":"/"}}`)
+	mustHave(t, got, `"finishReason":"STOP"`)
+	mustHave(t, got, `"promptTokenCount":12`)
+	if n := strings.Count(got, "data: "); n != 3 {
+		t.Errorf("want 3 SSE chunks (2 text + final), got %d:\n%s", n, got)
+	}
+	if u := tr.Usage(); u.PromptTokens != 12 || u.CompletionTokens != 7 {
+		t.Errorf("Usage() = %+v", u)
+	}
+}
+
+func TestGeminiStreamTranslator_JSONArrayFraming(t *testing.T) {
+	upstream := strings.NewReader(
+		`data: {"choices":[{"index":0,"delta":{"content":"hi"}}]}` + "\n\n" +
+			`data: {"choices":[{"index":0,"delta":{},"finish_reason":"length"}]}` + "\n\n",
+	)
+	tr := NewGeminiStreamTranslator("r2", "gemini-x", false, false)
+	var out bytes.Buffer
+	if err := tr.Run(upstream, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	var chunks []GeminiResponse
+	if err := json.Unmarshal(out.Bytes(), &chunks); err != nil {
+		t.Fatalf("without alt=sse the stream must be one JSON array: %v\n%s", err, out.String())
+	}
+	if len(chunks) != 2 || chunks[1].Candidates[0].FinishReason != "MAX_TOKENS" {
+		t.Errorf("chunks = %+v", chunks)
+	}
+}
@@ -0,0 +1,330 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultAnthropicMaxTokens fills Anthropic's mandatory max_tokens when
+// the OpenAI client left it unset (OpenAI treats it as optional).
+const defaultAnthropicMaxTokens = 4096
+
+// RequestToAnthropic translates an OpenAI POST /v1/chat/completions
+// payload to an Anthropic POST /v1/messages payload — the mirror image
+// of RequestToOpenAI, used when an OpenAI-protocol tool is routed to an
+// Anthropic-native upstream.
+//
+// Errors are returned only for structurally-broken OpenAI payloads
+// (missing model, no non-system messages). Unknown content-part types
+// are dropped.
+func RequestToAnthropic(in *OpenAIRequest) (*AnthropicRequest, error) {
+	if in == nil {
+		return nil, fmt.Errorf("nil openai request")
+	}
+	if strings.TrimSpace(in.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	out := &AnthropicRequest{
+		Model:         in.Model,
+		MaxTokens:     in.MaxTokens,
+		Temperature:   in.Temperature,
+		TopP:          in.TopP,
+		StopSequences: in.Stop,
+		Stream:        in.Stream,
+	}
+	if in.MaxCompletionTokens > 0 {
+		out.MaxTokens = in.MaxCompletionTokens
+	}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = defaultAnthropicMaxTokens
+	}
+
+	// 1) System / developer messages → top-level system string. 2) The
+	//    rest become Anthropic messages; tool-role messages turn into
+	//    tool_result blocks on a user turn, and consecutive same-role
+	//    turns are merged because Anthropic requires strict alternation.
+	var system []string
+	for _, m := range in.Messages {
+		switch m.Role {
+		case "system", "developer":
+			if t := openAIContentText(m.Content); t != "" {
+				system = append(system, t)
+			}
+		case "assistant":
+			out.Messages = appendAnthropicTurn(out.Messages, "assistant", assistantOpenAIToBlocks(m))
+		case "tool":
+			out.Messages = appendAnthropicTurn(out.Messages, "user", []ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   mustMarshalString(openAIContentText(m.Content)),
+			}})
+		default:
+			out.Messages = appendAnthropicTurn(out.Messages, "user", openAIContentToBlocks(m.Content))
+		}
+	}
+	if len(out.Messages) == 0 {
+		return nil, fmt.Errorf("messages array is required")
+	}
+	if len(system) > 0 {
+		out.System = mustMarshalString(strings.Join(system, "\n\n"))
+	}
+
+	// 3) Function tools → Anthropic tools.
+	for _, t := range in.Tools {
+		if t.Type != "" && t.Type != "function" {
+			continue
+		}
+		schema := t.Function.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			// Anthropic rejects a tool without input_schema.
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, AnthropicTool{
+			Name: t.Function.Name, Description: t.Function.Description, InputSchema: schema,
+		})
+	}
+
+	// 4) Reasoning effort / budget → extended thinking. Anthropic wants
+	//    max_tokens above the budget and no custom temperature.
+	budget := budgetForEffort(in.ReasoningEffort)
+	if in.Reasoning != nil {
+		if in.Reasoning.MaxTokens > 0 {
+			budget = in.Reasoning.MaxTokens
+		} else if b := budgetForEffort(in.Reasoning.Effort); b > 0 {
+			budget = b
+		}
+	}
+	if budget > 0 {
+		out.Thinking = &AnthropicThinking{Type: "enabled", BudgetTokens: budget}
+		if out.MaxTokens <= budget {
+			out.MaxTokens = budget + defaultAnthropicMaxTokens
+		}
+		out.Temperature, out.TopP = nil, nil
+	}
+
+	// 5) Tool choice mapping (inverse of RequestToOpenAI).
+	if len(in.ToolChoice) > 0 {
+		var mode string
+		if json.Unmarshal(in.ToolChoice, &mode) == nil {
+			switch mode {
+			case "auto":
+				out.ToolChoice = &AnthropicToolChoice{Type: "auto"}
+			case "required":
+				out.ToolChoice = &AnthropicToolChoice{Type: "any"}
+			case "none":
+				out.ToolChoice = &AnthropicToolChoice{Type: "none"}
+			}
+		} else {
+			var obj struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			}
+			if json.Unmarshal(in.ToolChoice, &obj) == nil && obj.Function.Name != "" {
+				out.ToolChoice = &AnthropicToolChoice{Type: "tool", Name: obj.Function.Name}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// appendAnthropicTurn appends blocks as a new message, or onto the last
+// message when it has the same role. Empty block lists are dropped.
+func appendAnthropicTurn(msgs []AnthropicMessage, role string, blocks []ContentBlock) []AnthropicMessage {
+	if len(blocks) == 0 {
+		return msgs
+	}
+	if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+		var prev []ContentBlock
+		_ = json.Unmarshal(msgs[n-1].Content, &prev)
+		msgs[n-1].Content, _ = json.Marshal(append(prev, blocks...))
+		return msgs
+	}
+	raw, _ := json.Marshal(blocks)
+	return append(msgs, AnthropicMessage{Role: role, Content: raw})
+}
+
+func assistantOpenAIToBlocks(m OpenAIMessage) []ContentBlock {
+	var blocks []ContentBlock
+	if t := openAIContentText(m.Content); t != "" {
+		blocks = append(blocks, ContentBlock{Type: "text", Text: t})
+	}
+	for _, tc := range m.ToolCalls {
+		input := json.RawMessage(orDefault(tc.Function.Arguments, "{}"))
+		if !json.Valid(input) {
+			// Some clients replay truncated argument strings; Anthropic
+			// requires an object, so keep the raw text under a key rather
+			// than failing the whole request.
+			input, _ = json.Marshal(map[string]string{"_raw": tc.Function.Arguments})
+		}
+		blocks = append(blocks, ContentBlock{
+			Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input,
+		})
+	}
+	return blocks
+}
+
+// openAIContentToBlocks converts a user message's content (string or
+// array of text / image_url parts) into Anthropic content blocks.
+func openAIContentToBlocks(raw json.RawMessage) []ContentBlock {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if s == "" {
+			return nil
+		}
+		return []ContentBlock{{Type: "text", Text: s}}
+	}
+	var parts []OpenAIContentPart
+	if json.Unmarshal(raw, &parts) != nil {
+		return nil
+	}
+	var blocks []ContentBlock
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			if p.Text != "" {
+				blocks = append(blocks, ContentBlock{Type: "text", Text: p.Text})
+			}
+		case "image_url":
+			if p.ImageURL != nil && p.ImageURL.URL != "" {
+				blocks = append(blocks, ContentBlock{Type: "image", Source: sourceFromURL(p.ImageURL.URL)})
+			}
+		case "file":
+			if p.File != nil && p.File.FileData != "" {
+				blocks = append(blocks, ContentBlock{
+					Type: "document", Title: p.File.Filename, Source: sourceFromURL(p.File.FileData),
+				})
+			}
+		}
+	}
+	return blocks
+}
+
+// sourceFromURL splits a data: URI into an Anthropic base64 source;
+// anything else is passed as a url source.
+func sourceFromURL(u string) *ContentBlockSource {
+	if rest, ok := strings.CutPrefix(u, "data:"); ok {
+		if meta, data, ok := strings.Cut(rest, ","); ok {
+			return &ContentBlockSource{
+				Type:      "base64",
+				MediaType: strings.TrimSuffix(meta, ";base64"),
+				Data:      data,
+			}
+		}
+	}
+	return &ContentBlockSource{Type: "url", URL: u}
+}
+
+// openAIContentText flattens string-or-parts content to plain text.
+func openAIContentText(raw json.RawMessage) string {
+	if s := readContentString(raw); s != "" {
+		return s
+	}
+	var parts []OpenAIContentPart
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func mustMarshalString(s string) json.RawMessage {
+	raw, _ := json.Marshal(s)
+	return raw
+}
+
+// ─── Response (non-stream) ─────────────────────────────────────────
+
+// ResponseToOpenAI wraps an Anthropic Messages response in the
+// ChatCompletion shape OpenAI SDKs expect. Text blocks are concatenated
+// into message.content; tool_use blocks become tool_calls; thinking
+// blocks become reasoning_content, the field DeepSeek-style clients read.
+//
+// Usage is reported OpenAI-style: prompt_tokens INCLUDES the Anthropic
+// cache streams (cache reads surface as the cached_tokens subset), so
+// the gateway's OpenAI-shape normalization keeps working unchanged.
+func ResponseToOpenAI(in *AnthropicResponse, model string) *OpenAIResponse {
+	if model == "" {
+		model = in.Model
+	}
+	out := &OpenAIResponse{
+		ID:     "chatcmpl-" + strings.TrimPrefix(in.ID, "msg_"),
+		Object: "chat.completion",
+		Model:  model,
+		Usage:  OpenAIUsageFromAnthropic(in.Usage),
+	}
+	msg := OpenAIMessage{Role: "assistant"}
+	var texts, thoughts []string
+	for _, b := range in.Content {
+		switch b.Type {
+		case "thinking":
+			if b.Thinking != "" {
+				thoughts = append(thoughts, b.Thinking)
+			}
+		case "text":
+			if b.Text != "" {
+				texts = append(texts, b.Text)
+			}
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, OpenAIToolCall{
+				ID:   b.ID,
+				Type: "function",
+				Function: OpenAIFunctionCall{
+					Name: b.Name, Arguments: orDefault(string(b.Input), "{}"),
+				},
+			})
+		}
+	}
+	if len(texts) > 0 {
+		msg.Content = mustMarshalString(strings.Join(texts, ""))
+	} else {
+		msg.Content = json.RawMessage(`null`)
+	}
+	msg.ReasoningContent = strings.Join(thoughts, "\n\n")
+	out.Choices = []OpenAIChoice{{
+		Index:        0,
+		Message:      msg,
+		FinishReason: mapStopReason(in.StopReason),
+	}}
+	return out
+}
+
+// OpenAIUsageFromAnthropic converts Anthropic's additive usage (cache
+// tokens NOT inside input_tokens) into OpenAI's subset shape (cached
+// tokens inside prompt_tokens). Cache-creation tokens are folded into
+// prompt_tokens only; callers that bill cache writes separately read
+// AnthropicUsage directly.
+func OpenAIUsageFromAnthropic(u AnthropicUsage) OpenAIUsage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return OpenAIUsage{
+		PromptTokens:        prompt,
+		CompletionTokens:    u.OutputTokens,
+		TotalTokens:         prompt + u.OutputTokens,
+		PromptTokensDetails: OpenAIPromptTokensDetails{CachedTokens: u.CacheReadInputTokens},
+	}
+}
+
+// mapStopReason is the inverse of mapFinishReason.
+func mapStopReason(reason string) string {
+	switch reason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default: // end_turn, stop_sequence, pause_turn, ""
+		return "stop"
+	}
+}
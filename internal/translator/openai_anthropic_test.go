@@ -0,0 +1,171 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRequestToAnthropic_SystemToolsAndToolResults(t *testing.T) {
+	var req OpenAIRequest
+	if err := json.Unmarshal([]byte(`{
+		"model": "claude-sonnet-4-5",
+		"max_completion_tokens": 512,
+		"stop": "END",
+		"messages": [
+			{"role": "system", "content": "Be terse."},
+			{"role": "user", "content": "Weather in Beijing?"},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Beijing\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "Sunny"},
+			{"role": "user", "content": "Thanks"}
+		],
+		"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type":"object"}}}],
+		"tool_choice": "required"
+	}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	out, err := RequestToAnthropic(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.MaxTokens != 512 {
+		t.Errorf("max_tokens = %d, want 512 from max_completion_tokens", out.MaxTokens)
+	}
+	if string(out.System) != `"Be terse."` {
+		t.Errorf("system = %s", out.System)
+	}
+	if len(out.StopSequences) != 1 || out.StopSequences[0] != "END" {
+		t.Errorf("stop_sequences = %v", out.StopSequences)
+	}
+	// user, assistant(tool_use), user(tool_result + text merged)
+	if len(out.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3 (alternating roles)", len(out.Messages))
+	}
+	asst := string(out.Messages[1].Content)
+	if !strings.Contains(asst, `"type":"tool_use"`) || !strings.Contains(asst, `"city":"Beijing"`) {
+		t.Errorf("assistant tool_use lost: %s", asst)
+	}
+	last := string(out.Messages[2].Content)
+	if !strings.Contains(last, `"type":"tool_result"`) || !strings.Contains(last, `"tool_use_id":"call_1"`) || !strings.Contains(last, "Thanks") {
+		t.Errorf("tool result + follow-up text should merge into one user turn: %s", last)
+	}
+	if len(out.Tools) != 1 || out.Tools[0].Name != "get_weather" {
+		t.Errorf("tools = %+v", out.Tools)
+	}
+	if out.ToolChoice == nil || out.ToolChoice.Type != "any" {
+		t.Errorf("tool_choice = %+v, want any", out.ToolChoice)
+	}
+}
+
+func TestRequestToAnthropic_DefaultsMaxTokensAndMapsImages(t *testing.T) {
+	var req OpenAIRequest
+	_ = json.Unmarshal([]byte(`{"model":"claude-x","messages":[{"role":"user","content":[
+		{"type":"text","text":"what is this"},
+		{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}
+	]}]}`), &req)
+	out, err := RequestToAnthropic(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.MaxTokens != defaultAnthropicMaxTokens {
+		t.Errorf("max_tokens = %d, want default", out.MaxTokens)
+	}
+	body := string(out.Messages[0].Content)
+	if !strings.Contains(body, `"media_type":"image/png"`) || !strings.Contains(body, `"data":"iVBORw0KGgo="`) {
+		t.Errorf("data URI should become a base64 image source: %s", body)
+	}
+}
+
+func TestRequestToAnthropic_RejectsSystemOnly(t *testing.T) {
+	req := &OpenAIRequest{Model: "x", Messages: []OpenAIMessage{{Role: "system", Content: json.RawMessage(`"hi"`)}}}
+	if _, err := RequestToAnthropic(req); err == nil {
+		t.Error("expected error when only system messages are present")
+	}
+}
+
+func TestResponseToOpenAI_TextToolUseAndUsage(t *testing.T) {
+	in := &AnthropicResponse{
+		ID:   "msg_abc",
+		Role: "assistant",
+		Content: []ContentBlock{
+			{Type: "text", Text: "Checking."},
+			{Type: "tool_use", ID: "toolu_1", Name: "get_weather", Input: json.RawMessage(`{"city":"Tokyo"}`)},
+		},
+		StopReason: "tool_use",
+		Usage:      AnthropicUsage{InputTokens: 10, OutputTokens: 5, CacheReadInputTokens: 90},
+	}
+	out := ResponseToOpenAI(in, "claude-sonnet-4-5")
+	if out.ID != "chatcmpl-abc" || out.Object != "chat.completion" {
+		t.Errorf("id/object = %s/%s", out.ID, out.Object)
+	}
+	c := out.Choices[0]
+	if c.FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %s", c.FinishReason)
+	}
+	if string(c.Message.Content) != `"Checking."` {
+		t.Errorf("content = %s", c.Message.Content)
+	}
+	if len(c.Message.ToolCalls) != 1 || c.Message.ToolCalls[0].Function.Arguments != `{"city":"Tokyo"}` {
+		t.Errorf("tool_calls = %+v", c.Message.ToolCalls)
+	}
+	// Anthropic cache reads ADD to input; OpenAI reports them as a subset.
+	if out.Usage.PromptTokens != 100 || out.Usage.PromptTokensDetails.CachedTokens != 90 {
+		t.Errorf("usage = %+v, want prompt 100 with 90 cached", out.Usage)
+	}
+}
+
+func TestOpenAIStreamTranslator_TextAndToolCall(t *testing.T) {
+	upstream := strings.NewReader(
+		"event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-x","content":[],"usage":{"input_tokens":12,"output_tokens":1,"cache_read_input_tokens":4}}}` + "\n\n" +
+			`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}` + "\n\n" +
+			`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+			`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Tokyo\"}"}}` + "\n\n" +
+			`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}` + "\n\n" +
+			`data: {"type":"message_stop"}` + "\n\n",
+	)
+	tr := NewOpenAIStreamTranslator("chatcmpl-1", "claude-x", true)
+	var out bytes.Buffer
+	if err := tr.Run(upstream, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	mustHave(t, got, `"role":"assistant"`)
+	mustHave(t, got, `"content":"Hi"`)
+	mustHave(t, got, `"id":"toolu_1"`)
+	mustHave(t, got, `"name":"get_weather"`)
+	mustHave(t, got, `"index":0,"function":{"arguments":"{\"city\":"}`)
+	mustHave(t, got, `"finish_reason":"tool_calls"`)
+	mustHave(t, got, `"prompt_tokens":16`)
+	mustHave(t, got, `"completion_tokens":9`)
+	if !strings.HasSuffix(got, "data: [DONE]\n\n") {
+		t.Errorf("stream must end with [DONE]: %s", got)
+	}
+	if strings.Contains(got, "event:") {
+		t.Errorf("OpenAI streams carry no event: lines: %s", got)
+	}
+	u := tr.Usage()
+	if u.InputTokens != 12 || u.OutputTokens != 9 || u.CacheReadInputTokens != 4 {
+		t.Errorf("Usage() = %+v", u)
+	}
+}
+
+func TestOpenAIStreamTranslator_ErrorEvent(t *testing.T) {
+	upstream := strings.NewReader(
+		`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n",
+	)
+	tr := NewOpenAIStreamTranslator("chatcmpl-2", "claude-x", false)
+	var out bytes.Buffer
+	if err := tr.Run(upstream, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	mustHave(t, out.String(), `"error":{"message":"Overloaded","type":"overloaded_error"}`)
+	mustHave(t, out.String(), "data: [DONE]")
+}
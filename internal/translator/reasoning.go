@@ -0,0 +1,131 @@
+package translator
+
+import "strings"
+
+// ReasoningStyle names the request field an OpenAI-compatible upstream
+// reads a thinking budget from. There is no common standard: OpenAI's
+// o-series take reasoning_effort, OpenRouter a reasoning object, Qwen
+// enable_thinking + thinking_budget, and DeepSeek-reasoner always
+// thinks and rejects all of them. Sending the wrong one 400s on strict
+// servers, so the style is chosen per upstream.
+type ReasoningStyle string
+
+const (
+	// ReasoningAuto infers the style from the model name: OpenAI
+	// reasoning models get reasoning_effort, everything else nothing.
+	ReasoningAuto       ReasoningStyle = ""
+	ReasoningEffort     ReasoningStyle = "effort"     // reasoning_effort: low | medium | high
+	ReasoningOpenRouter ReasoningStyle = "openrouter" // reasoning: {max_tokens}
+	ReasoningQwen       ReasoningStyle = "qwen"       // enable_thinking + thinking_budget
+	ReasoningOff        ReasoningStyle = "off"        // never send a reasoning parameter
+)
+
+// Budget ↔ effort buckets. Claude Code's "think" / "think hard" /
+// "ultrathink" budgets (4k / 10k / 32k) land in low / medium / high.
+const (
+	lowEffortMaxBudget    = 4096
+	mediumEffortMaxBudget = 16384
+
+	lowEffortBudget    = 4000
+	mediumEffortBudget = 10000
+	highEffortBudget   = 31999
+)
+
+// applyReasoning sets the style-specific reasoning parameter on out for
+// a thinking budget of budget tokens. budget <= 0 means thinking is off
+// and nothing is set.
+func applyReasoning(out *OpenAIRequest, budget int, style ReasoningStyle) {
+	if budget <= 0 {
+		return
+	}
+	if style == ReasoningAuto {
+		style = inferReasoningStyle(out.Model)
+	}
+	switch style {
+	case ReasoningEffort:
+		out.ReasoningEffort = effortForBudget(budget)
+	case ReasoningOpenRouter:
+		out.Reasoning = &OpenAIReasoning{MaxTokens: budget}
+	case ReasoningQwen:
+		on := true
+		out.EnableThinking = &on
+		out.ThinkingBudget = budget
+	}
+}
+
+// inferReasoningStyle recognises OpenAI's reasoning models, with or
+// without a vendor prefix ("openai/o3-mini").
+func inferReasoningStyle(model string) ReasoningStyle {
+	m := strings.ToLower(model)
+	if i := strings.LastIndexByte(m, '/'); i >= 0 {
+		m = m[i+1:]
+	}
+	for _, p := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if m == p || strings.HasPrefix(m, p+"-") {
+			return ReasoningEffort
+		}
+	}
+	return ReasoningOff
+}
+
+func effortForBudget(budget int) string {
+	switch {
+	case budget <= lowEffortMaxBudget:
+		return "low"
+	case budget <= mediumEffortMaxBudget:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// budgetForEffort is the inverse of effortForBudget, used on the
+// reverse path (OpenAI client → Anthropic upstream). Unknown values
+// return 0 (thinking off).
+func budgetForEffort(effort string) int {
+	switch strings.ToLower(effort) {
+	case "minimal", "low":
+		return lowEffortBudget
+	case "medium":
+		return mediumEffortBudget
+	case "high":
+		return highEffortBudget
+	}
+	return 0
+}
+
+// reasoningText returns whichever reasoning field the upstream used.
+func reasoningText(content, alt string) string {
+	if content != "" {
+		return content
+	}
+	return alt
+}
+
+// ReasoningTokens returns the reasoning-token count for a buffered
+// OpenAI response: the upstream's completion_tokens_details figure when
+// reported, otherwise an estimate from the reasoning text (several
+// reasoners stream reasoning_content but omit the breakdown).
+func ReasoningTokens(in *OpenAIResponse) int {
+	if n := in.Usage.CompletionTokensDetails.ReasoningTokens; n > 0 {
+		return n
+	}
+	if len(in.Choices) == 0 {
+		return 0
+	}
+	m := in.Choices[0].Message
+	return estimateReasoningTokens(len(reasoningText(m.ReasoningContent, m.Reasoning)), in.Usage.CompletionTokens)
+}
+
+// estimateReasoningTokens applies the gateway's usual ~4 bytes/token
+// heuristic, capped at the completion total the reasoning is part of.
+func estimateReasoningTokens(textBytes, completionTokens int) int {
+	n := textBytes / 4
+	if textBytes > 0 && n == 0 {
+		n = 1
+	}
+	if completionTokens > 0 && n > completionTokens {
+		n = completionTokens
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func thinkingRequest(model string, budget int) *AnthropicRequest {
+	return &AnthropicRequest{
+		Model:     model,
+		MaxTokens: budget + 4000,
+		Messages:  []AnthropicMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		Thinking:  &AnthropicThinking{Type: "enabled", BudgetTokens: budget},
+	}
+}
+
+func TestRequestToOpenAI_ThinkingBudgetPerStyle(t *testing.T) {
+	cases := []struct {
+		name   string
+		model  string
+		budget int
+		style  ReasoningStyle
+		want   string // substring of the marshalled request; "" = no reasoning field
+	}{
+		{"auto o-series", "o3-mini", 4000, ReasoningAuto, `"reasoning_effort":"low"`},
+		{"auto vendor-prefixed", "openai/gpt-5", 31999, ReasoningAuto, `"reasoning_effort":"high"`},
+		{"auto other model", "deepseek-reasoner", 10000, ReasoningAuto, ""},
+		{"explicit effort", "grok-3-mini", 10000, ReasoningEffort, `"reasoning_effort":"medium"`},
+		{"openrouter", "anthropic/claude-sonnet-4", 8000, ReasoningOpenRouter, `"reasoning":{"max_tokens":8000}`},
+		{"qwen", "qwen3-235b-a22b", 8000, ReasoningQwen, `"enable_thinking":true,"thinking_budget":8000`},
+		{"off", "o3", 8000, ReasoningOff, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := RequestToOpenAIWithOptions(thinkingRequest(tc.model, tc.budget), RequestOptions{Reasoning: tc.style})
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, _ := json.Marshal(out)
+			body := string(raw)
+			if tc.want == "" {
+				for _, k := range []string{"reasoning", "enable_thinking", "thinking_budget"} {
+					if strings.Contains(body, `"`+k) {
+						t.Errorf("no reasoning field expected, got %s", body)
+					}
+				}
+				return
+			}
+			mustHave(t, body, tc.want)
+		})
+	}
+}
+
+func TestRequestToOpenAI_DisabledThinkingSendsNothing(t *testing.T) {
+	req := thinkingRequest("o3", 8000)
+	req.Thinking.Type = "disabled"
+	out, _ := RequestToOpenAI(req)
+	if out.ReasoningEffort != "" {
+		t.Errorf("reasoning_effort = %q, want empty", out.ReasoningEffort)
+	}
+}
+
+func TestResponseToAnthropic_ReasoningBecomesThinkingBlock(t *testing.T) {
+	in := &OpenAIResponse{
+		ID: "chatcmpl-abcdefghijklmnop",
+		Choices: []OpenAIChoice{{
+			Message: OpenAIMessage{
+				Role:             "assistant",
+				Content:          json.RawMessage(`"42"`),
+				ReasoningContent: "Six times seven.",
+			},
+			FinishReason: "stop",
+		}},
+		Usage: OpenAIUsage{PromptTokens: 5, CompletionTokens: 20},
+	}
+	out := ResponseToAnthropic(in, "deepseek-reasoner")
+	if len(out.Content) != 2 || out.Content[0].Type != "thinking" || out.Content[0].Thinking != "Six times seven." {
+		t.Fatalf("content = %+v, want thinking block then text", out.Content)
+	}
+	if out.Content[1].Text != "42" {
+		t.Errorf("text = %q", out.Content[1].Text)
+	}
+	// No breakdown from upstream → estimated from the reasoning text.
+	if n := ReasoningTokens(in); n != len("Six times seven.")/4 {
+		t.Errorf("ReasoningTokens = %d", n)
+	}
+	in.Usage.CompletionTokensDetails.ReasoningTokens = 9
+	if n := ReasoningTokens(in); n != 9 {
+		t.Errorf("reported count should win, got %d", n)
+	}
+}
+
+func TestStreamTranslator_ReasoningContentBecomesThinkingDeltas(t *testing.T) {
+	upstream := `data: {"choices":[{"delta":{"role":"assistant","reasoning_content":"Let me "}}]}
+
+data: {"choices":[{"delta":{"reasoning_content":"think about it."}}]}
+
+data: {"choices":[{"delta":{"content":"Answer."}}]}
+
+data: {"choices":[{"delta":{},"finish_reason":"stop"}]}
+
+data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":50}}
+
+data: [DONE]
+`
+	var buf bytes.Buffer
+	tr := NewStreamTranslator("msg_r", "deepseek-reasoner", 0)
+	if err := tr.Run(strings.NewReader(upstream), &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	mustHave(t, out, `"content_block":{"signature":"","thinking":"","type":"thinking"},"index":0`)
+	mustHave(t, out, `"delta":{"thinking":"Let me ","type":"thinking_delta"}`)
+	mustHave(t, out, `"delta":{"thinking":"think about it.","type":"thinking_delta"}`)
+	mustHave(t, out, `"content_block":{"type":"text"},"index":1`)
+
+	// The thinking block must be closed before the text block opens.
+	stop0 := strings.Index(out, `"index":0,"type":"content_block_stop"`)
+	start1 := strings.Index(out, `"index":1`)
+	if stop0 < 0 || stop0 > start1 {
+		t.Errorf("thinking block not closed before text block:\n%s", out)
+	}
+	if _, reasoning := tr.CacheUsage(); reasoning != len("Let me think about it.")/4 {
+		t.Errorf("estimated reasoning tokens = %d", reasoning)
+	}
+}
+
+func TestRequestToAnthropic_ReasoningEffortEnablesThinking(t *testing.T) {
+	temp := 0.2
+	req := &OpenAIRequest{
+		Model:           "claude-sonnet-4-5",
+		MaxTokens:       1000,
+		Temperature:     &temp,
+		ReasoningEffort: "medium",
+		Messages:        []OpenAIMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	}
+	out, err := RequestToAnthropic(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Thinking == nil || out.Thinking.BudgetTokens != mediumEffortBudget {
+		t.Fatalf("thinking = %+v", out.Thinking)
+	}
+	if out.MaxTokens <= out.Thinking.BudgetTokens {
+		t.Errorf("max_tokens %d must exceed the budget", out.MaxTokens)
+	}
+	if out.Temperature != nil {
+		t.Error("temperature must be dropped when thinking is on")
+	}
+}
+
+func TestOpenAIStreamTranslator_ThinkingDeltaBecomesReasoningContent(t *testing.T) {
+	upstream := strings.NewReader(
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}` + "\n\n" +
+			`data: {"type":"message_stop"}` + "\n\n",
+	)
+	var out bytes.Buffer
+	if err := NewOpenAIStreamTranslator("c", "claude-x", false).Run(upstream, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	mustHave(t, out.String(), `"delta":{"reasoning_content":"hmm"}`)
+}
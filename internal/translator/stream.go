@@ -28,6 +28,9 @@ type StreamTranslator struct {
 	inputTokens      int
 
 	// Per-stream state.
+	thinkingBlockOpen bool
+	thinkingBlockIdx  int
+	reasoningBytes    int // reasoning text streamed, for the token estimate
 	textBlockOpen    bool
 	textBlockIdx     int
 	toolBlocks       map[int]*toolBlockState // OpenAI delta tool_call.index → our content block info
@@ -73,8 +76,15 @@ func (s *StreamTranslator) Usage() (inputTokens, outputTokens int) {
 // upstream's usage chunk omitted the details. Call after Run; the gateway
 // passes these through so cache-read bills at the discounted rate and
 // reasoning shows up on the dashboard without being billed twice.
+//
+// When the upstream streamed reasoning text but reported no breakdown,
+// the reasoning count is estimated from the text (see ReasoningTokens).
 func (s *StreamTranslator) CacheUsage() (cachedTokens, reasoningTokens int) {
-	return s.finalCachedTokens, s.finalReasoningTokens
+	reasoningTokens = s.finalReasoningTokens
+	if reasoningTokens == 0 {
+		reasoningTokens = estimateReasoningTokens(s.reasoningBytes, s.finalOutputTokens)
+	}
+	return s.finalCachedTokens, reasoningTokens
 }
 
 // Run consumes the upstream stream and writes Anthropic SSE events to
@@ -149,6 +159,32 @@ func (s *StreamTranslator) processChunk(chunk *OpenAIStreamChunk, out io.Writer,
 			len(s.toolBlocks) > 0)
 	}
 
+	// Reasoning delta → thinking block. Reasoners emit all reasoning
+	// before the answer, so the block is closed by the first text or
+	// tool-call delta.
+	if r := reasoningText(choice.Delta.ReasoningContent, choice.Delta.Reasoning); r != "" {
+		if !s.thinkingBlockOpen {
+			idx := s.nextBlockIdx
+			s.nextBlockIdx++
+			s.thinkingBlockIdx = idx
+			s.thinkingBlockOpen = true
+			if err := s.writeThinkingBlockStart(out, flush, idx); err != nil {
+				return err
+			}
+		}
+		s.reasoningBytes += len(r)
+		if err := s.writeThinkingDelta(out, flush, s.thinkingBlockIdx, r); err != nil {
+			return err
+		}
+	}
+
+	if (choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0) && s.thinkingBlockOpen {
+		if err := s.writeContentBlockStop(out, flush, s.thinkingBlockIdx); err != nil {
+			return err
+		}
+		s.thinkingBlockOpen = false
+	}
+
 	// Text delta.
 	if choice.Delta.Content != "" {
 		if !s.textBlockOpen {
@@ -206,6 +242,12 @@ func (s *StreamTranslator) processChunk(chunk *OpenAIStreamChunk, out io.Writer,
 }
 
 func (s *StreamTranslator) closeOpenBlocks(out io.Writer, flush func()) error {
+	if s.thinkingBlockOpen {
+		if err := s.writeContentBlockStop(out, flush, s.thinkingBlockIdx); err != nil {
+			return err
+		}
+		s.thinkingBlockOpen = false
+	}
 	if s.textBlockOpen {
 		if err := s.writeContentBlockStop(out, flush, s.textBlockIdx); err != nil {
 			return err
@@ -258,6 +300,25 @@ func (s *StreamTranslator) writeTextDelta(out io.Writer, flush func(), index int
 	})
 }
 
+// writeThinkingBlockStart opens a thinking block. Spelled out rather
+// than via ContentBlock so the empty thinking / signature keys are
+// present, as in a native Anthropic stream.
+func (s *StreamTranslator) writeThinkingBlockStart(out io.Writer, flush func(), index int) error {
+	return writeSSE(out, flush, "content_block_start", map[string]any{
+		"type":          "content_block_start",
+		"index":         index,
+		"content_block": map[string]any{"type": "thinking", "thinking": "", "signature": ""},
+	})
+}
+
+func (s *StreamTranslator) writeThinkingDelta(out io.Writer, flush func(), index int, thinking string) error {
+	return writeSSE(out, flush, "content_block_delta", map[string]any{
+		"type":  "content_block_delta",
+		"index": index,
+		"delta": map[string]any{"type": "thinking_delta", "thinking": thinking},
+	})
+}
+
 func (s *StreamTranslator) writeJSONDelta(out io.Writer, flush func(), index int, partialJSON string) error {
 	return writeSSE(out, flush, "content_block_delta", map[string]any{
 		"type":  "content_block_delta",
@@ -0,0 +1,230 @@
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GeminiStreamTranslator converts an OpenAI SSE stream into a Gemini
+// :streamGenerateContent stream. Each text delta becomes its own
+// GenerateContentResponse chunk; tool-call fragments are buffered and
+// emitted as complete functionCall parts on the final chunk, because
+// Gemini never streams partial function arguments. The final chunk also
+// carries finishReason and usageMetadata.
+//
+// Two framings exist: with ?alt=sse every chunk is a `data:` event
+// (what Gemini CLI asks for); without it the stream is one JSON array
+// written element by element.
+//
+// Usage:
+//
+//	tr := NewGeminiStreamTranslator("resp-id", "gemini-…", sse, includeThoughts)
+//	tr.Run(upstreamReader, geminiWriter, flushFn)
+type GeminiStreamTranslator struct {
+	responseID      string
+	model           string
+	sse             bool
+	includeThoughts bool // forward reasoning deltas as thought parts
+
+	// Per-stream state.
+	wrote          bool // at least one chunk written (JSON-array framing needs the comma)
+	calls          map[int]*geminiCallState
+	callOrder      []int
+	finishReason   string
+	usage          OpenAIUsage
+	sawUsage       bool
+	reasoningBytes int
+}
+
+type geminiCallState struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+func NewGeminiStreamTranslator(responseID, model string, sse, includeThoughts bool) *GeminiStreamTranslator {
+	return &GeminiStreamTranslator{
+		responseID:      responseID,
+		model:           model,
+		sse:             sse,
+		includeThoughts: includeThoughts,
+		calls:           make(map[int]*geminiCallState),
+	}
+}
+
+// Usage returns the upstream's final OpenAI usage chunk captured during
+// Run (zero when the upstream sent none), with the reasoning count
+// estimated from streamed reasoning text when the upstream omitted it.
+// Call after Run returns.
+func (s *GeminiStreamTranslator) Usage() OpenAIUsage {
+	u := s.usage
+	if u.CompletionTokensDetails.ReasoningTokens == 0 {
+		u.CompletionTokensDetails.ReasoningTokens = estimateReasoningTokens(s.reasoningBytes, u.CompletionTokens)
+	}
+	return u
+}
+
+// Run consumes the OpenAI stream and writes Gemini chunks to out. An
+// upstream error payload is relayed as a Gemini error object and ends
+// the stream.
+func (s *GeminiStreamTranslator) Run(upstream io.Reader, out io.Writer, flush func()) error {
+	if !s.sse {
+		if _, err := io.WriteString(out, "["); err != nil {
+			return err
+		}
+	}
+
+	scanner := bufio.NewScanner(upstream)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			OpenAIStreamChunk
+			Error *OpenAIErrorBody `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != nil {
+			if err := s.write(out, flush, GeminiError{Error: GeminiErrorBody{
+				Code: 500, Message: chunk.Error.Message, Status: "INTERNAL",
+			}}); err != nil {
+				return err
+			}
+			return s.close(out, flush)
+		}
+		if err := s.processChunk(&chunk.OpenAIStreamChunk, out, flush); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("scan upstream: %w", err)
+	}
+
+	if err := s.writeFinal(out, flush); err != nil {
+		return err
+	}
+	return s.close(out, flush)
+}
+
+func (s *GeminiStreamTranslator) processChunk(chunk *OpenAIStreamChunk, out io.Writer, flush func()) error {
+	if chunk.Usage != nil {
+		s.usage = *chunk.Usage
+		s.sawUsage = true
+	}
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = mapFinishReasonToGemini(*choice.FinishReason)
+	}
+	for _, tc := range choice.Delta.ToolCalls {
+		state, ok := s.calls[tc.Index]
+		if !ok {
+			state = &geminiCallState{id: tc.ID}
+			s.calls[tc.Index] = state
+			s.callOrder = append(s.callOrder, tc.Index)
+		}
+		if tc.Function != nil {
+			if tc.Function.Name != "" {
+				state.name = tc.Function.Name
+			}
+			state.args.WriteString(tc.Function.Arguments)
+		}
+	}
+	if r := reasoningText(choice.Delta.ReasoningContent, choice.Delta.Reasoning); r != "" {
+		s.reasoningBytes += len(r)
+		if s.includeThoughts {
+			if err := s.write(out, flush, s.chunk([]GeminiPart{{Text: r, Thought: true}}, "", nil)); err != nil {
+				return err
+			}
+		}
+	}
+	if choice.Delta.Content == "" {
+		return nil
+	}
+	return s.write(out, flush, s.chunk([]GeminiPart{{Text: choice.Delta.Content}}, "", nil))
+}
+
+// writeFinal emits the buffered function calls with finishReason and
+// usageMetadata.
+func (s *GeminiStreamTranslator) writeFinal(out io.Writer, flush func()) error {
+	parts := []GeminiPart{}
+	for _, idx := range s.callOrder {
+		c := s.calls[idx]
+		parts = append(parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+			ID: c.id, Name: c.name, Args: geminiArgs(c.args.String()),
+		}})
+	}
+	reason := s.finishReason
+	if reason == "" {
+		reason = "STOP"
+	}
+	var usage *GeminiUsageMetadata
+	if s.sawUsage {
+		u := GeminiUsageFromOpenAI(s.Usage())
+		usage = &u
+	}
+	return s.write(out, flush, s.chunk(parts, reason, usage))
+}
+
+func (s *GeminiStreamTranslator) chunk(parts []GeminiPart, finishReason string, usage *GeminiUsageMetadata) GeminiResponse {
+	return GeminiResponse{
+		Candidates: []GeminiCandidate{{
+			Content:      GeminiContent{Role: "model", Parts: parts},
+			FinishReason: finishReason,
+		}},
+		UsageMetadata: usage,
+		ModelVersion:  s.model,
+		ResponseID:    s.responseID,
+	}
+}
+
+// write frames one element for the chosen transport, then flushes.
+func (s *GeminiStreamTranslator) write(out io.Writer, flush func(), body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.sse:
+		_, err = fmt.Fprintf(out, "data: %s\r\n\r\n", data)
+	case s.wrote:
+		_, err = fmt.Fprintf(out, ",\r\n%s", data)
+	default:
+		_, err = out.Write(data)
+	}
+	if err != nil {
+		return err
+	}
+	s.wrote = true
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// close terminates the JSON-array framing; SSE needs no trailer.
+func (s *GeminiStreamTranslator) close(out io.Writer, flush func()) error {
+	if s.sse {
+		return nil
+	}
+	if _, err := io.WriteString(out, "]"); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OpenAIStreamTranslator converts an Anthropic SSE stream into an
+// OpenAI chat.completion.chunk stream — the mirror image of
+// StreamTranslator. Anthropic's named events carry explicit block
+// boundaries, so the state we keep is small: which content blocks are
+// tool calls (and their OpenAI tool_calls index), plus the usage
+// counters spread across message_start and message_delta.
+//
+// Usage:
+//
+//	tr := NewOpenAIStreamTranslator("chatcmpl-…", "claude-…", includeUsage)
+//	tr.Run(upstreamReader, openAIWriter, flushFn)
+type OpenAIStreamTranslator struct {
+	id           string
+	model        string
+	created      int64
+	includeUsage bool
+
+	// Per-stream state.
+	roleSent     bool
+	toolIdx      map[int]int // Anthropic content block index → OpenAI tool_calls index
+	nextToolIdx  int
+	finishReason string
+	usage        AnthropicUsage
+}
+
+// NewOpenAIStreamTranslator builds a translator. includeUsage mirrors
+// the client's stream_options.include_usage: when set, a final chunk
+// with empty choices and the usage totals is emitted before [DONE].
+func NewOpenAIStreamTranslator(id, model string, includeUsage bool) *OpenAIStreamTranslator {
+	return &OpenAIStreamTranslator{
+		id:           id,
+		model:        model,
+		created:      time.Now().Unix(),
+		includeUsage: includeUsage,
+		toolIdx:      make(map[int]int),
+	}
+}
+
+// Usage returns the upstream's Anthropic usage captured during Run
+// (input / cache tokens from message_start, output from message_delta).
+// Call after Run returns.
+func (s *OpenAIStreamTranslator) Usage() AnthropicUsage {
+	return s.usage
+}
+
+// Run consumes the Anthropic stream and writes OpenAI SSE chunks to
+// out, terminated by `data: [DONE]`. An upstream `error` event is
+// relayed as an OpenAI error payload and ends the stream.
+func (s *OpenAIStreamTranslator) Run(upstream io.Reader, out io.Writer, flush func()) error {
+	scanner := bufio.NewScanner(upstream)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue // "event:" lines are redundant with the payload's type
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" {
+			continue
+		}
+		var ev AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+			continue
+		}
+		done, err := s.processEvent(&ev, out, flush)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("scan upstream: %w", err)
+	}
+	return s.finish(out, flush)
+}
+
+func (s *OpenAIStreamTranslator) processEvent(ev *AnthropicStreamEvent, out io.Writer, flush func()) (done bool, err error) {
+	switch ev.Type {
+	case "message_start":
+		if ev.Message != nil {
+			s.usage.InputTokens = ev.Message.Usage.InputTokens
+			s.usage.CacheReadInputTokens = ev.Message.Usage.CacheReadInputTokens
+			s.usage.CacheCreationInputTokens = ev.Message.Usage.CacheCreationInputTokens
+		}
+		return false, s.ensureRole(out, flush)
+
+	case "content_block_start":
+		if ev.ContentBlock == nil || ev.ContentBlock.Type != "tool_use" {
+			return false, nil
+		}
+		idx := s.nextToolIdx
+		s.nextToolIdx++
+		s.toolIdx[ev.Index] = idx
+		return false, s.writeDelta(out, flush, OpenAIDelta{ToolCalls: []OpenAIDeltaToolCall{{
+			Index:    idx,
+			ID:       ev.ContentBlock.ID,
+			Type:     "function",
+			Function: &OpenAIDeltaFunction{Name: ev.ContentBlock.Name},
+		}}})
+
+	case "content_block_delta":
+		if ev.Delta == nil {
+			return false, nil
+		}
+		switch ev.Delta.Type {
+		case "text_delta":
+			if ev.Delta.Text == "" {
+				return false, nil
+			}
+			return false, s.writeDelta(out, flush, OpenAIDelta{Content: ev.Delta.Text})
+		case "thinking_delta":
+			if ev.Delta.Thinking == "" {
+				return false, nil
+			}
+			return false, s.writeDelta(out, flush, OpenAIDelta{ReasoningContent: ev.Delta.Thinking})
+		case "input_json_delta":
+			idx, ok := s.toolIdx[ev.Index]
+			if !ok || ev.Delta.PartialJSON == "" {
+				return false, nil
+			}
+			return false, s.writeDelta(out, flush, OpenAIDelta{ToolCalls: []OpenAIDeltaToolCall{{
+				Index:    idx,
+				Function: &OpenAIDeltaFunction{Arguments: ev.Delta.PartialJSON},
+			}}})
+		}
+		return false, nil
+
+	case "message_delta":
+		if ev.Delta != nil && ev.Delta.StopReason != "" {
+			s.finishReason = mapStopReason(ev.Delta.StopReason)
+		}
+		if ev.Usage != nil {
+			s.usage.OutputTokens = ev.Usage.OutputTokens
+			// Some relays only report input on the final delta.
+			if ev.Usage.InputTokens > 0 {
+				s.usage.InputTokens = ev.Usage.InputTokens
+			}
+		}
+		return false, nil
+
+	case "message_stop":
+		return true, s.finish(out, flush)
+
+	case "error":
+		msg := "upstream stream error"
+		errType := "api_error"
+		if ev.Error != nil {
+			msg, errType = ev.Error.Message, ev.Error.Type
+		}
+		if err := writeOpenAIData(out, flush, OpenAIError{Error: OpenAIErrorBody{Message: msg, Type: errType}}); err != nil {
+			return true, err
+		}
+		return true, writeOpenAIDone(out, flush)
+	}
+	return false, nil
+}
+
+// finish emits the finish_reason chunk, the optional usage chunk and
+// the [DONE] sentinel.
+func (s *OpenAIStreamTranslator) finish(out io.Writer, flush func()) error {
+	if err := s.ensureRole(out, flush); err != nil {
+		return err
+	}
+	reason := s.finishReason
+	if reason == "" {
+		reason = "stop"
+	}
+	if err := writeOpenAIData(out, flush, s.chunk([]OpenAIStreamChoice{{
+		Index: 0, Delta: OpenAIDelta{}, FinishReason: &reason,
+	}}, nil)); err != nil {
+		return err
+	}
+	if s.includeUsage {
+		u := OpenAIUsageFromAnthropic(s.usage)
+		if err := writeOpenAIData(out, flush, s.chunk([]OpenAIStreamChoice{}, &u)); err != nil {
+			return err
+		}
+	}
+	return writeOpenAIDone(out, flush)
+}
+
+// ensureRole emits the opening `delta.role=assistant` chunk once.
+func (s *OpenAIStreamTranslator) ensureRole(out io.Writer, flush func()) error {
+	if s.roleSent {
+		return nil
+	}
+	s.roleSent = true
+	return s.writeDelta(out, flush, OpenAIDelta{Role: "assistant"})
+}
+
+func (s *OpenAIStreamTranslator) writeDelta(out io.Writer, flush func(), d OpenAIDelta) error {
+	if d.Role == "" {
+		if err := s.ensureRole(out, flush); err != nil {
+			return err
+		}
+	}
+	return writeOpenAIData(out, flush, s.chunk([]OpenAIStreamChoice{{Index: 0, Delta: d}}, nil))
+}
+
+func (s *OpenAIStreamTranslator) chunk(choices []OpenAIStreamChoice, usage *OpenAIUsage) OpenAIStreamChunk {
+	return OpenAIStreamChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: choices,
+		Usage:   usage,
+	}
+}
+
+// writeOpenAIData emits one unnamed SSE `data:` frame, then flushes.
+// OpenAI streams carry no `event:` lines.
+func writeOpenAIData(out io.Writer, flush func(), body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+func writeOpenAIDone(out io.Writer, flush func()) error {
+	if _, err := io.WriteString(out, "data: [DONE]\n\n"); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
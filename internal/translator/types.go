@@ -4,6 +4,13 @@
 // OpenAI-compatible upstream — DeepSeek, Groq, OpenRouter, Ollama,
 // the user's home server, anything.
 //
+// The reverse direction (openai_anthropic.go, stream_openai.go) lets
+// Codex, Aider and other OpenAI-protocol tools target a relay that only
+// speaks the Anthropic Messages API.
+//
+// gemini_openai.go / stream_gemini.go do the same for Google's
+// generateContent protocol, so Gemini CLI can reach OpenAI upstreams.
+//
 // Scope of this MVP:
 //   - Text + tool_use / tool_result content blocks
 //   - System messages (string and array)
@@ -11,17 +18,24 @@
 //   - Standard params: model / max_tokens / temperature / top_p /
 //     stop_sequences / tools / tool_choice
 //
+// Image / document content blocks (multimodal) are forwarded as OpenAI
+// image_url / file content parts only when the caller opts in via
+// RequestOptions.ForwardMedia — the gateway sets it per upstream from
+// the relay endpoint's vision capability. Otherwise the bytes aren't
+// forwarded (the upstream may be text-only), but we don't drop them
+// silently either: a stub text note marks where non-text content was
+// so the model isn't left answering as if nothing was attached.
+//
 // Out of scope (fall through unmodified or rejected with a clear
 // error so we don't pretend we handled them):
-//   - Image / document content blocks (multimodal) — the bytes aren't
-//     forwarded (the upstream may be text-only), but we no longer drop
-//     them silently: a stub text note marks where non-text content was
-//     so the model isn't left answering as if nothing was attached.
 //   - Prompt caching cache_control hints — we strip them; upstream
 //     is unlikely to honor Anthropic-specific hints anyway.
 //   - Server tools (web_search, computer_use, code_execution).
-//   - Extended thinking blocks — left in passthrough; downstream
-//     OpenAI servers will ignore the unknown content type field.
+//   - Signed thinking history. The request's thinking budget maps to
+//     the upstream's reasoning parameter (see ReasoningStyle) and
+//     upstream reasoning text comes back as thinking blocks, but prior
+//     thinking blocks in the conversation are not replayed upstream —
+//     OpenAI-compatible servers either ignore them or 400 on them.
 package translator
 
 import "encoding/json"
@@ -44,6 +58,14 @@ type AnthropicRequest struct {
 	StopSequences []string           `json:"stop_sequences,omitempty"`
 	Stream        bool               `json:"stream,omitempty"`
 	Metadata      json.RawMessage    `json:"metadata,omitempty"`
+	Thinking      *AnthropicThinking `json:"thinking,omitempty"`
+}
+
+// AnthropicThinking is the extended-thinking config. Type is "enabled"
+// (BudgetTokens set) or "disabled".
+type AnthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
 }
 
 // AnthropicMessage role is "user" or "assistant". Content is either a
@@ -74,18 +96,29 @@ type ContentBlock struct {
 	// we keep it raw and let the consumer flatten.
 	Content json.RawMessage `json:"content,omitempty"`
 
-	// type=image | document. We don't forward the bytes (the upstream may
-	// not be vision-capable), but we read media_type so we can emit a
-	// human-readable stub note instead of silently dropping the block.
+	// type=image | document. Forwarded as an OpenAI content part when the
+	// upstream is vision-capable (RequestOptions.ForwardMedia); otherwise
+	// we read media_type so we can emit a human-readable stub note instead
+	// of silently dropping the block.
 	Source *ContentBlockSource `json:"source,omitempty"`
+	// Title is the optional display name of a document block. Used as the
+	// filename of the forwarded OpenAI file part.
+	Title string `json:"title,omitempty"`
+
+	// type=thinking. Signature is only ever set by a real Anthropic
+	// upstream; blocks we synthesize from OpenAI reasoning text have none.
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 // ContentBlockSource is the `source` object of an image / document block.
-// Only the fields needed for a stub note are typed; the base64 payload
-// (data / url) is intentionally ignored — see userBlocksToOpenAI.
+// Data carries the base64 payload (type=base64) or the raw text of a
+// plain-text document (type=text); URL is set for type=url.
 type ContentBlockSource struct {
-	Type      string `json:"type"`                 // "base64" | "url"
+	Type      string `json:"type"`                 // "base64" | "url" | "text"
 	MediaType string `json:"media_type,omitempty"` // e.g. "image/png"
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type AnthropicTool struct {
@@ -148,28 +181,96 @@ type AnthropicErrorBody struct {
 // ─── OpenAI schema ────────────────────────────────────────────────
 
 type OpenAIRequest struct {
-	Model            string          `json:"model"`
-	Messages         []OpenAIMessage `json:"messages"`
-	Tools            []OpenAITool    `json:"tools,omitempty"`
-	ToolChoice       json.RawMessage `json:"tool_choice,omitempty"` // string OR object
-	Temperature      *float64        `json:"temperature,omitempty"`
-	TopP             *float64        `json:"top_p,omitempty"`
-	MaxTokens        int             `json:"max_tokens,omitempty"`
-	Stop             []string        `json:"stop,omitempty"`
-	Stream           bool            `json:"stream,omitempty"`
-	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`
+	Model       string          `json:"model"`
+	Messages    []OpenAIMessage `json:"messages"`
+	Tools       []OpenAITool    `json:"tools,omitempty"`
+	ToolChoice  json.RawMessage `json:"tool_choice,omitempty"` // string OR object
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	// MaxCompletionTokens is the newer spelling of max_tokens that
+	// o-series-era clients send. Only read on the reverse path.
+	MaxCompletionTokens int            `json:"max_completion_tokens,omitempty"`
+	Stop                StopSequences  `json:"stop,omitempty"`
+	Stream              bool           `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions `json:"stream_options,omitempty"`
+
+	// Reasoning controls — each upstream family reads a different one,
+	// so at most one is set (see ReasoningStyle).
+	ReasoningEffort string           `json:"reasoning_effort,omitempty"` // low | medium | high
+	Reasoning       *OpenAIReasoning `json:"reasoning,omitempty"`        // OpenRouter
+	EnableThinking  *bool            `json:"enable_thinking,omitempty"`  // Qwen / DashScope
+	ThinkingBudget  int              `json:"thinking_budget,omitempty"`  // Qwen / DashScope
+}
+
+// OpenAIReasoning is OpenRouter's unified reasoning object.
+type OpenAIReasoning struct {
+	Effort    string `json:"effort,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
 }
 
 type StreamOptions struct {
 	IncludeUsage bool `json:"include_usage"`
 }
 
+// StopSequences accepts OpenAI's `stop` in either of its shapes — a
+// single string or an array of strings — and always marshals as an
+// array.
+type StopSequences []string
+
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		if one == "" {
+			*s = nil
+		} else {
+			*s = StopSequences{one}
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
 type OpenAIMessage struct {
 	Role       string         `json:"role"` // system | user | assistant | tool
 	Content    json.RawMessage `json:"content,omitempty"` // string or null
 	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string         `json:"tool_call_id,omitempty"` // role=tool
 	Name       string         `json:"name,omitempty"`
+
+	// ReasoningContent is the reasoning text DeepSeek, Qwen and most
+	// OpenAI-compatible reasoners return; OpenRouter calls it Reasoning.
+	// Response-only.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+}
+
+// OpenAIContentPart is one element of an array-shaped message content.
+// Only user messages carry arrays — we emit them when forwarding image /
+// document blocks; text-only messages keep the plain-string form.
+type OpenAIContentPart struct {
+	Type     string          `json:"type"` // text | image_url | file
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+	File     *OpenAIFile     `json:"file,omitempty"`
+}
+
+// OpenAIImageURL is an image reference: either an http(s) URL or an
+// inline data: URI carrying the base64 payload.
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// OpenAIFile is an inline file attachment (PDF etc). FileData is a
+// data: URI; OpenAI's file part has no remote-URL form.
+type OpenAIFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
 }
 
 type OpenAIToolCall struct {
@@ -260,6 +361,10 @@ type OpenAIDelta struct {
 	Role      string                  `json:"role,omitempty"`
 	Content   string                  `json:"content,omitempty"`
 	ToolCalls []OpenAIDeltaToolCall   `json:"tool_calls,omitempty"`
+
+	// Streaming counterparts of OpenAIMessage.ReasoningContent / Reasoning.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
 }
 
 type OpenAIDeltaToolCall struct {
@@ -273,3 +378,180 @@ type OpenAIDeltaFunction struct {
 	Name      string `json:"name,omitempty"`
 	Arguments string `json:"arguments,omitempty"`
 }
+
+// OpenAIError is the error envelope OpenAI SDKs parse.
+type OpenAIError struct {
+	Error OpenAIErrorBody `json:"error"`
+}
+
+type OpenAIErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+// ─── Anthropic stream schema (reverse path) ───────────────────────
+
+// AnthropicStreamEvent is one `data:` payload of an Anthropic SSE
+// stream. Which fields are set depends on Type:
+//
+//	message_start       → Message
+//	content_block_start → Index, ContentBlock
+//	content_block_delta → Index, Delta (text_delta | input_json_delta | thinking_delta)
+//	content_block_stop  → Index
+//	message_delta       → Delta (stop_reason), Usage (output_tokens)
+//	error               → Error
+
+type AnthropicStreamEvent struct {
+	Type         string              `json:"type"`
+	Message      *AnthropicResponse  `json:"message,omitempty"`
+	Index        int                 `json:"index"`
+	ContentBlock *ContentBlock       `json:"content_block,omitempty"`
+	Delta        *AnthropicDelta     `json:"delta,omitempty"`
+	Usage        *AnthropicUsage     `json:"usage,omitempty"`
+	Error        *AnthropicErrorBody `json:"error,omitempty"`
+}
+
+// AnthropicDelta is the `delta` object of content_block_delta and
+// message_delta events.
+type AnthropicDelta struct {
+	Type         string `json:"type,omitempty"`
+	Text         string `json:"text,omitempty"`
+	PartialJSON  string `json:"partial_json,omitempty"`
+	Thinking     string `json:"thinking,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
+}
+
+// ─── Gemini schema ────────────────────────────────────────────────
+
+// GeminiRequest is the inbound POST v1beta/models/{model}:generateContent
+// (or :streamGenerateContent) body. The model lives in the URL path, not
+// the body. Only the fields we translate are typed; safetySettings,
+// cachedContent and friends have no OpenAI analog and are ignored.
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is one conversation turn. Role is "user" or "model";
+// older clients send function responses under role "function".
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a union: exactly one of the payload fields is set.
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"` // text is model reasoning, not answer
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall is a model-issued tool call. ID is optional in the
+// Gemini API (most clients match responses by name); we fill it from
+// the OpenAI tool_call id so round-trips stay unambiguous.
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type GeminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// GeminiFunctionDeclaration carries its schema either as Parameters
+// (Gemini's OpenAPI subset, upper-case type names) or, from newer
+// clients, as ParametersJSONSchema (plain JSON Schema).
+type GeminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	Parameters           json.RawMessage `json:"parameters,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"` // AUTO | ANY | NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature     *float64              `json:"temperature,omitempty"`
+	TopP            *float64              `json:"topP,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string              `json:"stopSequences,omitempty"`
+	ThinkingConfig  *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// GeminiThinkingConfig: ThinkingBudget 0 disables thinking, -1 lets the
+// model decide. IncludeThoughts asks for reasoning text as thought parts.
+type GeminiThinkingConfig struct {
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+// GeminiResponse is a GenerateContentResponse — the full body for
+// :generateContent and each chunk of :streamGenerateContent.
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion,omitempty"`
+	ResponseID    string               `json:"responseId,omitempty"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"` // STOP | MAX_TOKENS | SAFETY | …
+	Index        int           `json:"index"`
+}
+
+// GeminiUsageMetadata mirrors Gemini's accounting. Like OpenAI,
+// CachedContentTokenCount is a SUBSET of PromptTokenCount. Unlike
+// OpenAI, CandidatesTokenCount EXCLUDES ThoughtsTokenCount; the total
+// is prompt + candidates + thoughts.
+type GeminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+}
+
+// GeminiError is Google's error envelope:
+// {"error":{"code":400,"message":"…","status":"INVALID_ARGUMENT"}}.
+type GeminiError struct {
+	Error GeminiErrorBody `json:"error"`
+}
+
+type GeminiErrorBody struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
@@ -7,7 +7,6 @@ import (
 
 	"lurus-switch/internal/agent"
 	"lurus-switch/internal/analytics"
-	"lurus-switch/internal/appconfig"
 	"lurus-switch/internal/appreg"
 	"lurus-switch/internal/audit"
 	"lurus-switch/internal/auth"
@@ -20,12 +19,12 @@ import (
 	"lurus-switch/internal/docmgr"
 	"lurus-switch/internal/envmgr"
 	"lurus-switch/internal/gateway"
+	"lurus-switch/internal/gatewayhost"
 	"lurus-switch/internal/installer"
 	"lurus-switch/internal/mcp"
 	"lurus-switch/internal/metering"
 	"lurus-switch/internal/modelcatalog"
 	"lurus-switch/internal/netproxy"
-	"lurus-switch/internal/obs"
 	"lurus-switch/internal/orgsync"
 	"lurus-switch/internal/process"
 	"lurus-switch/internal/promoter"
@@ -90,6 +89,10 @@ type services struct {
 	// observability is enabled. nil when disabled; called from App.shutdown.
 	obsShutdown func(context.Context) error
 
+	// gatewayHost owns the secrets vault the stores seal their keys
+	// into; the bindings unlock and rotate it through here.
+	gatewayHost *gatewayhost.Host
+
 	// Relay router with per-endpoint circuit breaker. Driven by user
 	// rules in relay-rules.yaml; updated on every upstream attempt by
 	// the gateway's FallbackChain observer.
@@ -180,20 +183,10 @@ func newServices(appDataDir, version string) (*services, []string) {
 		warnings = append(warnings, fmt.Sprintf("analytics tracker: %v", err))
 	}
 
-	relayStr, err := relay.NewStore(appDataDir)
-	if err != nil {
-		warnings = append(warnings, fmt.Sprintf("relay store: %v", err))
-	}
-
-	appReg, err := appreg.NewRegistry(appDataDir)
-	if err != nil {
-		warnings = append(warnings, fmt.Sprintf("app registry: %v", err))
-	}
-
-	meterStr, err := metering.NewStore(appDataDir)
-	if err != nil {
-		warnings = append(warnings, fmt.Sprintf("metering store: %v", err))
-	}
+	// The gateway and everything it routes, caps and records through;
+	// shared with the headless daemon.
+	host, hostWarnings := gatewayhost.Open(appDataDir, version)
+	warnings = append(warnings, hostWarnings...)
 
 	// Open SQLite database for agent fleet management.
 	database, err := db.Open(appDataDir)
@@ -216,11 +209,6 @@ func newServices(appDataDir, version string) (*services, []string) {
 		warnings = append(warnings, fmt.Sprintf("redemption store: %v", err))
 	}
 
-	auditJ, aerr := audit.NewJournal(appDataDir)
-	if aerr != nil {
-		warnings = append(warnings, fmt.Sprintf("audit journal: %v", aerr))
-	}
-
 	convIdx, cerr := conversation.NewIndex(appDataDir)
 	if cerr != nil {
 		warnings = append(warnings, fmt.Sprintf("conversation index: %v", cerr))
@@ -247,10 +235,13 @@ func newServices(appDataDir, version string) (*services, []string) {
 		envMgr:         envmgr.NewManager(),
 		tracker:        tracker,
 		serverMgr:      serverctl.NewManager(appDataDir),
-		relayStore:     relayStr,
+		relayStore:     host.RelayStore,
 		catalogMgr:     modelcatalog.NewManager(appDataDir),
-		appRegistry:    appReg,
-		meterStore:     meterStr,
+		appRegistry:    host.Registry,
+		meterStore:     host.Meter,
+		gatewaySrv:     host.Gateway,
+		budgetGuard:    host.Guard,
+		obsShutdown:    host.ObsShutdown,
 		database:       database,
 		agentStore:     agentStr,
 		agentConfigMgr: agentCfgMgr,
@@ -260,160 +251,41 @@ func newServices(appDataDir, version string) (*services, []string) {
 			}
 			return nil
 		}(),
-		redemptionStore:   redemptionStr,
-		redeemer:          redemption.NewRedeemer(version),
-		auditJournal:      auditJ,
-		dlpScanner:        dlp.NewScanner(),
-		conversationIndex: convIdx,
-		relayRouter: func() *relay.Router {
-			if relayStr == nil {
-				return nil
-			}
-			r, err := relay.NewRouter(appDataDir, relayStr, relay.NewCircuitBreaker())
-			if err != nil {
-				warnings = append(warnings, fmt.Sprintf("relay router: %v", err))
-				return r
-			}
-			return r
-		}(),
+		redemptionStore:     redemptionStr,
+		redeemer:            redemption.NewRedeemer(version),
+		auditJournal:        host.Journal,
+		dlpScanner:          host.DLP,
+		conversationIndex:   convIdx,
+		relayRouter:         host.Router,
+		gatewayHost:         host,
 		customProviderStore: customProvStr,
 		catalogTester:       modelcatalog.NewTester(),
 	}
-	// Gateway depends on appRegistry and meterStore, so create after the struct.
-	if appReg != nil && meterStr != nil {
-		svc.gatewaySrv = gateway.NewServer(appDataDir, appReg, meterStr)
-
-		// One-shot migration: pre-W4.2 cfg.Fallbacks → relay store user
-		// endpoints. Idempotent — Migrate returns (0, nil) when any user
-		// endpoint already exists, so reboots and re-runs are safe. After
-		// a successful migration we persist an empty Fallbacks slice so
-		// the next save drops the legacy field thanks to omitempty.
-		if relayStr != nil {
-			gwCfg := svc.gatewaySrv.GetConfig()
-			if len(gwCfg.Fallbacks) > 0 {
-				legacy := make([]relay.LegacyFallback, 0, len(gwCfg.Fallbacks))
-				for _, f := range gwCfg.Fallbacks {
-					legacy = append(legacy, relay.LegacyFallback{
-						Name: f.Name, URL: f.URL, Token: f.Token,
-					})
-				}
-				if migrated, mErr := relayStr.MigrateLegacyFallbacks(legacy); mErr != nil {
-					warnings = append(warnings, fmt.Sprintf("migrate gateway fallbacks: %v", mErr))
-				} else if migrated > 0 {
-					gwCfg.Fallbacks = nil
-					if sErr := svc.gatewaySrv.SaveConfig(gwCfg); sErr != nil {
-						warnings = append(warnings, fmt.Sprintf("clear migrated fallbacks: %v", sErr))
-					}
-				}
-			}
-		}
-
-		// Active Budget Wall — persisted config alongside other gateway
-		// state. The guard delegates "today's tokens" to meterStore so
-		// daily limits track all traffic, not just this process's session.
-		guard, gErr := budget.New(
-			fmt.Sprintf("%s/budget.json", appDataDir),
-			func() metering.DailySummary { return meterStr.TodaySummary() },
-		)
-		if gErr == nil {
-			svc.budgetGuard = guard
-			svc.gatewaySrv.SetBudgetGuard(guard)
-		} else {
-			warnings = append(warnings, fmt.Sprintf("budget guard init failed: %v", gErr))
-		}
-		// Wire the relay router so the gateway's fallback observer
-		// records circuit transitions per endpoint. Endpoint *names*
-		// from the FallbackChain map to RelayEndpoint IDs via the
-		// shared display label — we resolve via the relay store.
-		if svc.relayRouter != nil {
-			svc.gatewaySrv.SetRelayRouter(svc.relayRouter)
-			breaker := svc.relayRouter.Breaker()
-			relayStore := svc.relayStore
-			svc.gatewaySrv.GetFallbackChain().SetObserver(func(name string, ok bool, errMsg string, latencyMs int64) {
-				id := resolveEndpointIDByName(relayStore, name)
-				if id == "" {
-					return
-				}
-				if ok {
-					breaker.RecordSuccess(id)
-					// Feed measured latency back into the relay store so
-					// Pick()'s ascending-latency sort reflects live
-					// traffic, not just the last manual health check.
-					if latencyMs > 0 {
-						_ = relayStore.UpdateEndpointLatency(id, latencyMs)
-					}
-				} else {
-					breaker.RecordFailure(id, errMsg)
-				}
-			})
-		}
-
-		// Wire the DLP scanner into the gateway so request bodies are
-		// scrubbed before they reach the upstream. The same Scanner is
-		// also exposed via the bindings_dlp.go admin surface, so policy
-		// changes made in the UI immediately apply to live traffic.
-		svc.gatewaySrv.SetDLPScanner(svc.dlpScanner)
-		// Wire the audit journal so every block / redact event lands
-		// in the durable journal alongside Wails-binding mutations.
-		// Captured by reference so a later auditJ rebuild flows through.
-		journal := svc.auditJournal
-		if journal != nil {
-			svc.gatewaySrv.SetDLPAuditFn(func(op, target string, payload any, metadata map[string]string) {
-				entry := journal.RecordSystem("gateway", op, target, nil, payload, nil)
-				// Stamp the conversation-correlation metadata onto the
-				// freshly-written entry. RecordSystem returns a copy, so
-				// we re-attach via the journal's metadata helper which
-				// mutates the hot ring in place.
-				if len(metadata) > 0 {
-					journal.AttachMetadata(entry.ID, metadata)
-				}
-			})
-		}
-
-		// Optional OpenTelemetry export of gateway GenAI traffic. Default
-		// off — only wired when the user enables it and gives an OTLP
-		// endpoint. The gateway depends on the obs.Recorder interface, not
-		// otel; obs.New connects lazily so an unreachable collector never
-		// blocks startup. The shutdown func is held for App.shutdown.
-		if settings, sErr := appconfig.LoadAppSettings(); sErr == nil &&
-			settings.Observability.Enabled && settings.Observability.Endpoint != "" {
-			rec, shutdown, oErr := obs.New(obs.Config{
-				ServiceName:    "lurus-switch",
-				ServiceVersion: version,
-				Endpoint:       settings.Observability.Endpoint,
-				Headers:        settings.Observability.Headers,
-			})
-			if oErr != nil {
-				warnings = append(warnings, fmt.Sprintf("observability init failed: %v", oErr))
-			} else {
-				svc.gatewaySrv.SetObserver(rec)
-				svc.obsShutdown = shutdown
-			}
-		}
-	}
 	svc.promoterSvc = promoter.NewService(svc.ensureBillingClient)
+	warnings = append(warnings, svc.migrateSecrets()...)
 	return svc, warnings
 }
 
-// resolveEndpointIDByName looks up a RelayEndpoint by its display name —
-// the FallbackChain observer reports by name because FallbackEntry.Name
-// is the only identifier it carries. "primary" comes back as the
-// empty string (no recorded transition) since the primary entry isn't a
-// RelayEndpoint at gateway level.
-func resolveEndpointIDByName(s *relay.Store, name string) string {
-	if s == nil || name == "" || name == "primary" {
-		return ""
+// migrateSecrets moves the desktop-only stores' plaintext credentials —
+// custom provider keys and MCP preset env values — into the secrets
+// vault; gatewayhost migrates the gateway's own. A no-op while the vault
+// is missing or locked.
+func (s *services) migrateSecrets() []string {
+	if s.gatewayHost == nil || s.gatewayHost.Vault == nil || s.gatewayHost.Vault.Status().Locked {
+		return nil
 	}
-	eps, err := s.ListEndpoints()
-	if err != nil {
-		return ""
+	var warnings []string
+	if s.customProviderStore != nil {
+		if _, err := s.customProviderStore.MigrateSecrets(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("move custom provider keys to the secrets vault: %v", err))
+		}
 	}
-	for _, ep := range eps {
-		if ep.Name == name {
-			return ep.ID
+	if s.mcpStr != nil {
+		if _, err := s.mcpStr.MigrateSecrets(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("move MCP env values to the secrets vault: %v", err))
 		}
 	}
-	return ""
+	return warnings
 }
 
 // ensureBillingClient lazily initializes the billing client.